@@ -0,0 +1,71 @@
+// Command balances rebuilds the user balances from the transactions or checks them against the transactions.
+//
+// Usage:
+//
+//	balances rebuild
+//	balances check
+//
+// check exits with status 1 when a balance drifted from the sum of its transactions.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"user-transactions/core/services"
+	"user-transactions/infrastructure/database"
+	"user-transactions/infrastructure/repositories"
+
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	godotenv.Load()
+
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s rebuild | check\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	db := database.PostgresDB{Dsn: os.Getenv("DSN")}
+	dbConn, err := db.Connect()
+	if err != nil {
+		log.Fatalf("error connecting to database: %s", err)
+	}
+
+	balanceSvc := services.NewBalanceService(repositories.NewBalanceRepository(dbConn))
+	ctx := context.Background()
+
+	command := flag.Arg(0)
+	var result interface{}
+	consistent := true
+	switch command {
+	case "rebuild":
+		result, err = balanceSvc.RebuildBalances(ctx)
+	case "check":
+		res, checkErr := balanceSvc.CheckBalances(ctx)
+		if checkErr == nil {
+			consistent = res.Consistent
+		}
+		result, err = res, checkErr
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatalf("error running %s: %s", command, err)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(result); err != nil {
+		log.Fatalf("error writing the result: %s", err)
+	}
+	if !consistent {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,94 @@
+// Command deadletter inspects, replays or discards the bulks of transactions that could not be committed.
+//
+// Usage:
+//
+//	deadletter [-sink table|file] [-file path] list
+//	deadletter [-sink table|file] [-file path] show|replay|discard <batch id>
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"user-transactions/core/repositories"
+	"user-transactions/core/services"
+	"user-transactions/infrastructure/database"
+
+	infraRepositories "user-transactions/infrastructure/repositories"
+
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	godotenv.Load()
+
+	sinkDefault := os.Getenv("DEAD_LETTER_SINK")
+	if sinkDefault == "" {
+		sinkDefault = "table"
+	}
+	sink := flag.String("sink", sinkDefault, "where the dead-lettered batches are kept: table or file")
+	file := flag.String("file", os.Getenv("DEAD_LETTER_FILE"), "NDJSON file of the file sink")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] list | show <id> | replay <id> | discard <id>\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	command := flag.Arg(0)
+	id := flag.Arg(1)
+	if command == "" || (command != "list" && id == "") {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	// the database is needed by the replay even when the batches are kept in a file
+	db := database.PostgresDB{Dsn: os.Getenv("DSN")}
+	dbConn, err := db.Connect()
+	if err != nil {
+		log.Fatalf("error connecting to database: %s", err)
+	}
+
+	var deadLetterRepo repositories.DeadLetterRepository
+	switch *sink {
+	case "table":
+		deadLetterRepo = infraRepositories.NewDeadLetterRepository(dbConn)
+	case "file":
+		if *file == "" {
+			log.Fatalf("the file sink requires -file")
+		}
+		deadLetterRepo = infraRepositories.NewDeadLetterFile(*file)
+	default:
+		log.Fatalf("unknown sink %s", *sink)
+	}
+
+	deadLetterSvc, _ := services.NewDeadLetterService(deadLetterRepo, infraRepositories.NewTransactionRepository(dbConn))
+	ctx := context.Background()
+
+	var result interface{}
+	switch command {
+	case "list":
+		result, err = deadLetterSvc.ListBatches(ctx)
+	case "show":
+		result, err = deadLetterSvc.GetBatch(ctx, id)
+	case "replay":
+		result, err = deadLetterSvc.ReplayBatch(ctx, id)
+	case "discard":
+		err = deadLetterSvc.DiscardBatch(ctx, id)
+		result = map[string]string{"discarded": id}
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatalf("error running %s: %s", command, err)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(result); err != nil {
+		log.Fatalf("error writing the result: %s", err)
+	}
+}
@@ -8,20 +8,34 @@ import (
 	"os"
 	"os/signal"
 	"strconv"
+	"strings"
 	"syscall"
 	"time"
 	"user-transactions/application/handler"
 	"user-transactions/application/router"
+	"user-transactions/core/entities"
+	coreRepositories "user-transactions/core/repositories"
 	"user-transactions/core/services"
 	"user-transactions/infrastructure/database"
 	"user-transactions/infrastructure/repositories"
+	"user-transactions/infrastructure/spool"
 
 	"github.com/joho/godotenv"
 )
 
 var (
-	db   database.PostgresDB
-	port string
+	db                database.PostgresDB
+	port              string
+	spoolConfig       spool.Config
+	deadLetterSink    string
+	deadLetterFile    string
+	deadLetterBudget  time.Duration
+	bulkWorkers       int
+	bulkQueueCapacity int
+	bulkQueueWait     = time.Duration(-1) // the default wait is kept when not configured
+	overdraftPolicy   *entities.OverdraftPolicy
+	holdExpiry        = time.Minute // how often the expired holds are voided
+	adminToken        string
 )
 
 func init() {
@@ -40,10 +54,120 @@ func init() {
 	db.Debug = debug
 	db.AutoMigrateDb = autoMigrateDb
 	db.Dsn = os.Getenv("DSN")
+	if currency := os.Getenv("DEFAULT_CURRENCY"); currency != "" {
+		db.DefaultCurrency = entities.Currency(currency)
+		if !db.DefaultCurrency.Valid() {
+			log.Fatalf("error loading DEFAULT_CURRENCY env var: %s", currency)
+		}
+	}
 	port = os.Getenv("PORT")
 	if port == "" {
 		port = "3000"
 	}
+
+	if workers := os.Getenv("BULK_WORKERS"); workers != "" {
+		if bulkWorkers, err = strconv.Atoi(workers); err != nil {
+			log.Fatalf("error loading BULK_WORKERS env var: %s", workers)
+		}
+	}
+
+	if capacity := os.Getenv("BULK_QUEUE_CAPACITY"); capacity != "" {
+		if bulkQueueCapacity, err = strconv.Atoi(capacity); err != nil {
+			log.Fatalf("error loading BULK_QUEUE_CAPACITY env var: %s", capacity)
+		}
+	}
+	if wait := os.Getenv("BULK_QUEUE_WAIT_MS"); wait != "" {
+		ms, err := strconv.Atoi(wait)
+		if err != nil {
+			log.Fatalf("error loading BULK_QUEUE_WAIT_MS env var: %s", wait)
+		}
+		bulkQueueWait = time.Duration(ms) * time.Millisecond
+	}
+
+	// the spool is disabled without a directory
+	spoolConfig.Dir = os.Getenv("SPOOL_DIR")
+	spoolConfig.Fsync = spool.FsyncPolicy(os.Getenv("SPOOL_FSYNC"))
+	switch spoolConfig.Fsync {
+	case "", spool.FSYNC_ALWAYS, spool.FSYNC_INTERVAL, spool.FSYNC_NEVER:
+	default:
+		log.Fatalf("error loading SPOOL_FSYNC env var: %s", os.Getenv("SPOOL_FSYNC"))
+	}
+	if segmentBytes := os.Getenv("SPOOL_SEGMENT_BYTES"); segmentBytes != "" {
+		if spoolConfig.SegmentSize, err = strconv.ParseInt(segmentBytes, 10, 64); err != nil {
+			log.Fatalf("error loading SPOOL_SEGMENT_BYTES env var: %s", segmentBytes)
+		}
+	}
+	if fsyncInterval := os.Getenv("SPOOL_FSYNC_INTERVAL_MS"); fsyncInterval != "" {
+		ms, err := strconv.Atoi(fsyncInterval)
+		if err != nil {
+			log.Fatalf("error loading SPOOL_FSYNC_INTERVAL_MS env var: %s", fsyncInterval)
+		}
+		spoolConfig.FsyncInterval = time.Duration(ms) * time.Millisecond
+	}
+
+	// the debits are only checked against the balance when a limit is configured
+	var overdraftLimit *int64
+	if limit := os.Getenv("OVERDRAFT_LIMIT"); limit != "" {
+		parsed, err := strconv.ParseInt(limit, 10, 64)
+		if err != nil || parsed < 0 {
+			log.Fatalf("error loading OVERDRAFT_LIMIT env var: %s", limit)
+		}
+		overdraftLimit = &parsed
+	}
+	limitsByUser := loadOverdraftLimits("OVERDRAFT_LIMITS_BY_USER")
+	limitsByOrigin := loadOverdraftLimits("OVERDRAFT_LIMITS_BY_ORIGIN")
+	if overdraftLimit != nil || len(limitsByUser) > 0 || len(limitsByOrigin) > 0 {
+		overdraftPolicy = entities.NewOverdraftPolicy(overdraftLimit, limitsByUser, limitsByOrigin)
+	}
+
+	if interval := os.Getenv("HOLD_EXPIRY_INTERVAL_SECONDS"); interval != "" {
+		seconds, err := strconv.Atoi(interval)
+		if err != nil || seconds <= 0 {
+			log.Fatalf("error loading HOLD_EXPIRY_INTERVAL_SECONDS env var: %s", interval)
+		}
+		holdExpiry = time.Duration(seconds) * time.Second
+	}
+
+	deadLetterSink = os.Getenv("DEAD_LETTER_SINK")
+	switch deadLetterSink {
+	case "":
+		deadLetterSink = "table"
+	case "table", "file":
+	default:
+		log.Fatalf("error loading DEAD_LETTER_SINK env var: %s", deadLetterSink)
+	}
+	deadLetterFile = os.Getenv("DEAD_LETTER_FILE")
+	if deadLetterSink == "file" && deadLetterFile == "" {
+		log.Fatalf("error loading DEAD_LETTER_FILE env var: required by the file sink")
+	}
+	if retryMinutes := os.Getenv("DEAD_LETTER_RETRY_MINUTES"); retryMinutes != "" {
+		minutes, err := strconv.Atoi(retryMinutes)
+		if err != nil {
+			log.Fatalf("error loading DEAD_LETTER_RETRY_MINUTES env var: %s", retryMinutes)
+		}
+		deadLetterBudget = time.Duration(minutes) * time.Minute
+	}
+
+	adminToken = os.Getenv("ADMIN_TOKEN")
+}
+
+// loadOverdraftLimits reads a list of limits like "user123=5000,user456=0" from the env var.
+func loadOverdraftLimits(name string) map[string]int64 {
+	value := os.Getenv(name)
+	if value == "" {
+		return nil
+	}
+
+	limits := make(map[string]int64)
+	for _, entry := range strings.Split(value, ",") {
+		key, limit, found := strings.Cut(strings.TrimSpace(entry), "=")
+		parsed, err := strconv.ParseInt(limit, 10, 64)
+		if !found || key == "" || err != nil || parsed < 0 {
+			log.Fatalf("error loading %s env var: %s", name, value)
+		}
+		limits[key] = parsed
+	}
+	return limits
 }
 
 func main() {
@@ -52,12 +176,50 @@ func main() {
 		log.Fatalf("error connecting to database: %s", err)
 	}
 
+	var deadLetterRepo coreRepositories.DeadLetterRepository
+	if deadLetterSink == "file" {
+		deadLetterRepo = repositories.NewDeadLetterFile(deadLetterFile)
+	} else {
+		deadLetterRepo = repositories.NewDeadLetterRepository(dbConn)
+	}
+
 	transactionRepo := repositories.NewTransactionRepository(dbConn)
+	idempotencyRepo := repositories.NewIdempotencyRepository(dbConn)
 	transactionSvc, _ := services.NewTransactionService(transactionRepo)
-	handler := handler.NewTransactionHandler(transactionSvc)
-	go transactionRepo.WithBulkConfig(100, 1).RunGroupTransactions()
+	transactionSvc.WithIdempotencyRepository(idempotencyRepo).WithOverdraftPolicy(overdraftPolicy)
+	transferSvc, _ := services.NewTransferService(repositories.NewTransferRepository(dbConn).WithTransactions(transactionRepo))
+	transferSvc.WithOverdraftPolicy(overdraftPolicy)
+	statementSvc, _ := services.NewStatementService(transactionRepo)
+	deadLetterSvc, _ := services.NewDeadLetterService(deadLetterRepo, transactionRepo)
+	transactionHandler := handler.NewTransactionHandler(transactionSvc)
+	transferHandler := handler.NewTransferHandler(transferSvc)
+	statementHandler := handler.NewStatementHandler(statementSvc)
+	adminHandler := handler.NewAdminHandler(deadLetterSvc, services.NewMonitorService(transactionRepo)).WithToken(adminToken)
+	transactionRepo.WithBulkConfig(100, 1).
+		WithBulkWorkers(bulkWorkers).
+		WithBulkQueue(bulkQueueCapacity, bulkQueueWait).
+		WithDeadLetter(deadLetterRepo, deadLetterBudget)
 
-	routes := router.SetupRouter(handler)
+	var transactionSpool *spool.Spool
+	if spoolConfig.Dir != "" {
+		spooled, entries, err := spool.Open(spoolConfig)
+		if err != nil {
+			log.Fatalf("error opening the spool: %s", err)
+		}
+		transactionSpool = spooled
+		transactionRepo.WithSpool(transactionSpool)
+
+		// the transactions accepted before the last stop are committed before accepting new ones
+		if err := transactionRepo.ReplaySpool(entries); err != nil {
+			log.Fatalf("error replaying the spool: %s", err)
+		}
+	}
+	go transactionRepo.RunGroupTransactions()
+
+	holdExpiryCtx, stopHoldExpiry := context.WithCancel(context.Background())
+	go transactionSvc.RunHoldExpiry(holdExpiryCtx, holdExpiry)
+
+	routes := router.SetupRouter(transactionHandler, transferHandler, adminHandler, statementHandler)
 	srv := &http.Server{
 		Addr:    ":" + port,
 		Handler: routes,
@@ -74,6 +236,12 @@ func main() {
 	}()
 
 	gracefulShutdown(quit, srv, transactionRepo)
+	stopHoldExpiry()
+	if transactionSpool != nil {
+		if err := transactionSpool.Close(); err != nil {
+			log.Printf("error closing the spool: %s", err)
+		}
+	}
 	log.Println("Server exited")
 }
 
@@ -0,0 +1,15 @@
+package dto
+
+import (
+	"encoding/xml"
+	"time"
+)
+
+type DeadLetterBatchRes struct {
+	XMLName      xml.Name          `json:"-" xml:"dead_letter_batch"`
+	ID           string            `json:"id" xml:"id"`
+	Size         int               `json:"size" xml:"size"`
+	LastError    string            `json:"last_error" xml:"last_error"`
+	Transactions []*TransactionRes `json:"transactions,omitempty" xml:"transactions>transaction,omitempty"` // only when a single batch is returned
+	CreatedAt    time.Time         `json:"created_at" xml:"created_at"`
+}
@@ -0,0 +1,45 @@
+package dto
+
+import (
+	"encoding/xml"
+	"slices"
+)
+
+// Metadata are the key/values of a transaction, in XML each one is an <entry key="...">value</entry> since maps can't be encoded.
+type Metadata map[string]string
+
+type metadataEntry struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+func (m Metadata) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	entries := struct {
+		Entries []metadataEntry `xml:"entry"`
+	}{}
+	for _, key := range keys {
+		entries.Entries = append(entries.Entries, metadataEntry{Key: key, Value: m[key]})
+	}
+	return e.EncodeElement(entries, start)
+}
+
+func (m *Metadata) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	entries := struct {
+		Entries []metadataEntry `xml:"entry"`
+	}{}
+	if err := d.DecodeElement(&entries, &start); err != nil {
+		return err
+	}
+
+	*m = make(Metadata, len(entries.Entries))
+	for _, entry := range entries.Entries {
+		(*m)[entry.Key] = entry.Value
+	}
+	return nil
+}
@@ -0,0 +1,10 @@
+package dto
+
+import "encoding/xml"
+
+type QueueStatsRes struct {
+	XMLName  xml.Name `json:"-" xml:"queue"`
+	Depth    int      `json:"depth" xml:"depth"`
+	Capacity int      `json:"capacity" xml:"capacity"`
+	Pending  int      `json:"pending" xml:"pending"` // accepted and not committed yet
+}
@@ -0,0 +1,35 @@
+package dto
+
+import (
+	"encoding/xml"
+	"time"
+)
+
+// StatementRes is the account statement of a user in a currency for a month.
+type StatementRes struct {
+	XMLName  xml.Name            `json:"-" xml:"statement"`
+	UserID   string              `json:"user_id" xml:"user_id"`
+	Period   string              `json:"period" xml:"period"` // YYYY-MM
+	Currency string              `json:"currency" xml:"currency"`
+	Exponent int                 `json:"exponent" xml:"exponent"`
+	From     time.Time           `json:"from" xml:"from"` // inclusive
+	To       time.Time           `json:"to" xml:"to"`     // exclusive
+	Opening  int64               `json:"opening_balance" xml:"opening_balance"`
+	Credits  int64               `json:"credits" xml:"credits"`
+	Debits   int64               `json:"debits" xml:"debits"`
+	Closing  int64               `json:"closing_balance" xml:"closing_balance"`
+	Lines    []*StatementLineRes `json:"lines" xml:"lines>line"`
+}
+
+// StatementLineRes is a transaction of a statement with the balance after it.
+type StatementLineRes struct {
+	XMLName           xml.Name  `json:"-" xml:"line"`
+	ID                string    `json:"id" xml:"id"`
+	Origin            string    `json:"origin" xml:"origin"`
+	Type              string    `json:"type" xml:"type"`
+	Amount            int64     `json:"amount" xml:"amount"`
+	Balance           int64     `json:"balance" xml:"balance"`
+	ReversalOf        string    `json:"reversal_of,omitempty" xml:"reversal_of,omitempty"`
+	ExternalReference string    `json:"external_reference,omitempty" xml:"external_reference,omitempty"`
+	CreatedAt         time.Time `json:"created_at" xml:"created_at"`
+}
@@ -6,19 +6,101 @@ import (
 )
 
 type CreateTransactionReq struct {
-	XMLName xml.Name `json:"-" xml:"transaction"`
-	Origin  string   `json:"origin" xml:"origin"`
-	UserID  string   `json:"user_id" xml:"user_id"`
-	Amount  int64    `json:"amount" xml:"amount"`
-	Type    string   `json:"type" xml:"type"`
+	XMLName           xml.Name   `json:"-" xml:"transaction"`
+	Origin            string     `json:"origin" xml:"origin"`
+	UserID            string     `json:"user_id" xml:"user_id"`
+	Amount            int64      `json:"amount" xml:"amount"`
+	Type              string     `json:"type" xml:"type"`
+	Currency          string     `json:"currency,omitempty" xml:"currency,omitempty"`                     // ISO 4217 code, DEFAULT_CURRENCY when empty
+	Exponent          *int       `json:"exponent,omitempty" xml:"exponent,omitempty"`                     // decimal places of the amount, checked against the currency when sent
+	Consistency       string     `json:"consistency,omitempty" xml:"consistency,omitempty"`               // async (default) or commit-sync, also set by the Prefer header
+	Hold              bool       `json:"hold,omitempty" xml:"hold,omitempty"`                             // reserves the amount of a debit until captured or voided
+	ExpiresAt         *time.Time `json:"expires_at,omitempty" xml:"expires_at,omitempty"`                 // when the hold is voided, HOLD_TTL_MINUTES from now when empty
+	ExternalReference string     `json:"external_reference,omitempty" xml:"external_reference,omitempty"` // unique per origin, e.g. the order ID
+	Metadata          Metadata   `json:"metadata,omitempty" xml:"metadata,omitempty"`
+	IdempotencyKey    string     `json:"-" xml:"-"` // from the Idempotency-Key header
+}
+
+// CreateTransactionBatchReq is the XML body of a batch, the JSON and NDJSON bodies are read as a list of CreateTransactionReq.
+type CreateTransactionBatchReq struct {
+	XMLName      xml.Name                `json:"-" xml:"transactions"`
+	Transactions []*CreateTransactionReq `json:"transactions" xml:"transaction"`
+}
+
+type CreateReversalReq struct {
+	XMLName xml.Name `json:"-" xml:"reversal"`
+	Amount  int64    `json:"amount" xml:"amount"` // absolute amount to reverse, all that is left when empty
 }
 
 type TransactionRes struct {
-	XMLName   xml.Name  `json:"-" xml:"transaction"`
-	ID        string    `json:"id" xml:"id"`
-	Origin    string    `json:"origin" xml:"origin"`
+	XMLName           xml.Name   `json:"-" xml:"transaction"`
+	ID                string     `json:"id" xml:"id"`
+	Origin            string     `json:"origin" xml:"origin"`
+	UserID            string     `json:"user_id" xml:"user_id"`
+	Amount            int64      `json:"amount" xml:"amount"`
+	Type              string     `json:"type" xml:"type"`
+	Currency          string     `json:"currency" xml:"currency"`
+	Exponent          int        `json:"exponent" xml:"exponent"`
+	ReversalOf        string     `json:"reversal_of,omitempty" xml:"reversal_of,omitempty"`
+	TransferID        string     `json:"transfer_id,omitempty" xml:"transfer_id,omitempty"`
+	ReversalStatus    string     `json:"reversal_status,omitempty" xml:"reversal_status,omitempty"`
+	ReversedAmount    int64      `json:"reversed_amount,omitempty" xml:"reversed_amount,omitempty"`
+	Reversals         []string   `json:"reversals,omitempty" xml:"reversals>id,omitempty"`
+	Status            string     `json:"status" xml:"status"` // pending while held, then posted or voided
+	ExpiresAt         *time.Time `json:"expires_at,omitempty" xml:"expires_at,omitempty"`
+	ExternalReference string     `json:"external_reference,omitempty" xml:"external_reference,omitempty"`
+	Metadata          Metadata   `json:"metadata,omitempty" xml:"metadata,omitempty"`
+	CommitStatus      string     `json:"commit_status" xml:"commit_status"` // pending while in the bulk buffer, then committed
+	CreatedAt         time.Time  `json:"created_at" xml:"created_at"`
+}
+
+type TransactionBatchItemRes struct {
+	XMLName     xml.Name        `json:"-" xml:"item"`
+	Index       int             `json:"index" xml:"index"` // position of the transaction in the request
+	Status      string          `json:"status" xml:"status"`
+	Transaction *TransactionRes `json:"transaction,omitempty" xml:"transaction,omitempty"`
+	Errors      []string        `json:"errors,omitempty" xml:"errors>error,omitempty"`
+}
+
+type TransactionBatchRes struct {
+	XMLName xml.Name                   `json:"-" xml:"batch"`
+	Atomic  bool                       `json:"atomic" xml:"atomic"`
+	Created int                        `json:"created" xml:"created"`
+	Failed  int                        `json:"failed" xml:"failed"`
+	Items   []*TransactionBatchItemRes `json:"items" xml:"items>item"`
+}
+
+type TransactionPageRes struct {
+	Transactions []*TransactionRes
+	HasNext      bool
+	NextCursor   string
+	PrevCursor   string
+	Total        *int64 // only counted when requested
+}
+
+type BalanceRes struct {
+	XMLName   xml.Name  `json:"-" xml:"balance"`
 	UserID    string    `json:"user_id" xml:"user_id"`
-	Amount    int64     `json:"amount" xml:"amount"`
-	Type      string    `json:"type" xml:"type"`
-	CreatedAt time.Time `json:"created_at" xml:"created_at"`
+	Origin    string    `json:"origin,omitempty" xml:"origin,omitempty"`
+	Currency  string    `json:"currency" xml:"currency"`
+	Exponent  int       `json:"exponent" xml:"exponent"`
+	Balance   int64     `json:"balance" xml:"balance"`     // ledger balance, of the posted transactions
+	Held      int64     `json:"held" xml:"held"`           // reserved by the pending holds
+	Available int64     `json:"available" xml:"available"` // ledger balance minus the amount held
+	AsOf      time.Time `json:"as_of" xml:"as_of"`
+}
+
+// TransactionSummaryRes totals a group of transactions, the fields not grouped by are left out.
+type TransactionSummaryRes struct {
+	XMLName  xml.Name   `json:"-" xml:"summary"`
+	Period   *time.Time `json:"period,omitempty" xml:"period,omitempty"` // start of the interval
+	Origin   string     `json:"origin,omitempty" xml:"origin,omitempty"`
+	UserID   string     `json:"user_id,omitempty" xml:"user_id,omitempty"`
+	Type     string     `json:"type,omitempty" xml:"type,omitempty"`
+	Currency string     `json:"currency" xml:"currency"`
+	Exponent int        `json:"exponent" xml:"exponent"`
+	Count    int64      `json:"count" xml:"count"`
+	Total    int64      `json:"total" xml:"total"`
+	Credits  int64      `json:"credits" xml:"credits"`
+	Debits   int64      `json:"debits" xml:"debits"`
 }
@@ -0,0 +1,30 @@
+package dto
+
+import (
+	"encoding/xml"
+	"time"
+)
+
+type CreateTransferReq struct {
+	XMLName    xml.Name `json:"-" xml:"transfer"`
+	Origin     string   `json:"origin" xml:"origin"`
+	FromUserID string   `json:"from_user_id" xml:"from_user_id"`
+	ToUserID   string   `json:"to_user_id" xml:"to_user_id"`
+	Amount     int64    `json:"amount" xml:"amount"` // positive, debited from the sender and credited to the receiver
+	Currency   string   `json:"currency,omitempty" xml:"currency,omitempty"`
+	Exponent   *int     `json:"exponent,omitempty" xml:"exponent,omitempty"`
+}
+
+type TransferRes struct {
+	XMLName    xml.Name        `json:"-" xml:"transfer"`
+	ID         string          `json:"id" xml:"id"`
+	Origin     string          `json:"origin" xml:"origin"`
+	FromUserID string          `json:"from_user_id" xml:"from_user_id"`
+	ToUserID   string          `json:"to_user_id" xml:"to_user_id"`
+	Amount     int64           `json:"amount" xml:"amount"`
+	Currency   string          `json:"currency" xml:"currency"`
+	Exponent   int             `json:"exponent" xml:"exponent"`
+	Debit      *TransactionRes `json:"debit" xml:"debit>transaction"`
+	Credit     *TransactionRes `json:"credit" xml:"credit>transaction"`
+	CreatedAt  time.Time       `json:"created_at" xml:"created_at"`
+}
@@ -0,0 +1,23 @@
+package dto
+
+import "encoding/xml"
+
+type BalanceRebuildRes struct {
+	XMLName  xml.Name `json:"-" xml:"rebuild"`
+	Balances int64    `json:"balances" xml:"balances"` // user balances summed from the transactions
+}
+
+type BalanceDriftRes struct {
+	XMLName    xml.Name `json:"-" xml:"drift"`
+	UserID     string   `json:"user_id" xml:"user_id"`
+	Currency   string   `json:"currency" xml:"currency"`
+	Balance    int64    `json:"balance" xml:"balance"` // stored in the user balances
+	Ledger     int64    `json:"ledger" xml:"ledger"`   // sum of the transactions
+	Difference int64    `json:"difference" xml:"difference"`
+}
+
+type BalanceCheckRes struct {
+	XMLName    xml.Name           `json:"-" xml:"check"`
+	Consistent bool               `json:"consistent" xml:"consistent"`
+	Drifts     []*BalanceDriftRes `json:"drifts" xml:"drifts>drift"`
+}
@@ -0,0 +1,106 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+	"user-transactions/application/presenters"
+	"user-transactions/core/entities"
+	"user-transactions/core/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	errAdminDisabled = entities.NewError(entities.KIND_NOT_FOUND, "not_found", "the resource was not found")
+	errUnauthorized  = entities.NewError(entities.KIND_UNAUTHORIZED, "unauthorized", "the request needs a valid admin token")
+)
+
+type AdminHandler struct {
+	DeadLetterService *services.DeadLetterService
+	MonitorService    *services.MonitorService
+	Token             string // the bearer token of the admin requests, without one the admin routes are disabled
+}
+
+func NewAdminHandler(deadLetterService *services.DeadLetterService, monitorService *services.MonitorService) *AdminHandler {
+	return &AdminHandler{DeadLetterService: deadLetterService, MonitorService: monitorService}
+}
+
+func (ah *AdminHandler) WithToken(token string) *AdminHandler {
+	ah.Token = token
+	return ah
+}
+
+// Authorize lets through the requests with the admin token in the Authorization header, e.g. Bearer <token>.
+// The admin routes answer 404 when no token is configured, so they can't be reached by default.
+func (ah *AdminHandler) Authorize(c *gin.Context) {
+	if ah.Token == "" {
+		problem(c, errAdminDisabled)
+		c.Abort()
+		return
+	}
+
+	scheme, token, _ := strings.Cut(c.GetHeader("Authorization"), " ")
+	if !strings.EqualFold(scheme, "Bearer") || subtle.ConstantTimeCompare([]byte(token), []byte(ah.Token)) != 1 {
+		c.Header("WWW-Authenticate", `Bearer realm="admin"`)
+		problem(c, errUnauthorized)
+		c.Abort()
+		return
+	}
+	c.Next()
+}
+
+func (ah *AdminHandler) Queue(c *gin.Context) {
+	c.Negotiate(http.StatusOK, gin.Negotiate{
+		Offered: []string{"application/json", "application/xml"},
+		Data:    presenters.TransformDataToApiFormat(ah.MonitorService.QueueStats()),
+	})
+}
+
+func (ah *AdminHandler) ListDeadLetters(c *gin.Context) {
+	batches, err := ah.DeadLetterService.ListBatches(c)
+	if err != nil {
+		problem(c, err)
+		return
+	}
+
+	c.Negotiate(http.StatusOK, gin.Negotiate{
+		Offered: []string{"application/json", "application/xml"},
+		Data:    presenters.TransformDataToApiFormat(batches),
+	})
+}
+
+func (ah *AdminHandler) GetDeadLetter(c *gin.Context) {
+	batch, err := ah.DeadLetterService.GetBatch(c, c.Param("id"))
+	if err != nil {
+		problem(c, err)
+		return
+	}
+
+	c.Negotiate(http.StatusOK, gin.Negotiate{
+		Offered: []string{"application/json", "application/xml"},
+		Data:    presenters.TransformDataToApiFormat(batch),
+	})
+}
+
+func (ah *AdminHandler) ReplayDeadLetter(c *gin.Context) {
+	batch, err := ah.DeadLetterService.ReplayBatch(c, c.Param("id"))
+	if err != nil {
+		problem(c, err)
+		return
+	}
+
+	c.Negotiate(http.StatusOK, gin.Negotiate{
+		Offered: []string{"application/json", "application/xml"},
+		Data:    presenters.TransformDataToApiFormat(batch),
+	})
+}
+
+func (ah *AdminHandler) DiscardDeadLetter(c *gin.Context) {
+	if err := ah.DeadLetterService.DiscardBatch(c, c.Param("id")); err != nil {
+		problem(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,199 @@
+//go:build integration
+// +build integration
+
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"user-transactions/application/dto"
+	"user-transactions/application/handler"
+	"user-transactions/core/entities"
+	"user-transactions/core/services"
+	"user-transactions/infrastructure/repositories"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func Test_AdminHandler_DeadLetters(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	assert.NoError(t, db.AutoMigrate(&entities.Transaction{}, &entities.DeadLetterBatch{}, &entities.UserBalance{}))
+	t.Cleanup(func() {
+		sqlDB, _ := db.DB()
+		sqlDB.Close()
+	})
+
+	deadLetterRepo := repositories.NewDeadLetterRepository(db)
+	s, _ := services.NewDeadLetterService(deadLetterRepo, repositories.NewTransactionRepository(db))
+	h := handler.NewAdminHandler(s, services.NewMonitorService(repositories.NewTransactionRepository(db))).WithToken("secret")
+
+	// Create a new Gin router
+	router := gin.Default()
+	admin := router.Group("/admin", h.Authorize)
+	admin.GET("/dead-letters", h.ListDeadLetters)
+	admin.GET("/dead-letters/:id", h.GetDeadLetter)
+	admin.POST("/dead-letters/:id/replay", h.ReplayDeadLetter)
+	admin.DELETE("/dead-letters/:id", h.DiscardDeadLetter)
+	admin.GET("/queue", h.Queue)
+
+	// Create two dead-lettered batches
+	transaction, errs := entities.NewTransaction("desktop-web", "user123", 200, entities.CREDIT)
+	assert.Empty(t, errs)
+	replayed, err := entities.NewDeadLetterBatch([]*entities.Transaction{transaction}, errors.New("connection refused"))
+	assert.NoError(t, err)
+	assert.NoError(t, deadLetterRepo.Insert(context.Background(), replayed))
+
+	other, errs := entities.NewTransaction("desktop-web", "user123", 300, entities.CREDIT)
+	assert.Empty(t, errs)
+	discarded, err := entities.NewDeadLetterBatch([]*entities.Transaction{other}, errors.New("connection refused"))
+	assert.NoError(t, err)
+	assert.NoError(t, deadLetterRepo.Insert(context.Background(), discarded))
+
+	serve := func(method, url string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, url, nil)
+		assert.NoError(t, err)
+		req.Header.Set("Accept", "application/json")
+		req.Header.Set("Authorization", "Bearer secret")
+
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, req)
+		return res
+	}
+
+	t.Run("listing the dead-lettered batches", func(t *testing.T) {
+		res := serve("GET", "/admin/dead-letters")
+
+		// Assert the response status code
+		assert.Equal(t, http.StatusOK, res.Code)
+
+		// Assert the response body
+		var result struct {
+			Data []dto.DeadLetterBatchRes `json:"data"`
+		}
+		assert.NoError(t, json.NewDecoder(res.Body).Decode(&result))
+		assert.Len(t, result.Data, 2)
+	})
+
+	t.Run("getting a dead-lettered batch with its transactions", func(t *testing.T) {
+		res := serve("GET", "/admin/dead-letters/"+replayed.ID.String())
+
+		// Assert the response status code
+		assert.Equal(t, http.StatusOK, res.Code)
+
+		// Assert the response body
+		var result struct {
+			Data dto.DeadLetterBatchRes `json:"data"`
+		}
+		assert.NoError(t, json.NewDecoder(res.Body).Decode(&result))
+		assert.Equal(t, "connection refused", result.Data.LastError)
+		assert.Len(t, result.Data.Transactions, 1)
+		assert.Equal(t, transaction.ID.String(), result.Data.Transactions[0].ID)
+	})
+
+	t.Run("replaying a dead-lettered batch", func(t *testing.T) {
+		res := serve("POST", "/admin/dead-letters/"+replayed.ID.String()+"/replay")
+
+		// Assert the response status code
+		assert.Equal(t, http.StatusOK, res.Code)
+
+		// the transaction is committed and the batch removed
+		var found entities.Transaction
+		assert.NoError(t, db.Where("id = ?", transaction.ID.String()).First(&found).Error)
+		_, err := deadLetterRepo.Find(context.Background(), replayed.ID.String())
+		assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	})
+
+	t.Run("discarding a dead-lettered batch", func(t *testing.T) {
+		res := serve("DELETE", "/admin/dead-letters/"+discarded.ID.String())
+
+		// Assert the response status code
+		assert.Equal(t, http.StatusNoContent, res.Code)
+
+		// the transaction is not committed
+		var count int64
+		assert.NoError(t, db.Model(&entities.Transaction{}).Where("id = ?", other.ID.String()).Count(&count).Error)
+		assert.Equal(t, int64(0), count)
+
+		res = serve("GET", "/admin/dead-letters")
+		var result struct {
+			Data []dto.DeadLetterBatchRes `json:"data"`
+		}
+		assert.NoError(t, json.NewDecoder(res.Body).Decode(&result))
+		assert.Empty(t, result.Data)
+	})
+
+	t.Run("getting the state of the bulk queue", func(t *testing.T) {
+		res := serve("GET", "/admin/queue")
+
+		// Assert the response status code
+		assert.Equal(t, http.StatusOK, res.Code)
+
+		// Assert the response body, the repository is not in bulk mode
+		var result struct {
+			Data dto.QueueStatsRes `json:"data"`
+		}
+		assert.NoError(t, json.NewDecoder(res.Body).Decode(&result))
+		assert.Equal(t, 0, result.Data.Depth)
+		assert.Equal(t, 0, result.Data.Capacity)
+	})
+}
+
+func Test_AdminHandler_Authorize(t *testing.T) {
+	serve := func(token, authorization string) *httptest.ResponseRecorder {
+		h := handler.NewAdminHandler(nil, nil).WithToken(token)
+
+		// Create a new Gin router, the route is only reached once authorized
+		router := gin.Default()
+		router.GET("/admin/queue", h.Authorize, func(c *gin.Context) { c.Status(http.StatusOK) })
+
+		// Create a new HTTP request
+		req, err := http.NewRequest("GET", "/admin/queue", nil)
+		assert.NoError(t, err)
+		req.Header.Set("Accept", "application/json")
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+
+		// Create a new HTTP response recorder
+		res := httptest.NewRecorder()
+
+		// Serve the HTTP request
+		router.ServeHTTP(res, req)
+		return res
+	}
+
+	t.Run("authorizing the admin token", func(t *testing.T) {
+		res := serve("secret", "Bearer secret")
+
+		// Assert the response status code
+		assert.Equal(t, http.StatusOK, res.Code)
+	})
+
+	t.Run("rejecting the requests without the admin token", func(t *testing.T) {
+		for _, authorization := range []string{"", "Bearer wrong", "Basic secret", "secret"} {
+			res := serve("secret", authorization)
+
+			// Assert the response status code and body
+			assert.Equal(t, http.StatusUnauthorized, res.Code, authorization)
+			assert.Equal(t, `Bearer realm="admin"`, res.Header().Get("WWW-Authenticate"))
+			assert.Contains(t, res.Body.String(), `"code":"unauthorized"`)
+		}
+	})
+
+	t.Run("disabling the admin routes without a token", func(t *testing.T) {
+		for _, authorization := range []string{"", "Bearer "} {
+			res := serve("", authorization)
+
+			// Assert the response status code
+			assert.Equal(t, http.StatusNotFound, res.Code, authorization)
+		}
+	})
+}
@@ -0,0 +1,26 @@
+package handler
+
+import (
+	"net/http"
+	"user-transactions/application/openapi"
+
+	"github.com/gin-gonic/gin"
+)
+
+type DocsHandler struct {
+	Document *openapi.Document
+}
+
+func NewDocsHandler(document *openapi.Document) *DocsHandler {
+	return &DocsHandler{Document: document}
+}
+
+// Spec returns the OpenAPI 3 document of the API.
+func (dh *DocsHandler) Spec(c *gin.Context) {
+	c.JSON(http.StatusOK, dh.Document)
+}
+
+// Page returns the page that renders the document.
+func (dh *DocsHandler) Page(c *gin.Context) {
+	c.Data(http.StatusOK, "text/html; charset=utf-8", openapi.DocsPage)
+}
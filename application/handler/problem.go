@@ -0,0 +1,47 @@
+package handler
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"user-transactions/application/presenters"
+	"user-transactions/core/entities"
+	"user-transactions/core/repositories"
+
+	"github.com/gin-gonic/gin"
+)
+
+// retryAfterSeconds is sent with the 503 responses, it's about the time the bulk writer takes to commit a bulk.
+const retryAfterSeconds = "1"
+
+// problem responds with the problem details of the errors, the failures of the service and of the database
+// are logged with their causes.
+func problem(c *gin.Context, errs ...error) {
+	p := presenters.NewProblem(c.Request.URL.Path, errs...)
+	for _, err := range errs {
+		if presenters.Internal(err) || errors.Is(err, repositories.ErrDatabaseUnavailable) {
+			log.Printf("error handling %s %s: %s", c.Request.Method, c.Request.URL.Path, err)
+		}
+	}
+	if p.Status == http.StatusServiceUnavailable {
+		c.Header("Retry-After", retryAfterSeconds)
+	}
+	renderProblem(c, p)
+}
+
+// renderProblem sends the problem as XML when accepted, as JSON otherwise, even to the clients of the other formats.
+func renderProblem(c *gin.Context, p *presenters.Problem) {
+	switch c.NegotiateFormat(presenters.MIMEProblemJSON, gin.MIMEJSON, presenters.MIMEProblemXML, gin.MIMEXML) {
+	case presenters.MIMEProblemXML, gin.MIMEXML:
+		c.Header("Content-Type", presenters.MIMEProblemXML+"; charset=utf-8")
+		c.XML(p.Status, p)
+	default:
+		c.Header("Content-Type", presenters.MIMEProblemJSON+"; charset=utf-8")
+		c.JSON(p.Status, p)
+	}
+}
+
+// invalidBody is the error of a request body that can't be decoded.
+func invalidBody(err error) error {
+	return &entities.Error{Kind: entities.KIND_VALIDATION, Code: "invalid_body", Message: err.Error(), Err: err}
+}
@@ -0,0 +1,62 @@
+package handler
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"user-transactions/application/presenters"
+	"user-transactions/core/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type StatementHandler struct {
+	StatementService *services.StatementService
+}
+
+func NewStatementHandler(statementService *services.StatementService) *StatementHandler {
+	return &StatementHandler{StatementService: statementService}
+}
+
+// Get returns the monthly statement of the user as JSON, XML, CSV or plain text, as negotiated by the Accept header.
+func (sh *StatementHandler) Get(c *gin.Context) {
+	userId := c.Param("user_id")
+	filter := map[string]string{
+		"period":   c.Query("period"),
+		"currency": c.Query("currency"),
+	}
+
+	format := c.NegotiateFormat("application/json", "application/xml", presenters.MIMECSV, presenters.MIMEText)
+	if format == "" {
+		renderProblem(c, presenters.NewStatusProblem(c.Request.URL.Path, http.StatusNotAcceptable, "not_acceptable",
+			fmt.Sprintf("statement must accept one of [application/json application/xml %s %s]", presenters.MIMECSV, presenters.MIMEText)))
+		return
+	}
+
+	statement, err := sh.StatementService.GetStatement(c, userId, filter)
+	if err != nil {
+		// as in the exports, the errors of the CSV and text statements are sent as JSON
+		problem(c, err)
+		return
+	}
+
+	var body bytes.Buffer
+	switch format {
+	case presenters.MIMECSV:
+		err = presenters.WriteStatementCSV(&body, statement)
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="statement-%s-%s.csv"`, statement.Period, statement.Currency))
+	case presenters.MIMEText:
+		err = presenters.WriteStatementText(&body, statement)
+	default:
+		c.Negotiate(http.StatusOK, gin.Negotiate{
+			Offered: []string{"application/json", "application/xml"},
+			Data:    presenters.TransformDataToApiFormat(statement),
+		})
+		return
+	}
+	if err != nil {
+		problem(c, err)
+		return
+	}
+	c.Data(http.StatusOK, format+"; charset=utf-8", body.Bytes())
+}
@@ -0,0 +1,197 @@
+//go:build integration
+// +build integration
+
+package handler_test
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"user-transactions/application/dto"
+	"user-transactions/application/handler"
+	"user-transactions/core/entities"
+	"user-transactions/core/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_StatementHandler_Get(t *testing.T) {
+	s := setupService(t)
+	ss, err := services.NewStatementService(s.TransactionRepository)
+	assert.NoError(t, err)
+	h := handler.NewStatementHandler(ss)
+
+	// Create a new Gin router
+	router := gin.Default()
+	router.GET("/users/:user_id/statements", h.Get)
+
+	// Create a transaction before the month and two in it, and a hold left out of the statement
+	var transactions []*entities.Transaction
+	for _, item := range []struct {
+		amount    int64
+		createdAt time.Time
+	}{
+		{1000, time.Date(2023, 10, 20, 12, 0, 0, 0, time.UTC)},
+		{500, time.Date(2023, 11, 2, 9, 30, 0, 0, time.UTC)},
+		{-2000, time.Date(2023, 11, 30, 23, 59, 0, 0, time.UTC)},
+		{300, time.Date(2023, 12, 1, 0, 0, 0, 0, time.UTC)},
+	} {
+		opType := entities.CREDIT
+		if item.amount < 0 {
+			opType = entities.DEBIT
+		}
+		transaction, errs := entities.NewTransaction("desktop-web", "user123", item.amount, opType)
+		assert.Empty(t, errs)
+		transaction.CreatedAt = item.createdAt
+		transactions = append(transactions, transaction)
+	}
+	hold, errs := entities.NewTransaction("desktop-web", "user123", -100, entities.DEBIT, entities.WithHold(time.Now().Add(time.Hour)))
+	assert.Empty(t, errs)
+	hold.CreatedAt = time.Date(2023, 11, 15, 0, 0, 0, 0, time.UTC)
+	transactions = append(transactions, hold)
+	assert.NoError(t, s.TransactionRepository.InsertMany(context.Background(), transactions))
+
+	t.Run("getting the statement of a month", func(t *testing.T) {
+		// Create a new HTTP request
+		req, err := http.NewRequest("GET", "/users/user123/statements?period=2023-11", nil)
+		assert.NoError(t, err)
+		req.Header.Set("Accept", "application/json")
+
+		// Create a new HTTP response recorder
+		res := httptest.NewRecorder()
+
+		// Serve the HTTP request
+		router.ServeHTTP(res, req)
+
+		// Assert the response status code and body
+		assert.Equal(t, http.StatusOK, res.Code)
+		var result struct {
+			Data dto.StatementRes `json:"data"`
+		}
+		assert.NoError(t, json.NewDecoder(res.Body).Decode(&result))
+		assert.Equal(t, "2023-11", result.Data.Period)
+		assert.Equal(t, int64(1000), result.Data.Opening)
+		assert.Equal(t, int64(-500), result.Data.Closing)
+		if assert.Len(t, result.Data.Lines, 2) {
+			assert.Equal(t, transactions[1].ID.String(), result.Data.Lines[0].ID)
+			assert.Equal(t, int64(1500), result.Data.Lines[0].Balance)
+			assert.Equal(t, int64(-500), result.Data.Lines[1].Balance)
+		}
+	})
+
+	t.Run("getting the statement accepting XML", func(t *testing.T) {
+		// Create a new HTTP request
+		req, err := http.NewRequest("GET", "/users/user123/statements?period=2023-11", nil)
+		assert.NoError(t, err)
+		req.Header.Set("Accept", "application/xml")
+
+		// Create a new HTTP response recorder
+		res := httptest.NewRecorder()
+
+		// Serve the HTTP request
+		router.ServeHTTP(res, req)
+
+		// Assert the response status code and body
+		assert.Equal(t, http.StatusOK, res.Code)
+		var result struct {
+			Data dto.StatementRes `xml:"statement"`
+		}
+		assert.NoError(t, xml.NewDecoder(res.Body).Decode(&result))
+		assert.Equal(t, int64(-500), result.Data.Closing)
+		assert.Len(t, result.Data.Lines, 2)
+	})
+
+	t.Run("getting the statement as CSV", func(t *testing.T) {
+		// Create a new HTTP request
+		req, err := http.NewRequest("GET", "/users/user123/statements?period=2023-11", nil)
+		assert.NoError(t, err)
+		req.Header.Set("Accept", "text/csv")
+
+		// Create a new HTTP response recorder
+		res := httptest.NewRecorder()
+
+		// Serve the HTTP request
+		router.ServeHTTP(res, req)
+
+		// Assert the response status code and body
+		assert.Equal(t, http.StatusOK, res.Code)
+		assert.Equal(t, "text/csv; charset=utf-8", res.Header().Get("Content-Type"))
+		assert.Equal(t, `attachment; filename="statement-2023-11-BRL.csv"`, res.Header().Get("Content-Disposition"))
+		rows, err := csv.NewReader(res.Body).ReadAll()
+		assert.NoError(t, err)
+		if assert.Len(t, rows, 5) {
+			assert.Equal(t, "created_at", rows[0][0])
+			assert.Equal(t, []string{"2023-11-01T00:00:00Z", "", "", "opening_balance", "", "BRL", "1000", "", ""}, rows[1])
+			assert.Equal(t, transactions[1].ID.String(), rows[2][1])
+			assert.Equal(t, "1500", rows[2][6])
+			assert.Equal(t, []string{"2023-12-01T00:00:00Z", "", "", "closing_balance", "", "BRL", "-500", "", ""}, rows[4])
+		}
+	})
+
+	t.Run("getting the statement as plain text", func(t *testing.T) {
+		// Create a new HTTP request
+		req, err := http.NewRequest("GET", "/users/user123/statements?period=2023-11", nil)
+		assert.NoError(t, err)
+		req.Header.Set("Accept", "text/plain")
+
+		// Create a new HTTP response recorder
+		res := httptest.NewRecorder()
+
+		// Serve the HTTP request
+		router.ServeHTTP(res, req)
+
+		// Assert the response status code and body
+		assert.Equal(t, http.StatusOK, res.Code)
+		assert.Equal(t, "text/plain; charset=utf-8", res.Header().Get("Content-Type"))
+		body := res.Body.String()
+		assert.Contains(t, body, "Statement of user123")
+		assert.Contains(t, body, "Period: 2023-11-01 to 2023-11-30 (UTC)")
+		assert.Regexp(t, `\n2023-11-01 +Opening balance +10\.00\n`, body)
+		assert.Regexp(t, `\n2023-11-30 23:59:00 +`+transactions[2].ID.String()+` +desktop-web debit +-20\.00 +-5\.00\n`, body)
+		assert.Regexp(t, `\n2023-11-30 +Closing balance +-5\.00\n`, body)
+		assert.Contains(t, body, "Credits: 5.00\nDebits: -20.00\n")
+	})
+
+	t.Run("getting the statement of an invalid period", func(t *testing.T) {
+		for _, accept := range []string{"application/json", "text/csv"} {
+			// Create a new HTTP request
+			req, err := http.NewRequest("GET", "/users/user123/statements?period=november", nil)
+			assert.NoError(t, err)
+			req.Header.Set("Accept", accept)
+
+			// Create a new HTTP response recorder
+			res := httptest.NewRecorder()
+
+			// Serve the HTTP request
+			router.ServeHTTP(res, req)
+
+			// Assert the response status code and body
+			assert.Equal(t, http.StatusBadRequest, res.Code)
+			assert.True(t, strings.HasPrefix(res.Header().Get("Content-Type"), "application/problem+json"))
+			assert.Contains(t, res.Body.String(), "period must be a month (YYYY-MM)")
+		}
+	})
+
+	t.Run("getting the statement accepting an unknown format", func(t *testing.T) {
+		// Create a new HTTP request
+		req, err := http.NewRequest("GET", "/users/user123/statements?period=2023-11", nil)
+		assert.NoError(t, err)
+		req.Header.Set("Accept", "application/pdf")
+
+		// Create a new HTTP response recorder
+		res := httptest.NewRecorder()
+
+		// Serve the HTTP request
+		router.ServeHTTP(res, req)
+
+		// Assert the response status code
+		assert.Equal(t, http.StatusNotAcceptable, res.Code)
+	})
+}
@@ -1,13 +1,26 @@
 package handler
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"log"
+	"math"
 	"net/http"
 	"strconv"
+	"strings"
 	"user-transactions/application/dto"
 	"user-transactions/application/presenters"
+	"user-transactions/core/entities"
+	"user-transactions/core/repositories"
 	"user-transactions/core/services"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 )
 
 type TransactionHandler struct {
@@ -20,38 +33,139 @@ func NewTransactionHandler(transactionService *services.TransactionService) *Tra
 
 func (th *TransactionHandler) Save(c *gin.Context) {
 	req := &dto.CreateTransactionReq{}
-	if err := c.Bind(&req); err != nil {
-		c.Negotiate(http.StatusBadRequest, gin.Negotiate{
-			Offered: []string{"application/json", "application/xml"},
-			Data:    presenters.TransformErrorToApiError(err),
-		})
+	if err := c.ShouldBind(&req); err != nil {
+		problem(c, invalidBody(err))
 		return
 	}
 
+	req.IdempotencyKey = c.GetHeader("Idempotency-Key")
+	// the consistency field of the body has precedence over the Prefer header
+	if req.Consistency == "" && preferCommitSync(c) {
+		req.Consistency = string(repositories.CONSISTENCY_COMMIT_SYNC)
+	}
+
 	transaction, errs := th.TransactionService.CreateTransaction(c, req)
 	if len(errs) > 0 {
-		c.Negotiate(http.StatusBadRequest, gin.Negotiate{
-			Offered: []string{"application/json", "application/xml"},
-			Data:    presenters.TransformErrorToApiError(errs...),
-		})
+		problem(c, errs...)
 		return
 	}
 
+	if req.Consistency == string(repositories.CONSISTENCY_COMMIT_SYNC) && preferCommitSync(c) {
+		c.Header("Preference-Applied", string(repositories.CONSISTENCY_COMMIT_SYNC))
+	}
+
 	c.Negotiate(http.StatusCreated, gin.Negotiate{
 		Offered: []string{"application/json", "application/xml"},
 		Data:    presenters.TransformDataToApiFormat(transaction),
 	})
 }
 
+// SaveBatch creates the transactions of a JSON array, a NDJSON stream or a XML list, with ?atomic=true
+// none of them is created unless all of them are.
+func (th *TransactionHandler) SaveBatch(c *gin.Context) {
+	atomic := false
+	if atomicStr := c.Query("atomic"); atomicStr != "" {
+		var err error
+		if atomic, err = strconv.ParseBool(atomicStr); err != nil {
+			problem(c, entities.NewParameterError("atomic", "atomic must be a boolean"))
+			return
+		}
+	}
+
+	reqs, err := bindBatch(c, th.TransactionService.MaxBatchSize)
+	if err != nil {
+		var tooLarge *entities.Error
+		var maxBytes *http.MaxBytesError
+		switch {
+		case errors.As(err, &tooLarge):
+			problem(c, tooLarge)
+		case errors.As(err, &maxBytes):
+			problem(c, entities.NewFieldError("invalid_batch_size", "/transactions", fmt.Sprintf("the body of the batch must have at most %d bytes", maxBytes.Limit)))
+		default:
+			problem(c, invalidBody(err))
+		}
+		return
+	}
+
+	batch, errs := th.TransactionService.CreateTransactions(c, reqs, atomic)
+	if len(errs) > 0 {
+		problem(c, errs...)
+		return
+	}
+
+	// some created and some failed is a multi-status, the result of each transaction is in the items
+	status := http.StatusMultiStatus
+	switch {
+	case batch.Failed == 0:
+		status = http.StatusCreated
+	case batch.Created == 0:
+		status = http.StatusUnprocessableEntity
+	}
+
+	c.Negotiate(status, gin.Negotiate{
+		Offered: []string{"application/json", "application/xml"},
+		Data:    presenters.TransformDataToApiFormat(batch),
+	})
+}
+
 func (th *TransactionHandler) Get(c *gin.Context) {
 	id := c.Param("id")
 
 	transaction, err := th.TransactionService.GetTransaction(c, id)
 	if err != nil {
-		c.Negotiate(http.StatusBadRequest, gin.Negotiate{
-			Offered: []string{"application/json", "application/xml"},
-			Data:    presenters.TransformErrorToApiError(err),
-		})
+		problem(c, err)
+		return
+	}
+
+	c.Negotiate(http.StatusOK, gin.Negotiate{
+		Offered: []string{"application/json", "application/xml"},
+		Data:    presenters.TransformDataToApiFormat(transaction),
+	})
+}
+
+func (th *TransactionHandler) Reverse(c *gin.Context) {
+	id := c.Param("id")
+
+	// the body is optional, without it all that is left of the transaction is reversed
+	req := &dto.CreateReversalReq{}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBind(&req); err != nil {
+			problem(c, invalidBody(err))
+			return
+		}
+	}
+
+	reversal, errs := th.TransactionService.ReverseTransaction(c, id, req)
+	if len(errs) > 0 {
+		problem(c, errs...)
+		return
+	}
+
+	c.Negotiate(http.StatusCreated, gin.Negotiate{
+		Offered: []string{"application/json", "application/xml"},
+		Data:    presenters.TransformDataToApiFormat(reversal),
+	})
+}
+
+// Capture posts a pending hold.
+func (th *TransactionHandler) Capture(c *gin.Context) {
+	transaction, err := th.TransactionService.CaptureTransaction(c, c.Param("id"))
+	if err != nil {
+		problem(c, err)
+		return
+	}
+
+	c.Negotiate(http.StatusOK, gin.Negotiate{
+		Offered: []string{"application/json", "application/xml"},
+		Data:    presenters.TransformDataToApiFormat(transaction),
+	})
+}
+
+// Void releases a pending hold.
+func (th *TransactionHandler) Void(c *gin.Context) {
+	transaction, err := th.TransactionService.VoidTransaction(c, c.Param("id"))
+	if err != nil {
+		problem(c, err)
 		return
 	}
 
@@ -72,27 +186,335 @@ func (th *TransactionHandler) List(c *gin.Context) {
 	pageSizeStr, pageStr := c.Query("page_size"), c.Query("page")
 	// Convert pageSize and offset to int
 	pageSize, err := strconv.Atoi(pageSizeStr)
-	if err != nil {
+	if err != nil || pageSize <= 0 {
 		pageSize = 10
 	}
 
 	page, err := strconv.Atoi(pageStr)
-	if err != nil {
+	if err != nil || page < 0 {
 		page = 0
 	}
 
-	// Use query parameters as a filter for List method of TransactionService
-	transactions, err := th.TransactionService.ListTransactions(c, pageSize, page, queryParams)
-	if err != nil {
-		c.Negotiate(http.StatusBadRequest, gin.Negotiate{
+	// counting all the transactions is expensive, it's only done when requested
+	includeTotal := false
+	if includeTotalStr := c.Query("include_total"); includeTotalStr != "" {
+		if includeTotal, err = strconv.ParseBool(includeTotalStr); err != nil {
+			problem(c, entities.NewParameterError("include_total", "include_total must be a boolean"))
+			return
+		}
+	}
+
+	// keyset pagination is used when a cursor is given, even an empty one for the first page
+	if cursor, ok := c.GetQuery("cursor"); ok {
+		result, err := th.TransactionService.ListTransactionsByCursor(c, pageSize, cursor, includeTotal, queryParams)
+		if err != nil {
+			problem(c, err)
+			return
+		}
+
+		links := []presenters.Link{{Rel: "first", URL: linkTo(c, "cursor", "")}}
+		if result.PrevCursor != "" {
+			links = append(links, presenters.Link{Rel: "prev", URL: linkTo(c, "cursor", result.PrevCursor)})
+		}
+		if result.NextCursor != "" {
+			links = append(links, presenters.Link{Rel: "next", URL: linkTo(c, "cursor", result.NextCursor)})
+		}
+		c.Header("Link", presenters.FormatLinks(links...))
+
+		c.Negotiate(http.StatusOK, gin.Negotiate{
 			Offered: []string{"application/json", "application/xml"},
-			Data:    presenters.TransformErrorToApiError(err),
+			Data: presenters.TransformDataToApiFormat(result.Transactions).
+				WithCursorPagination(pageSize, result.NextCursor, result.PrevCursor).
+				WithPageInfo(result.HasNext, result.Total),
 		})
 		return
 	}
 
+	// the offset of the page and of the next one must fit in an int
+	if page >= math.MaxInt/pageSize {
+		problem(c, entities.NewParameterError("page", "page is too large for page_size"))
+		return
+	}
+
+	// Use query parameters as a filter for List method of TransactionService
+	result, err := th.TransactionService.ListTransactions(c, pageSize, page*pageSize, includeTotal, queryParams)
+	if err != nil {
+		problem(c, err)
+		return
+	}
+
+	data := presenters.TransformDataToApiFormat(result.Transactions).
+		WithPagination(page, pageSize).
+		WithPageInfo(result.HasNext, result.Total)
+
+	links := []presenters.Link{{Rel: "first", URL: linkTo(c, "page", "0")}}
+	if page > 0 {
+		links = append(links, presenters.Link{Rel: "prev", URL: linkTo(c, "page", strconv.Itoa(page-1))})
+	}
+	if result.HasNext {
+		links = append(links, presenters.Link{Rel: "next", URL: linkTo(c, "page", strconv.Itoa(page+1))})
+	}
+	if data.Pagination.TotalPages != nil && *data.Pagination.TotalPages > 0 {
+		links = append(links, presenters.Link{Rel: "last", URL: linkTo(c, "page", strconv.FormatInt(*data.Pagination.TotalPages-1, 10))})
+	}
+	c.Header("Link", presenters.FormatLinks(links...))
+
+	c.Negotiate(http.StatusOK, gin.Negotiate{
+		Offered: []string{"application/json", "application/xml"},
+		Data:    data,
+	})
+}
+
+// Summary totals the transactions passing the List filters, grouped by the group_by columns and the interval.
+func (th *TransactionHandler) Summary(c *gin.Context) {
+	queryParams := make(map[string]string)
+	for key, values := range c.Request.URL.Query() {
+		if len(values) > 0 {
+			queryParams[key] = values[0]
+		}
+	}
+
+	summaries, err := th.TransactionService.SummarizeTransactions(c, queryParams)
+	if err != nil {
+		problem(c, err)
+		return
+	}
+
+	c.Negotiate(http.StatusOK, gin.Negotiate{
+		Offered: []string{"application/json", "application/xml"},
+		Data:    presenters.TransformDataToApiFormat(summaries),
+	})
+}
+
+// Export streams the transactions passing the List filters as CSV or NDJSON, as negotiated by the Accept header.
+func (th *TransactionHandler) Export(c *gin.Context) {
+	format := c.NegotiateFormat(presenters.MIMECSV, presenters.MIMENDJSON)
+	if format == "" {
+		renderProblem(c, presenters.NewStatusProblem(c.Request.URL.Path, http.StatusNotAcceptable, "not_acceptable",
+			fmt.Sprintf("export must accept one of [%s %s]", presenters.MIMECSV, presenters.MIMENDJSON)))
+		return
+	}
+
+	queryParams := make(map[string]string)
+	for key, values := range c.Request.URL.Query() {
+		if len(values) > 0 {
+			queryParams[key] = values[0]
+		}
+	}
+
+	// the response starts with the first transaction, so a filter error is still answered with its status
+	var exporter presenters.Exporter
+	begin := func() {
+		c.Header("Content-Type", format)
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="transactions%s"`, exportExtension[format]))
+		c.Status(http.StatusOK)
+		exporter = presenters.NewExporter(format, c.Writer)
+	}
+
+	rows := 0
+	// the request context is done when the client disconnects, which stops reading the rows
+	err := th.TransactionService.ExportTransactions(c.Request.Context(), queryParams, func(transaction *dto.TransactionRes) error {
+		if exporter == nil {
+			begin()
+		}
+		if err := exporter.Write(transaction); err != nil {
+			return err
+		}
+		rows++
+		if rows%exportFlushRows == 0 {
+			return exporter.Flush()
+		}
+		return nil
+	})
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+	if err != nil {
+		if exporter == nil {
+			// the errors of an export can't be negotiated with the Accept header, they are sent as JSON
+			problem(c, err)
+			return
+		}
+		// the status was already sent, the export is cut short
+		log.Printf("error exporting the transactions after %d rows: %s", rows, err)
+		return
+	}
+
+	if exporter == nil {
+		begin()
+	}
+	if err := exporter.Flush(); err != nil {
+		log.Printf("error exporting the transactions after %d rows: %s", rows, err)
+	}
+}
+
+func (th *TransactionHandler) Balance(c *gin.Context) {
+	userId := c.Param("user_id")
+	filter := map[string]string{
+		"origin":   c.Query("origin"),
+		"currency": c.Query("currency"),
+		"as_of":    c.Query("as_of"),
+	}
+
+	balance, err := th.TransactionService.GetBalance(c, userId, filter)
+	if err != nil {
+		problem(c, err)
+		return
+	}
+
 	c.Negotiate(http.StatusOK, gin.Negotiate{
 		Offered: []string{"application/json", "application/xml"},
-		Data:    presenters.TransformDataToApiFormat(transactions).WithPagination(page, pageSize),
+		Data:    presenters.TransformDataToApiFormat(balance),
 	})
 }
+
+// Balances returns a balance for each currency of the user.
+func (th *TransactionHandler) Balances(c *gin.Context) {
+	userId := c.Param("user_id")
+	filter := map[string]string{
+		"origin":   c.Query("origin"),
+		"currency": c.Query("currency"),
+		"as_of":    c.Query("as_of"),
+	}
+
+	balances, err := th.TransactionService.GetBalances(c, userId, filter)
+	if err != nil {
+		problem(c, err)
+		return
+	}
+
+	c.Negotiate(http.StatusOK, gin.Negotiate{
+		Offered: []string{"application/json", "application/xml"},
+		Data:    presenters.TransformDataToApiFormat(balances),
+	})
+}
+
+// preferCommitSync reports whether the Prefer header (RFC 7240) asks to wait for the commit.
+func preferCommitSync(c *gin.Context) bool {
+	for _, header := range c.Request.Header.Values("Prefer") {
+		for _, preference := range strings.Split(header, ",") {
+			if strings.EqualFold(strings.TrimSpace(preference), string(repositories.CONSISTENCY_COMMIT_SYNC)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// bindBatch reads the transactions of a batch in the format of its content type, a JSON array by default.
+func bindBatch(c *gin.Context, maxSize int) ([]*dto.CreateTransactionReq, error) {
+	// the transactions are decoded as the body is read, so a batch over maxSize isn't read whole, and the body is
+	// limited to the bytes of the transactions it can have
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, max(int64(maxSize)*maxBatchItemBytes, maxBatchLineBytes))
+
+	var reqs []*dto.CreateTransactionReq
+	add := func(decode func(req *dto.CreateTransactionReq) error) error {
+		if len(reqs) == maxSize {
+			return services.BatchTooLargeError(maxSize)
+		}
+		req := &dto.CreateTransactionReq{}
+		if err := decode(req); err != nil {
+			return err
+		}
+		reqs = append(reqs, req)
+		return nil
+	}
+
+	switch c.ContentType() {
+	case "application/x-ndjson", "application/ndjson":
+		scanner := bufio.NewScanner(c.Request.Body)
+		scanner.Buffer(make([]byte, 0, 64*1024), maxBatchLineBytes)
+		for line := 1; scanner.Scan(); line++ {
+			if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+				continue
+			}
+			err := add(func(req *dto.CreateTransactionReq) error { return json.Unmarshal(scanner.Bytes(), req) })
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+		}
+		return reqs, scanner.Err()
+	case binding.MIMEXML, binding.MIMEXML2:
+		decoder := xml.NewDecoder(c.Request.Body)
+		root, err := xmlRoot(decoder)
+		if err != nil {
+			return nil, err
+		}
+		if root.Name.Local != "transactions" {
+			return nil, fmt.Errorf("expected element type <transactions> but have <%s>", root.Name.Local)
+		}
+		for {
+			token, err := decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+			switch element := token.(type) {
+			case xml.StartElement:
+				if element.Name.Local != "transaction" {
+					err = decoder.Skip()
+				} else {
+					err = add(func(req *dto.CreateTransactionReq) error { return decoder.DecodeElement(req, &element) })
+				}
+				if err != nil {
+					return nil, err
+				}
+			case xml.EndElement:
+				return reqs, nil
+			}
+		}
+	default:
+		decoder := json.NewDecoder(c.Request.Body)
+		if token, err := decoder.Token(); err != nil {
+			return nil, err
+		} else if token != json.Delim('[') {
+			return nil, fmt.Errorf("the batch must be an array of transactions")
+		}
+		for decoder.More() {
+			if err := add(func(req *dto.CreateTransactionReq) error { return decoder.Decode(req) }); err != nil {
+				return nil, err
+			}
+		}
+		if _, err := decoder.Token(); err != nil {
+			return nil, err
+		}
+		return reqs, nil
+	}
+}
+
+// xmlRoot reads the tokens up to the root element of the document.
+func xmlRoot(decoder *xml.Decoder) (xml.StartElement, error) {
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return xml.StartElement{}, err
+		}
+		if root, ok := token.(xml.StartElement); ok {
+			return root, nil
+		}
+	}
+}
+
+// exportFlushRows is how many rows of an export are buffered before being sent to the client.
+const exportFlushRows = 100
+
+var exportExtension = map[string]string{
+	presenters.MIMECSV:    ".csv",
+	presenters.MIMENDJSON: ".ndjson",
+}
+
+// maxBatchLineBytes is the longest line accepted in a NDJSON batch.
+const maxBatchLineBytes = 1024 * 1024
+
+// maxBatchItemBytes is the size of a transaction of a batch on average, the body of a batch can have this many bytes
+// for each transaction it accepts.
+const maxBatchItemBytes = 4 * 1024
+
+// linkTo returns the URL of the current request with the query parameter replaced.
+func linkTo(c *gin.Context, key, value string) string {
+	query := c.Request.URL.Query()
+	query.Set(key, value)
+
+	u := *c.Request.URL
+	u.RawQuery = query.Encode()
+	return u.RequestURI()
+}
@@ -8,6 +8,7 @@ import (
 	"encoding/json"
 	"encoding/xml"
 	"fmt"
+	"math"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -20,6 +21,7 @@ import (
 	"user-transactions/infrastructure/repositories"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
@@ -29,7 +31,7 @@ import (
 func setupService(t *testing.T) *services.TransactionService {
 	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
 	assert.NoError(t, err)
-	assert.NoError(t, db.AutoMigrate(&entities.Transaction{}))
+	assert.NoError(t, db.AutoMigrate(&entities.Transaction{}, &entities.IdempotencyKey{}, &entities.UserBalance{}))
 
 	t.Cleanup(func() {
 		sqlDB, _ := db.DB()
@@ -38,6 +40,7 @@ func setupService(t *testing.T) *services.TransactionService {
 
 	tr := repositories.NewTransactionRepository(db)
 	s, _ := services.NewTransactionService(tr)
+	s.WithIdempotencyRepository(repositories.NewIdempotencyRepository(db))
 
 	return s
 }
@@ -181,6 +184,66 @@ func Test_TransactionHandler_Save(t *testing.T) {
 	})
 }
 
+func Test_TransactionHandler_Save_Idempotency(t *testing.T) {
+	s := setupService(t)
+	h := handler.NewTransactionHandler(s)
+
+	// Create a new Gin router
+	router := gin.Default()
+	router.POST("/transactions", h.Save)
+
+	send := func(key, payload string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest("POST", "/transactions", strings.NewReader(payload))
+		assert.NoError(t, err)
+
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", "application/json")
+		req.Header.Set("Idempotency-Key", key)
+
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, req)
+		return res
+	}
+	payload := `{
+		"origin": "mobile-android",
+		"user_id": "user123",
+		"amount": 200,
+		"type": "credit"
+	}`
+
+	first := send("retry-1", payload)
+	assert.Equal(t, http.StatusCreated, first.Code)
+
+	t.Run("replaying a repeated submission", func(t *testing.T) {
+		res := send("retry-1", payload)
+
+		// Assert the response status code
+		assert.Equal(t, http.StatusCreated, res.Code)
+
+		// Assert the response body is the original one
+		assert.JSONEq(t, first.Body.String(), res.Body.String())
+
+		var count int64
+		assert.NoError(t, s.TransactionRepository.(*repositories.TransactionRepository).Db.Model(&entities.Transaction{}).Count(&count).Error)
+		assert.Equal(t, int64(1), count)
+	})
+
+	t.Run("reusing a key with a different payload", func(t *testing.T) {
+		res := send("retry-1", `{
+			"origin": "mobile-android",
+			"user_id": "user123",
+			"amount": 900,
+			"type": "credit"
+		}`)
+
+		// Assert the response status code
+		assert.Equal(t, http.StatusUnprocessableEntity, res.Code)
+
+		// Assert the response body
+		assert.Contains(t, res.Body.String(), services.ErrIdempotencyKeyReused.Error())
+	})
+}
+
 func Test_TransactionHandler_Get(t *testing.T) {
 	s := setupService(t)
 	h := handler.NewTransactionHandler(s)
@@ -287,7 +350,93 @@ func Test_TransactionHandler_Get(t *testing.T) {
 		assert.Equal(t, http.StatusBadRequest, res.Code)
 
 		// Assert the response body
-		assert.Contains(t, res.Body.String(), "record not found")
+		var result presenters.Problem
+		assert.NoError(t, json.NewDecoder(res.Body).Decode(&result))
+		assert.Equal(t, "invalid_id", result.Code)
+		assert.Equal(t, "/transactions/invalid", result.Instance)
+		assert.Equal(t, "the id of a transaction must be a UUID", result.Detail)
+	})
+
+	t.Run("getting a transaction that does not exist", func(t *testing.T) {
+		path := fmt.Sprintf("/transactions/%s", uuid.New())
+
+		// Create a new HTTP request
+		req, err := http.NewRequest("GET", path, nil)
+		assert.NoError(t, err)
+		req.Header.Set("Accept", "application/json")
+
+		// Create a new HTTP response recorder
+		res := httptest.NewRecorder()
+
+		// Serve the HTTP request
+		router.ServeHTTP(res, req)
+
+		// Assert the response status code and body
+		assert.Equal(t, http.StatusNotFound, res.Code)
+		var result presenters.Problem
+		assert.NoError(t, json.NewDecoder(res.Body).Decode(&result))
+		assert.Equal(t, "not_found", result.Code)
+		assert.Equal(t, path, result.Instance)
+		assert.NotContains(t, result.Detail, "record not found")
+	})
+}
+
+func Test_TransactionHandler_Get_Failures(t *testing.T) {
+	setup := func(t *testing.T) (*gin.Engine, *gorm.DB) {
+		s := setupService(t)
+		h := handler.NewTransactionHandler(s)
+
+		// Create a new Gin router
+		router := gin.Default()
+		router.GET("/transactions/:id", h.Get)
+
+		return router, s.TransactionRepository.(*repositories.TransactionRepository).Db
+	}
+
+	get := func(router *gin.Engine) *httptest.ResponseRecorder {
+		// Create a new HTTP request
+		req, err := http.NewRequest("GET", fmt.Sprintf("/transactions/%s", uuid.New()), nil)
+		assert.NoError(t, err)
+		req.Header.Set("Accept", "application/json")
+
+		// Create a new HTTP response recorder
+		res := httptest.NewRecorder()
+
+		// Serve the HTTP request
+		router.ServeHTTP(res, req)
+		return res
+	}
+
+	t.Run("answering 503 when the database is unavailable", func(t *testing.T) {
+		router, db := setup(t)
+		sqlDB, err := db.DB()
+		assert.NoError(t, err)
+		assert.NoError(t, sqlDB.Close())
+
+		res := get(router)
+
+		// Assert the response status code and body
+		assert.Equal(t, http.StatusServiceUnavailable, res.Code)
+		assert.Equal(t, "1", res.Header().Get("Retry-After"))
+		assert.NotContains(t, res.Body.String(), "database is closed")
+		var result presenters.Problem
+		assert.NoError(t, json.NewDecoder(res.Body).Decode(&result))
+		assert.Equal(t, "database_unavailable", result.Code)
+	})
+
+	t.Run("answering 500 when the query fails", func(t *testing.T) {
+		router, db := setup(t)
+		assert.NoError(t, db.Migrator().DropTable(&entities.Transaction{}))
+
+		res := get(router)
+
+		// Assert the response status code and body
+		assert.Equal(t, http.StatusInternalServerError, res.Code)
+		assert.Empty(t, res.Header().Get("Retry-After"))
+		assert.NotContains(t, res.Body.String(), "no such table")
+		var result presenters.Problem
+		assert.NoError(t, json.NewDecoder(res.Body).Decode(&result))
+		assert.Equal(t, "internal_error", result.Code)
 	})
 }
 
@@ -397,3 +546,1554 @@ func Test_TransactionHandler_List(t *testing.T) {
 		assert.Equal(t, 2, result.Pagination.PageSize)
 	})
 }
+
+func Test_TransactionHandler_Balance(t *testing.T) {
+	s := setupService(t)
+	h := handler.NewTransactionHandler(s)
+
+	// Create a new Gin router
+	router := gin.Default()
+	router.GET("/users/:user_id/balance", h.Balance)
+
+	for _, amount := range []int64{500, 250} {
+		// Create a new credit transaction with desktop-web origin
+		transaction, errs := entities.NewTransaction("desktop-web", "user123", amount, entities.CREDIT)
+		assert.Empty(t, errs)
+		_, err := s.TransactionRepository.Insert(context.Background(), transaction)
+		assert.NoError(t, err)
+	}
+	// Create a new debit transaction with mobile-android origin
+	transaction, errs := entities.NewTransaction("mobile-android", "user123", -100, entities.DEBIT)
+	assert.Empty(t, errs)
+	_, err := s.TransactionRepository.Insert(context.Background(), transaction)
+	assert.NoError(t, err)
+
+	t.Run("getting the balance of a user", func(t *testing.T) {
+		// Create a new HTTP request
+		req, err := http.NewRequest("GET", "/users/user123/balance", nil)
+		assert.NoError(t, err)
+
+		// Set the request content type
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", "application/json")
+
+		// Create a new HTTP response recorder
+		res := httptest.NewRecorder()
+
+		// Serve the HTTP request
+		router.ServeHTTP(res, req)
+
+		// Assert the response status code
+		assert.Equal(t, http.StatusOK, res.Code)
+
+		// Assert the response body
+		var result struct {
+			Data dto.BalanceRes `json:"data"`
+		}
+		err = json.NewDecoder(res.Body).Decode(&result)
+		assert.NoError(t, err)
+		assert.Equal(t, "user123", result.Data.UserID)
+		assert.Equal(t, int64(650), result.Data.Balance)
+		assert.NotEmpty(t, result.Data.AsOf)
+	})
+
+	t.Run("getting the balance of a user by origin accepting XML", func(t *testing.T) {
+		// Create a new HTTP request
+		req, err := http.NewRequest("GET", "/users/user123/balance?origin=desktop-web", nil)
+		assert.NoError(t, err)
+
+		// Set the request content type
+		req.Header.Set("Content-Type", "application/xml")
+		req.Header.Set("Accept", "application/xml")
+
+		// Create a new HTTP response recorder
+		res := httptest.NewRecorder()
+
+		// Serve the HTTP request
+		router.ServeHTTP(res, req)
+
+		// Assert the response status code
+		assert.Equal(t, http.StatusOK, res.Code)
+
+		// Assert the response body
+		var result struct {
+			Data dto.BalanceRes `xml:"balance"`
+		}
+		err = xml.NewDecoder(res.Body).Decode(&result)
+		assert.NoError(t, err)
+		assert.Equal(t, "user123", result.Data.UserID)
+		assert.Equal(t, "desktop-web", result.Data.Origin)
+		assert.Equal(t, int64(750), result.Data.Balance)
+	})
+
+	t.Run("getting the balance with an invalid as_of", func(t *testing.T) {
+		// Create a new HTTP request
+		req, err := http.NewRequest("GET", "/users/user123/balance?as_of=yesterday", nil)
+		assert.NoError(t, err)
+
+		// Set the request content type
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", "application/json")
+
+		// Create a new HTTP response recorder
+		res := httptest.NewRecorder()
+
+		// Serve the HTTP request
+		router.ServeHTTP(res, req)
+
+		// Assert the response status code
+		assert.Equal(t, http.StatusBadRequest, res.Code)
+
+		// Assert the response body
+		assert.Contains(t, res.Body.String(), "as_of must be a RFC 3339 timestamp")
+	})
+}
+
+func Test_TransactionHandler_Reverse(t *testing.T) {
+	s := setupService(t)
+	h := handler.NewTransactionHandler(s)
+
+	// Create a new Gin router
+	router := gin.Default()
+	router.GET("/transactions/:id", h.Get)
+	router.POST("/transactions/:id/reversal", h.Reverse)
+
+	// Create a new transaction
+	transaction, errs := entities.NewTransaction("desktop-web", "user123", 1000, entities.CREDIT)
+	assert.Empty(t, errs)
+	_, err := s.TransactionRepository.Insert(context.Background(), transaction)
+	assert.NoError(t, err)
+
+	t.Run("reversing part of a transaction", func(t *testing.T) {
+		// Create a new HTTP request
+		req, err := http.NewRequest("POST", fmt.Sprintf("/transactions/%s/reversal", transaction.ID), strings.NewReader(`{"amount": 400}`))
+		assert.NoError(t, err)
+
+		// Set the request content type
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", "application/json")
+
+		// Create a new HTTP response recorder
+		res := httptest.NewRecorder()
+
+		// Serve the HTTP request
+		router.ServeHTTP(res, req)
+
+		// Assert the response status code
+		assert.Equal(t, http.StatusCreated, res.Code)
+
+		// Assert the response body
+		var result struct {
+			Data dto.TransactionRes `json:"data"`
+		}
+		err = json.NewDecoder(res.Body).Decode(&result)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(-400), result.Data.Amount)
+		assert.Equal(t, "debit", result.Data.Type)
+		assert.Equal(t, transaction.ID.String(), result.Data.ReversalOf)
+	})
+
+	t.Run("getting the reversal status of the transaction", func(t *testing.T) {
+		// Create a new HTTP request
+		req, err := http.NewRequest("GET", fmt.Sprintf("/transactions/%s", transaction.ID), nil)
+		assert.NoError(t, err)
+
+		// Set the request content type
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", "application/json")
+
+		// Create a new HTTP response recorder
+		res := httptest.NewRecorder()
+
+		// Serve the HTTP request
+		router.ServeHTTP(res, req)
+
+		// Assert the response status code
+		assert.Equal(t, http.StatusOK, res.Code)
+
+		// Assert the response body
+		var result struct {
+			Data dto.TransactionRes `json:"data"`
+		}
+		err = json.NewDecoder(res.Body).Decode(&result)
+		assert.NoError(t, err)
+		assert.Equal(t, "partial", result.Data.ReversalStatus)
+		assert.Equal(t, int64(400), result.Data.ReversedAmount)
+		assert.Len(t, result.Data.Reversals, 1)
+	})
+
+	t.Run("reversing what is left of the transaction without a body", func(t *testing.T) {
+		// Create a new HTTP request
+		req, err := http.NewRequest("POST", fmt.Sprintf("/transactions/%s/reversal", transaction.ID), nil)
+		assert.NoError(t, err)
+
+		// Set the request content type
+		req.Header.Set("Accept", "application/xml")
+
+		// Create a new HTTP response recorder
+		res := httptest.NewRecorder()
+
+		// Serve the HTTP request
+		router.ServeHTTP(res, req)
+
+		// Assert the response status code
+		assert.Equal(t, http.StatusCreated, res.Code)
+
+		// Assert the response body
+		var result struct {
+			Data dto.TransactionRes `xml:"transaction"`
+		}
+		err = xml.NewDecoder(res.Body).Decode(&result)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(-600), result.Data.Amount)
+	})
+
+	t.Run("reversing a transaction twice", func(t *testing.T) {
+		// Create a new HTTP request
+		req, err := http.NewRequest("POST", fmt.Sprintf("/transactions/%s/reversal", transaction.ID), nil)
+		assert.NoError(t, err)
+
+		// Set the request content type
+		req.Header.Set("Accept", "application/json")
+
+		// Create a new HTTP response recorder
+		res := httptest.NewRecorder()
+
+		// Serve the HTTP request
+		router.ServeHTTP(res, req)
+
+		// Assert the response status code
+		assert.Equal(t, http.StatusConflict, res.Code)
+
+		// Assert the response body
+		assert.Contains(t, res.Body.String(), entities.ErrAlreadyReversed.Error())
+	})
+}
+
+func Test_TransactionHandler_List_Pages(t *testing.T) {
+	s := setupService(t)
+	h := handler.NewTransactionHandler(s)
+
+	// Create a new Gin router
+	router := gin.Default()
+	router.GET("/transactions", h.List)
+
+	for i := 0; i < 5; i++ {
+		// Create a new transaction with desktop-web origin
+		transaction, errs := entities.NewTransaction("desktop-web", "user123", 100, entities.CREDIT)
+		assert.Empty(t, errs)
+		_, err := s.TransactionRepository.Insert(context.Background(), transaction)
+		assert.NoError(t, err)
+	}
+
+	t.Run("listing a page with the total", func(t *testing.T) {
+		// Create a new HTTP request
+		req, err := http.NewRequest("GET", "/transactions?origin=desktop-web&page=1&page_size=2&include_total=true", nil)
+		assert.NoError(t, err)
+
+		// Set the request content type
+		req.Header.Set("Accept", "application/json")
+
+		// Create a new HTTP response recorder
+		res := httptest.NewRecorder()
+
+		// Serve the HTTP request
+		router.ServeHTTP(res, req)
+
+		// Assert the response status code
+		assert.Equal(t, http.StatusOK, res.Code)
+
+		// Assert the response headers
+		assert.Equal(t, `</transactions?include_total=true&origin=desktop-web&page=0&page_size=2>; rel="first", `+
+			`</transactions?include_total=true&origin=desktop-web&page=0&page_size=2>; rel="prev", `+
+			`</transactions?include_total=true&origin=desktop-web&page=2&page_size=2>; rel="next", `+
+			`</transactions?include_total=true&origin=desktop-web&page=2&page_size=2>; rel="last"`, res.Header().Get("Link"))
+
+		// Assert the response body
+		var result struct {
+			Data       []*dto.TransactionRes  `json:"data"`
+			Pagination *presenters.Pagination `json:"pagination"`
+		}
+		err = json.NewDecoder(res.Body).Decode(&result)
+		assert.NoError(t, err)
+		assert.Len(t, result.Data, 2)
+		assert.True(t, result.Pagination.HasNext)
+		assert.Equal(t, int64(5), *result.Pagination.TotalItems)
+		assert.Equal(t, int64(3), *result.Pagination.TotalPages)
+	})
+
+	t.Run("listing the last page without the total accepting XML", func(t *testing.T) {
+		// Create a new HTTP request
+		req, err := http.NewRequest("GET", "/transactions?page=2&page_size=2", nil)
+		assert.NoError(t, err)
+
+		// Set the request content type
+		req.Header.Set("Accept", "application/xml")
+
+		// Create a new HTTP response recorder
+		res := httptest.NewRecorder()
+
+		// Serve the HTTP request
+		router.ServeHTTP(res, req)
+
+		// Assert the response status code
+		assert.Equal(t, http.StatusOK, res.Code)
+
+		// Assert the response headers
+		assert.Equal(t, `</transactions?page=0&page_size=2>; rel="first", </transactions?page=1&page_size=2>; rel="prev"`, res.Header().Get("Link"))
+
+		// Assert the response body
+		assert.Contains(t, res.Body.String(), "<HasNext>false</HasNext>")
+		assert.NotContains(t, res.Body.String(), "TotalItems")
+	})
+
+	t.Run("listing with an invalid include_total", func(t *testing.T) {
+		// Create a new HTTP request
+		req, err := http.NewRequest("GET", "/transactions?include_total=maybe", nil)
+		assert.NoError(t, err)
+
+		// Set the request content type
+		req.Header.Set("Accept", "application/json")
+
+		// Create a new HTTP response recorder
+		res := httptest.NewRecorder()
+
+		// Serve the HTTP request
+		router.ServeHTTP(res, req)
+
+		// Assert the response status code
+		assert.Equal(t, http.StatusBadRequest, res.Code)
+		assert.Contains(t, res.Body.String(), "include_total must be a boolean")
+	})
+	t.Run("listing a page whose offset overflows", func(t *testing.T) {
+		// Create a new HTTP request
+		req, err := http.NewRequest("GET", fmt.Sprintf("/transactions?page_size=100&page=%d", math.MaxInt/100), nil)
+		assert.NoError(t, err)
+
+		// Set the request content type
+		req.Header.Set("Accept", "application/json")
+
+		// Create a new HTTP response recorder
+		res := httptest.NewRecorder()
+
+		// Serve the HTTP request
+		router.ServeHTTP(res, req)
+
+		// Assert the response status code
+		assert.Equal(t, http.StatusBadRequest, res.Code)
+		assert.Contains(t, res.Body.String(), "page is too large for page_size")
+	})
+}
+
+func Test_TransactionHandler_List_Filters(t *testing.T) {
+	s := setupService(t)
+	h := handler.NewTransactionHandler(s)
+
+	// Create a new Gin router
+	router := gin.Default()
+	router.GET("/transactions", h.List)
+
+	for _, amount := range []int64{300, -100, 500} {
+		opType := entities.CREDIT
+		if amount < 0 {
+			opType = entities.DEBIT
+		}
+		transaction, errs := entities.NewTransaction("desktop-web", "user123", amount, opType)
+		assert.Empty(t, errs)
+		_, err := s.TransactionRepository.Insert(context.Background(), transaction)
+		assert.NoError(t, err)
+	}
+
+	t.Run("listing transactions in an amount range sorted by amount", func(t *testing.T) {
+		// Create a new HTTP request
+		req, err := http.NewRequest("GET", "/transactions?min_amount=0&sort=-amount", nil)
+		assert.NoError(t, err)
+
+		// Set the request content type
+		req.Header.Set("Accept", "application/json")
+
+		// Create a new HTTP response recorder
+		res := httptest.NewRecorder()
+
+		// Serve the HTTP request
+		router.ServeHTTP(res, req)
+
+		// Assert the response status code
+		assert.Equal(t, http.StatusOK, res.Code)
+
+		// Assert the response body
+		var result struct {
+			Data []*dto.TransactionRes `json:"data"`
+		}
+		err = json.NewDecoder(res.Body).Decode(&result)
+		assert.NoError(t, err)
+		assert.Len(t, result.Data, 2)
+		assert.Equal(t, int64(500), result.Data[0].Amount)
+		assert.Equal(t, int64(300), result.Data[1].Amount)
+	})
+
+	t.Run("listing transactions with an invalid filter", func(t *testing.T) {
+		// Create a new HTTP request
+		req, err := http.NewRequest("GET", "/transactions?created_from=yesterday", nil)
+		assert.NoError(t, err)
+
+		// Set the request content type
+		req.Header.Set("Accept", "application/json")
+
+		// Create a new HTTP response recorder
+		res := httptest.NewRecorder()
+
+		// Serve the HTTP request
+		router.ServeHTTP(res, req)
+
+		// Assert the response status code
+		assert.Equal(t, http.StatusBadRequest, res.Code)
+
+		// Assert the response body
+		assert.Contains(t, res.Body.String(), "created_from must be a RFC 3339 timestamp or a date (YYYY-MM-DD)")
+	})
+}
+
+func Test_TransactionHandler_List_Cursor(t *testing.T) {
+	s := setupService(t)
+	h := handler.NewTransactionHandler(s)
+
+	// Create a new Gin router
+	router := gin.Default()
+	router.GET("/transactions", h.List)
+
+	for i := 0; i < 5; i++ {
+		// Create a new transaction with desktop-web origin
+		transaction, errs := entities.NewTransaction("desktop-web", "user123", 100, entities.CREDIT)
+		assert.Empty(t, errs)
+		_, err := s.TransactionRepository.Insert(context.Background(), transaction)
+		assert.NoError(t, err)
+	}
+
+	type page struct {
+		Data       []*dto.TransactionRes  `json:"data"`
+		Pagination *presenters.Pagination `json:"pagination"`
+	}
+	list := func(query string) page {
+		// Create a new HTTP request
+		req, err := http.NewRequest("GET", "/transactions?"+query, nil)
+		assert.NoError(t, err)
+
+		// Set the request content type
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", "application/json")
+
+		// Create a new HTTP response recorder
+		res := httptest.NewRecorder()
+
+		// Serve the HTTP request
+		router.ServeHTTP(res, req)
+
+		// Assert the response status code
+		assert.Equal(t, http.StatusOK, res.Code)
+
+		var result page
+		assert.NoError(t, json.NewDecoder(res.Body).Decode(&result))
+		return result
+	}
+
+	t.Run("walking through the pages with cursors", func(t *testing.T) {
+		first := list("cursor=&page_size=2")
+		assert.Len(t, first.Data, 2)
+		assert.NotEmpty(t, first.Pagination.NextCursor)
+		assert.Empty(t, first.Pagination.PrevCursor)
+
+		second := list("page_size=2&cursor=" + first.Pagination.NextCursor)
+		assert.Len(t, second.Data, 2)
+		assert.NotEmpty(t, second.Pagination.PrevCursor)
+
+		third := list("page_size=2&cursor=" + second.Pagination.NextCursor)
+		assert.Len(t, third.Data, 1)
+		assert.Empty(t, third.Pagination.NextCursor)
+
+		back := list("page_size=2&cursor=" + second.Pagination.PrevCursor)
+		assert.Equal(t, first.Data, back.Data)
+	})
+
+	t.Run("listing with an invalid cursor", func(t *testing.T) {
+		req, err := http.NewRequest("GET", "/transactions?cursor=invalid", nil)
+		assert.NoError(t, err)
+		req.Header.Set("Accept", "application/json")
+
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, req)
+
+		assert.Equal(t, http.StatusBadRequest, res.Code)
+		assert.Contains(t, res.Body.String(), "cursor is invalid")
+	})
+}
+
+func Test_TransactionHandler_Save_QueueFull(t *testing.T) {
+	s := setupService(t)
+	// the bulk writer is not running, so the queue is never drained
+	s.TransactionRepository.(*repositories.TransactionRepository).
+		WithBulkConfig(100, 3600).
+		WithBulkQueue(1, 0)
+	h := handler.NewTransactionHandler(s)
+
+	// Create a new Gin router
+	router := gin.Default()
+	router.POST("/transactions", h.Save)
+
+	send := func() *httptest.ResponseRecorder {
+		payload := `{
+			"origin": "desktop-web",
+			"user_id": "user123",
+			"amount": 200,
+			"type": "credit"
+		}`
+		req, err := http.NewRequest("POST", "/transactions", strings.NewReader(payload))
+		assert.NoError(t, err)
+
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", "application/json")
+
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, req)
+		return res
+	}
+
+	// Fill the queue
+	assert.Equal(t, http.StatusCreated, send().Code)
+
+	t.Run("shedding a transaction when the queue is full", func(t *testing.T) {
+		res := send()
+
+		// Assert the response status code and the retry hint
+		assert.Equal(t, http.StatusServiceUnavailable, res.Code)
+		assert.Equal(t, "1", res.Header().Get("Retry-After"))
+
+		// Assert the response body
+		assert.Equal(t, "application/problem+json; charset=utf-8", res.Header().Get("Content-Type"))
+		var result presenters.Problem
+		assert.NoError(t, json.NewDecoder(res.Body).Decode(&result))
+		assert.Equal(t, http.StatusServiceUnavailable, result.Status)
+		assert.Equal(t, "queue_full", result.Code)
+		assert.Equal(t, "the transaction queue is full, try again later", result.Detail)
+	})
+}
+
+func Test_TransactionHandler_Save_CommitSync(t *testing.T) {
+	s := setupService(t)
+	repo := s.TransactionRepository.(*repositories.TransactionRepository).WithBulkConfig(100, 0.05)
+	go repo.RunGroupTransactions()
+	h := handler.NewTransactionHandler(s)
+
+	// Create a new Gin router
+	router := gin.Default()
+	router.POST("/transactions", h.Save)
+
+	t.Run("saving a transaction waiting for the commit", func(t *testing.T) {
+		// Create a new HTTP request
+		payload := `{
+			"origin": "desktop-web",
+			"user_id": "user123",
+			"amount": 200,
+			"type": "credit"
+		}`
+		req, err := http.NewRequest("POST", "/transactions", strings.NewReader(payload))
+		assert.NoError(t, err)
+
+		// Set the request content type and the write consistency
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", "application/json")
+		req.Header.Set("Prefer", "return=representation, commit-sync")
+
+		// Create a new HTTP response recorder
+		res := httptest.NewRecorder()
+
+		// Serve the HTTP request
+		router.ServeHTTP(res, req)
+
+		// Assert the response status code and the applied preference
+		assert.Equal(t, http.StatusCreated, res.Code)
+		assert.Equal(t, "commit-sync", res.Header().Get("Preference-Applied"))
+
+		// Assert the transaction is already committed
+		var result struct {
+			Data dto.TransactionRes `json:"data"`
+		}
+		assert.NoError(t, json.NewDecoder(res.Body).Decode(&result))
+		var count int64
+		assert.NoError(t, repo.Db.Model(&entities.Transaction{}).Where("id = ?", result.Data.ID).Count(&count).Error)
+		assert.Equal(t, int64(1), count)
+	})
+
+	t.Run("saving a transaction with an unknown consistency", func(t *testing.T) {
+		// Create a new HTTP request
+		payload := `{
+			"origin": "desktop-web",
+			"user_id": "user123",
+			"amount": 200,
+			"type": "credit",
+			"consistency": "eventual"
+		}`
+		req, err := http.NewRequest("POST", "/transactions", strings.NewReader(payload))
+		assert.NoError(t, err)
+
+		// Set the request content type
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", "application/json")
+
+		// Create a new HTTP response recorder
+		res := httptest.NewRecorder()
+
+		// Serve the HTTP request
+		router.ServeHTTP(res, req)
+
+		// Assert the response status code
+		assert.Equal(t, http.StatusBadRequest, res.Code)
+		assert.Empty(t, res.Header().Get("Preference-Applied"))
+	})
+}
+
+func Test_TransactionHandler_Get_Pending(t *testing.T) {
+	s := setupService(t)
+	repo := s.TransactionRepository.(*repositories.TransactionRepository).WithBulkConfig(100, 3600)
+	// holds the transactions as the bulk buffer does, without committing them
+	go func() {
+		for range repo.InsertChan {
+		}
+	}()
+	h := handler.NewTransactionHandler(s)
+
+	// Create a new Gin router
+	router := gin.Default()
+	router.POST("/transactions", h.Save)
+	router.GET("/transactions/:id", h.Get)
+
+	t.Run("getting a transaction that was not committed yet", func(t *testing.T) {
+		// Create a new HTTP request
+		payload := `{
+			"origin": "desktop-web",
+			"user_id": "user123",
+			"amount": 200,
+			"type": "credit"
+		}`
+		req, err := http.NewRequest("POST", "/transactions", strings.NewReader(payload))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", "application/json")
+
+		// Serve the HTTP request
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, req)
+		assert.Equal(t, http.StatusCreated, res.Code)
+
+		var created struct {
+			Data dto.TransactionRes `json:"data"`
+		}
+		assert.NoError(t, json.NewDecoder(res.Body).Decode(&created))
+		assert.Equal(t, "pending", created.Data.CommitStatus)
+
+		// Get the transaction right after saving it
+		req, err = http.NewRequest("GET", "/transactions/"+created.Data.ID, nil)
+		assert.NoError(t, err)
+		req.Header.Set("Accept", "application/json")
+
+		res = httptest.NewRecorder()
+		router.ServeHTTP(res, req)
+
+		// Assert the transaction is found and marked as pending
+		assert.Equal(t, http.StatusOK, res.Code)
+		var found struct {
+			Data dto.TransactionRes `json:"data"`
+		}
+		assert.NoError(t, json.NewDecoder(res.Body).Decode(&found))
+		assert.Equal(t, created.Data.ID, found.Data.ID)
+		assert.Equal(t, "pending", found.Data.CommitStatus)
+	})
+}
+
+func Test_TransactionHandler_SaveBatch(t *testing.T) {
+	s := setupService(t)
+	db := s.TransactionRepository.(*repositories.TransactionRepository).Db
+	h := handler.NewTransactionHandler(s)
+
+	// Create a new Gin router
+	router := gin.Default()
+	router.POST("/transactions/batch", h.SaveBatch)
+
+	countTransactions := func(t *testing.T) int64 {
+		var count int64
+		assert.NoError(t, db.Model(&entities.Transaction{}).Count(&count).Error)
+		return count
+	}
+
+	t.Run("saving a JSON batch with an invalid transaction", func(t *testing.T) {
+		// Create a new HTTP request
+		payload := `[
+			{"origin": "desktop-web", "user_id": "user123", "amount": 200, "type": "credit"},
+			{"origin": "desktop-web", "user_id": "user123", "amount": 200, "type": "debit"},
+			{"origin": "desktop-web", "user_id": "user123", "amount": -50, "type": "debit"}
+		]`
+		req, err := http.NewRequest("POST", "/transactions/batch", strings.NewReader(payload))
+		assert.NoError(t, err)
+
+		// Set the request content type
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", "application/json")
+
+		// Create a new HTTP response recorder
+		res := httptest.NewRecorder()
+
+		// Serve the HTTP request
+		router.ServeHTTP(res, req)
+
+		// Assert the response status code
+		assert.Equal(t, http.StatusMultiStatus, res.Code)
+
+		// Assert the result of each transaction
+		var result struct {
+			Data dto.TransactionBatchRes `json:"data"`
+		}
+		assert.NoError(t, json.NewDecoder(res.Body).Decode(&result))
+		assert.Equal(t, 2, result.Data.Created)
+		assert.Equal(t, 1, result.Data.Failed)
+		assert.Equal(t, "created", result.Data.Items[0].Status)
+		assert.Equal(t, "failed", result.Data.Items[1].Status)
+		assert.Equal(t, []string{"Amount must be negative for debit transactions"}, result.Data.Items[1].Errors)
+		assert.Equal(t, int64(-50), result.Data.Items[2].Transaction.Amount)
+		assert.Equal(t, int64(2), countTransactions(t))
+	})
+
+	t.Run("saving a NDJSON batch", func(t *testing.T) {
+		before := countTransactions(t)
+
+		// Create a new HTTP request
+		payload := `{"origin": "desktop-web", "user_id": "user123", "amount": 200, "type": "credit"}
+
+{"origin": "mobile-android", "user_id": "user456", "amount": -75, "type": "debit"}
+`
+		req, err := http.NewRequest("POST", "/transactions/batch", strings.NewReader(payload))
+		assert.NoError(t, err)
+
+		// Set the request content type
+		req.Header.Set("Content-Type", "application/x-ndjson")
+		req.Header.Set("Accept", "application/json")
+
+		// Create a new HTTP response recorder
+		res := httptest.NewRecorder()
+
+		// Serve the HTTP request
+		router.ServeHTTP(res, req)
+
+		// Assert the response status code
+		assert.Equal(t, http.StatusCreated, res.Code)
+		assert.Equal(t, before+2, countTransactions(t))
+	})
+
+	t.Run("saving a XML batch", func(t *testing.T) {
+		before := countTransactions(t)
+
+		// Create a new HTTP request
+		payload := `<transactions>
+			<transaction><origin>desktop-web</origin><user_id>user123</user_id><amount>200</amount><type>credit</type></transaction>
+			<transaction><origin>desktop-web</origin><user_id>user123</user_id><amount>300</amount><type>credit</type></transaction>
+		</transactions>`
+		req, err := http.NewRequest("POST", "/transactions/batch", strings.NewReader(payload))
+		assert.NoError(t, err)
+
+		// Set the request content type
+		req.Header.Set("Content-Type", "application/xml")
+		req.Header.Set("Accept", "application/xml")
+
+		// Create a new HTTP response recorder
+		res := httptest.NewRecorder()
+
+		// Serve the HTTP request
+		router.ServeHTTP(res, req)
+
+		// Assert the response status code
+		assert.Equal(t, http.StatusCreated, res.Code)
+		assert.Contains(t, res.Body.String(), "<status>created</status>")
+		assert.Equal(t, before+2, countTransactions(t))
+	})
+
+	t.Run("saving an atomic batch with an invalid transaction", func(t *testing.T) {
+		before := countTransactions(t)
+
+		// Create a new HTTP request
+		payload := `[
+			{"origin": "desktop-web", "user_id": "user123", "amount": 200, "type": "credit"},
+			{"origin": "desktop-web", "user_id": "user123", "amount": 200, "type": "debit"}
+		]`
+		req, err := http.NewRequest("POST", "/transactions/batch?atomic=true", strings.NewReader(payload))
+		assert.NoError(t, err)
+
+		// Set the request content type
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", "application/json")
+
+		// Create a new HTTP response recorder
+		res := httptest.NewRecorder()
+
+		// Serve the HTTP request
+		router.ServeHTTP(res, req)
+
+		// Assert the response status code and that nothing was created
+		assert.Equal(t, http.StatusUnprocessableEntity, res.Code)
+		assert.Contains(t, res.Body.String(), services.ErrBatchAborted.Error())
+		assert.Equal(t, before, countTransactions(t))
+	})
+
+	t.Run("saving a malformed batch", func(t *testing.T) {
+		// Create a new HTTP request
+		payload := `{"origin": "desktop-web"}
+not json`
+		req, err := http.NewRequest("POST", "/transactions/batch", strings.NewReader(payload))
+		assert.NoError(t, err)
+
+		// Set the request content type
+		req.Header.Set("Content-Type", "application/x-ndjson")
+		req.Header.Set("Accept", "application/json")
+
+		// Create a new HTTP response recorder
+		res := httptest.NewRecorder()
+
+		// Serve the HTTP request
+		router.ServeHTTP(res, req)
+
+		// Assert the response status code
+		assert.Equal(t, http.StatusBadRequest, res.Code)
+		assert.Contains(t, res.Body.String(), "line 2")
+	})
+
+	t.Run("saving a batch over the max size", func(t *testing.T) {
+		before := countTransactions(t)
+		defer func(size int) { s.MaxBatchSize = size }(s.MaxBatchSize)
+		s.MaxBatchSize = 2
+
+		transaction := `{"origin": "desktop-web", "user_id": "user123", "amount": 200, "type": "credit"}`
+		element := `<transaction><origin>desktop-web</origin><user_id>user123</user_id><amount>200</amount><type>credit</type></transaction>`
+		payloads := map[string]string{
+			"application/json":     "[" + strings.Repeat(transaction+",", 2) + transaction + "]",
+			"application/x-ndjson": strings.Repeat(transaction+"\n", 3),
+			"application/xml":      "<transactions>" + strings.Repeat(element, 3) + "</transactions>",
+		}
+		for contentType, payload := range payloads {
+			// Create a new HTTP request
+			req, err := http.NewRequest("POST", "/transactions/batch", strings.NewReader(payload))
+			assert.NoError(t, err)
+
+			// Set the request content type
+			req.Header.Set("Content-Type", contentType)
+			req.Header.Set("Accept", "application/json")
+
+			// Create a new HTTP response recorder
+			res := httptest.NewRecorder()
+
+			// Serve the HTTP request
+			router.ServeHTTP(res, req)
+
+			// Assert the response status code and body
+			assert.Equal(t, http.StatusBadRequest, res.Code, contentType)
+			assert.Contains(t, res.Body.String(), `"code":"invalid_batch_size"`, contentType)
+			assert.Contains(t, res.Body.String(), "at most 2 transactions", contentType)
+		}
+		assert.Equal(t, before, countTransactions(t))
+	})
+
+	t.Run("saving a batch with a body over the max size", func(t *testing.T) {
+		defer func(size int) { s.MaxBatchSize = size }(s.MaxBatchSize)
+		s.MaxBatchSize = 1
+
+		// Create a new HTTP request, a single transaction with a metadata of 2 MB
+		payload := `[{"origin": "desktop-web", "user_id": "user123", "amount": 200, "type": "credit", "metadata": {"note": "` +
+			strings.Repeat("a", 2*1024*1024) + `"}}]`
+		req, err := http.NewRequest("POST", "/transactions/batch", strings.NewReader(payload))
+		assert.NoError(t, err)
+
+		// Set the request content type
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", "application/json")
+
+		// Create a new HTTP response recorder
+		res := httptest.NewRecorder()
+
+		// Serve the HTTP request
+		router.ServeHTTP(res, req)
+
+		// Assert the response status code and body
+		assert.Equal(t, http.StatusBadRequest, res.Code)
+		assert.Contains(t, res.Body.String(), `"code":"invalid_batch_size"`)
+		assert.Contains(t, res.Body.String(), "at most 1048576 bytes")
+	})
+}
+
+func Test_TransactionHandler_Export(t *testing.T) {
+	s := setupService(t)
+	db := s.TransactionRepository.(*repositories.TransactionRepository).Db
+	h := handler.NewTransactionHandler(s)
+
+	// Create a new Gin router
+	router := gin.Default()
+	router.GET("/transactions/export", h.Export)
+
+	// Insert the transactions to export
+	var transactions []*entities.Transaction
+	for i, origin := range []string{"desktop-web", "=cmd", "mobile-android"} {
+		transaction, errs := entities.NewTransaction(origin, "user123", int64(100*(i+1)), entities.CREDIT)
+		assert.Empty(t, errs)
+		assert.NoError(t, db.Create(transaction).Error)
+		transactions = append(transactions, transaction)
+	}
+
+	t.Run("exporting the transactions as CSV", func(t *testing.T) {
+		// Create a new HTTP request
+		req, err := http.NewRequest("GET", "/transactions/export?sort=-amount", nil)
+		assert.NoError(t, err)
+		req.Header.Set("Accept", "text/csv")
+
+		// Create a new HTTP response recorder
+		res := httptest.NewRecorder()
+
+		// Serve the HTTP request
+		router.ServeHTTP(res, req)
+
+		// Assert the response status code and headers
+		assert.Equal(t, http.StatusOK, res.Code)
+		assert.Equal(t, "text/csv", res.Header().Get("Content-Type"))
+		assert.Contains(t, res.Header().Get("Content-Disposition"), "transactions.csv")
+
+		// Assert the rows, the formula is escaped
+		lines := strings.Split(strings.TrimSpace(res.Body.String()), "\n")
+		assert.Len(t, lines, 4)
+		assert.Equal(t, "id,origin,user_id,amount,currency,type,status,reversal_of,created_at", lines[0])
+		assert.True(t, strings.HasPrefix(lines[1], transactions[2].ID.String()+",mobile-android,user123,300,BRL,credit,posted,,"))
+		assert.True(t, strings.HasPrefix(lines[2], transactions[1].ID.String()+",'=cmd,user123,200,BRL,credit,posted,,"))
+	})
+
+	t.Run("exporting the transactions of a filter as NDJSON", func(t *testing.T) {
+		// Create a new HTTP request
+		req, err := http.NewRequest("GET", "/transactions/export?origin=desktop-web", nil)
+		assert.NoError(t, err)
+		req.Header.Set("Accept", "application/x-ndjson")
+
+		// Create a new HTTP response recorder
+		res := httptest.NewRecorder()
+
+		// Serve the HTTP request
+		router.ServeHTTP(res, req)
+
+		// Assert the response status code and the transactions
+		assert.Equal(t, http.StatusOK, res.Code)
+		assert.Equal(t, "application/x-ndjson", res.Header().Get("Content-Type"))
+		lines := strings.Split(strings.TrimSpace(res.Body.String()), "\n")
+		assert.Len(t, lines, 1)
+		var exported dto.TransactionRes
+		assert.NoError(t, json.Unmarshal([]byte(lines[0]), &exported))
+		assert.Equal(t, transactions[0].ID.String(), exported.ID)
+	})
+
+	t.Run("exporting no transactions as CSV", func(t *testing.T) {
+		// Create a new HTTP request
+		req, err := http.NewRequest("GET", "/transactions/export?origin=unknown", nil)
+		assert.NoError(t, err)
+
+		// Create a new HTTP response recorder
+		res := httptest.NewRecorder()
+
+		// Serve the HTTP request
+		router.ServeHTTP(res, req)
+
+		// Assert only the header is exported, CSV is the default format
+		assert.Equal(t, http.StatusOK, res.Code)
+		assert.Equal(t, "id,origin,user_id,amount,currency,type,status,reversal_of,created_at\n", res.Body.String())
+	})
+
+	t.Run("exporting with an invalid filter", func(t *testing.T) {
+		// Create a new HTTP request
+		req, err := http.NewRequest("GET", "/transactions/export?type=refund", nil)
+		assert.NoError(t, err)
+		req.Header.Set("Accept", "text/csv")
+
+		// Create a new HTTP response recorder
+		res := httptest.NewRecorder()
+
+		// Serve the HTTP request
+		router.ServeHTTP(res, req)
+
+		// Assert the response status code
+		assert.Equal(t, http.StatusBadRequest, res.Code)
+		assert.Contains(t, res.Body.String(), "type must be one of [debit credit]")
+	})
+
+	t.Run("exporting in a format not supported", func(t *testing.T) {
+		// Create a new HTTP request
+		req, err := http.NewRequest("GET", "/transactions/export", nil)
+		assert.NoError(t, err)
+		req.Header.Set("Accept", "application/pdf")
+
+		// Create a new HTTP response recorder
+		res := httptest.NewRecorder()
+
+		// Serve the HTTP request
+		router.ServeHTTP(res, req)
+
+		// Assert the response status code
+		assert.Equal(t, http.StatusNotAcceptable, res.Code)
+	})
+
+	t.Run("exporting to a client that disconnected", func(t *testing.T) {
+		// Create a new HTTP request with its context already canceled
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		req, err := http.NewRequestWithContext(ctx, "GET", "/transactions/export", nil)
+		assert.NoError(t, err)
+		req.Header.Set("Accept", "text/csv")
+
+		// Create a new HTTP response recorder
+		res := httptest.NewRecorder()
+
+		// Serve the HTTP request
+		router.ServeHTTP(res, req)
+
+		// Assert nothing was written
+		assert.Empty(t, res.Body.String())
+	})
+}
+
+func Test_TransactionHandler_Balance_Currencies(t *testing.T) {
+	s := setupService(t)
+	h := handler.NewTransactionHandler(s)
+
+	// Create a new Gin router
+	router := gin.Default()
+	router.POST("/transactions", h.Save)
+	router.GET("/users/:user_id/balance", h.Balance)
+	router.GET("/users/:user_id/balances", h.Balances)
+
+	for _, payload := range []string{
+		`{"origin": "desktop-web", "user_id": "user123", "amount": 500, "type": "credit", "currency": "BRL"}`,
+		`{"origin": "desktop-web", "user_id": "user123", "amount": 300, "type": "credit", "currency": "USD", "exponent": 2}`,
+		`{"origin": "desktop-web", "user_id": "user123", "amount": -100, "type": "debit", "currency": "USD"}`,
+	} {
+		// Create the transaction in its currency
+		req, err := http.NewRequest("POST", "/transactions", strings.NewReader(payload))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, req)
+		assert.Equal(t, http.StatusCreated, res.Code)
+	}
+
+	t.Run("getting the balance mixing currencies", func(t *testing.T) {
+		// Create a new HTTP request
+		req, err := http.NewRequest("GET", "/users/user123/balance", nil)
+		assert.NoError(t, err)
+		req.Header.Set("Accept", "application/json")
+
+		// Create a new HTTP response recorder
+		res := httptest.NewRecorder()
+
+		// Serve the HTTP request
+		router.ServeHTTP(res, req)
+
+		// Assert the amounts of different currencies are not added up
+		assert.Equal(t, http.StatusBadRequest, res.Code)
+		assert.Contains(t, res.Body.String(), "more than one currency")
+	})
+
+	t.Run("getting the balance of a currency", func(t *testing.T) {
+		// Create a new HTTP request
+		req, err := http.NewRequest("GET", "/users/user123/balance?currency=USD", nil)
+		assert.NoError(t, err)
+		req.Header.Set("Accept", "application/json")
+
+		// Create a new HTTP response recorder
+		res := httptest.NewRecorder()
+
+		// Serve the HTTP request
+		router.ServeHTTP(res, req)
+
+		// Assert the response status code and body
+		assert.Equal(t, http.StatusOK, res.Code)
+		var result struct {
+			Data dto.BalanceRes `json:"data"`
+		}
+		assert.NoError(t, json.NewDecoder(res.Body).Decode(&result))
+		assert.Equal(t, "USD", result.Data.Currency)
+		assert.Equal(t, int64(200), result.Data.Balance)
+	})
+
+	t.Run("getting the balances of all the currencies", func(t *testing.T) {
+		// Create a new HTTP request
+		req, err := http.NewRequest("GET", "/users/user123/balances", nil)
+		assert.NoError(t, err)
+		req.Header.Set("Accept", "application/json")
+
+		// Create a new HTTP response recorder
+		res := httptest.NewRecorder()
+
+		// Serve the HTTP request
+		router.ServeHTTP(res, req)
+
+		// Assert the response status code and body
+		assert.Equal(t, http.StatusOK, res.Code)
+		var result struct {
+			Data []dto.BalanceRes `json:"data"`
+		}
+		assert.NoError(t, json.NewDecoder(res.Body).Decode(&result))
+		assert.Len(t, result.Data, 2)
+		assert.Equal(t, "BRL", result.Data[0].Currency)
+		assert.Equal(t, int64(500), result.Data[0].Balance)
+		assert.Equal(t, "USD", result.Data[1].Currency)
+		assert.Equal(t, int64(200), result.Data[1].Balance)
+	})
+}
+
+func Test_TransactionHandler_Holds(t *testing.T) {
+	s := setupService(t)
+	h := handler.NewTransactionHandler(s)
+
+	// Create a new Gin router
+	router := gin.Default()
+	router.POST("/transactions", h.Save)
+	router.POST("/transactions/:id/capture", h.Capture)
+	router.POST("/transactions/:id/void", h.Void)
+	router.GET("/users/:user_id/balance", h.Balance)
+
+	// Create a credit and two holds
+	credit, errs := entities.NewTransaction("desktop-web", "user123", 1000, entities.CREDIT)
+	assert.Empty(t, errs)
+	assert.NoError(t, s.TransactionRepository.InsertMany(context.Background(), []*entities.Transaction{credit}))
+
+	holds := make([]dto.TransactionRes, 2)
+	for i, payload := range []string{
+		`{"origin": "desktop-web", "user_id": "user123", "amount": -300, "type": "debit", "hold": true}`,
+		`{"origin": "desktop-web", "user_id": "user123", "amount": -200, "type": "debit", "hold": true}`,
+	} {
+		req, err := http.NewRequest("POST", "/transactions", strings.NewReader(payload))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, req)
+		assert.Equal(t, http.StatusCreated, res.Code)
+
+		var result struct {
+			Data dto.TransactionRes `json:"data"`
+		}
+		assert.NoError(t, json.NewDecoder(res.Body).Decode(&result))
+		assert.Equal(t, "pending", result.Data.Status)
+		assert.NotNil(t, result.Data.ExpiresAt)
+		holds[i] = result.Data
+	}
+
+	getBalance := func(t *testing.T) dto.BalanceRes {
+		req, err := http.NewRequest("GET", "/users/user123/balance", nil)
+		assert.NoError(t, err)
+		req.Header.Set("Accept", "application/json")
+
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, req)
+		assert.Equal(t, http.StatusOK, res.Code)
+
+		var result struct {
+			Data dto.BalanceRes `json:"data"`
+		}
+		assert.NoError(t, json.NewDecoder(res.Body).Decode(&result))
+		return result.Data
+	}
+
+	t.Run("getting the available balance of a user with holds", func(t *testing.T) {
+		balance := getBalance(t)
+		assert.Equal(t, int64(1000), balance.Balance)
+		assert.Equal(t, int64(500), balance.Held)
+		assert.Equal(t, int64(500), balance.Available)
+	})
+
+	t.Run("capturing a hold", func(t *testing.T) {
+		// Create a new HTTP request
+		req, err := http.NewRequest("POST", fmt.Sprintf("/transactions/%s/capture", holds[0].ID), nil)
+		assert.NoError(t, err)
+		req.Header.Set("Accept", "application/json")
+
+		// Create a new HTTP response recorder
+		res := httptest.NewRecorder()
+
+		// Serve the HTTP request
+		router.ServeHTTP(res, req)
+
+		// Assert the response status code and body
+		assert.Equal(t, http.StatusOK, res.Code)
+		var result struct {
+			Data dto.TransactionRes `json:"data"`
+		}
+		assert.NoError(t, json.NewDecoder(res.Body).Decode(&result))
+		assert.Equal(t, "posted", result.Data.Status)
+
+		balance := getBalance(t)
+		assert.Equal(t, int64(700), balance.Balance)
+		assert.Equal(t, int64(200), balance.Held)
+		assert.Equal(t, int64(500), balance.Available)
+	})
+
+	t.Run("voiding a hold accepting XML", func(t *testing.T) {
+		// Create a new HTTP request
+		req, err := http.NewRequest("POST", fmt.Sprintf("/transactions/%s/void", holds[1].ID), nil)
+		assert.NoError(t, err)
+		req.Header.Set("Accept", "application/xml")
+
+		// Create a new HTTP response recorder
+		res := httptest.NewRecorder()
+
+		// Serve the HTTP request
+		router.ServeHTTP(res, req)
+
+		// Assert the response status code and body
+		assert.Equal(t, http.StatusOK, res.Code)
+		var result struct {
+			Data dto.TransactionRes `xml:"transaction"`
+		}
+		assert.NoError(t, xml.NewDecoder(res.Body).Decode(&result))
+		assert.Equal(t, "voided", result.Data.Status)
+
+		balance := getBalance(t)
+		assert.Equal(t, int64(700), balance.Balance)
+		assert.Equal(t, int64(0), balance.Held)
+		assert.Equal(t, int64(700), balance.Available)
+	})
+
+	t.Run("capturing a hold already voided", func(t *testing.T) {
+		// Create a new HTTP request
+		req, err := http.NewRequest("POST", fmt.Sprintf("/transactions/%s/capture", holds[1].ID), nil)
+		assert.NoError(t, err)
+		req.Header.Set("Accept", "application/json")
+
+		// Create a new HTTP response recorder
+		res := httptest.NewRecorder()
+
+		// Serve the HTTP request
+		router.ServeHTTP(res, req)
+
+		// Assert the response status code and body
+		assert.Equal(t, http.StatusConflict, res.Code)
+		assert.Contains(t, res.Body.String(), entities.ErrNotPending.Error())
+	})
+
+	t.Run("holding a credit", func(t *testing.T) {
+		// Create a new HTTP request
+		payload := `{"origin": "desktop-web", "user_id": "user123", "amount": 300, "type": "credit", "hold": true}`
+		req, err := http.NewRequest("POST", "/transactions", strings.NewReader(payload))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", "application/json")
+
+		// Create a new HTTP response recorder
+		res := httptest.NewRecorder()
+
+		// Serve the HTTP request
+		router.ServeHTTP(res, req)
+
+		// Assert the response status code
+		assert.Equal(t, http.StatusBadRequest, res.Code)
+	})
+}
+
+func Test_TransactionHandler_Save_Metadata(t *testing.T) {
+	s := setupService(t)
+	h := handler.NewTransactionHandler(s)
+
+	// Create a new Gin router
+	router := gin.Default()
+	router.POST("/transactions", h.Save)
+	router.GET("/transactions", h.List)
+
+	payload := `{
+		"origin": "desktop-web",
+		"user_id": "user123",
+		"amount": 200,
+		"type": "credit",
+		"external_reference": "order-123",
+		"metadata": {"order_id": "123", "description": "Monthly plan"}
+	}`
+
+	t.Run("saving a transaction with metadata and an external reference", func(t *testing.T) {
+		// Create a new HTTP request
+		req, err := http.NewRequest("POST", "/transactions", strings.NewReader(payload))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", "application/json")
+
+		// Create a new HTTP response recorder
+		res := httptest.NewRecorder()
+
+		// Serve the HTTP request
+		router.ServeHTTP(res, req)
+
+		// Assert the response status code and body
+		assert.Equal(t, http.StatusCreated, res.Code)
+		var result struct {
+			Data dto.TransactionRes `json:"data"`
+		}
+		assert.NoError(t, json.NewDecoder(res.Body).Decode(&result))
+		assert.Equal(t, "order-123", result.Data.ExternalReference)
+		assert.Equal(t, dto.Metadata{"order_id": "123", "description": "Monthly plan"}, result.Data.Metadata)
+	})
+
+	t.Run("saving a duplicate external reference", func(t *testing.T) {
+		// Create a new HTTP request
+		req, err := http.NewRequest("POST", "/transactions", strings.NewReader(payload))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", "application/json")
+
+		// Create a new HTTP response recorder
+		res := httptest.NewRecorder()
+
+		// Serve the HTTP request
+		router.ServeHTTP(res, req)
+
+		// Assert the response status code and body
+		assert.Equal(t, http.StatusConflict, res.Code)
+		assert.Contains(t, res.Body.String(), entities.ErrDuplicateExternalReference.Error())
+	})
+
+	t.Run("saving a transaction with metadata in XML format", func(t *testing.T) {
+		// Create a new HTTP request
+		payload := `<transaction>
+			<origin>mobile-android</origin>
+			<user_id>user123</user_id>
+			<amount>100</amount>
+			<type>credit</type>
+			<metadata><entry key="order_id">456</entry></metadata>
+		</transaction>`
+		req, err := http.NewRequest("POST", "/transactions", strings.NewReader(payload))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/xml")
+		req.Header.Set("Accept", "application/xml")
+
+		// Create a new HTTP response recorder
+		res := httptest.NewRecorder()
+
+		// Serve the HTTP request
+		router.ServeHTTP(res, req)
+
+		// Assert the response status code and body
+		assert.Equal(t, http.StatusCreated, res.Code)
+		assert.Contains(t, res.Body.String(), `<metadata><entry key="order_id">456</entry></metadata>`)
+	})
+
+	t.Run("listing transactions by metadata", func(t *testing.T) {
+		// Create a new HTTP request, the transaction still in the bulk buffer is filtered too
+		req, err := http.NewRequest("GET", "/transactions?metadata.order_id=123", nil)
+		assert.NoError(t, err)
+		req.Header.Set("Accept", "application/json")
+
+		// Create a new HTTP response recorder
+		res := httptest.NewRecorder()
+
+		// Serve the HTTP request
+		router.ServeHTTP(res, req)
+
+		// Assert the response status code and body
+		assert.Equal(t, http.StatusOK, res.Code)
+		var result struct {
+			Data []dto.TransactionRes `json:"data"`
+		}
+		assert.NoError(t, json.NewDecoder(res.Body).Decode(&result))
+		assert.Len(t, result.Data, 1)
+		assert.Equal(t, "order-123", result.Data[0].ExternalReference)
+	})
+}
+
+func Test_TransactionHandler_Summary(t *testing.T) {
+	s := setupService(t)
+	h := handler.NewTransactionHandler(s)
+
+	// Create a new Gin router
+	router := gin.Default()
+	router.GET("/transactions/summary", h.Summary)
+
+	// Create the transactions of two origins
+	var transactions []*entities.Transaction
+	for _, origin := range []string{"desktop-web", "desktop-web", "mobile-android"} {
+		transaction, errs := entities.NewTransaction(origin, "user123", 100, entities.CREDIT)
+		assert.Empty(t, errs)
+		transactions = append(transactions, transaction)
+	}
+	assert.NoError(t, s.TransactionRepository.InsertMany(context.Background(), transactions))
+
+	t.Run("summarizing the transactions by origin", func(t *testing.T) {
+		// Create a new HTTP request
+		req, err := http.NewRequest("GET", "/transactions/summary?group_by=origin&interval=day", nil)
+		assert.NoError(t, err)
+		req.Header.Set("Accept", "application/json")
+
+		// Create a new HTTP response recorder
+		res := httptest.NewRecorder()
+
+		// Serve the HTTP request
+		router.ServeHTTP(res, req)
+
+		// Assert the response status code and body
+		assert.Equal(t, http.StatusOK, res.Code)
+		var result struct {
+			Data []dto.TransactionSummaryRes `json:"data"`
+		}
+		assert.NoError(t, json.NewDecoder(res.Body).Decode(&result))
+		assert.Len(t, result.Data, 2)
+		assert.Equal(t, "desktop-web", result.Data[0].Origin)
+		assert.Equal(t, int64(2), result.Data[0].Count)
+		assert.Equal(t, int64(200), result.Data[0].Total)
+		assert.Equal(t, "mobile-android", result.Data[1].Origin)
+		assert.NotNil(t, result.Data[1].Period)
+	})
+
+	t.Run("summarizing the transactions accepting XML", func(t *testing.T) {
+		// Create a new HTTP request
+		req, err := http.NewRequest("GET", "/transactions/summary?origin=mobile-android", nil)
+		assert.NoError(t, err)
+		req.Header.Set("Accept", "application/xml")
+
+		// Create a new HTTP response recorder
+		res := httptest.NewRecorder()
+
+		// Serve the HTTP request
+		router.ServeHTTP(res, req)
+
+		// Assert the response status code and body
+		assert.Equal(t, http.StatusOK, res.Code)
+		var result struct {
+			Data []dto.TransactionSummaryRes `xml:"summary"`
+		}
+		assert.NoError(t, xml.NewDecoder(res.Body).Decode(&result))
+		assert.Len(t, result.Data, 1)
+		assert.Equal(t, int64(1), result.Data[0].Count)
+		assert.Nil(t, result.Data[0].Period)
+	})
+
+	t.Run("summarizing with an invalid group", func(t *testing.T) {
+		// Create a new HTTP request
+		req, err := http.NewRequest("GET", "/transactions/summary?group_by=amount", nil)
+		assert.NoError(t, err)
+		req.Header.Set("Accept", "application/json")
+
+		// Create a new HTTP response recorder
+		res := httptest.NewRecorder()
+
+		// Serve the HTTP request
+		router.ServeHTTP(res, req)
+
+		// Assert the response status code
+		assert.Equal(t, http.StatusBadRequest, res.Code)
+	})
+}
+
+func Test_TransactionHandler_Problem(t *testing.T) {
+	s := setupService(t)
+	h := handler.NewTransactionHandler(s)
+
+	// Create a new Gin router
+	router := gin.Default()
+	router.POST("/transactions", h.Save)
+	router.GET("/transactions", h.List)
+
+	t.Run("describing the invalid fields", func(t *testing.T) {
+		// Create a new HTTP request
+		payload := `{"origin": "desktop-web", "amount": 200, "type": "debit"}`
+		req, err := http.NewRequest("POST", "/transactions", strings.NewReader(payload))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", "application/json")
+
+		// Create a new HTTP response recorder
+		res := httptest.NewRecorder()
+
+		// Serve the HTTP request
+		router.ServeHTTP(res, req)
+
+		// Assert the response status code and body
+		assert.Equal(t, http.StatusBadRequest, res.Code)
+		assert.Equal(t, "application/problem+json; charset=utf-8", res.Header().Get("Content-Type"))
+		var result presenters.Problem
+		assert.NoError(t, json.NewDecoder(res.Body).Decode(&result))
+		assert.Equal(t, "about:blank", result.Type)
+		assert.Equal(t, "Bad Request", result.Title)
+		assert.Equal(t, http.StatusBadRequest, result.Status)
+		assert.Equal(t, "/transactions", result.Instance)
+		assert.Equal(t, "amount_sign_mismatch", result.Code)
+		assert.Equal(t, []*presenters.ProblemError{
+			{Code: "amount_sign_mismatch", Detail: "Amount must be negative for debit transactions", Pointer: "/amount"},
+			{Code: "required", Detail: "UserID is a required field", Pointer: "/user_id"},
+		}, result.Errors)
+	})
+
+	t.Run("describing the errors accepting XML", func(t *testing.T) {
+		// Create a new HTTP request
+		req, err := http.NewRequest("GET", "/transactions?sort=origin", nil)
+		assert.NoError(t, err)
+		req.Header.Set("Accept", "application/xml")
+
+		// Create a new HTTP response recorder
+		res := httptest.NewRecorder()
+
+		// Serve the HTTP request
+		router.ServeHTTP(res, req)
+
+		// Assert the response status code and body
+		assert.Equal(t, http.StatusBadRequest, res.Code)
+		assert.Equal(t, "application/problem+xml; charset=utf-8", res.Header().Get("Content-Type"))
+		assert.Contains(t, res.Body.String(), `<problem xmlns="urn:ietf:rfc:7807">`)
+		var result presenters.Problem
+		assert.NoError(t, xml.NewDecoder(res.Body).Decode(&result))
+		assert.Equal(t, "invalid_parameter", result.Code)
+		if assert.Len(t, result.Errors, 1) {
+			assert.Equal(t, "sort", result.Errors[0].Parameter)
+		}
+	})
+
+	t.Run("describing a body that can't be decoded", func(t *testing.T) {
+		// Create a new HTTP request
+		req, err := http.NewRequest("POST", "/transactions", strings.NewReader(`{"amount": "200"`))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", "application/problem+json")
+
+		// Create a new HTTP response recorder
+		res := httptest.NewRecorder()
+
+		// Serve the HTTP request
+		router.ServeHTTP(res, req)
+
+		// Assert the response status code and body, the headers as they were when the status was written
+		assert.Equal(t, http.StatusBadRequest, res.Code)
+		assert.Equal(t, "application/problem+json; charset=utf-8", res.Result().Header.Get("Content-Type"))
+		var result presenters.Problem
+		assert.NoError(t, json.NewDecoder(res.Body).Decode(&result))
+		assert.Equal(t, "invalid_body", result.Code)
+	})
+
+	t.Run("hiding the database errors", func(t *testing.T) {
+		sqlDB, err := s.TransactionRepository.(*repositories.TransactionRepository).Db.DB()
+		assert.NoError(t, err)
+		assert.NoError(t, sqlDB.Close())
+
+		// Create a new HTTP request
+		req, err := http.NewRequest("GET", "/transactions", nil)
+		assert.NoError(t, err)
+		req.Header.Set("Accept", "application/json")
+
+		// Create a new HTTP response recorder
+		res := httptest.NewRecorder()
+
+		// Serve the HTTP request
+		router.ServeHTTP(res, req)
+
+		// Assert the response status code and body
+		assert.Equal(t, http.StatusInternalServerError, res.Code)
+		assert.NotContains(t, res.Body.String(), "database is closed")
+		var result presenters.Problem
+		assert.NoError(t, json.NewDecoder(res.Body).Decode(&result))
+		assert.Equal(t, "internal_error", result.Code)
+		assert.Equal(t, "an unexpected error occurred", result.Detail)
+	})
+}
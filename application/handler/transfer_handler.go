@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"net/http"
+	"user-transactions/application/dto"
+	"user-transactions/application/presenters"
+	"user-transactions/core/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TransferHandler struct {
+	TransferService *services.TransferService
+}
+
+func NewTransferHandler(transferService *services.TransferService) *TransferHandler {
+	return &TransferHandler{TransferService: transferService}
+}
+
+func (th *TransferHandler) Save(c *gin.Context) {
+	req := &dto.CreateTransferReq{}
+	if err := c.ShouldBind(&req); err != nil {
+		problem(c, invalidBody(err))
+		return
+	}
+
+	transfer, errs := th.TransferService.CreateTransfer(c, req)
+	if len(errs) > 0 {
+		problem(c, errs...)
+		return
+	}
+
+	c.Negotiate(http.StatusCreated, gin.Negotiate{
+		Offered: []string{"application/json", "application/xml"},
+		Data:    presenters.TransformDataToApiFormat(transfer),
+	})
+}
+
+func (th *TransferHandler) Get(c *gin.Context) {
+	id := c.Param("id")
+
+	transfer, err := th.TransferService.GetTransfer(c, id)
+	if err != nil {
+		problem(c, err)
+		return
+	}
+
+	c.Negotiate(http.StatusOK, gin.Negotiate{
+		Offered: []string{"application/json", "application/xml"},
+		Data:    presenters.TransformDataToApiFormat(transfer),
+	})
+}
@@ -0,0 +1,196 @@
+//go:build integration
+// +build integration
+
+package handler_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"user-transactions/application/dto"
+	"user-transactions/application/handler"
+	"user-transactions/application/presenters"
+	"user-transactions/core/entities"
+	"user-transactions/core/services"
+	"user-transactions/infrastructure/repositories"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_TransferHandler(t *testing.T) {
+	db := setupService(t).TransactionRepository.(*repositories.TransactionRepository).Db
+	s, err := services.NewTransferService(repositories.NewTransferRepository(db))
+	assert.NoError(t, err)
+	h := handler.NewTransferHandler(s)
+
+	// Create a new Gin router
+	router := gin.Default()
+	router.POST("/transfers", h.Save)
+	router.GET("/transfers/:id", h.Get)
+
+	var created dto.TransferRes
+
+	t.Run("saving a transfer with valid payload", func(t *testing.T) {
+		// Create a new HTTP request
+		payload := `{
+			"origin": "desktop-web",
+			"from_user_id": "user123",
+			"to_user_id": "user456",
+			"amount": 500,
+			"currency": "EUR"
+		}`
+		req, err := http.NewRequest("POST", "/transfers", strings.NewReader(payload))
+		assert.NoError(t, err)
+
+		// Set the request content type
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", "application/json")
+
+		// Create a new HTTP response recorder
+		res := httptest.NewRecorder()
+
+		// Serve the HTTP request
+		router.ServeHTTP(res, req)
+
+		// Assert the response status code
+		assert.Equal(t, http.StatusCreated, res.Code)
+
+		// Assert the response body
+		var result struct {
+			Data dto.TransferRes `json:"data"`
+		}
+		assert.NoError(t, json.NewDecoder(res.Body).Decode(&result))
+		created = result.Data
+		assert.NotEmpty(t, created.ID)
+		assert.Equal(t, "EUR", created.Currency)
+		assert.Equal(t, "user123", created.Debit.UserID)
+		assert.Equal(t, int64(-500), created.Debit.Amount)
+		assert.Equal(t, "user456", created.Credit.UserID)
+		assert.Equal(t, int64(500), created.Credit.Amount)
+		assert.Equal(t, "committed", created.Credit.CommitStatus)
+	})
+
+	t.Run("getting the transfer", func(t *testing.T) {
+		// Create a new HTTP request
+		req, err := http.NewRequest("GET", "/transfers/"+created.ID, nil)
+		assert.NoError(t, err)
+
+		// Set the request content type
+		req.Header.Set("Content-Type", "application/xml")
+		req.Header.Set("Accept", "application/xml")
+
+		// Create a new HTTP response recorder
+		res := httptest.NewRecorder()
+
+		// Serve the HTTP request
+		router.ServeHTTP(res, req)
+
+		// Assert the response status code and body
+		assert.Equal(t, http.StatusOK, res.Code)
+		assert.Contains(t, res.Body.String(), "<id>"+created.ID+"</id>")
+		assert.Contains(t, res.Body.String(), "<from_user_id>user123</from_user_id>")
+		assert.Contains(t, res.Body.String(), "<transfer_id>"+created.ID+"</transfer_id>")
+	})
+
+	t.Run("getting a transfer by invalid ID", func(t *testing.T) {
+		// Create a new HTTP request
+		req, err := http.NewRequest("GET", "/transfers/invalid", nil)
+		assert.NoError(t, err)
+
+		// Set the request content type
+		req.Header.Set("Accept", "application/json")
+
+		// Create a new HTTP response recorder
+		res := httptest.NewRecorder()
+
+		// Serve the HTTP request
+		router.ServeHTTP(res, req)
+
+		// Assert the response status code and body
+		assert.Equal(t, http.StatusBadRequest, res.Code)
+		var result presenters.Problem
+		assert.NoError(t, json.NewDecoder(res.Body).Decode(&result))
+		assert.Equal(t, "invalid_id", result.Code)
+		assert.Equal(t, "the id of a transfer must be a UUID", result.Detail)
+	})
+
+	t.Run("saving a transfer to the same user", func(t *testing.T) {
+		// Create a new HTTP request
+		payload := `{
+			"origin": "desktop-web",
+			"from_user_id": "user123",
+			"to_user_id": "user123",
+			"amount": 500
+		}`
+		req, err := http.NewRequest("POST", "/transfers", strings.NewReader(payload))
+		assert.NoError(t, err)
+
+		// Set the request content type
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", "application/json")
+
+		// Create a new HTTP response recorder
+		res := httptest.NewRecorder()
+
+		// Serve the HTTP request
+		router.ServeHTTP(res, req)
+
+		// Assert the response status code
+		assert.Equal(t, http.StatusBadRequest, res.Code)
+		assert.Contains(t, res.Body.String(), "a transfer must be between different users")
+	})
+
+	t.Run("saving a transfer without funds", func(t *testing.T) {
+		s.WithOverdraftPolicy(entities.NewOverdraftPolicy(nil, map[string]int64{"user789": 0}, nil))
+		defer s.WithOverdraftPolicy(nil)
+
+		// Create a new HTTP request
+		payload := `{
+			"origin": "desktop-web",
+			"from_user_id": "user789",
+			"to_user_id": "user123",
+			"amount": 500
+		}`
+		req, err := http.NewRequest("POST", "/transfers", strings.NewReader(payload))
+		assert.NoError(t, err)
+
+		// Set the request content type
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", "application/json")
+
+		// Create a new HTTP response recorder
+		res := httptest.NewRecorder()
+
+		// Serve the HTTP request
+		router.ServeHTTP(res, req)
+
+		// Assert the response status code
+		assert.Equal(t, http.StatusUnprocessableEntity, res.Code)
+		assert.Contains(t, res.Body.String(), "insufficient funds")
+	})
+	t.Run("saving a transfer with a malformed payload", func(t *testing.T) {
+		// Create a new HTTP request
+		req, err := http.NewRequest("POST", "/transfers", strings.NewReader(`{"from_user_id": "user123", "amount": "500"`))
+		assert.NoError(t, err)
+
+		// Set the request content type
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", "application/json")
+
+		// Create a new HTTP response recorder
+		res := httptest.NewRecorder()
+
+		// Serve the HTTP request
+		router.ServeHTTP(res, req)
+
+		// Assert the response status code and body, the headers as they were when the status was written
+		assert.Equal(t, http.StatusBadRequest, res.Code)
+		assert.Equal(t, "application/problem+json; charset=utf-8", res.Result().Header.Get("Content-Type"))
+		var result presenters.Problem
+		assert.NoError(t, json.NewDecoder(res.Body).Decode(&result))
+		assert.Equal(t, "invalid_body", result.Code)
+	})
+}
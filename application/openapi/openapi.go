@@ -0,0 +1,189 @@
+// Package openapi generates the OpenAPI 3 document of the API from the routes of the router, the DTOs and the
+// presenters, so the document follows the code. The routes without an operation are left out of the document.
+package openapi
+
+import (
+	_ "embed"
+	"net/http"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"user-transactions/application/presenters"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DocsPage renders the document served by the API, it has no dependencies so it works offline.
+//
+//go:embed docs.html
+var DocsPage []byte
+
+// adminScheme is the security scheme of the admin routes.
+const adminScheme = "adminToken"
+
+var tags = []*Tag{
+	{Name: "transactions", Description: "The credits and debits of the users."},
+	{Name: "holds", Description: "The debits reserving an amount until captured or voided."},
+	{Name: "transfers", Description: "The amounts moved between users."},
+	{Name: "balances", Description: "The balances and statements of the users."},
+	{Name: "admin", Description: "The bulk writer and its dead letters."},
+	{Name: "docs", Description: "This document."},
+}
+
+// New describes the routes, the routes without an operation are left out.
+func New(routes gin.RoutesInfo) *Document {
+	document := &Document{
+		OpenAPI: "3.0.3",
+		Info: &Info{
+			Title: "User transactions",
+			Description: "The requests and responses are JSON or XML, as negotiated by the Content-Type and Accept headers. " +
+				"The responses have the data in a data envelope, with the pagination of the lists. " +
+				"The failed requests are answered with problem details (RFC 7807).",
+			Version: "v1",
+		},
+		Tags:  tags,
+		Paths: make(map[string]*PathItem),
+		Components: &Components{
+			Schemas:   make(map[string]*Schema),
+			Responses: make(map[string]*Response),
+			SecuritySchemes: map[string]*SecurityScheme{
+				adminScheme: {Type: "http", Scheme: "bearer", Description: "The ADMIN_TOKEN of the server, the admin routes answer 404 without one."},
+			},
+		},
+	}
+	s := schemas(document.Components.Schemas)
+
+	for _, route := range routes {
+		op, ok := operations[route.Method+" "+route.Path]
+		if !ok {
+			continue
+		}
+
+		path, parameters := openAPIPath(route.Path)
+		item, ok := document.Paths[path]
+		if !ok {
+			item = &PathItem{}
+			document.Paths[path] = item
+		}
+		(*item)[strings.ToLower(route.Method)] = s.operation(op, operationID(route.Handler), parameters, document.Components.Responses)
+	}
+	return document
+}
+
+func (s schemas) operation(op *operation, id string, parameters []*Parameter, problems map[string]*Response) *Operation {
+	operation := &Operation{
+		Tags:        []string{op.tag},
+		Summary:     op.summary,
+		Description: op.description,
+		OperationID: id,
+		Parameters:  append(parameters, op.parameters...),
+		Responses:   make(map[string]*Response),
+	}
+
+	bodies := op.bodies
+	if op.body != nil {
+		bodies = map[string]any{gin.MIMEJSON: op.body, gin.MIMEXML: op.body}
+	}
+	if bodies != nil {
+		operation.RequestBody = &RequestBody{Required: !op.optional, Content: make(map[string]*MediaType)}
+		for mediaType, body := range bodies {
+			operation.RequestBody.Content[mediaType] = &MediaType{Schema: s.of(reflect.TypeOf(body))}
+		}
+	}
+
+	for _, res := range op.responses {
+		response := &Response{Description: res.description, Headers: res.headers}
+		if res.data != nil || res.content != nil {
+			response.Content = make(map[string]*MediaType)
+		}
+		if res.data != nil {
+			envelope := s.envelope(reflect.TypeOf(res.data), res.paginated)
+			response.Content[gin.MIMEJSON] = &MediaType{Schema: envelope}
+			response.Content[gin.MIMEXML] = &MediaType{Schema: envelope}
+		}
+		for mediaType, value := range res.content {
+			response.Content[mediaType] = &MediaType{Schema: s.of(reflect.TypeOf(value))}
+		}
+		operation.Responses[strconv.Itoa(res.status)] = response
+	}
+
+	// every route can fail, and the failures of the service are answered with 500
+	statuses := append([]int{http.StatusInternalServerError}, op.errors...)
+	if op.admin {
+		operation.Security = []map[string][]string{{adminScheme: {}}}
+		statuses = append(statuses, http.StatusUnauthorized)
+		if !slices.Contains(statuses, http.StatusNotFound) {
+			statuses = append(statuses, http.StatusNotFound)
+		}
+	}
+	sort.Ints(statuses)
+	for _, status := range statuses {
+		name := strings.ReplaceAll(http.StatusText(status), " ", "")
+		if _, ok := problems[name]; !ok {
+			problems[name] = s.problem(status)
+		}
+
+		code := strconv.Itoa(status)
+		if response, ok := operation.Responses[code]; ok {
+			// the status of a successful response that can be a problem too, e.g. a batch none of whose transactions was created
+			for mediaType, content := range problems[name].Content {
+				response.Content[mediaType] = content
+			}
+			continue
+		}
+		operation.Responses[code] = &Response{Ref: "#/components/responses/" + name}
+	}
+	return operation
+}
+
+// problem is the response of the problems with the status, as presenters.Problem in JSON or XML.
+func (s schemas) problem(status int) *Response {
+	schema := s.of(reflect.TypeOf(presenters.Problem{}))
+	response := &Response{
+		Description: http.StatusText(status) + ", the errors of the request are in the problem details.",
+		Content: map[string]*MediaType{
+			presenters.MIMEProblemJSON: {Schema: schema},
+			presenters.MIMEProblemXML:  {Schema: schema},
+		},
+	}
+	if status == http.StatusServiceUnavailable {
+		response.Headers = map[string]*Header{
+			"Retry-After": {Description: "The seconds to wait before retrying.", Schema: &Schema{Type: "integer", Format: "int64"}},
+		}
+	}
+	return response
+}
+
+// openAPIPath turns the parameters of a route, e.g. :id, into the ones of a path template, e.g. {id}.
+func openAPIPath(route string) (string, []*Parameter) {
+	var parameters []*Parameter
+	segments := strings.Split(route, "/")
+	for i, segment := range segments {
+		name, ok := strings.CutPrefix(segment, ":")
+		if !ok {
+			continue
+		}
+		segments[i] = "{" + name + "}"
+		parameters = append(parameters, &Parameter{
+			Name:        name,
+			In:          "path",
+			Description: pathParameters[name],
+			Required:    true,
+			Schema:      &Schema{Type: "string"},
+		})
+	}
+	return strings.Join(segments, "/"), parameters
+}
+
+// operationID names the operation after its handler, e.g. user-transactions/application/handler.(*TransactionHandler).Get-fm
+// is TransactionHandler.Get.
+func operationID(handler string) string {
+	name := handler[strings.LastIndex(handler, "/")+1:]
+	name = strings.TrimSuffix(name, "-fm")
+	if _, method, ok := strings.Cut(name, "(*"); ok {
+		name = strings.Replace(method, ").", ".", 1)
+	}
+	return name
+}
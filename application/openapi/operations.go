@@ -0,0 +1,329 @@
+package openapi
+
+import (
+	"net/http"
+	"user-transactions/application/dto"
+	"user-transactions/application/presenters"
+	"user-transactions/core/entities"
+	"user-transactions/core/repositories"
+	"user-transactions/core/services"
+)
+
+// operation documents a route, the schemas of its bodies are generated from the DTOs sent and returned.
+type operation struct {
+	tag         string
+	summary     string
+	description string
+	parameters  []*Parameter
+	body        any            // the DTO of the request body, sent as JSON or XML
+	bodies      map[string]any // the DTO of the request body by media type, when they differ
+	optional    bool           // the request body can be left out
+	responses   []response
+	errors      []int // the status of the problems the route answers with
+	admin       bool  // the route needs the admin token
+}
+
+// response is a successful response, the data is sent as JSON or XML in the presenters.ApiDataFormat envelope.
+type response struct {
+	status      int
+	description string
+	data        any
+	paginated   bool
+	content     map[string]any // the values sent in the formats other than the envelope, e.g. CSV
+	headers     map[string]*Header
+}
+
+// operations are the routes of the router by method and path, as registered.
+var operations = map[string]*operation{
+	"POST /v1/transactions": {
+		tag:     "transactions",
+		summary: "Create a transaction",
+		description: "The transaction is acknowledged once accepted by the bulk writer, unless the consistency is commit-sync. " +
+			"The holds, the transactions with an external reference and the debits limited by an overdraft policy are inserted right away.",
+		parameters: []*Parameter{
+			header("Idempotency-Key", "Creates the transaction once, the retries with the key get the first response."),
+			header("Prefer", "commit-sync waits for the transaction to be committed, as the commit-sync consistency."),
+		},
+		body: dto.CreateTransactionReq{},
+		responses: []response{{
+			status:      http.StatusCreated,
+			description: "The transaction was created.",
+			data:        dto.TransactionRes{},
+			headers: map[string]*Header{
+				"Preference-Applied": {Description: "commit-sync when the Prefer header was applied.", Schema: &Schema{Type: "string"}},
+			},
+		}},
+		errors: []int{http.StatusBadRequest, http.StatusConflict, http.StatusUnprocessableEntity, http.StatusServiceUnavailable, http.StatusGatewayTimeout},
+	},
+	"POST /v1/transactions/batch": {
+		tag:     "transactions",
+		summary: "Create a batch of transactions",
+		description: "The body is a JSON array, a NDJSON stream or a XML list of transactions. " +
+			"With atomic=true none of them is created unless all of them are.",
+		parameters: []*Parameter{
+			query("atomic", "Creates all the transactions or none of them.", &Schema{Type: "boolean"}),
+		},
+		bodies: map[string]any{
+			"application/json":    []*dto.CreateTransactionReq{},
+			"application/xml":     dto.CreateTransactionBatchReq{},
+			presenters.MIMENDJSON: dto.CreateTransactionReq{}, // a transaction on each line
+		},
+		responses: []response{
+			{status: http.StatusCreated, description: "All the transactions were created.", data: dto.TransactionBatchRes{}},
+			{status: http.StatusMultiStatus, description: "Some transactions were created, the items tell the result of each one.", data: dto.TransactionBatchRes{}},
+			{status: http.StatusUnprocessableEntity, description: "None of the transactions was created.", data: dto.TransactionBatchRes{}},
+		},
+		errors: []int{http.StatusBadRequest, http.StatusUnprocessableEntity, http.StatusServiceUnavailable},
+	},
+	"GET /v1/transactions": {
+		tag:     "transactions",
+		summary: "List the transactions",
+		description: "Pages with page and page_size, or with the keyset pagination when a cursor is sent, an empty one for the first page. " +
+			"The Link header points to the first, prev, next and last pages. The metadata.<key>=<value> parameters filter by metadata.",
+		parameters: append(append([]*Parameter{
+			query("page", "The page, from 0.", &Schema{Type: "integer", Format: "int64"}),
+			query("page_size", "The transactions in a page, 10 by default.", &Schema{Type: "integer", Format: "int64"}),
+			query("cursor", "The next_cursor or prev_cursor of the pagination, empty for the first page.", &Schema{Type: "string"}),
+			query("include_total", "Counts the total_items and total_pages.", &Schema{Type: "boolean"}),
+		}, transactionFilters()...), sortParameter()),
+		responses: []response{{
+			status:      http.StatusOK,
+			description: "A page of transactions.",
+			data:        []*dto.TransactionRes{},
+			paginated:   true,
+			headers: map[string]*Header{
+				"Link": {Description: "The first, prev, next and last pages (RFC 8288).", Schema: &Schema{Type: "string"}},
+			},
+		}},
+		errors: []int{http.StatusBadRequest},
+	},
+	"GET /v1/transactions/export": {
+		tag:         "transactions",
+		summary:     "Export the transactions",
+		description: "Streams the transactions of the list filters as CSV or NDJSON, the transactions still in the bulk buffer are not exported.",
+		parameters:  append(transactionFilters(), sortParameter()),
+		responses: []response{{
+			status:      http.StatusOK,
+			description: "The transactions, as negotiated by the Accept header.",
+			content: map[string]any{
+				presenters.MIMECSV:    "",                   // a header row and a row for each transaction
+				presenters.MIMENDJSON: dto.TransactionRes{}, // a transaction on each line
+			},
+			headers: map[string]*Header{"Content-Disposition": contentDisposition},
+		}},
+		errors: []int{http.StatusBadRequest, http.StatusNotAcceptable},
+	},
+	"GET /v1/transactions/summary": {
+		tag:         "transactions",
+		summary:     "Total the transactions",
+		description: "Totals the transactions of the list filters by currency, the groups and the interval. Only the posted transactions are totaled unless status is sent.",
+		parameters: append(transactionFilters(),
+			query("group_by", "A comma separated list of origin, type and user_id.", &Schema{Type: "string"}),
+			query("interval", "Buckets the totals by the period, in UTC.", enum(entities.INTERVAL_DAY, entities.INTERVAL_WEEK, entities.INTERVAL_MONTH)),
+		),
+		responses: []response{{status: http.StatusOK, description: "The totals of each group.", data: []*dto.TransactionSummaryRes{}}},
+		errors:    []int{http.StatusBadRequest},
+	},
+	"GET /v1/transactions/:id": {
+		tag:         "transactions",
+		summary:     "Get a transaction",
+		description: "The transactions still in the bulk buffer are returned with the pending commit_status.",
+		responses:   []response{{status: http.StatusOK, description: "The transaction.", data: dto.TransactionRes{}}},
+		errors:      []int{http.StatusBadRequest, http.StatusNotFound, http.StatusServiceUnavailable},
+	},
+	"POST /v1/transactions/:id/reversal": {
+		tag:         "transactions",
+		summary:     "Reverse a transaction",
+		description: "Creates a transaction of the opposite type, of all that is left of the transaction without a body. The transactions of a transfer can't be reversed.",
+		body:        dto.CreateReversalReq{},
+		optional:    true,
+		responses:   []response{{status: http.StatusCreated, description: "The reversal.", data: dto.TransactionRes{}}},
+		errors:      []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict, http.StatusUnprocessableEntity},
+	},
+	"POST /v1/transactions/:id/capture": {
+		tag:       "holds",
+		summary:   "Capture a hold",
+		responses: []response{{status: http.StatusOK, description: "The posted transaction.", data: dto.TransactionRes{}}},
+		errors:    []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict},
+	},
+	"POST /v1/transactions/:id/void": {
+		tag:       "holds",
+		summary:   "Void a hold",
+		responses: []response{{status: http.StatusOK, description: "The voided transaction.", data: dto.TransactionRes{}}},
+		errors:    []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict},
+	},
+	"POST /v1/transfers": {
+		tag:         "transfers",
+		summary:     "Transfer an amount between users",
+		description: "Debits the sender and credits the receiver in a single database transaction.",
+		body:        dto.CreateTransferReq{},
+		responses:   []response{{status: http.StatusCreated, description: "The transfer.", data: dto.TransferRes{}}},
+		errors:      []int{http.StatusBadRequest, http.StatusUnprocessableEntity},
+	},
+	"GET /v1/transfers/:id": {
+		tag:       "transfers",
+		summary:   "Get a transfer",
+		responses: []response{{status: http.StatusOK, description: "The transfer with both transactions.", data: dto.TransferRes{}}},
+		errors:    []int{http.StatusBadRequest, http.StatusNotFound},
+	},
+	"GET /v1/users/:user_id/balance": {
+		tag:         "balances",
+		summary:     "Get the balance of a user",
+		description: "The currency is required when the user has transactions in more than one currency.",
+		parameters:  balanceFilters(),
+		responses:   []response{{status: http.StatusOK, description: "The balance.", data: dto.BalanceRes{}}},
+		errors:      []int{http.StatusBadRequest},
+	},
+	"GET /v1/users/:user_id/balances": {
+		tag:        "balances",
+		summary:    "Get the balances of a user",
+		parameters: balanceFilters(),
+		responses:  []response{{status: http.StatusOK, description: "A balance for each currency, ordered by currency.", data: []*dto.BalanceRes{}}},
+		errors:     []int{http.StatusBadRequest},
+	},
+	"GET /v1/users/:user_id/statements": {
+		tag:         "balances",
+		summary:     "Get the monthly statement of a user",
+		description: "The posted transactions of the month in the order they were created, with the running balance.",
+		parameters: []*Parameter{
+			requiredQuery("period", "The month, in UTC.", &Schema{Type: "string", Format: "YYYY-MM"}),
+			query("currency", "The ISO 4217 code of the currency, the default currency when not sent.", &Schema{Type: "string"}),
+		},
+		responses: []response{{
+			status:      http.StatusOK,
+			description: "The statement, as negotiated by the Accept header.",
+			data:        dto.StatementRes{},
+			content: map[string]any{
+				presenters.MIMECSV:  "", // the opening balance, the transactions and the closing balance as rows
+				presenters.MIMEText: "", // a printable page
+			},
+			headers: map[string]*Header{"Content-Disposition": contentDisposition},
+		}},
+		errors: []int{http.StatusBadRequest, http.StatusNotAcceptable},
+	},
+	"GET /admin/queue": {
+		tag:       "admin",
+		admin:     true,
+		summary:   "Get the state of the bulk writer queue",
+		responses: []response{{status: http.StatusOK, description: "The queue.", data: dto.QueueStatsRes{}}},
+	},
+	"GET /admin/dead-letters": {
+		tag:       "admin",
+		admin:     true,
+		summary:   "List the dead letter batches",
+		responses: []response{{status: http.StatusOK, description: "The batches the bulk writer couldn't commit, without their transactions.", data: []*dto.DeadLetterBatchRes{}}},
+	},
+	"GET /admin/dead-letters/:id": {
+		tag:       "admin",
+		admin:     true,
+		summary:   "Get a dead letter batch",
+		responses: []response{{status: http.StatusOK, description: "The batch with its transactions.", data: dto.DeadLetterBatchRes{}}},
+		errors:    []int{http.StatusNotFound},
+	},
+	"POST /admin/dead-letters/:id/replay": {
+		tag:       "admin",
+		admin:     true,
+		summary:   "Replay a dead letter batch",
+		responses: []response{{status: http.StatusOK, description: "The replayed batch.", data: dto.DeadLetterBatchRes{}}},
+		errors:    []int{http.StatusNotFound},
+	},
+	"DELETE /admin/dead-letters/:id": {
+		tag:       "admin",
+		admin:     true,
+		summary:   "Discard a dead letter batch",
+		responses: []response{{status: http.StatusNoContent, description: "The batch was discarded."}},
+		errors:    []int{http.StatusNotFound},
+	},
+	"GET /openapi.json": {
+		tag:     "docs",
+		summary: "Get this document",
+		responses: []response{{
+			status:      http.StatusOK,
+			description: "The OpenAPI 3 document of the API.",
+			content:     map[string]any{"application/json": map[string]any{}},
+		}},
+	},
+	"GET /docs": {
+		tag:     "docs",
+		summary: "Read the documentation",
+		responses: []response{{
+			status:      http.StatusOK,
+			description: "A page rendering this document.",
+			content:     map[string]any{"text/html": ""},
+		}},
+	},
+}
+
+// pathParameters describes the parameters of the paths by name.
+var pathParameters = map[string]string{
+	"id":      "The id of the resource, a UUID.",
+	"user_id": "The id of the user.",
+}
+
+// enums are the values of the string fields of the DTOs, by the name of the DTO and the JSON field.
+var enums = map[string][]string{
+	"CreateTransactionReq.type":        values(entities.DEBIT, entities.CREDIT),
+	"CreateTransactionReq.consistency": values(repositories.CONSISTENCY_ASYNC, repositories.CONSISTENCY_COMMIT_SYNC),
+	"TransactionRes.type":              values(entities.DEBIT, entities.CREDIT),
+	"TransactionRes.status":            values(entities.STATUS_PENDING, entities.STATUS_POSTED, entities.STATUS_VOIDED),
+	"TransactionRes.reversal_status":   values(entities.NOT_REVERSED, entities.PARTIALLY_REVERSED, entities.REVERSED),
+	"TransactionRes.commit_status":     values(entities.COMMIT_PENDING, entities.COMMITTED),
+	"TransactionBatchItemRes.status":   {services.BATCH_ITEM_CREATED, services.BATCH_ITEM_FAILED},
+	"TransactionSummaryRes.type":       values(entities.DEBIT, entities.CREDIT),
+	"StatementLineRes.type":            values(entities.DEBIT, entities.CREDIT),
+}
+
+var contentDisposition = &Header{Description: "The name of the file to save the body to.", Schema: &Schema{Type: "string"}}
+
+// transactionFilters are the filters of the list, the export and the summary.
+func transactionFilters() []*Parameter {
+	return []*Parameter{
+		query("origin", "", &Schema{Type: "string"}),
+		query("user_id", "", &Schema{Type: "string"}),
+		query("type", "", enum(entities.DEBIT, entities.CREDIT)),
+		query("currency", "The ISO 4217 code of the currency.", &Schema{Type: "string"}),
+		query("status", "The posted transactions include the captured holds.", enum(entities.STATUS_PENDING, entities.STATUS_POSTED, entities.STATUS_VOIDED)),
+		query("created_from", "Inclusive, a RFC 3339 timestamp or a date.", &Schema{Type: "string"}),
+		query("created_to", "Exclusive, a RFC 3339 timestamp or a date.", &Schema{Type: "string"}),
+		query("min_amount", "Inclusive, in the minor unit of the currency.", &Schema{Type: "integer", Format: "int64"}),
+		query("max_amount", "Inclusive, in the minor unit of the currency.", &Schema{Type: "integer", Format: "int64"}),
+	}
+}
+
+func sortParameter() *Parameter {
+	return query("sort", "created_at by default.", enum(entities.SORT_CREATED_AT, entities.SORT_CREATED_AT_DESC, entities.SORT_AMOUNT, entities.SORT_AMOUNT_DESC))
+}
+
+func balanceFilters() []*Parameter {
+	return []*Parameter{
+		query("origin", "Sums the transactions of the origin only.", &Schema{Type: "string"}),
+		query("currency", "The ISO 4217 code of the currency.", &Schema{Type: "string"}),
+		query("as_of", "The balance at the moment, a RFC 3339 timestamp.", &Schema{Type: "string", Format: "date-time"}),
+	}
+}
+
+func query(name, description string, schema *Schema) *Parameter {
+	return &Parameter{Name: name, In: "query", Description: description, Schema: schema}
+}
+
+func requiredQuery(name, description string, schema *Schema) *Parameter {
+	parameter := query(name, description, schema)
+	parameter.Required = true
+	return parameter
+}
+
+func header(name, description string) *Parameter {
+	return &Parameter{Name: name, In: "header", Description: description, Schema: &Schema{Type: "string"}}
+}
+
+func enum[T ~string](constants ...T) *Schema {
+	return &Schema{Type: "string", Enum: values(constants...)}
+}
+
+func values[T ~string](constants ...T) []string {
+	values := make([]string, 0, len(constants))
+	for _, constant := range constants {
+		values = append(values, string(constant))
+	}
+	return values
+}
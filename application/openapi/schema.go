@@ -0,0 +1,178 @@
+package openapi
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+	"user-transactions/application/dto"
+	"user-transactions/application/presenters"
+)
+
+const schemaRef = "#/components/schemas/"
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	metadataType = reflect.TypeOf(dto.Metadata{})
+	apiDataType  = reflect.TypeOf(presenters.ApiDataFormat{})
+)
+
+// schemas are the components of the document, a schema for each struct reached from the operations, named after it.
+// The JSON shape comes from the json tags and the XML one from the xml tags, following the rules of encoding/xml.
+type schemas map[string]*Schema
+
+// of returns the schema of a type, a reference to the component of the structs.
+func (s schemas) of(t reflect.Type) *Schema {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case metadataType:
+		// Metadata marshals its own XML, it can't be read from its tags
+		if _, ok := s[t.Name()]; !ok {
+			s[t.Name()] = &Schema{
+				Type:                 "object",
+				Description:          `The key/values of a transaction, in XML an <entry key="...">value</entry> element for each key.`,
+				AdditionalProperties: &Schema{Type: "string"},
+			}
+		}
+		return &Schema{Ref: schemaRef + t.Name()}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Int32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Slice:
+		return &Schema{Type: "array", Items: s.of(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: s.of(t.Elem())}
+	case reflect.Struct:
+		if _, ok := s[t.Name()]; !ok {
+			s[t.Name()] = &Schema{} // taken while the fields are read
+			s[t.Name()] = s.object(t, nil)
+		}
+		return &Schema{Ref: schemaRef + t.Name()}
+	}
+	return &Schema{}
+}
+
+// envelope is the schema of the presenters.ApiDataFormat the responses are sent in, with the data of the type and
+// its pagination when paginated.
+func (s schemas) envelope(data reflect.Type, paginated bool) *Schema {
+	schema := s.object(apiDataType, data)
+	if !paginated {
+		delete(schema.Properties, "pagination")
+	}
+	return schema
+}
+
+// object reads the schema of a struct from its fields, the interface fields hold the data type.
+func (s schemas) object(t reflect.Type, data reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		xmlName, xmlOptions, _ := strings.Cut(field.Tag.Get("xml"), ",")
+		if field.Name == "XMLName" {
+			schema.XML = xmlElement(xmlName)
+			continue
+		}
+
+		jsonName, jsonOptions, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if jsonName == "-" {
+			continue
+		}
+		if jsonName == "" {
+			jsonName = field.Name
+		}
+
+		fieldType := field.Type
+		if fieldType.Kind() == reflect.Interface {
+			if data == nil {
+				continue
+			}
+			fieldType = data
+		}
+
+		property := s.of(fieldType)
+		if xmlName != "-" {
+			if xmlName == "" {
+				xmlName = field.Name
+			}
+			property = s.xmlProperty(property, jsonName, xmlName, slices.Contains(strings.Split(xmlOptions, ","), "attr"))
+		}
+		if values, ok := enums[t.Name()+"."+jsonName]; ok {
+			property.Enum = values
+		}
+
+		schema.Properties[jsonName] = property
+		if !strings.Contains(jsonOptions, "omitempty") {
+			schema.Required = append(schema.Required, jsonName)
+		}
+	}
+	return schema
+}
+
+// xmlProperty names the element of a property when it isn't the name of its JSON field. The elements of the
+// structs with a XMLName field are named after it, whatever the tag of the property, and a tag as a>b wraps
+// the elements b of a list in an element a.
+func (s schemas) xmlProperty(property *Schema, jsonName, xmlName string, attribute bool) *Schema {
+	if attribute {
+		property.XML = &XML{Name: xmlName, Attribute: true}
+		return property
+	}
+
+	parent, child, nested := strings.Cut(xmlName, ">")
+	if property.Type == "array" {
+		if nested {
+			property.XML = &XML{Name: parent, Wrapped: true}
+			property.Items = s.named(property.Items, child)
+		} else {
+			property.Items = s.named(property.Items, xmlName)
+		}
+		return property
+	}
+
+	if nested {
+		return &Schema{AllOf: []*Schema{s.named(property, child)}, Description: "In XML, wrapped in a <" + parent + "> element."}
+	}
+	if xmlName == jsonName {
+		return property
+	}
+	return s.named(property, xmlName)
+}
+
+// named returns the schema written as an element with the name, the components keep their own name.
+func (s schemas) named(schema *Schema, name string) *Schema {
+	if schema.Ref == "" {
+		schema.XML = &XML{Name: name}
+		return schema
+	}
+	if component := s[strings.TrimPrefix(schema.Ref, schemaRef)]; component != nil && component.XML != nil {
+		return schema
+	}
+	return &Schema{AllOf: []*Schema{schema}, XML: &XML{Name: name}}
+}
+
+// xmlElement reads the name of the element of a XMLName tag, e.g. "urn:ietf:rfc:7807 problem".
+func xmlElement(tag string) *XML {
+	if tag == "" {
+		return nil
+	}
+	if namespace, name, ok := strings.Cut(tag, " "); ok {
+		return &XML{Name: name, Namespace: namespace}
+	}
+	return &XML{Name: tag}
+}
@@ -3,8 +3,13 @@ package presenters
 import "encoding/xml"
 
 type Pagination struct {
-	Page     int `json:"page"`
-	PageSize int `json:"page_size"`
+	Page       int    `json:"page"`
+	PageSize   int    `json:"page_size"`
+	HasNext    bool   `json:"has_next"`
+	TotalItems *int64 `json:"total_items,omitempty" xml:",omitempty"`
+	TotalPages *int64 `json:"total_pages,omitempty" xml:",omitempty"`
+	NextCursor string `json:"next_cursor,omitempty" xml:",omitempty"`
+	PrevCursor string `json:"prev_cursor,omitempty" xml:",omitempty"`
 }
 
 type ApiDataFormat struct {
@@ -26,3 +31,26 @@ func (format *ApiDataFormat) WithPagination(page, pageSize int) *ApiDataFormat {
 	}
 	return format
 }
+
+func (format *ApiDataFormat) WithCursorPagination(pageSize int, nextCursor, prevCursor string) *ApiDataFormat {
+	format.Pagination = &Pagination{
+		PageSize:   pageSize,
+		NextCursor: nextCursor,
+		PrevCursor: prevCursor,
+	}
+	return format
+}
+
+// WithPageInfo adds to the pagination if there is a next page and, when counted, the total of items and pages.
+func (format *ApiDataFormat) WithPageInfo(hasNext bool, totalItems *int64) *ApiDataFormat {
+	format.Pagination.HasNext = hasNext
+	if totalItems != nil {
+		totalPages := *totalItems / int64(format.Pagination.PageSize)
+		if *totalItems%int64(format.Pagination.PageSize) > 0 {
+			totalPages++
+		}
+		format.Pagination.TotalItems = totalItems
+		format.Pagination.TotalPages = &totalPages
+	}
+	return format
+}
@@ -0,0 +1,115 @@
+package presenters
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"user-transactions/application/dto"
+)
+
+const (
+	MIMECSV    = "text/csv"
+	MIMENDJSON = "application/x-ndjson"
+)
+
+// csvHeader is the first row of the CSV export, in the order of the columns written by the CSV exporter.
+var csvHeader = []string{"id", "origin", "user_id", "amount", "currency", "type", "status", "reversal_of", "created_at"}
+
+// Exporter writes the transactions of an export one after the other, Flush sends the buffered ones to the client.
+type Exporter interface {
+	Write(transaction *dto.TransactionRes) error
+	Flush() error
+}
+
+// NewExporter returns the exporter of the format, nil when the format is not exported.
+func NewExporter(format string, w io.Writer) Exporter {
+	switch format {
+	case MIMECSV:
+		return &csvExporter{w: w, csv: csv.NewWriter(w)}
+	case MIMENDJSON:
+		buffer := bufio.NewWriter(w)
+		return &ndjsonExporter{w: w, buffer: buffer, encoder: json.NewEncoder(buffer)}
+	}
+	return nil
+}
+
+type csvExporter struct {
+	w             io.Writer
+	csv           *csv.Writer
+	headerWritten bool
+}
+
+func (e *csvExporter) Write(transaction *dto.TransactionRes) error {
+	if !e.headerWritten {
+		if err := e.csv.Write(csvHeader); err != nil {
+			return err
+		}
+		e.headerWritten = true
+	}
+
+	return e.csv.Write([]string{
+		transaction.ID,
+		escapeFormula(transaction.Origin),
+		escapeFormula(transaction.UserID),
+		strconv.FormatInt(transaction.Amount, 10),
+		transaction.Currency,
+		transaction.Type,
+		transaction.Status,
+		transaction.ReversalOf,
+		transaction.CreatedAt.UTC().Format(time.RFC3339Nano),
+	})
+}
+
+func (e *csvExporter) Flush() error {
+	// an empty export still has the header
+	if !e.headerWritten {
+		if err := e.csv.Write(csvHeader); err != nil {
+			return err
+		}
+		e.headerWritten = true
+	}
+
+	e.csv.Flush()
+	if err := e.csv.Error(); err != nil {
+		return err
+	}
+	flush(e.w)
+	return nil
+}
+
+type ndjsonExporter struct {
+	w       io.Writer
+	buffer  *bufio.Writer
+	encoder *json.Encoder
+}
+
+func (e *ndjsonExporter) Write(transaction *dto.TransactionRes) error {
+	return e.encoder.Encode(transaction)
+}
+
+func (e *ndjsonExporter) Flush() error {
+	if err := e.buffer.Flush(); err != nil {
+		return err
+	}
+	flush(e.w)
+	return nil
+}
+
+func flush(w io.Writer) {
+	if flusher, ok := w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// escapeFormula keeps the spreadsheets from running a text value as a formula.
+func escapeFormula(value string) string {
+	if value != "" && strings.ContainsRune("=+-@", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
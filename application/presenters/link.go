@@ -0,0 +1,20 @@
+package presenters
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Link is a web link of the RFC 8288 sent in the Link header.
+type Link struct {
+	Rel string
+	URL string
+}
+
+func FormatLinks(links ...Link) string {
+	var values []string
+	for _, link := range links {
+		values = append(values, fmt.Sprintf(`<%s>; rel="%s"`, link.URL, link.Rel))
+	}
+	return strings.Join(values, ", ")
+}
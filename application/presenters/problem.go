@@ -0,0 +1,129 @@
+package presenters
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"user-transactions/core/entities"
+
+	"gorm.io/gorm"
+)
+
+// The media types of the problem details (RFC 7807).
+const (
+	MIMEProblemJSON = "application/problem+json"
+	MIMEProblemXML  = "application/problem+xml"
+)
+
+// kindInternal is the kind of the failures of the service, the errors without a kind.
+const kindInternal entities.ErrorKind = "internal"
+
+// kindStatus maps the kinds of the errors to the status of the response, in the order they take precedence
+// when a request fails with more than one error.
+var kindStatus = []struct {
+	kind   entities.ErrorKind
+	status int
+}{
+	{entities.KIND_TIMEOUT, http.StatusGatewayTimeout},
+	{entities.KIND_UNAVAILABLE, http.StatusServiceUnavailable},
+	{kindInternal, http.StatusInternalServerError},
+	{entities.KIND_UNAUTHORIZED, http.StatusUnauthorized},
+	{entities.KIND_CONFLICT, http.StatusConflict},
+	{entities.KIND_UNPROCESSABLE, http.StatusUnprocessableEntity},
+	{entities.KIND_NOT_FOUND, http.StatusNotFound},
+	{entities.KIND_VALIDATION, http.StatusBadRequest},
+}
+
+// Problem is the body of a failed request (RFC 7807), with the stable code of the error that set its status
+// and every error of the request, e.g. all the invalid fields of a transaction.
+type Problem struct {
+	XMLName  xml.Name        `json:"-" xml:"urn:ietf:rfc:7807 problem"`
+	Type     string          `json:"type" xml:"type"`
+	Title    string          `json:"title" xml:"title"`
+	Status   int             `json:"status" xml:"status"`
+	Detail   string          `json:"detail,omitempty" xml:"detail,omitempty"`
+	Instance string          `json:"instance,omitempty" xml:"instance,omitempty"`
+	Code     string          `json:"code" xml:"code"`
+	Errors   []*ProblemError `json:"errors" xml:"errors>error"`
+}
+
+type ProblemError struct {
+	Code      string `json:"code" xml:"code"`
+	Detail    string `json:"detail" xml:"detail"`
+	Pointer   string `json:"pointer,omitempty" xml:"pointer,omitempty"`     // JSON pointer of the invalid field of the body
+	Parameter string `json:"parameter,omitempty" xml:"parameter,omitempty"` // the invalid query parameter
+}
+
+// NewProblem describes the errors of the request to the instance, the path of the request. The messages of the
+// failures of the service, e.g. the database errors, are not sent to the clients.
+func NewProblem(instance string, errs ...error) *Problem {
+	classified := make([]*entities.Error, 0, len(errs))
+	for _, err := range errs {
+		classified = append(classified, ClassifyError(err))
+	}
+
+	primary, status := ClassifyError(nil), http.StatusInternalServerError
+precedence:
+	for _, ks := range kindStatus {
+		for _, err := range classified {
+			if err.Kind == ks.kind {
+				primary, status = err, ks.status
+				break precedence
+			}
+		}
+	}
+
+	problem := newProblem(instance, status, primary.Code, primary.Message)
+	problem.Errors = make([]*ProblemError, 0, len(classified))
+	for _, err := range classified {
+		problem.Errors = append(problem.Errors, &ProblemError{
+			Code:      err.Code,
+			Detail:    err.Message,
+			Pointer:   err.Pointer,
+			Parameter: err.Parameter,
+		})
+	}
+	return problem
+}
+
+// NewStatusProblem describes a failure of the request decided by the handler, e.g. a format that can't be negotiated.
+func NewStatusProblem(instance string, status int, code, detail string) *Problem {
+	problem := newProblem(instance, status, code, detail)
+	problem.Errors = []*ProblemError{{Code: code, Detail: detail}}
+	return problem
+}
+
+func newProblem(instance string, status int, code, detail string) *Problem {
+	return &Problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   detail,
+		Instance: instance,
+		Code:     code,
+	}
+}
+
+// ClassifyError returns the kind, the code and the message the clients get for the error. The records not found
+// by the repositories are not found errors, the other errors without a kind are failures of the service.
+func ClassifyError(err error) *entities.Error {
+	var typed *entities.Error
+	switch {
+	case errors.As(err, &typed):
+		// the message of the whole chain, e.g. with the reference of a duplicate
+		classified := *typed
+		classified.Message = err.Error()
+		return &classified
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return entities.NewError(entities.KIND_NOT_FOUND, "not_found", "the resource was not found")
+	case errors.Is(err, context.DeadlineExceeded):
+		return &entities.Error{Kind: entities.KIND_UNAVAILABLE, Code: "deadline_exceeded", Message: "the request took too long, try again later", Err: err}
+	}
+	return &entities.Error{Kind: kindInternal, Code: "internal_error", Message: "an unexpected error occurred", Err: err}
+}
+
+// Internal reports whether the error is a failure of the service, whose message is not sent to the clients.
+func Internal(err error) bool {
+	return ClassifyError(err).Kind == kindInternal
+}
@@ -0,0 +1,96 @@
+package presenters
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"user-transactions/application/dto"
+)
+
+const MIMEText = "text/plain"
+
+// statementCSVHeader is the first row of a CSV statement, the opening and closing balances are rows of their own.
+var statementCSVHeader = []string{"created_at", "id", "origin", "type", "amount", "currency", "balance", "external_reference", "reversal_of"}
+
+// WriteStatementCSV writes the statement as CSV, the amounts in the minor unit of the currency as in the exports.
+func WriteStatementCSV(w io.Writer, statement *dto.StatementRes) error {
+	writer := csv.NewWriter(w)
+	balance := func(at time.Time, kind string, amount int64) []string {
+		return []string{at.UTC().Format(time.RFC3339Nano), "", "", kind, "", statement.Currency, strconv.FormatInt(amount, 10), "", ""}
+	}
+
+	rows := [][]string{statementCSVHeader, balance(statement.From, "opening_balance", statement.Opening)}
+	for _, line := range statement.Lines {
+		rows = append(rows, []string{
+			line.CreatedAt.UTC().Format(time.RFC3339Nano),
+			line.ID,
+			escapeFormula(line.Origin),
+			line.Type,
+			strconv.FormatInt(line.Amount, 10),
+			statement.Currency,
+			strconv.FormatInt(line.Balance, 10),
+			escapeFormula(line.ExternalReference),
+			line.ReversalOf,
+		})
+	}
+	rows = append(rows, balance(statement.To, "closing_balance", statement.Closing))
+
+	return writer.WriteAll(rows)
+}
+
+// WriteStatementText writes the statement as a plain text page to be read or printed, the amounts in the major unit.
+func WriteStatementText(w io.Writer, statement *dto.StatementRes) error {
+	amount := func(value int64) string {
+		return FormatAmount(value, statement.Exponent)
+	}
+	last := statement.To.Add(-time.Nanosecond)
+
+	fmt.Fprintf(w, "Statement of %s\n", statement.UserID)
+	fmt.Fprintf(w, "Period: %s to %s (UTC)\n", statement.From.Format(time.DateOnly), last.Format(time.DateOnly))
+	fmt.Fprintf(w, "Currency: %s\n\n", statement.Currency)
+
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "Date\tTransaction\tDescription\tAmount\tBalance")
+	fmt.Fprintf(table, "%s\t\tOpening balance\t\t%s\n", statement.From.Format(time.DateOnly), amount(statement.Opening))
+	for _, line := range statement.Lines {
+		description := line.Origin + " " + line.Type
+		if line.ExternalReference != "" {
+			description += " " + line.ExternalReference
+		}
+		if line.ReversalOf != "" {
+			description += " (reversal of " + line.ReversalOf + ")"
+		}
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%s\n",
+			line.CreatedAt.UTC().Format(time.DateTime), line.ID, description, amount(line.Amount), amount(line.Balance))
+	}
+	fmt.Fprintf(table, "%s\t\tClosing balance\t\t%s\n", last.Format(time.DateOnly), amount(statement.Closing))
+	if err := table.Flush(); err != nil {
+		return err
+	}
+
+	_, err := fmt.Fprintf(w, "\nCredits: %s\nDebits: %s\n", amount(statement.Credits), amount(statement.Debits))
+	return err
+}
+
+// FormatAmount writes an amount in the minor unit of a currency with the decimal places of its exponent,
+// e.g. -150 with the exponent 2 is -1.50.
+func FormatAmount(amount int64, exponent int) string {
+	sign := ""
+	abs := uint64(amount)
+	if amount < 0 {
+		sign = "-"
+		abs = -abs
+	}
+	digits := strconv.FormatUint(abs, 10)
+	if exponent <= 0 {
+		return sign + digits
+	}
+	if len(digits) <= exponent {
+		digits = strings.Repeat("0", exponent-len(digits)+1) + digits
+	}
+	return sign + digits[:len(digits)-exponent] + "." + digits[len(digits)-exponent:]
+}
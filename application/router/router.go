@@ -3,19 +3,20 @@ package router
 import (
 	"time"
 	"user-transactions/application/handler"
+	"user-transactions/application/openapi"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 )
 
-func SetupRouter(th *handler.TransactionHandler) *gin.Engine {
+func SetupRouter(th *handler.TransactionHandler, trh *handler.TransferHandler, ah *handler.AdminHandler, sh *handler.StatementHandler) *gin.Engine {
 	r := gin.Default()
 
 	r.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"http://localhost:3000"},
-		AllowMethods:     []string{"GET", "POST"},
-		AllowHeaders:     []string{"Content-Type"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowMethods:     []string{"GET", "POST", "DELETE"},
+		AllowHeaders:     []string{"Authorization", "Content-Type", "Idempotency-Key", "Prefer"},
+		ExposeHeaders:    []string{"Content-Length", "Retry-After", "Preference-Applied", "Content-Disposition"},
 		AllowCredentials: true,
 		AllowOriginFunc: func(origin string) bool {
 			return origin == "http://localhost:3000"
@@ -26,8 +27,37 @@ func SetupRouter(th *handler.TransactionHandler) *gin.Engine {
 	v1 := r.Group("/v1")
 
 	v1.POST("/transactions", th.Save)
+	v1.POST("/transactions/batch", th.SaveBatch)
 	v1.GET("/transactions", th.List)
+	v1.GET("/transactions/export", th.Export)
+	v1.GET("/transactions/summary", th.Summary)
 	v1.GET("/transactions/:id", th.Get)
+	v1.POST("/transactions/:id/reversal", th.Reverse)
+	v1.POST("/transactions/:id/capture", th.Capture)
+	v1.POST("/transactions/:id/void", th.Void)
+
+	v1.POST("/transfers", trh.Save)
+	v1.GET("/transfers/:id", trh.Get)
+
+	v1.GET("/users/:user_id/balance", th.Balance)
+	v1.GET("/users/:user_id/balances", th.Balances)
+	v1.GET("/users/:user_id/statements", sh.Get)
+
+	// the admin routes change the committed transactions, they need the admin token
+	admin := r.Group("/admin", ah.Authorize)
+
+	admin.GET("/queue", ah.Queue)
+
+	admin.GET("/dead-letters", ah.ListDeadLetters)
+	admin.GET("/dead-letters/:id", ah.GetDeadLetter)
+	admin.POST("/dead-letters/:id/replay", ah.ReplayDeadLetter)
+	admin.DELETE("/dead-letters/:id", ah.DiscardDeadLetter)
+
+	dh := handler.NewDocsHandler(nil)
+	r.GET("/openapi.json", dh.Spec)
+	r.GET("/docs", dh.Page)
+	// the document describes the routes registered above, so it's generated once all of them are
+	dh.Document = openapi.New(r.Routes())
 
 	return r
 }
@@ -0,0 +1,164 @@
+package router_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"user-transactions/application/handler"
+	"user-transactions/application/openapi"
+	"user-transactions/application/router"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_SetupRouter_OpenAPI(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	// the handlers are not called, the routes are only registered
+	r := router.SetupRouter(nil, nil, nil, nil)
+
+	// Create a new HTTP request
+	req, err := http.NewRequest("GET", "/openapi.json", nil)
+	assert.NoError(t, err)
+
+	// Create a new HTTP response recorder
+	res := httptest.NewRecorder()
+
+	// Serve the HTTP request
+	r.ServeHTTP(res, req)
+
+	// Assert the response status code and body
+	assert.Equal(t, http.StatusOK, res.Code)
+	var document openapi.Document
+	assert.NoError(t, json.NewDecoder(res.Body).Decode(&document))
+	assert.Equal(t, "3.0.3", document.OpenAPI)
+
+	t.Run("documenting every route", func(t *testing.T) {
+		for _, route := range r.Routes() {
+			path := route.Path
+			for _, segment := range strings.Split(route.Path, "/") {
+				if name, ok := strings.CutPrefix(segment, ":"); ok {
+					path = strings.Replace(path, segment, "{"+name+"}", 1)
+				}
+			}
+
+			item, ok := document.Paths[path]
+			if !assert.True(t, ok, "%s is missing from the document", path) {
+				continue
+			}
+			operation, ok := (*item)[strings.ToLower(route.Method)]
+			if assert.True(t, ok, "%s %s is missing from the document", route.Method, path) {
+				assert.NotEmpty(t, operation.Responses, "%s %s has no responses", route.Method, path)
+			}
+		}
+	})
+
+	t.Run("resolving every reference", func(t *testing.T) {
+		encoded, err := json.Marshal(document)
+		assert.NoError(t, err)
+		for _, ref := range strings.Split(string(encoded), `"$ref":"`)[1:] {
+			ref = ref[:strings.Index(ref, `"`)]
+			switch {
+			case strings.HasPrefix(ref, "#/components/schemas/"):
+				assert.Contains(t, document.Components.Schemas, strings.TrimPrefix(ref, "#/components/schemas/"))
+			case strings.HasPrefix(ref, "#/components/responses/"):
+				assert.Contains(t, document.Components.Responses, strings.TrimPrefix(ref, "#/components/responses/"))
+			default:
+				assert.Fail(t, "unexpected reference", ref)
+			}
+		}
+	})
+
+	t.Run("describing the XML shapes", func(t *testing.T) {
+		transaction := document.Components.Schemas["TransactionRes"]
+		if assert.NotNil(t, transaction) {
+			assert.Equal(t, &openapi.XML{Name: "transaction"}, transaction.XML)
+			assert.Equal(t, &openapi.XML{Name: "reversals", Wrapped: true}, transaction.Properties["reversals"].XML)
+			assert.Equal(t, &openapi.XML{Name: "id"}, transaction.Properties["reversals"].Items.XML)
+		}
+
+		problem := document.Components.Schemas["Problem"]
+		if assert.NotNil(t, problem) {
+			assert.Equal(t, &openapi.XML{Name: "problem", Namespace: "urn:ietf:rfc:7807"}, problem.XML)
+		}
+
+		pagination := document.Components.Schemas["Pagination"]
+		if assert.NotNil(t, pagination) {
+			assert.Equal(t, &openapi.XML{Name: "HasNext"}, pagination.Properties["has_next"].XML)
+		}
+
+		list := (*document.Paths["/v1/transactions"])["get"].Responses["200"]
+		assert.Contains(t, list.Content["application/json"].Schema.Properties, "pagination")
+		assert.Equal(t, &openapi.XML{Name: "data"}, list.Content["application/xml"].Schema.XML)
+	})
+
+	t.Run("describing the errors as problem details", func(t *testing.T) {
+		get := (*document.Paths["/v1/transactions/{id}"])["get"]
+		for _, status := range []string{"400", "404", "500", "503"} {
+			assert.Contains(t, get.Responses, status)
+		}
+
+		unavailable := document.Components.Responses["ServiceUnavailable"]
+		if assert.NotNil(t, unavailable) {
+			assert.Contains(t, unavailable.Headers, "Retry-After")
+			assert.Equal(t, "#/components/schemas/Problem", unavailable.Content["application/problem+json"].Schema.Ref)
+			assert.Equal(t, "#/components/schemas/Problem", unavailable.Content["application/problem+xml"].Schema.Ref)
+		}
+	})
+
+	t.Run("securing the admin routes", func(t *testing.T) {
+		for path, item := range document.Paths {
+			for method, operation := range *item {
+				if !strings.HasPrefix(path, "/admin/") {
+					assert.Empty(t, operation.Security, "%s %s", method, path)
+					continue
+				}
+				assert.Equal(t, []map[string][]string{{"adminToken": {}}}, operation.Security, "%s %s", method, path)
+				assert.Contains(t, operation.Responses, "401", "%s %s", method, path)
+			}
+		}
+		assert.Equal(t, &openapi.SecurityScheme{Type: "http", Scheme: "bearer",
+			Description: "The ADMIN_TOKEN of the server, the admin routes answer 404 without one."}, document.Components.SecuritySchemes["adminToken"])
+	})
+}
+
+func Test_SetupRouter_Docs(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := router.SetupRouter(nil, nil, nil, nil)
+
+	// Create a new HTTP request
+	req, err := http.NewRequest("GET", "/docs", nil)
+	assert.NoError(t, err)
+
+	// Create a new HTTP response recorder
+	res := httptest.NewRecorder()
+
+	// Serve the HTTP request
+	r.ServeHTTP(res, req)
+
+	// Assert the response status code and body
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, "text/html; charset=utf-8", res.Header().Get("Content-Type"))
+	assert.Contains(t, res.Body.String(), `fetch("openapi.json")`)
+}
+
+func Test_SetupRouter_Admin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	// the admin handler has no token, so its routes are disabled
+	r := router.SetupRouter(nil, nil, handler.NewAdminHandler(nil, nil), nil)
+
+	// Create a new HTTP request
+	req, err := http.NewRequest("DELETE", "/admin/dead-letters/8d0b1bc4-9d6c-4b4c-9c59-5e4d3a2f1b00", nil)
+	assert.NoError(t, err)
+
+	// Create a new HTTP response recorder
+	res := httptest.NewRecorder()
+
+	// Serve the HTTP request
+	r.ServeHTTP(res, req)
+
+	// Assert the response status code
+	assert.Equal(t, http.StatusNotFound, res.Code)
+}
@@ -0,0 +1,65 @@
+package entities
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Cursor points to a transaction in the listing ordered by the sort column and the id,
+// pages are read after it or, when Backward, before it.
+type Cursor struct {
+	Sort      TransactionSort `json:"s"`
+	CreatedAt time.Time       `json:"t"`
+	Amount    int64           `json:"a"`
+	ID        uuid.UUID       `json:"id"`
+	Backward  bool            `json:"b,omitempty"`
+}
+
+func NewCursor(transaction *Transaction, sort TransactionSort, backward bool) *Cursor {
+	return &Cursor{
+		Sort:      sort,
+		CreatedAt: transaction.CreatedAt,
+		Amount:    transaction.Amount,
+		ID:        transaction.ID,
+		Backward:  backward,
+	}
+}
+
+// Value is the value of the sort column of the transaction pointed by the cursor.
+func (c *Cursor) Value() interface{} {
+	if c.Sort.Column() == "amount" {
+		return c.Amount
+	}
+	return c.CreatedAt.UTC()
+}
+
+// Precedes reports whether the transaction is read after the cursor, in the direction of the cursor.
+func (c *Cursor) Precedes(transaction *Transaction) bool {
+	cmp := c.Sort.Compare(&Transaction{ID: c.ID, CreatedAt: c.CreatedAt, Amount: c.Amount}, transaction)
+	if c.Backward {
+		return cmp > 0
+	}
+	return cmp < 0
+}
+
+// Encode returns the opaque representation of the cursor given to the clients.
+func (c *Cursor) Encode() string {
+	content, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(content)
+}
+
+func DecodeCursor(encoded string) (*Cursor, error) {
+	content, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, NewParameterError("cursor", "cursor is invalid")
+	}
+
+	var c Cursor
+	if err := json.Unmarshal(content, &c); err != nil || c.ID == uuid.Nil || !c.Sort.Valid() {
+		return nil, NewParameterError("cursor", "cursor is invalid")
+	}
+	return &c, nil
+}
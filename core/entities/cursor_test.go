@@ -0,0 +1,54 @@
+package entities_test
+
+import (
+	"testing"
+	"time"
+	"user-transactions/core/entities"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Cursor(t *testing.T) {
+	t.Run("encode and decode a cursor", func(t *testing.T) {
+		cursor := &entities.Cursor{
+			Sort:      entities.SORT_AMOUNT_DESC,
+			Amount:    -1500,
+			CreatedAt: time.Date(2023, 11, 20, 10, 0, 0, 123456000, time.UTC),
+			ID:        uuid.New(),
+			Backward:  true,
+		}
+
+		decoded, err := entities.DecodeCursor(cursor.Encode())
+		assert.NoError(t, err)
+		assert.Equal(t, cursor, decoded)
+	})
+
+	t.Run("decode an invalid cursor", func(t *testing.T) {
+		unknownSort := &entities.Cursor{Sort: "user_id", ID: uuid.New()}
+		for _, encoded := range []string{"not base64!", "bm90IGpzb24", "e30", unknownSort.Encode()} {
+			decoded, err := entities.DecodeCursor(encoded)
+			assert.Nil(t, decoded)
+			assert.Equal(t, "cursor is invalid", err.Error())
+		}
+	})
+}
+
+func Test_Cursor_Precedes(t *testing.T) {
+	now := time.Now().UTC()
+	at := &entities.Transaction{ID: uuid.New(), CreatedAt: now}
+	before := &entities.Transaction{ID: uuid.New(), CreatedAt: now.Add(-time.Second)}
+	after := &entities.Transaction{ID: uuid.New(), CreatedAt: now.Add(time.Second)}
+
+	forward := entities.NewCursor(at, entities.SORT_CREATED_AT, false)
+	assert.True(t, forward.Precedes(after))
+	assert.False(t, forward.Precedes(before))
+	assert.False(t, forward.Precedes(at))
+
+	backward := entities.NewCursor(at, entities.SORT_CREATED_AT, true)
+	assert.True(t, backward.Precedes(before))
+	assert.False(t, backward.Precedes(after))
+
+	desc := entities.NewCursor(at, entities.SORT_CREATED_AT_DESC, false)
+	assert.True(t, desc.Precedes(before))
+}
@@ -0,0 +1,51 @@
+package entities
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DeadLetterBatch keeps a bulk of transactions that could not be committed after all the retries,
+// so it can be inspected and replayed or discarded later.
+type DeadLetterBatch struct {
+	ID        uuid.UUID
+	Size      int
+	Payload   string // JSON array with the transactions of the bulk
+	LastError string // error of the last commit attempt
+	CreatedAt time.Time
+}
+
+func NewDeadLetterBatch(transactions []*Transaction, lastError error) (*DeadLetterBatch, error) {
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := json.Marshal(transactions)
+	if err != nil {
+		return nil, err
+	}
+
+	batch := &DeadLetterBatch{
+		ID:        id,
+		Size:      len(transactions),
+		Payload:   string(payload),
+		CreatedAt: time.Now().UTC(),
+	}
+	if lastError != nil {
+		batch.LastError = lastError.Error()
+	}
+
+	return batch, nil
+}
+
+// Transactions decodes the transactions kept in the batch.
+func (b *DeadLetterBatch) Transactions() ([]*Transaction, error) {
+	var transactions []*Transaction
+	if err := json.Unmarshal([]byte(b.Payload), &transactions); err != nil {
+		return nil, err
+	}
+	return transactions, nil
+}
@@ -0,0 +1,93 @@
+package entities
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// ErrorKind is the category of a failure, it tells the clients whether to fix the request, retry it later or give up.
+type ErrorKind string
+
+const (
+	KIND_VALIDATION    ErrorKind = "validation"    // the request is malformed or has invalid values
+	KIND_UNAUTHORIZED  ErrorKind = "unauthorized"  // the request has no valid credentials
+	KIND_NOT_FOUND     ErrorKind = "not_found"     // the resource doesn't exist
+	KIND_CONFLICT      ErrorKind = "conflict"      // the request conflicts with the current state of the resource
+	KIND_UNPROCESSABLE ErrorKind = "unprocessable" // the request is valid but a business rule rejects it
+	KIND_UNAVAILABLE   ErrorKind = "unavailable"   // the request can be retried later
+	KIND_TIMEOUT       ErrorKind = "timeout"       // the outcome of the request is unknown
+)
+
+// Error is a failure the clients can act on, identified by a stable machine-readable code.
+// The errors without one are failures of the service and their messages are not sent to the clients.
+type Error struct {
+	Kind      ErrorKind
+	Code      string // e.g. insufficient_funds, never changes once released
+	Message   string
+	Pointer   string // JSON pointer of the invalid field of the request body, e.g. /amount
+	Parameter string // the invalid query parameter, e.g. created_from
+	Err       error  // the cause, when any
+}
+
+func NewError(kind ErrorKind, code, message string) *Error {
+	return &Error{Kind: kind, Code: code, Message: message}
+}
+
+// NewFieldError is a validation error of a field of the request body.
+func NewFieldError(code, pointer, message string) *Error {
+	return &Error{Kind: KIND_VALIDATION, Code: code, Message: message, Pointer: pointer}
+}
+
+// NewParameterError is a validation error of a query parameter.
+func NewParameterError(parameter, message string) *Error {
+	return &Error{Kind: KIND_VALIDATION, Code: "invalid_parameter", Message: message, Parameter: parameter}
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// WithCause returns a copy of the error caused by err, the message of the cause is left out of the message.
+func (e *Error) WithCause(err error) *Error {
+	caused := *e
+	caused.Err = err
+	return &caused
+}
+
+// Is matches the copies of the target made by WithCause.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Err == nil && e.Kind == t.Kind && e.Code == t.Code && e.Message == t.Message
+}
+
+// validationErrors translates the failed struct validations into field errors, in the order of the fields.
+func validationErrors(err validator.ValidationErrors) []error {
+	errs := make([]error, 0, len(err))
+	for _, fe := range err {
+		code := "invalid_value"
+		if fe.Tag() == "required" {
+			code = "required"
+		}
+		errs = append(errs, NewFieldError(code, "/"+snakeCase(fe.Field()), fe.Translate(trans)))
+	}
+	return errs
+}
+
+// snakeCase turns a field name into the name of its JSON field, e.g. UserID into user_id.
+func snakeCase(name string) string {
+	runes := []rune(name)
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) && i > 0 && (unicode.IsLower(runes[i-1]) || i+1 < len(runes) && unicode.IsLower(runes[i+1])) {
+			b.WriteByte('_')
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
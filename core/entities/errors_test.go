@@ -0,0 +1,60 @@
+package entities_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"user-transactions/core/entities"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Error(t *testing.T) {
+	t.Run("field errors of a transaction", func(t *testing.T) {
+		_, errs := entities.NewTransaction("desktop-web", "", 1000, entities.DEBIT)
+		if assert.Len(t, errs, 2) {
+			var sign, required *entities.Error
+			assert.True(t, errors.As(errs[0], &sign))
+			assert.Equal(t, entities.KIND_VALIDATION, sign.Kind)
+			assert.Equal(t, "amount_sign_mismatch", sign.Code)
+			assert.Equal(t, "/amount", sign.Pointer)
+
+			assert.True(t, errors.As(errs[1], &required))
+			assert.Equal(t, "required", required.Code)
+			assert.Equal(t, "/user_id", required.Pointer)
+			assert.Equal(t, "UserID is a required field", required.Error())
+		}
+	})
+
+	t.Run("wrapped errors keep their kind and code", func(t *testing.T) {
+		err := fmt.Errorf("%w: order-1", entities.ErrDuplicateExternalReference)
+
+		var typed *entities.Error
+		assert.ErrorIs(t, err, entities.ErrDuplicateExternalReference)
+		assert.True(t, errors.As(err, &typed))
+		assert.Equal(t, entities.KIND_CONFLICT, typed.Kind)
+		assert.Equal(t, "duplicate_external_reference", typed.Code)
+		assert.Equal(t, "/external_reference", typed.Pointer)
+	})
+
+	t.Run("errors with a cause match the error they were made from", func(t *testing.T) {
+		unavailable := entities.NewError(entities.KIND_UNAVAILABLE, "database_unavailable", "the database is unavailable")
+		cause := errors.New("sql: database is closed")
+		err := unavailable.WithCause(cause)
+
+		assert.ErrorIs(t, err, unavailable)
+		assert.ErrorIs(t, err, cause)
+		assert.Equal(t, "the database is unavailable", err.Error())
+		assert.Nil(t, unavailable.Err)
+		assert.NotErrorIs(t, unavailable, err)
+		assert.NotErrorIs(t, err, entities.NewError(entities.KIND_UNAVAILABLE, "queue_full", "the database is unavailable"))
+	})
+
+	t.Run("parameter errors", func(t *testing.T) {
+		err := entities.NewParameterError("sort", "sort must be one of [created_at -created_at amount -amount]")
+		assert.Equal(t, entities.KIND_VALIDATION, err.Kind)
+		assert.Equal(t, "invalid_parameter", err.Code)
+		assert.Equal(t, "sort", err.Parameter)
+		assert.Empty(t, err.Pointer)
+	})
+}
@@ -0,0 +1,119 @@
+package entities
+
+import (
+	"strings"
+	"time"
+)
+
+// BalanceFilter narrows the transactions that are summed up into a balance.
+type BalanceFilter struct {
+	Origin   string
+	Currency Currency   // all the currencies when empty
+	AsOf     *time.Time // inclusive, nil means up to now
+}
+
+// Match reports whether the transaction belongs to the balance of the user with the given filter.
+func (f *BalanceFilter) Match(userId string, transaction *Transaction) bool {
+	if transaction.UserID != userId {
+		return false
+	}
+	if f.Origin != "" && transaction.Origin != f.Origin {
+		return false
+	}
+	if f.Currency != "" && transaction.Currency != f.Currency {
+		return false
+	}
+	if f.AsOf != nil && transaction.CreatedAt.After(*f.AsOf) {
+		return false
+	}
+	return true
+}
+
+type TransactionSort string
+
+const (
+	SORT_CREATED_AT      TransactionSort = "created_at"
+	SORT_CREATED_AT_DESC TransactionSort = "-created_at"
+	SORT_AMOUNT          TransactionSort = "amount"
+	SORT_AMOUNT_DESC     TransactionSort = "-amount"
+)
+
+// TransactionFilter narrows and orders the listed transactions, the empty fields are not applied.
+type TransactionFilter struct {
+	Origin      string
+	UserID      string
+	Type        OperationType
+	Currency    Currency
+	Status      TransactionStatus
+	Metadata    map[string]string // all the key/values must be in the metadata of the transaction
+	CreatedFrom *time.Time        // inclusive
+	CreatedTo   *time.Time        // exclusive
+	MinAmount   *int64            // inclusive
+	MaxAmount   *int64            // inclusive
+	Sort        TransactionSort
+}
+
+// Match reports whether the transaction passes the filter, as the repository query does.
+func (f *TransactionFilter) Match(transaction *Transaction) bool {
+	switch {
+	case f.Origin != "" && transaction.Origin != f.Origin,
+		f.UserID != "" && transaction.UserID != f.UserID,
+		f.Type != "" && transaction.Type != f.Type,
+		f.Currency != "" && transaction.Currency != f.Currency,
+		f.Status != "" && transaction.Status != f.Status && !(f.Status == STATUS_POSTED && transaction.Posted()),
+		f.CreatedFrom != nil && transaction.CreatedAt.Before(*f.CreatedFrom),
+		f.CreatedTo != nil && !transaction.CreatedAt.Before(*f.CreatedTo),
+		f.MinAmount != nil && transaction.Amount < *f.MinAmount,
+		f.MaxAmount != nil && transaction.Amount > *f.MaxAmount:
+		return false
+	}
+	for key, value := range f.Metadata {
+		if stored, ok := transaction.Metadata[key]; !ok || stored != value {
+			return false
+		}
+	}
+	return true
+}
+
+// Compare orders the transactions as the repository does for the sort, the id breaks the ties.
+func (s TransactionSort) Compare(a, b *Transaction) int {
+	var cmp int
+	if s.Column() == "amount" {
+		switch {
+		case a.Amount < b.Amount:
+			cmp = -1
+		case a.Amount > b.Amount:
+			cmp = 1
+		}
+	} else {
+		cmp = a.CreatedAt.Compare(b.CreatedAt)
+	}
+	if cmp == 0 {
+		cmp = strings.Compare(a.ID.String(), b.ID.String())
+	}
+
+	if s.Desc() {
+		return -cmp
+	}
+	return cmp
+}
+
+// Column is the column used to order the transactions, the id breaks the ties.
+func (s TransactionSort) Column() string {
+	if s == SORT_AMOUNT || s == SORT_AMOUNT_DESC {
+		return "amount"
+	}
+	return "created_at"
+}
+
+func (s TransactionSort) Desc() bool {
+	return s == SORT_CREATED_AT_DESC || s == SORT_AMOUNT_DESC
+}
+
+func (s TransactionSort) Valid() bool {
+	switch s {
+	case SORT_CREATED_AT, SORT_CREATED_AT_DESC, SORT_AMOUNT, SORT_AMOUNT_DESC:
+		return true
+	}
+	return false
+}
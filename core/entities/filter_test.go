@@ -0,0 +1,46 @@
+package entities_test
+
+import (
+	"testing"
+	"time"
+	"user-transactions/core/entities"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_TransactionFilter_Match(t *testing.T) {
+	transaction, errs := entities.NewTransaction("desktop-web", "user123", 200, entities.CREDIT)
+	assert.Empty(t, errs)
+
+	from := transaction.CreatedAt.Add(-time.Minute)
+	to := transaction.CreatedAt
+	minAmount, maxAmount := int64(100), int64(200)
+
+	assert.True(t, (&entities.TransactionFilter{}).Match(transaction))
+	assert.True(t, (&entities.TransactionFilter{Origin: "desktop-web", UserID: "user123", Type: entities.CREDIT}).Match(transaction))
+	assert.True(t, (&entities.TransactionFilter{CreatedFrom: &from, MinAmount: &minAmount, MaxAmount: &maxAmount}).Match(transaction))
+	assert.False(t, (&entities.TransactionFilter{Origin: "mobile-android"}).Match(transaction))
+	assert.False(t, (&entities.TransactionFilter{Type: entities.DEBIT}).Match(transaction))
+	assert.True(t, (&entities.TransactionFilter{Currency: entities.DEFAULT_CURRENCY}).Match(transaction))
+	assert.False(t, (&entities.TransactionFilter{Currency: "USD"}).Match(transaction))
+	// created_to is exclusive
+	assert.False(t, (&entities.TransactionFilter{CreatedTo: &to}).Match(transaction))
+	assert.False(t, (&entities.TransactionFilter{MaxAmount: &minAmount}).Match(transaction))
+}
+
+func Test_TransactionSort_Compare(t *testing.T) {
+	now := time.Now().UTC()
+	first := &entities.Transaction{ID: uuid.MustParse("00000000-0000-0000-0000-000000000001"), Amount: 300, CreatedAt: now}
+	second := &entities.Transaction{ID: uuid.MustParse("00000000-0000-0000-0000-000000000002"), Amount: 300, CreatedAt: now.Add(time.Second)}
+	third := &entities.Transaction{ID: uuid.MustParse("00000000-0000-0000-0000-000000000003"), Amount: 100, CreatedAt: now.Add(time.Second)}
+
+	assert.Equal(t, -1, entities.SORT_CREATED_AT.Compare(first, second))
+	assert.Equal(t, 1, entities.SORT_CREATED_AT_DESC.Compare(first, second))
+	// the id breaks the ties
+	assert.Equal(t, -1, entities.SORT_CREATED_AT.Compare(second, third))
+	assert.Equal(t, -1, entities.SORT_AMOUNT.Compare(first, second))
+	assert.Equal(t, 1, entities.SORT_AMOUNT.Compare(first, third))
+	assert.Equal(t, -1, entities.SORT_AMOUNT_DESC.Compare(first, third))
+	assert.Equal(t, 0, entities.SORT_AMOUNT.Compare(first, first))
+}
@@ -0,0 +1,73 @@
+package entities
+
+import (
+	"time"
+)
+
+// TransactionStatus is the settlement of a transaction, a hold reserves the funds of a debit while pending
+// and is captured (posted) or voided later. It is not the CommitStatus of the bulk buffer.
+type TransactionStatus string
+
+const (
+	STATUS_PENDING TransactionStatus = "pending" // a hold, not in the ledger balance yet
+	STATUS_POSTED  TransactionStatus = "posted"
+	STATUS_VOIDED  TransactionStatus = "voided" // a hold released, voided or expired
+)
+
+var (
+	ErrNotPending  = NewError(KIND_CONFLICT, "not_pending", "only pending transactions can be captured or voided")
+	ErrHoldExpired = NewError(KIND_CONFLICT, "hold_expired", "the hold expired")
+	ErrNotPosted   = NewError(KIND_CONFLICT, "not_posted", "only posted transactions can be reversed")
+)
+
+// WithHold creates the transaction as a hold that expires at the given time if not captured or voided before.
+func WithHold(expiresAt time.Time) TransactionOption {
+	return func(t *Transaction) {
+		t.Status = STATUS_PENDING
+		expiresAt = expiresAt.UTC()
+		t.ExpiresAt = &expiresAt
+	}
+}
+
+func (s TransactionStatus) Valid() bool {
+	return s == STATUS_PENDING || s == STATUS_POSTED || s == STATUS_VOIDED
+}
+
+// Posted tells if the transaction is in the ledger balance, the transactions created before the holds have no status.
+func (t *Transaction) Posted() bool {
+	return t.Status == STATUS_POSTED || t.Status == ""
+}
+
+// Held is the amount reserved by a pending hold, zero for the other transactions.
+func (t *Transaction) Held() int64 {
+	if t.Status != STATUS_PENDING {
+		return 0
+	}
+	return -t.Amount
+}
+
+// Expired tells if the hold can no longer be captured at the given time.
+func (t *Transaction) Expired(now time.Time) bool {
+	return t.Status == STATUS_PENDING && t.ExpiresAt != nil && !now.Before(*t.ExpiresAt)
+}
+
+// Capture posts the pending hold, its amount moves from the held to the ledger balance.
+func (t *Transaction) Capture(now time.Time) error {
+	if t.Status != STATUS_PENDING {
+		return ErrNotPending
+	}
+	if t.Expired(now) {
+		return ErrHoldExpired
+	}
+	t.Status = STATUS_POSTED
+	return nil
+}
+
+// Void releases the pending hold, its amount is no longer held.
+func (t *Transaction) Void() error {
+	if t.Status != STATUS_PENDING {
+		return ErrNotPending
+	}
+	t.Status = STATUS_VOIDED
+	return nil
+}
@@ -0,0 +1,68 @@
+package entities_test
+
+import (
+	"testing"
+	"time"
+	"user-transactions/core/entities"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_NewTransaction_Hold(t *testing.T) {
+	expiresAt := time.Now().Add(time.Hour)
+
+	hold, errs := entities.NewTransaction("desktop-web", "user123", -150, entities.DEBIT, entities.WithHold(expiresAt))
+	assert.Empty(t, errs)
+	assert.Equal(t, entities.STATUS_PENDING, hold.Status)
+	assert.Equal(t, int64(150), hold.Held())
+	assert.False(t, hold.Posted())
+
+	posted, errs := entities.NewTransaction("desktop-web", "user123", -150, entities.DEBIT)
+	assert.Empty(t, errs)
+	assert.Equal(t, entities.STATUS_POSTED, posted.Status)
+	assert.Equal(t, int64(0), posted.Held())
+	assert.Nil(t, posted.ExpiresAt)
+
+	// only debits can be held, until a time after their creation
+	_, errs = entities.NewTransaction("desktop-web", "user123", 150, entities.CREDIT, entities.WithHold(expiresAt))
+	assert.NotEmpty(t, errs)
+	_, errs = entities.NewTransaction("desktop-web", "user123", -150, entities.DEBIT, entities.WithHold(time.Now().Add(-time.Minute)))
+	assert.NotEmpty(t, errs)
+}
+
+func Test_Transaction_Capture(t *testing.T) {
+	now := time.Now()
+	hold, errs := entities.NewTransaction("desktop-web", "user123", -150, entities.DEBIT, entities.WithHold(now.Add(time.Hour)))
+	assert.Empty(t, errs)
+
+	assert.ErrorIs(t, hold.Capture(now.Add(time.Hour)), entities.ErrHoldExpired)
+	assert.Equal(t, entities.STATUS_PENDING, hold.Status)
+
+	assert.NoError(t, hold.Capture(now))
+	assert.Equal(t, entities.STATUS_POSTED, hold.Status)
+	assert.True(t, hold.Posted())
+	assert.Equal(t, int64(0), hold.Held())
+
+	assert.ErrorIs(t, hold.Capture(now), entities.ErrNotPending)
+	assert.ErrorIs(t, hold.Void(), entities.ErrNotPending)
+}
+
+func Test_Transaction_Void(t *testing.T) {
+	now := time.Now()
+	hold, errs := entities.NewTransaction("desktop-web", "user123", -150, entities.DEBIT, entities.WithHold(now.Add(time.Hour)))
+	assert.Empty(t, errs)
+
+	// an expired hold can still be voided
+	assert.True(t, hold.Expired(now.Add(2*time.Hour)))
+	assert.NoError(t, hold.Void())
+	assert.Equal(t, entities.STATUS_VOIDED, hold.Status)
+	assert.False(t, hold.Posted())
+	assert.False(t, hold.Expired(now.Add(2*time.Hour)))
+
+	assert.ErrorIs(t, hold.Void(), entities.ErrNotPending)
+	assert.ErrorIs(t, hold.Capture(now), entities.ErrNotPending)
+
+	// a voided hold is not in the ledger, so it can't be reversed
+	_, errs = hold.Reverse(0, nil)
+	assert.ErrorIs(t, errs[0], entities.ErrNotPosted)
+}
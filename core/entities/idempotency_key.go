@@ -0,0 +1,58 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+)
+
+type IdempotencyStatus string
+
+const (
+	IDEMPOTENCY_PROCESSING IdempotencyStatus = "processing"
+	IDEMPOTENCY_COMPLETED  IdempotencyStatus = "completed"
+)
+
+// IdempotencyKey records the first submission made with a client provided key,
+// so repeated submissions can be recognized and answered with the original response.
+type IdempotencyKey struct {
+	Origin        string `gorm:"primaryKey" validate:"required"`
+	Key           string `gorm:"primaryKey" validate:"required,max=255"`
+	Fingerprint   string `validate:"required"`
+	TransactionID uuid.UUID
+	Status        IdempotencyStatus
+	Response      string // serialized response of the first submission
+	CreatedAt     time.Time
+	ExpiresAt     time.Time // a reservation still processing is taken over once expired, e.g. after a crash
+}
+
+func NewIdempotencyKey(origin, key, fingerprint string, transactionId uuid.UUID, ttl time.Duration) (*IdempotencyKey, []error) {
+	now := time.Now().UTC()
+	k := &IdempotencyKey{
+		Origin:        origin,
+		Key:           key,
+		Fingerprint:   fingerprint,
+		TransactionID: transactionId,
+		Status:        IDEMPOTENCY_PROCESSING,
+		CreatedAt:     now,
+		ExpiresAt:     now.Add(ttl),
+	}
+
+	if err := validate.Struct(k); err != nil {
+		var errs []error
+		// the key is sent in the Idempotency-Key header, not in the body
+		for _, fe := range err.(validator.ValidationErrors) {
+			errs = append(errs, NewError(KIND_VALIDATION, "invalid_idempotency_key", fe.Translate(trans)))
+		}
+		return nil, errs
+	}
+
+	return k, nil
+}
+
+// Expired tells whether the reservation can be taken over at now, the reservations made before they had an expiry
+// have none and are expired.
+func (k *IdempotencyKey) Expired(now time.Time) bool {
+	return k.Status == IDEMPOTENCY_PROCESSING && (k.ExpiresAt.IsZero() || k.ExpiresAt.Before(now))
+}
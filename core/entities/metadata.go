@@ -0,0 +1,107 @@
+package entities
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// Limits of the metadata of a transaction, so it can't grow the rows without bounds.
+const (
+	MetadataMaxKeys        = 50
+	MetadataMaxKeyLength   = 40
+	MetadataMaxValueLength = 500
+)
+
+// ExternalReferenceMaxLength is the longest reference of a transaction in the system of its origin.
+const ExternalReferenceMaxLength = 255
+
+var ErrDuplicateExternalReference = &Error{Kind: KIND_CONFLICT, Code: "duplicate_external_reference", Message: "a transaction with this external reference already exists for the origin", Pointer: "/external_reference"}
+
+// metadataKey is also safe to use in the JSON paths of the metadata filters.
+var metadataKey = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// Metadata is a set of free-form key/values of a transaction, e.g. its order ID or description.
+// It is stored as JSONB on Postgres and as JSON text on the other databases.
+type Metadata map[string]string
+
+// WithMetadata sets the metadata of the transaction.
+func WithMetadata(metadata map[string]string) TransactionOption {
+	return func(t *Transaction) {
+		if len(metadata) > 0 {
+			t.Metadata = metadata
+		}
+	}
+}
+
+// WithExternalReference sets the reference of the transaction in the system of its origin, unique per origin.
+func WithExternalReference(reference string) TransactionOption {
+	return func(t *Transaction) {
+		if reference != "" {
+			t.ExternalReference = &reference
+		}
+	}
+}
+
+// ValidMetadataKey tells if the key can be used in the metadata.
+func ValidMetadataKey(key string) bool {
+	return len(key) <= MetadataMaxKeyLength && metadataKey.MatchString(key)
+}
+
+func (m Metadata) validate() (errs []error) {
+	if len(m) > MetadataMaxKeys {
+		errs = append(errs, NewFieldError("invalid_metadata", "/metadata", fmt.Sprintf("Metadata must have at most %d keys", MetadataMaxKeys)))
+	}
+	for key, value := range m {
+		if !ValidMetadataKey(key) {
+			errs = append(errs, NewFieldError("invalid_metadata", "/metadata", fmt.Sprintf("Metadata key %q must have at most %d letters, digits, _ or -", key, MetadataMaxKeyLength)))
+		}
+		if utf8.RuneCountInString(value) > MetadataMaxValueLength {
+			errs = append(errs, NewFieldError("invalid_metadata", "/metadata/"+key, fmt.Sprintf("Metadata value of %q must have at most %d characters", key, MetadataMaxValueLength)))
+		}
+	}
+	return errs
+}
+
+func (m Metadata) Value() (driver.Value, error) {
+	if m == nil {
+		return nil, nil
+	}
+	content, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	return string(content), nil
+}
+
+func (m *Metadata) Scan(value any) error {
+	var content []byte
+	switch v := value.(type) {
+	case nil:
+		*m = nil
+		return nil
+	case string:
+		content = []byte(v)
+	case []byte:
+		content = v
+	default:
+		return fmt.Errorf("can't scan the metadata from %T", value)
+	}
+	return json.Unmarshal(content, m)
+}
+
+func (Metadata) GormDataType() string {
+	return "json"
+}
+
+func (Metadata) GormDBDataType(db *gorm.DB, field *schema.Field) string {
+	if db.Dialector.Name() == "postgres" {
+		return "JSONB"
+	}
+	return "TEXT"
+}
@@ -0,0 +1,65 @@
+package entities_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"user-transactions/core/entities"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_NewTransaction_Metadata(t *testing.T) {
+	transaction, errs := entities.NewTransaction("desktop-web", "user123", 100, entities.CREDIT,
+		entities.WithExternalReference("order-123"),
+		entities.WithMetadata(map[string]string{"order_id": "123", "description": "Monthly plan"}))
+	assert.Empty(t, errs)
+	assert.Equal(t, "order-123", *transaction.ExternalReference)
+	assert.Equal(t, entities.Metadata{"order_id": "123", "description": "Monthly plan"}, transaction.Metadata)
+
+	// empty values are not set
+	transaction, errs = entities.NewTransaction("desktop-web", "user123", 100, entities.CREDIT, entities.WithExternalReference(""), entities.WithMetadata(nil))
+	assert.Empty(t, errs)
+	assert.Nil(t, transaction.ExternalReference)
+	assert.Nil(t, transaction.Metadata)
+
+	tooMany := make(map[string]string)
+	for i := 0; i <= entities.MetadataMaxKeys; i++ {
+		tooMany[fmt.Sprintf("key%d", i)] = "value"
+	}
+	for name, metadata := range map[string]map[string]string{
+		"too many keys":    tooMany,
+		"an invalid key":   {"order id": "123"},
+		"a key too long":   {strings.Repeat("k", entities.MetadataMaxKeyLength+1): "123"},
+		"a value too long": {"description": strings.Repeat("v", entities.MetadataMaxValueLength+1)},
+	} {
+		_, errs := entities.NewTransaction("desktop-web", "user123", 100, entities.CREDIT, entities.WithMetadata(metadata))
+		assert.NotEmpty(t, errs, name)
+	}
+
+	_, errs = entities.NewTransaction("desktop-web", "user123", 100, entities.CREDIT, entities.WithExternalReference(strings.Repeat("r", entities.ExternalReferenceMaxLength+1)))
+	assert.NotEmpty(t, errs)
+}
+
+func Test_Metadata_Scan(t *testing.T) {
+	metadata := entities.Metadata{"order_id": "123"}
+	value, err := metadata.Value()
+	assert.NoError(t, err)
+	assert.Equal(t, `{"order_id":"123"}`, value)
+
+	var scanned entities.Metadata
+	assert.NoError(t, scanned.Scan([]byte(`{"order_id":"123"}`)))
+	assert.Equal(t, metadata, scanned)
+	assert.NoError(t, scanned.Scan(nil))
+	assert.Nil(t, scanned)
+	assert.Error(t, scanned.Scan(42))
+}
+
+func Test_TransactionFilter_Match_Metadata(t *testing.T) {
+	transaction, errs := entities.NewTransaction("desktop-web", "user123", 100, entities.CREDIT, entities.WithMetadata(map[string]string{"order_id": "123", "channel": "web"}))
+	assert.Empty(t, errs)
+
+	assert.True(t, (&entities.TransactionFilter{Metadata: map[string]string{"order_id": "123"}}).Match(transaction))
+	assert.False(t, (&entities.TransactionFilter{Metadata: map[string]string{"order_id": "456"}}).Match(transaction))
+	assert.False(t, (&entities.TransactionFilter{Metadata: map[string]string{"order_id": "123", "store": ""}}).Match(transaction))
+}
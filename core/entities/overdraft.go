@@ -0,0 +1,51 @@
+package entities
+
+import (
+	"fmt"
+)
+
+var ErrInsufficientFunds = NewError(KIND_UNPROCESSABLE, "insufficient_funds", "insufficient funds")
+
+// OverdraftPolicy limits how far below zero the debits can take the balance of a user, in the minor unit of
+// the currency of the debit. The limit of the user has precedence over the limit of the origin of the debit,
+// which has precedence over the default one.
+type OverdraftPolicy struct {
+	Default  *int64 // the debits without a user or origin limit are not checked when nil
+	ByUser   map[string]int64
+	ByOrigin map[string]int64
+}
+
+func NewOverdraftPolicy(defaultLimit *int64, byUser, byOrigin map[string]int64) *OverdraftPolicy {
+	return &OverdraftPolicy{
+		Default:  defaultLimit,
+		ByUser:   byUser,
+		ByOrigin: byOrigin,
+	}
+}
+
+// Limit returns the overdraft limit of the transaction, false when it is not a debit or it has no limit.
+func (p *OverdraftPolicy) Limit(transaction *Transaction) (int64, bool) {
+	if p == nil || transaction.Type != DEBIT {
+		return 0, false
+	}
+	if limit, ok := p.ByUser[transaction.UserID]; ok {
+		return limit, true
+	}
+	if limit, ok := p.ByOrigin[transaction.Origin]; ok {
+		return limit, true
+	}
+	if p.Default != nil {
+		return *p.Default, true
+	}
+	return 0, false
+}
+
+// Check returns ErrInsufficientFunds when the balance after the debit is below the limit of the transaction.
+func (p *OverdraftPolicy) Check(transaction *Transaction, balanceAfter int64) error {
+	limit, ok := p.Limit(transaction)
+	if !ok || balanceAfter >= -limit {
+		return nil
+	}
+	return fmt.Errorf("%w: the balance of %s would be %d %s, below the overdraft limit of %d",
+		ErrInsufficientFunds, transaction.UserID, balanceAfter, transaction.Currency, limit)
+}
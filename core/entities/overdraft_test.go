@@ -0,0 +1,60 @@
+package entities_test
+
+import (
+	"testing"
+	"user-transactions/core/entities"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_OverdraftPolicy_Limit(t *testing.T) {
+	defaultLimit := int64(1000)
+	policy := entities.NewOverdraftPolicy(&defaultLimit, map[string]int64{"user123": 5000}, map[string]int64{"desktop-web": 0, "mobile-android": 200})
+
+	debit := func(origin, userId string) *entities.Transaction {
+		transaction, errs := entities.NewTransaction(origin, userId, -100, entities.DEBIT)
+		assert.Empty(t, errs)
+		return transaction
+	}
+
+	// the limit of the user has precedence over the one of the origin, which has precedence over the default
+	limit, ok := policy.Limit(debit("desktop-web", "user123"))
+	assert.True(t, ok)
+	assert.Equal(t, int64(5000), limit)
+	limit, ok = policy.Limit(debit("mobile-android", "user456"))
+	assert.True(t, ok)
+	assert.Equal(t, int64(200), limit)
+	limit, ok = policy.Limit(debit("desktop-web", "user456"))
+	assert.True(t, ok)
+	assert.Equal(t, int64(0), limit)
+	limit, ok = policy.Limit(debit("mobile-ios", "user456"))
+	assert.True(t, ok)
+	assert.Equal(t, int64(1000), limit)
+
+	// the credits are never limited
+	credit, errs := entities.NewTransaction("desktop-web", "user123", 100, entities.CREDIT)
+	assert.Empty(t, errs)
+	_, ok = policy.Limit(credit)
+	assert.False(t, ok)
+
+	// without a default, only the debits of the users and origins configured are limited
+	_, ok = entities.NewOverdraftPolicy(nil, map[string]int64{"user123": 0}, nil).Limit(debit("desktop-web", "user456"))
+	assert.False(t, ok)
+
+	var none *entities.OverdraftPolicy
+	_, ok = none.Limit(debit("desktop-web", "user123"))
+	assert.False(t, ok)
+}
+
+func Test_OverdraftPolicy_Check(t *testing.T) {
+	policy := entities.NewOverdraftPolicy(nil, map[string]int64{"user123": 500}, nil)
+	debit, errs := entities.NewTransaction("desktop-web", "user123", -100, entities.DEBIT)
+	assert.Empty(t, errs)
+
+	assert.NoError(t, policy.Check(debit, 0))
+	assert.NoError(t, policy.Check(debit, -500))
+
+	err := policy.Check(debit, -501)
+	assert.ErrorIs(t, err, entities.ErrInsufficientFunds)
+	assert.Equal(t, "insufficient funds: the balance of user123 would be -501 BRL, below the overdraft limit of 500", err.Error())
+}
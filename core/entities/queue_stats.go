@@ -0,0 +1,8 @@
+package entities
+
+// QueueStats describes the transactions accepted by the bulk mode that are not committed yet.
+type QueueStats struct {
+	Depth    int // transactions waiting in the queue for the bulk writer
+	Capacity int // transactions the queue holds before rejecting new ones
+	Pending  int // transactions accepted and not committed yet, including the ones being committed
+}
@@ -0,0 +1,107 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type ReversalStatus string
+
+const (
+	NOT_REVERSED       ReversalStatus = "none"
+	PARTIALLY_REVERSED ReversalStatus = "partial"
+	REVERSED           ReversalStatus = "full"
+)
+
+var (
+	ErrReversalOfReversal      = NewError(KIND_CONFLICT, "reversal_of_reversal", "a reversal cannot be reversed")
+	ErrReversalOfTransferLeg   = NewError(KIND_CONFLICT, "reversal_of_transfer_leg", "a transaction of a transfer cannot be reversed on its own")
+	ErrAlreadyReversed         = NewError(KIND_CONFLICT, "already_reversed", "transaction is already fully reversed")
+	ErrReversalExceedsOriginal = NewError(KIND_CONFLICT, "reversal_exceeds_original", "reversal amount exceeds the amount left to reverse")
+)
+
+// Reverse creates the compensating transaction of the given absolute amount, zero reverses all that is left.
+// The reversals already made to the transaction are used to not reverse more than the original amount.
+// The legs of a transfer are not reversed, reversing one would leave the other user with the amount.
+func (t *Transaction) Reverse(amount int64, reversals []*Transaction) (*Transaction, []error) {
+	if t.ReversalOf != nil {
+		return nil, []error{ErrReversalOfReversal}
+	}
+	if t.TransferID != nil {
+		return nil, []error{ErrReversalOfTransferLeg}
+	}
+	if !t.Posted() {
+		return nil, []error{ErrNotPosted}
+	}
+
+	left := abs(t.Amount) - ReversedAmount(reversals)
+	if left <= 0 {
+		return nil, []error{ErrAlreadyReversed}
+	}
+
+	if amount < 0 {
+		return nil, []error{NewFieldError("invalid_amount", "/amount", "Amount to reverse must be positive")}
+	}
+	if amount == 0 {
+		amount = left
+	}
+	if amount > left {
+		return nil, []error{ErrReversalExceedsOriginal}
+	}
+
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return nil, []error{err}
+	}
+
+	reversal := &Transaction{
+		ID:         id,
+		Origin:     t.Origin,
+		UserID:     t.UserID,
+		Amount:     amount,
+		Type:       CREDIT,
+		Currency:   t.Currency,
+		ReversalOf: &t.ID,
+		Status:     STATUS_POSTED,
+		CreatedAt:  time.Now().UTC(),
+	}
+	if t.Type == CREDIT {
+		reversal.Amount = -amount
+		reversal.Type = DEBIT
+	}
+
+	if err := reversal.validate(); err != nil {
+		return nil, err
+	}
+
+	return reversal, nil
+}
+
+// ReversalStatus tells how much of the transaction was compensated by its reversals.
+func (t *Transaction) ReversalStatus(reversals []*Transaction) ReversalStatus {
+	reversed := ReversedAmount(reversals)
+	switch {
+	case reversed == 0:
+		return NOT_REVERSED
+	case reversed < abs(t.Amount):
+		return PARTIALLY_REVERSED
+	default:
+		return REVERSED
+	}
+}
+
+// ReversedAmount sums the absolute amount of the reversals.
+func ReversedAmount(reversals []*Transaction) (total int64) {
+	for _, reversal := range reversals {
+		total += abs(reversal.Amount)
+	}
+	return
+}
+
+func abs(amount int64) int64 {
+	if amount < 0 {
+		return -amount
+	}
+	return amount
+}
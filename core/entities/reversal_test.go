@@ -0,0 +1,102 @@
+package entities_test
+
+import (
+	"testing"
+	"user-transactions/core/entities"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Transaction_Reverse(t *testing.T) {
+	credit, errs := entities.NewTransaction("desktop-web", "user123", 1000, entities.CREDIT)
+	assert.Empty(t, errs)
+
+	t.Run("reverse all the transaction", func(t *testing.T) {
+		reversal, errs := credit.Reverse(0, nil)
+		assert.Empty(t, errs)
+		assert.NotEqual(t, credit.ID, reversal.ID)
+		assert.Equal(t, credit.Origin, reversal.Origin)
+		assert.Equal(t, credit.UserID, reversal.UserID)
+		assert.Equal(t, int64(-1000), reversal.Amount)
+		assert.Equal(t, entities.DEBIT, reversal.Type)
+		assert.Equal(t, credit.ID, *reversal.ReversalOf)
+		assert.Equal(t, credit.Currency, reversal.Currency)
+	})
+
+	t.Run("reverse part of a debit transaction", func(t *testing.T) {
+		debit, errs := entities.NewTransaction("desktop-web", "user123", -1000, entities.DEBIT)
+		assert.Empty(t, errs)
+
+		reversal, errs := debit.Reverse(300, nil)
+		assert.Empty(t, errs)
+		assert.Equal(t, int64(300), reversal.Amount)
+		assert.Equal(t, entities.CREDIT, reversal.Type)
+	})
+
+	t.Run("reverse what is left of the transaction", func(t *testing.T) {
+		previous, errs := credit.Reverse(400, nil)
+		assert.Empty(t, errs)
+
+		reversal, errs := credit.Reverse(0, []*entities.Transaction{previous})
+		assert.Empty(t, errs)
+		assert.Equal(t, int64(-600), reversal.Amount)
+	})
+
+	t.Run("don't reverse a transaction twice", func(t *testing.T) {
+		previous, errs := credit.Reverse(0, nil)
+		assert.Empty(t, errs)
+
+		reversal, errs := credit.Reverse(0, []*entities.Transaction{previous})
+		assert.Nil(t, reversal)
+		assert.ErrorIs(t, errs[0], entities.ErrAlreadyReversed)
+	})
+
+	t.Run("don't reverse more than what is left", func(t *testing.T) {
+		previous, errs := credit.Reverse(700, nil)
+		assert.Empty(t, errs)
+
+		reversal, errs := credit.Reverse(400, []*entities.Transaction{previous})
+		assert.Nil(t, reversal)
+		assert.ErrorIs(t, errs[0], entities.ErrReversalExceedsOriginal)
+	})
+
+	t.Run("don't reverse a negative amount", func(t *testing.T) {
+		reversal, errs := credit.Reverse(-100, nil)
+		assert.Nil(t, reversal)
+		assert.Equal(t, "Amount to reverse must be positive", errs[0].Error())
+	})
+
+	t.Run("don't reverse a reversal", func(t *testing.T) {
+		previous, errs := credit.Reverse(0, nil)
+		assert.Empty(t, errs)
+
+		reversal, errs := previous.Reverse(0, nil)
+		assert.Nil(t, reversal)
+		assert.ErrorIs(t, errs[0], entities.ErrReversalOfReversal)
+	})
+
+	t.Run("don't reverse a leg of a transfer", func(t *testing.T) {
+		transfer, errs := entities.NewTransfer("desktop-web", "user123", "user456", 500)
+		assert.Empty(t, errs)
+
+		for _, leg := range []*entities.Transaction{transfer.Debit, transfer.Credit} {
+			reversal, errs := leg.Reverse(0, nil)
+			assert.Nil(t, reversal)
+			assert.ErrorIs(t, errs[0], entities.ErrReversalOfTransferLeg)
+		}
+	})
+}
+
+func Test_Transaction_ReversalStatus(t *testing.T) {
+	credit, errs := entities.NewTransaction("desktop-web", "user123", 1000, entities.CREDIT)
+	assert.Empty(t, errs)
+	partial, errs := credit.Reverse(400, nil)
+	assert.Empty(t, errs)
+	rest, errs := credit.Reverse(600, nil)
+	assert.Empty(t, errs)
+
+	assert.Equal(t, entities.NOT_REVERSED, credit.ReversalStatus(nil))
+	assert.Equal(t, entities.PARTIALLY_REVERSED, credit.ReversalStatus([]*entities.Transaction{partial}))
+	assert.Equal(t, entities.REVERSED, credit.ReversalStatus([]*entities.Transaction{partial, rest}))
+	assert.Equal(t, int64(1000), entities.ReversedAmount([]*entities.Transaction{partial, rest}))
+}
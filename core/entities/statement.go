@@ -0,0 +1,45 @@
+package entities
+
+import "time"
+
+// Statement is the account statement of a user in a currency for a period, the posted transactions
+// created in the period in the order they were created with the balance after each of them.
+type Statement struct {
+	UserID   string
+	Currency Currency
+	From     time.Time // inclusive
+	To       time.Time // exclusive
+	Opening  int64     // ledger balance before the period
+	Closing  int64     // ledger balance at the end of the period
+	Credits  int64     // sum of the positive amounts
+	Debits   int64     // sum of the negative amounts
+	Lines    []StatementLine
+}
+
+// StatementLine is a transaction of a statement and the running balance after it.
+type StatementLine struct {
+	Transaction *Transaction
+	Balance     int64
+}
+
+func NewStatement(userId string, currency Currency, from, to time.Time, opening int64) *Statement {
+	return &Statement{
+		UserID:   userId,
+		Currency: currency,
+		From:     from,
+		To:       to,
+		Opening:  opening,
+		Closing:  opening,
+	}
+}
+
+// Add appends the transaction to the statement, the transactions must be added in the order they were created.
+func (s *Statement) Add(transaction *Transaction) {
+	s.Closing += transaction.Amount
+	if transaction.Amount > 0 {
+		s.Credits += transaction.Amount
+	} else {
+		s.Debits += transaction.Amount
+	}
+	s.Lines = append(s.Lines, StatementLine{Transaction: transaction, Balance: s.Closing})
+}
@@ -0,0 +1,35 @@
+package entities_test
+
+import (
+	"testing"
+	"time"
+	"user-transactions/core/entities"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Statement_Add(t *testing.T) {
+	from := time.Date(2023, 11, 1, 0, 0, 0, 0, time.UTC)
+	statement := entities.NewStatement("user123", "BRL", from, from.AddDate(0, 1, 0), 1000)
+	assert.Equal(t, int64(1000), statement.Closing)
+
+	for _, amount := range []int64{500, -200, -1500} {
+		opType := entities.CREDIT
+		if amount < 0 {
+			opType = entities.DEBIT
+		}
+		transaction, errs := entities.NewTransaction("desktop-web", "user123", amount, opType)
+		assert.Empty(t, errs)
+		statement.Add(transaction)
+	}
+
+	assert.Equal(t, int64(1000), statement.Opening)
+	assert.Equal(t, int64(-200), statement.Closing)
+	assert.Equal(t, int64(500), statement.Credits)
+	assert.Equal(t, int64(-1700), statement.Debits)
+	if assert.Len(t, statement.Lines, 3) {
+		assert.Equal(t, int64(1500), statement.Lines[0].Balance)
+		assert.Equal(t, int64(1300), statement.Lines[1].Balance)
+		assert.Equal(t, int64(-200), statement.Lines[2].Balance)
+	}
+}
@@ -0,0 +1,67 @@
+package entities
+
+import "time"
+
+// SummaryGroup is a column the transaction totals can be grouped by, the totals are always grouped by currency.
+type SummaryGroup string
+
+const (
+	GROUP_ORIGIN  SummaryGroup = "origin"
+	GROUP_TYPE    SummaryGroup = "type"
+	GROUP_USER_ID SummaryGroup = "user_id"
+)
+
+func (g SummaryGroup) Valid() bool {
+	return g == GROUP_ORIGIN || g == GROUP_TYPE || g == GROUP_USER_ID
+}
+
+// SummaryInterval is the period the transaction totals are bucketed by, from the start of the period in UTC.
+type SummaryInterval string
+
+const (
+	INTERVAL_DAY   SummaryInterval = "day"
+	INTERVAL_WEEK  SummaryInterval = "week" // starting on Monday
+	INTERVAL_MONTH SummaryInterval = "month"
+)
+
+func (i SummaryInterval) Valid() bool {
+	return i == INTERVAL_DAY || i == INTERVAL_WEEK || i == INTERVAL_MONTH
+}
+
+// TransactionSummary totals the transactions of a group, the fields not grouped by are empty.
+type TransactionSummary struct {
+	Period   *time.Time // start of the interval, nil without one
+	Origin   string
+	UserID   string
+	Type     OperationType
+	Currency Currency
+	Count    int64
+	Total    int64 // sum of the amounts, in the minor unit of the currency
+	Credits  int64 // sum of the positive amounts
+	Debits   int64 // sum of the negative amounts
+}
+
+// Start is the beginning of the interval the time is in, in UTC.
+func (i SummaryInterval) Start(t time.Time) time.Time {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	switch i {
+	case INTERVAL_WEEK:
+		// Go weeks start on Sunday
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	case INTERVAL_MONTH:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+	return day
+}
+
+// Add adds the transaction to the totals.
+func (s *TransactionSummary) Add(transaction *Transaction) {
+	s.Count++
+	s.Total += transaction.Amount
+	if transaction.Amount > 0 {
+		s.Credits += transaction.Amount
+	} else {
+		s.Debits += transaction.Amount
+	}
+}
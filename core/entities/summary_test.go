@@ -0,0 +1,37 @@
+package entities_test
+
+import (
+	"testing"
+	"time"
+	"user-transactions/core/entities"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_SummaryInterval_Start(t *testing.T) {
+	// a Sunday evening in São Paulo is already Monday in UTC
+	sunday := time.Date(2023, 11, 19, 22, 30, 0, 0, time.FixedZone("BRT", -3*60*60))
+
+	assert.Equal(t, time.Date(2023, 11, 20, 0, 0, 0, 0, time.UTC), entities.INTERVAL_DAY.Start(sunday))
+	assert.Equal(t, time.Date(2023, 11, 20, 0, 0, 0, 0, time.UTC), entities.INTERVAL_WEEK.Start(sunday))
+	assert.Equal(t, time.Date(2023, 11, 13, 0, 0, 0, 0, time.UTC), entities.INTERVAL_WEEK.Start(sunday.Add(-3*time.Hour)))
+	assert.Equal(t, time.Date(2023, 11, 1, 0, 0, 0, 0, time.UTC), entities.INTERVAL_MONTH.Start(sunday))
+}
+
+func Test_TransactionSummary_Add(t *testing.T) {
+	summary := &entities.TransactionSummary{}
+	for _, amount := range []int64{500, -200, 100} {
+		opType := entities.CREDIT
+		if amount < 0 {
+			opType = entities.DEBIT
+		}
+		transaction, errs := entities.NewTransaction("desktop-web", "user123", amount, opType)
+		assert.Empty(t, errs)
+		summary.Add(transaction)
+	}
+
+	assert.Equal(t, int64(3), summary.Count)
+	assert.Equal(t, int64(400), summary.Total)
+	assert.Equal(t, int64(600), summary.Credits)
+	assert.Equal(t, int64(-200), summary.Debits)
+}
@@ -3,6 +3,7 @@ package entities
 import (
 	"fmt"
 	"time"
+	"unicode/utf8"
 
 	"github.com/go-playground/locales/en"
 	ut "github.com/go-playground/universal-translator"
@@ -18,13 +19,30 @@ const (
 	CREDIT OperationType = "credit"
 )
 
+type CommitStatus string
+
+const (
+	COMMIT_PENDING CommitStatus = "pending"   // still in the bulk buffer
+	COMMITTED      CommitStatus = "committed" // stored in the database
+)
+
+// The (created_at, id) indexes serve the pages sorted by creation, keyset or offset, in both directions, also of the
+// transactions of a user or an origin.
 type Transaction struct {
-	ID        uuid.UUID
-	Origin    string        `gorm:"index:idx_origin;index:idx_transaction" validate:"required"`
-	UserID    string        `gorm:"index:idx_user_iD;index:idx_transaction" validate:"required"`
-	Amount    int64         `gorm:"index:idx_amount;index:idx_transaction" validate:"required,numeric"` // cents, 0 is not allowed
-	Type      OperationType `gorm:"index:idx_type;index:idx_transaction" validate:"required,oneof=debit credit"`
-	CreatedAt time.Time
+	ID                uuid.UUID         `gorm:"index:idx_created_at_id,priority:2;index:idx_user_id_created_at_id,priority:3;index:idx_origin_created_at_id,priority:3"`
+	Origin            string            `gorm:"index:idx_origin;index:idx_transaction;uniqueIndex:idx_origin_external_reference;index:idx_origin_created_at_id,priority:1" validate:"required"`
+	UserID            string            `gorm:"index:idx_user_iD;index:idx_transaction;index:idx_user_id_created_at_id,priority:1" validate:"required"`
+	Amount            int64             `gorm:"index:idx_amount;index:idx_transaction" validate:"required,numeric"` // minor units of the currency (e.g. cents), 0 is not allowed
+	Type              OperationType     `gorm:"index:idx_type;index:idx_transaction" validate:"required,oneof=debit credit"`
+	Currency          Currency          `gorm:"size:3;index:idx_currency" validate:"required"` // ISO 4217 code
+	ReversalOf        *uuid.UUID        `gorm:"index:idx_reversal_of"`                         // the transaction compensated by this one
+	TransferID        *uuid.UUID        `gorm:"index:idx_transfer_id"`                         // the transfer this transaction is a leg of
+	Status            TransactionStatus `gorm:"size:7;index:idx_status;default:posted"`
+	ExpiresAt         *time.Time        `gorm:"index:idx_expires_at"`                               // when a pending hold is voided if not captured
+	ExternalReference *string           `gorm:"size:255;uniqueIndex:idx_origin_external_reference"` // e.g. the order ID in the system of the origin
+	Metadata          Metadata
+	CreatedAt         time.Time `gorm:"index:idx_created_at_id,priority:1;index:idx_user_id_created_at_id,priority:2;index:idx_origin_created_at_id,priority:2"`
+	Pending           bool      `gorm:"-" json:"-"` // accepted by the bulk mode and not committed yet
 }
 
 var (
@@ -42,7 +60,17 @@ func init() {
 	en_translations.RegisterDefaultTranslations(validate, trans)
 }
 
-func NewTransaction(origin, userId string, amount int64, opType OperationType) (*Transaction, []error) {
+// TransactionOption sets an optional field of a new transaction.
+type TransactionOption func(*Transaction)
+
+// WithCurrency sets the currency of the transaction, DEFAULT_CURRENCY is used without it.
+func WithCurrency(currency Currency) TransactionOption {
+	return func(t *Transaction) {
+		t.Currency = currency
+	}
+}
+
+func NewTransaction(origin, userId string, amount int64, opType OperationType, opts ...TransactionOption) (*Transaction, []error) {
 	id, err := uuid.NewRandom()
 	if err != nil {
 		return nil, []error{err}
@@ -54,8 +82,13 @@ func NewTransaction(origin, userId string, amount int64, opType OperationType) (
 		UserID:    userId,
 		Amount:    amount,
 		Type:      opType,
+		Currency:  DEFAULT_CURRENCY,
+		Status:    STATUS_POSTED,
 		CreatedAt: time.Now().UTC(),
 	}
+	for _, opt := range opts {
+		opt(t)
+	}
 
 	if err := t.validate(); err != nil {
 		return nil, err
@@ -66,11 +99,30 @@ func NewTransaction(origin, userId string, amount int64, opType OperationType) (
 
 func (t *Transaction) validate() (errs []error) {
 	if t.Type == DEBIT && t.Amount > 0 {
-		errs = append(errs, fmt.Errorf("Amount must be negative for debit transactions"))
+		errs = append(errs, NewFieldError("amount_sign_mismatch", "/amount", "Amount must be negative for debit transactions"))
 	}
 
 	if t.Type == CREDIT && t.Amount < 0 {
-		errs = append(errs, fmt.Errorf("Amount must be positive for credit transactions"))
+		errs = append(errs, NewFieldError("amount_sign_mismatch", "/amount", "Amount must be positive for credit transactions"))
+	}
+
+	if t.Currency != "" && !t.Currency.Valid() {
+		errs = append(errs, NewFieldError("invalid_currency", "/currency", "Currency must be an ISO 4217 code"))
+	}
+
+	if t.ExternalReference != nil && utf8.RuneCountInString(*t.ExternalReference) > ExternalReferenceMaxLength {
+		errs = append(errs, NewFieldError("too_long", "/external_reference", fmt.Sprintf("ExternalReference must have at most %d characters", ExternalReferenceMaxLength)))
+	}
+
+	errs = append(errs, t.Metadata.validate()...)
+
+	if t.Status == STATUS_PENDING {
+		if t.Type != DEBIT {
+			errs = append(errs, NewFieldError("invalid_hold", "/hold", "Only debit transactions can be held"))
+		}
+		if t.ExpiresAt == nil || !t.ExpiresAt.After(t.CreatedAt) {
+			errs = append(errs, NewFieldError("invalid_expires_at", "/expires_at", "ExpiresAt of a hold must be in the future"))
+		}
 	}
 
 	err := validate.Struct(t)
@@ -78,14 +130,17 @@ func (t *Transaction) validate() (errs []error) {
 		return
 	}
 
-	verrs := err.(validator.ValidationErrors).Translate(trans)
-	for _, v := range verrs {
-		errs = append(errs, fmt.Errorf(v))
-	}
-
+	errs = append(errs, validationErrors(err.(validator.ValidationErrors))...)
 	return
 }
 
+func (t *Transaction) CommitStatus() CommitStatus {
+	if t.Pending {
+		return COMMIT_PENDING
+	}
+	return COMMITTED
+}
+
 func (ot *OperationType) String() string {
 	return string(*ot)
 }
@@ -115,6 +115,35 @@ func Test_NewTransaction(t *testing.T) {
 		assert.Nil(t, transaction)
 		assert.Equal(t, "Type must be one of [debit credit]", err[0].Error())
 	})
+
+	t.Run("create transaction with a currency", func(t *testing.T) {
+		transaction, err := entities.NewTransaction("desktop-web", "123", 1000, entities.CREDIT, entities.WithCurrency("JPY"))
+		assert.Empty(t, err)
+		assert.Equal(t, entities.Currency("JPY"), transaction.Currency)
+	})
+
+	t.Run("create transaction with the default currency", func(t *testing.T) {
+		transaction, err := entities.NewTransaction("desktop-web", "123", 1000, entities.CREDIT)
+		assert.Empty(t, err)
+		assert.Equal(t, entities.DEFAULT_CURRENCY, transaction.Currency)
+	})
+
+	t.Run("create transaction with invalid currency", func(t *testing.T) {
+		transaction, err := entities.NewTransaction("desktop-web", "123", 1000, entities.CREDIT, entities.WithCurrency("usd"))
+		assert.Equal(t, len(err), 1)
+		assert.Nil(t, transaction)
+		assert.Equal(t, "Currency must be an ISO 4217 code", err[0].Error())
+	})
+}
+
+func Test_Currency_Exponent(t *testing.T) {
+	assert.Equal(t, 2, entities.Currency("BRL").Exponent())
+	assert.Equal(t, 2, entities.Currency("USD").Exponent())
+	assert.Equal(t, 2, entities.Currency("EUR").Exponent())
+	assert.Equal(t, 0, entities.Currency("JPY").Exponent())
+	assert.Equal(t, 3, entities.Currency("KWD").Exponent())
+	assert.True(t, entities.Currency("EUR").Valid())
+	assert.False(t, entities.Currency("XYZ").Valid())
 }
 
 func Test_OperationType_String(t *testing.T) {
@@ -0,0 +1,77 @@
+package entities
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var ErrTransferToSameUser = &Error{Kind: KIND_VALIDATION, Code: "same_user", Message: "a transfer must be between different users", Pointer: "/to_user_id"}
+
+// Transfer moves an amount from a user to another with a pair of transactions sharing the TransferID,
+// a debit of the sender and a credit of the receiver.
+type Transfer struct {
+	ID     uuid.UUID
+	Debit  *Transaction
+	Credit *Transaction
+}
+
+func NewTransfer(origin, fromUserId, toUserId string, amount int64, opts ...TransactionOption) (*Transfer, []error) {
+	if amount <= 0 {
+		return nil, []error{NewFieldError("invalid_amount", "/amount", "Amount of a transfer must be positive")}
+	}
+	if fromUserId != "" && fromUserId == toUserId {
+		return nil, []error{ErrTransferToSameUser}
+	}
+
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return nil, []error{err}
+	}
+
+	debit, errs := NewTransaction(origin, fromUserId, -amount, DEBIT, opts...)
+	if errs != nil {
+		return nil, errs
+	}
+	credit, errs := NewTransaction(origin, toUserId, amount, CREDIT, opts...)
+	if errs != nil {
+		return nil, errs
+	}
+
+	// both legs happen at the same moment
+	credit.CreatedAt = debit.CreatedAt
+	debit.TransferID = &id
+	credit.TransferID = &id
+
+	return &Transfer{ID: id, Debit: debit, Credit: credit}, nil
+}
+
+// NewTransferFromLegs rebuilds the transfer from its stored transactions.
+func NewTransferFromLegs(legs []*Transaction) (*Transfer, error) {
+	if len(legs) != 2 || legs[0].TransferID == nil || legs[1].TransferID == nil || *legs[0].TransferID != *legs[1].TransferID {
+		return nil, fmt.Errorf("a transfer must have a debit and a credit with the same transfer id")
+	}
+
+	transfer := &Transfer{ID: *legs[0].TransferID}
+	for _, leg := range legs {
+		if leg.Type == DEBIT {
+			transfer.Debit = leg
+		} else {
+			transfer.Credit = leg
+		}
+	}
+	if transfer.Debit == nil || transfer.Credit == nil {
+		return nil, fmt.Errorf("a transfer must have a debit and a credit with the same transfer id")
+	}
+	return transfer, nil
+}
+
+// Amount is the amount moved by the transfer, always positive.
+func (t *Transfer) Amount() int64 {
+	return t.Credit.Amount
+}
+
+func (t *Transfer) CreatedAt() time.Time {
+	return t.Debit.CreatedAt
+}
@@ -0,0 +1,64 @@
+package entities_test
+
+import (
+	"testing"
+	"user-transactions/core/entities"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_NewTransfer(t *testing.T) {
+	t.Run("create the legs of the transfer", func(t *testing.T) {
+		transfer, errs := entities.NewTransfer("desktop-web", "user123", "user456", 500, entities.WithCurrency("USD"))
+		assert.Empty(t, errs)
+		assert.NotEmpty(t, transfer.ID)
+
+		assert.Equal(t, "user123", transfer.Debit.UserID)
+		assert.Equal(t, int64(-500), transfer.Debit.Amount)
+		assert.Equal(t, entities.DEBIT, transfer.Debit.Type)
+		assert.Equal(t, "user456", transfer.Credit.UserID)
+		assert.Equal(t, int64(500), transfer.Credit.Amount)
+		assert.Equal(t, entities.CREDIT, transfer.Credit.Type)
+
+		assert.Equal(t, transfer.ID, *transfer.Debit.TransferID)
+		assert.Equal(t, transfer.ID, *transfer.Credit.TransferID)
+		assert.Equal(t, entities.Currency("USD"), transfer.Credit.Currency)
+		assert.Equal(t, transfer.Debit.CreatedAt, transfer.Credit.CreatedAt)
+		assert.Equal(t, int64(500), transfer.Amount())
+	})
+
+	t.Run("create transfer to the same user", func(t *testing.T) {
+		transfer, errs := entities.NewTransfer("desktop-web", "user123", "user123", 500)
+		assert.Nil(t, transfer)
+		assert.Equal(t, []error{entities.ErrTransferToSameUser}, errs)
+	})
+
+	t.Run("create transfer with invalid amount", func(t *testing.T) {
+		transfer, errs := entities.NewTransfer("desktop-web", "user123", "user456", -500)
+		assert.Nil(t, transfer)
+		assert.Equal(t, "Amount of a transfer must be positive", errs[0].Error())
+	})
+
+	t.Run("create transfer without receiver", func(t *testing.T) {
+		transfer, errs := entities.NewTransfer("desktop-web", "user123", "", 500)
+		assert.Nil(t, transfer)
+		assert.Equal(t, "UserID is a required field", errs[0].Error())
+	})
+}
+
+func Test_NewTransferFromLegs(t *testing.T) {
+	transfer, errs := entities.NewTransfer("desktop-web", "user123", "user456", 500)
+	assert.Empty(t, errs)
+
+	rebuilt, err := entities.NewTransferFromLegs([]*entities.Transaction{transfer.Credit, transfer.Debit})
+	assert.NoError(t, err)
+	assert.Equal(t, transfer, rebuilt)
+
+	other, errs := entities.NewTransfer("desktop-web", "user123", "user456", 500)
+	assert.Empty(t, errs)
+	_, err = entities.NewTransferFromLegs([]*entities.Transaction{transfer.Debit, other.Credit})
+	assert.Error(t, err)
+
+	_, err = entities.NewTransferFromLegs([]*entities.Transaction{transfer.Debit})
+	assert.Error(t, err)
+}
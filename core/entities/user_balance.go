@@ -0,0 +1,40 @@
+package entities
+
+import (
+	"time"
+)
+
+// UserBalance is the sum of the committed transactions of a user in a currency, kept up to date
+// by the database transactions inserting them so the balance is not summed on every read.
+type UserBalance struct {
+	UserID    string   `gorm:"primaryKey"`
+	Currency  Currency `gorm:"primaryKey;size:3"`
+	Balance   int64    // ledger balance, of the posted transactions
+	Held      int64    `gorm:"not null;default:0"` // reserved by the pending holds
+	UpdatedAt time.Time
+}
+
+// Balance of a user in a currency, the holds are in the available balance and not in the ledger one until captured.
+type Balance struct {
+	Ledger int64
+	Held   int64
+}
+
+func (b Balance) Available() int64 {
+	return b.Ledger - b.Held
+}
+
+// BalanceDrift is a user balance that doesn't match the sum of the transactions of the user in the currency.
+type BalanceDrift struct {
+	UserID     string
+	Currency   Currency
+	Balance    int64 // stored in the user balances
+	Ledger     int64 // sum of the posted transactions
+	Held       int64 // stored in the user balances
+	LedgerHeld int64 // sum of the pending holds
+}
+
+// Difference is how much the stored balance is above the sum of the transactions.
+func (d *BalanceDrift) Difference() int64 {
+	return d.Balance - d.Ledger
+}
@@ -0,0 +1,14 @@
+package repositories
+
+import (
+	"context"
+	"user-transactions/core/entities"
+)
+
+// BalanceRepository maintains the user balances summed from the transactions.
+type BalanceRepository interface {
+	// Rebuild recomputes all the user balances from the transactions, returning how many there are.
+	Rebuild(ctx context.Context) (int64, error)
+	// Drift returns the user balances that don't match the sum of their transactions.
+	Drift(ctx context.Context) ([]*entities.BalanceDrift, error)
+}
@@ -0,0 +1,13 @@
+package repositories
+
+import (
+	"context"
+	"user-transactions/core/entities"
+)
+
+type DeadLetterRepository interface {
+	Insert(ctx context.Context, batch *entities.DeadLetterBatch) error
+	List(ctx context.Context) ([]*entities.DeadLetterBatch, error)
+	Find(ctx context.Context, id string) (*entities.DeadLetterBatch, error)
+	Delete(ctx context.Context, id string) error
+}
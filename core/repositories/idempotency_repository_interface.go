@@ -0,0 +1,18 @@
+package repositories
+
+import (
+	"context"
+	"user-transactions/core/entities"
+)
+
+type IdempotencyRepository interface {
+	// Reserve stores the key if it was not used yet, it returns false when the key already exists.
+	Reserve(ctx context.Context, key *entities.IdempotencyKey) (bool, error)
+	// TakeOver replaces the expired reservation still processing with the key, it returns false when the reservation
+	// was completed, released or taken over in the meantime.
+	TakeOver(ctx context.Context, expired, key *entities.IdempotencyKey) (bool, error)
+	Find(ctx context.Context, origin, key string) (*entities.IdempotencyKey, error)
+	// Complete and Release only change the reservation of the key, not the one that took it over once expired.
+	Complete(ctx context.Context, key *entities.IdempotencyKey) error
+	Release(ctx context.Context, key *entities.IdempotencyKey) error
+}
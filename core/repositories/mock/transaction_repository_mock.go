@@ -11,6 +11,7 @@ package mock_repositories
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
 	entities "user-transactions/core/entities"
 
 	gomock "go.uber.org/mock/gomock"
@@ -39,6 +40,51 @@ func (m *MockTransactionRepository) EXPECT() *MockTransactionRepositoryMockRecor
 	return m.recorder
 }
 
+// Balance mocks base method.
+func (m *MockTransactionRepository) Balance(ctx context.Context, userId string, filter *entities.BalanceFilter) (map[entities.Currency]entities.Balance, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Balance", ctx, userId, filter)
+	ret0, _ := ret[0].(map[entities.Currency]entities.Balance)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Balance indicates an expected call of Balance.
+func (mr *MockTransactionRepositoryMockRecorder) Balance(ctx, userId, filter any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Balance", reflect.TypeOf((*MockTransactionRepository)(nil).Balance), ctx, userId, filter)
+}
+
+// Count mocks base method.
+func (m *MockTransactionRepository) Count(ctx context.Context, filter *entities.TransactionFilter) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Count", ctx, filter)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Count indicates an expected call of Count.
+func (mr *MockTransactionRepositoryMockRecorder) Count(ctx, filter any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Count", reflect.TypeOf((*MockTransactionRepository)(nil).Count), ctx, filter)
+}
+
+// ExpireHolds mocks base method.
+func (m *MockTransactionRepository) ExpireHolds(ctx context.Context, now time.Time, limit int) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExpireHolds", ctx, now, limit)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ExpireHolds indicates an expected call of ExpireHolds.
+func (mr *MockTransactionRepositoryMockRecorder) ExpireHolds(ctx, now, limit any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExpireHolds", reflect.TypeOf((*MockTransactionRepository)(nil).ExpireHolds), ctx, now, limit)
+}
+
 // Find mocks base method.
 func (m *MockTransactionRepository) Find(ctx context.Context, id string) (*entities.Transaction, error) {
 	m.ctrl.T.Helper()
@@ -69,8 +115,66 @@ func (mr *MockTransactionRepositoryMockRecorder) Insert(ctx, transaction any) *g
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Insert", reflect.TypeOf((*MockTransactionRepository)(nil).Insert), ctx, transaction)
 }
 
+// InsertMany mocks base method.
+func (m *MockTransactionRepository) InsertMany(ctx context.Context, transactions []*entities.Transaction) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InsertMany", ctx, transactions)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// InsertMany indicates an expected call of InsertMany.
+func (mr *MockTransactionRepositoryMockRecorder) InsertMany(ctx, transactions any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InsertMany", reflect.TypeOf((*MockTransactionRepository)(nil).InsertMany), ctx, transactions)
+}
+
+// InsertWithBalanceCheck mocks base method.
+func (m *MockTransactionRepository) InsertWithBalanceCheck(ctx context.Context, transactions []*entities.Transaction, policy *entities.OverdraftPolicy) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InsertWithBalanceCheck", ctx, transactions, policy)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// InsertWithBalanceCheck indicates an expected call of InsertWithBalanceCheck.
+func (mr *MockTransactionRepositoryMockRecorder) InsertWithBalanceCheck(ctx, transactions, policy any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InsertWithBalanceCheck", reflect.TypeOf((*MockTransactionRepository)(nil).InsertWithBalanceCheck), ctx, transactions, policy)
+}
+
+// ListByCursor mocks base method.
+func (m *MockTransactionRepository) ListByCursor(ctx context.Context, pageSize int, cursor *entities.Cursor, filter *entities.TransactionFilter) ([]*entities.Transaction, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListByCursor", ctx, pageSize, cursor, filter)
+	ret0, _ := ret[0].([]*entities.Transaction)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListByCursor indicates an expected call of ListByCursor.
+func (mr *MockTransactionRepositoryMockRecorder) ListByCursor(ctx, pageSize, cursor, filter any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListByCursor", reflect.TypeOf((*MockTransactionRepository)(nil).ListByCursor), ctx, pageSize, cursor, filter)
+}
+
+// ListReversals mocks base method.
+func (m *MockTransactionRepository) ListReversals(ctx context.Context, id string) ([]*entities.Transaction, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListReversals", ctx, id)
+	ret0, _ := ret[0].([]*entities.Transaction)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListReversals indicates an expected call of ListReversals.
+func (mr *MockTransactionRepositoryMockRecorder) ListReversals(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListReversals", reflect.TypeOf((*MockTransactionRepository)(nil).ListReversals), ctx, id)
+}
+
 // List mocks base method.
-func (m *MockTransactionRepository) List(ctx context.Context, pageSize, offset int, filter map[string]string) ([]*entities.Transaction, error) {
+func (m *MockTransactionRepository) List(ctx context.Context, pageSize, offset int, filter *entities.TransactionFilter) ([]*entities.Transaction, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "List", ctx, pageSize, offset, filter)
 	ret0, _ := ret[0].([]*entities.Transaction)
@@ -83,3 +187,62 @@ func (mr *MockTransactionRepositoryMockRecorder) List(ctx, pageSize, offset, fil
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockTransactionRepository)(nil).List), ctx, pageSize, offset, filter)
 }
+
+// Reverse mocks base method.
+func (m *MockTransactionRepository) Reverse(ctx context.Context, id string, policy *entities.OverdraftPolicy, build func(*entities.Transaction, []*entities.Transaction) (*entities.Transaction, []error)) (*entities.Transaction, []error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Reverse", ctx, id, policy, build)
+	ret0, _ := ret[0].(*entities.Transaction)
+	ret1, _ := ret[1].([]error)
+	return ret0, ret1
+}
+
+// Reverse indicates an expected call of Reverse.
+func (mr *MockTransactionRepositoryMockRecorder) Reverse(ctx, id, policy, build any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Reverse", reflect.TypeOf((*MockTransactionRepository)(nil).Reverse), ctx, id, policy, build)
+}
+
+// Stream mocks base method.
+func (m *MockTransactionRepository) Stream(ctx context.Context, filter *entities.TransactionFilter, fn func(*entities.Transaction) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Stream", ctx, filter, fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Stream indicates an expected call of Stream.
+func (mr *MockTransactionRepositoryMockRecorder) Stream(ctx, filter, fn any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Stream", reflect.TypeOf((*MockTransactionRepository)(nil).Stream), ctx, filter, fn)
+}
+
+// Summarize mocks base method.
+func (m *MockTransactionRepository) Summarize(ctx context.Context, filter *entities.TransactionFilter, groupBy []entities.SummaryGroup, interval entities.SummaryInterval) ([]*entities.TransactionSummary, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Summarize", ctx, filter, groupBy, interval)
+	ret0, _ := ret[0].([]*entities.TransactionSummary)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Summarize indicates an expected call of Summarize.
+func (mr *MockTransactionRepositoryMockRecorder) Summarize(ctx, filter, groupBy, interval any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Summarize", reflect.TypeOf((*MockTransactionRepository)(nil).Summarize), ctx, filter, groupBy, interval)
+}
+
+// UpdateStatus mocks base method.
+func (m *MockTransactionRepository) UpdateStatus(ctx context.Context, id string, update func(*entities.Transaction) error) (*entities.Transaction, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateStatus", ctx, id, update)
+	ret0, _ := ret[0].(*entities.Transaction)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateStatus indicates an expected call of UpdateStatus.
+func (mr *MockTransactionRepositoryMockRecorder) UpdateStatus(ctx, id, update any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateStatus", reflect.TypeOf((*MockTransactionRepository)(nil).UpdateStatus), ctx, id, update)
+}
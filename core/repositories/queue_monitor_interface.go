@@ -0,0 +1,12 @@
+package repositories
+
+import (
+	"user-transactions/core/entities"
+)
+
+// ErrQueueFull is returned by TransactionRepository.Insert when the bulk queue has no room for the transaction.
+var ErrQueueFull = entities.NewError(entities.KIND_UNAVAILABLE, "queue_full", "the transaction queue is full, try again later")
+
+type QueueMonitor interface {
+	QueueStats() entities.QueueStats
+}
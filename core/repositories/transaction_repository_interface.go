@@ -2,11 +2,40 @@ package repositories
 
 import (
 	"context"
+	"time"
 	"user-transactions/core/entities"
 )
 
+// ErrDatabaseUnavailable is returned, caused by the error of the driver, when the database can't be reached.
+var ErrDatabaseUnavailable = entities.NewError(entities.KIND_UNAVAILABLE, "database_unavailable", "the database is unavailable, try again later")
+
 type TransactionRepository interface {
 	Insert(ctx context.Context, transaction *entities.Transaction) (*entities.Transaction, error)
+	// InsertMany inserts the transactions in a single database transaction, skipping the ones already inserted.
+	InsertMany(ctx context.Context, transactions []*entities.Transaction) error
+	// InsertWithBalanceCheck is InsertMany rejecting the debits that would take a balance below the overdraft limit of the policy,
+	// the balances are locked until the transactions are inserted so concurrent debits can't race past the limit.
+	InsertWithBalanceCheck(ctx context.Context, transactions []*entities.Transaction, policy *entities.OverdraftPolicy) error
+	// Find returns gorm.ErrRecordNotFound when there is no transaction with the id.
 	Find(ctx context.Context, id string) (*entities.Transaction, error)
-	List(ctx context.Context, pageSize, offset int, filter map[string]string) ([]*entities.Transaction, error)
+	List(ctx context.Context, pageSize, offset int, filter *entities.TransactionFilter) ([]*entities.Transaction, error)
+	// ListByCursor returns up to pageSize transactions next to the cursor (the first ones when nil), in the order of the filter sort.
+	ListByCursor(ctx context.Context, pageSize int, cursor *entities.Cursor, filter *entities.TransactionFilter) ([]*entities.Transaction, error)
+	Count(ctx context.Context, filter *entities.TransactionFilter) (int64, error)
+	// Summarize totals the transactions passing the filter by currency, the given groups and the interval when not empty.
+	// The filter defaults to the posted transactions.
+	Summarize(ctx context.Context, filter *entities.TransactionFilter, groupBy []entities.SummaryGroup, interval entities.SummaryInterval) ([]*entities.TransactionSummary, error)
+	// Stream calls fn with each committed transaction passing the filter, in the order of the filter sort, without loading them all in memory.
+	// It stops at the first error returned by fn or when the context is done.
+	Stream(ctx context.Context, filter *entities.TransactionFilter, fn func(*entities.Transaction) error) error
+	// Reverse locks the transaction and inserts the reversal returned by build, given the reversals already made.
+	// A reversal that debits the balance is checked against the policy as by InsertWithBalanceCheck.
+	Reverse(ctx context.Context, id string, policy *entities.OverdraftPolicy, build func(original *entities.Transaction, reversals []*entities.Transaction) (*entities.Transaction, []error)) (*entities.Transaction, []error)
+	ListReversals(ctx context.Context, id string) ([]*entities.Transaction, error)
+	// UpdateStatus locks the transaction and saves the status set by update, moving its amount between the held and the ledger balance.
+	UpdateStatus(ctx context.Context, id string, update func(transaction *entities.Transaction) error) (*entities.Transaction, error)
+	// ExpireHolds voids up to limit pending holds expired at now, returning how many were voided.
+	ExpireHolds(ctx context.Context, now time.Time, limit int) (int64, error)
+	// Balance sums the transactions of the user by currency, the ledger balance and the amount held.
+	Balance(ctx context.Context, userId string, filter *entities.BalanceFilter) (map[entities.Currency]entities.Balance, error)
 }
@@ -0,0 +1,14 @@
+package repositories
+
+import (
+	"context"
+	"user-transactions/core/entities"
+)
+
+type TransferRepository interface {
+	// Insert commits both legs of the transfer in a single database transaction, skipping the bulk buffer.
+	Insert(ctx context.Context, transfer *entities.Transfer) error
+	// InsertWithBalanceCheck is Insert rejecting the transfer when the debit would take the balance of the sender below its overdraft limit.
+	InsertWithBalanceCheck(ctx context.Context, transfer *entities.Transfer, policy *entities.OverdraftPolicy) error
+	Find(ctx context.Context, id string) (*entities.Transfer, error)
+}
@@ -0,0 +1,36 @@
+package repositories
+
+import (
+	"context"
+	"user-transactions/core/entities"
+)
+
+type WriteConsistency string
+
+const (
+	CONSISTENCY_ASYNC       WriteConsistency = "async"       // acknowledged once accepted by the bulk mode
+	CONSISTENCY_COMMIT_SYNC WriteConsistency = "commit-sync" // acknowledged once committed to the database
+)
+
+// ErrCommitNotConfirmed is returned by TransactionRepository.Insert when the caller stopped waiting for the commit
+// or the transaction was sent to the dead-letter sink, the transaction may still be committed afterwards.
+var ErrCommitNotConfirmed = entities.NewError(entities.KIND_TIMEOUT, "commit_not_confirmed", "the transaction was accepted but its commit was not confirmed")
+
+type consistencyKey struct{}
+
+// WithConsistency returns a context asking TransactionRepository.Insert for the given write consistency.
+func WithConsistency(ctx context.Context, consistency WriteConsistency) context.Context {
+	return context.WithValue(ctx, consistencyKey{}, consistency)
+}
+
+// Consistency returns the write consistency asked in the context, async when none was asked.
+func Consistency(ctx context.Context) WriteConsistency {
+	if consistency, ok := ctx.Value(consistencyKey{}).(WriteConsistency); ok {
+		return consistency
+	}
+	return CONSISTENCY_ASYNC
+}
+
+func (c WriteConsistency) Valid() bool {
+	return c == CONSISTENCY_ASYNC || c == CONSISTENCY_COMMIT_SYNC
+}
@@ -0,0 +1,51 @@
+package services
+
+import (
+	"context"
+
+	"user-transactions/application/dto"
+	"user-transactions/core/repositories"
+)
+
+// BalanceService rebuilds the user balances and checks them against the transactions.
+// Both read every transaction, so they are not bounded by the services timeout.
+type BalanceService struct {
+	BalanceRepository repositories.BalanceRepository
+}
+
+func NewBalanceService(br repositories.BalanceRepository) *BalanceService {
+	return &BalanceService{
+		BalanceRepository: br,
+	}
+}
+
+func (bs *BalanceService) RebuildBalances(ctx context.Context) (*dto.BalanceRebuildRes, error) {
+	count, err := bs.BalanceRepository.Rebuild(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &dto.BalanceRebuildRes{Balances: count}, nil
+}
+
+// CheckBalances reports the user balances that drifted from the sum of their transactions.
+func (bs *BalanceService) CheckBalances(ctx context.Context) (*dto.BalanceCheckRes, error) {
+	drifts, err := bs.BalanceRepository.Drift(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &dto.BalanceCheckRes{
+		Consistent: len(drifts) == 0,
+		Drifts:     make([]*dto.BalanceDriftRes, 0, len(drifts)),
+	}
+	for _, drift := range drifts {
+		res.Drifts = append(res.Drifts, &dto.BalanceDriftRes{
+			UserID:     drift.UserID,
+			Currency:   drift.Currency.String(),
+			Balance:    drift.Balance,
+			Ledger:     drift.Ledger,
+			Difference: drift.Difference(),
+		})
+	}
+	return res, nil
+}
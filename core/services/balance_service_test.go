@@ -0,0 +1,62 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"user-transactions/core/entities"
+	mock_repositories "user-transactions/core/repositories/mock"
+	"user-transactions/core/services"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+func Test_BalanceService(t *testing.T) {
+	ctx := context.Background()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRepo := mock_repositories.NewMockBalanceRepository(ctrl)
+
+	service := services.NewBalanceService(mockRepo)
+
+	t.Run("rebuild the balances", func(t *testing.T) {
+		mockRepo.EXPECT().Rebuild(gomock.Any()).Return(int64(42), nil)
+
+		res, err := service.RebuildBalances(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(42), res.Balances)
+	})
+
+	t.Run("report the balances that drifted", func(t *testing.T) {
+		mockRepo.EXPECT().Drift(gomock.Any()).Return([]*entities.BalanceDrift{
+			{UserID: "user123", Currency: "BRL", Balance: 500, Ledger: 450},
+		}, nil)
+
+		res, err := service.CheckBalances(ctx)
+		assert.NoError(t, err)
+		assert.False(t, res.Consistent)
+		assert.Len(t, res.Drifts, 1)
+		assert.Equal(t, "user123", res.Drifts[0].UserID)
+		assert.Equal(t, int64(50), res.Drifts[0].Difference)
+	})
+
+	t.Run("report consistent balances", func(t *testing.T) {
+		mockRepo.EXPECT().Drift(gomock.Any()).Return(nil, nil)
+
+		res, err := service.CheckBalances(ctx)
+		assert.NoError(t, err)
+		assert.True(t, res.Consistent)
+		assert.Empty(t, res.Drifts)
+	})
+
+	t.Run("return the error of the repository", func(t *testing.T) {
+		mockRepo.EXPECT().Rebuild(gomock.Any()).Return(int64(0), errors.New("connection refused"))
+
+		res, err := service.RebuildBalances(ctx)
+		assert.Nil(t, res)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,116 @@
+package services
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"time"
+
+	"user-transactions/application/dto"
+	"user-transactions/core/entities"
+	"user-transactions/core/repositories"
+)
+
+// DeadLetterService inspects the bulks that could not be committed, replaying or discarding them.
+type DeadLetterService struct {
+	Timeout               int
+	DeadLetterRepository  repositories.DeadLetterRepository
+	TransactionRepository repositories.TransactionRepository
+}
+
+func NewDeadLetterService(dr repositories.DeadLetterRepository, tr repositories.TransactionRepository) (*DeadLetterService, error) {
+	timeout, err := strconv.Atoi(os.Getenv("TIMEOUT_SERVICES"))
+	if err != nil {
+		timeout = 5
+	}
+
+	return &DeadLetterService{
+		Timeout:               timeout,
+		DeadLetterRepository:  dr,
+		TransactionRepository: tr,
+	}, nil
+}
+
+func (ds *DeadLetterService) ListBatches(c context.Context) ([]*dto.DeadLetterBatchRes, error) {
+	ctx, cancel := context.WithTimeout(c, time.Duration(ds.Timeout)*time.Second)
+	defer cancel()
+
+	batches, err := ds.DeadLetterRepository.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]*dto.DeadLetterBatchRes, 0, len(batches))
+	for _, batch := range batches {
+		res = append(res, newDeadLetterBatchRes(batch))
+	}
+	return res, nil
+}
+
+func (ds *DeadLetterService) GetBatch(c context.Context, id string) (*dto.DeadLetterBatchRes, error) {
+	ctx, cancel := context.WithTimeout(c, time.Duration(ds.Timeout)*time.Second)
+	defer cancel()
+
+	batch, err := ds.DeadLetterRepository.Find(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	transactions, err := batch.Transactions()
+	if err != nil {
+		return nil, err
+	}
+
+	res := newDeadLetterBatchRes(batch)
+	for _, transaction := range transactions {
+		res.Transactions = append(res.Transactions, newTransactionRes(transaction))
+	}
+	return res, nil
+}
+
+// ReplayBatch inserts the transactions of the batch at once and removes the batch,
+// the transactions already in the database are skipped so a replay can be repeated safely.
+func (ds *DeadLetterService) ReplayBatch(c context.Context, id string) (*dto.DeadLetterBatchRes, error) {
+	ctx, cancel := context.WithTimeout(c, time.Duration(ds.Timeout)*time.Second)
+	defer cancel()
+
+	batch, err := ds.DeadLetterRepository.Find(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	transactions, err := batch.Transactions()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ds.TransactionRepository.InsertMany(ctx, transactions); err != nil {
+		return nil, err
+	}
+
+	if err := ds.DeadLetterRepository.Delete(ctx, id); err != nil {
+		return nil, err
+	}
+
+	res := newDeadLetterBatchRes(batch)
+	for _, transaction := range transactions {
+		res.Transactions = append(res.Transactions, newTransactionRes(transaction))
+	}
+	return res, nil
+}
+
+func (ds *DeadLetterService) DiscardBatch(c context.Context, id string) error {
+	ctx, cancel := context.WithTimeout(c, time.Duration(ds.Timeout)*time.Second)
+	defer cancel()
+
+	return ds.DeadLetterRepository.Delete(ctx, id)
+}
+
+func newDeadLetterBatchRes(batch *entities.DeadLetterBatch) *dto.DeadLetterBatchRes {
+	return &dto.DeadLetterBatchRes{
+		ID:        batch.ID.String(),
+		Size:      batch.Size,
+		LastError: batch.LastError,
+		CreatedAt: batch.CreatedAt,
+	}
+}
@@ -0,0 +1,84 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"user-transactions/core/entities"
+	mock_repositories "user-transactions/core/repositories/mock"
+	"user-transactions/core/services"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+func Test_DeadLetterService_ReplayBatch(t *testing.T) {
+	ctx := context.Background()
+
+	transaction, errs := entities.NewTransaction("desktop-web", "user123", 200, entities.CREDIT)
+	assert.Empty(t, errs)
+	batch, err := entities.NewDeadLetterBatch([]*entities.Transaction{transaction}, errors.New("connection refused"))
+	assert.NoError(t, err)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockDeadLetterRepo := mock_repositories.NewMockDeadLetterRepository(ctrl)
+	mockTransactionRepo := mock_repositories.NewMockTransactionRepository(ctrl)
+
+	service, err := services.NewDeadLetterService(mockDeadLetterRepo, mockTransactionRepo)
+	assert.NoError(t, err)
+
+	t.Run("replaying a batch", func(t *testing.T) {
+		mockDeadLetterRepo.EXPECT().Find(gomock.Any(), batch.ID.String()).Return(batch, nil)
+		mockTransactionRepo.EXPECT().InsertMany(gomock.Any(), gomock.Len(1)).DoAndReturn(func(ctx context.Context, transactions []*entities.Transaction) error {
+			assert.Equal(t, transaction.ID, transactions[0].ID)
+			assert.Equal(t, transaction.Amount, transactions[0].Amount)
+			return nil
+		})
+		mockDeadLetterRepo.EXPECT().Delete(gomock.Any(), batch.ID.String()).Return(nil)
+
+		res, err := service.ReplayBatch(ctx, batch.ID.String())
+		assert.NoError(t, err)
+		assert.Equal(t, batch.ID.String(), res.ID)
+		assert.Equal(t, 1, res.Size)
+		assert.Equal(t, "connection refused", res.LastError)
+		assert.Len(t, res.Transactions, 1)
+		assert.Equal(t, transaction.ID.String(), res.Transactions[0].ID)
+	})
+
+	t.Run("keeping the batch when the replay fails", func(t *testing.T) {
+		mockDeadLetterRepo.EXPECT().Find(gomock.Any(), batch.ID.String()).Return(batch, nil)
+		mockTransactionRepo.EXPECT().InsertMany(gomock.Any(), gomock.Any()).Return(errors.New("connection refused"))
+		// Delete must not be called
+
+		res, err := service.ReplayBatch(ctx, batch.ID.String())
+		assert.Nil(t, res)
+		assert.Equal(t, "connection refused", err.Error())
+	})
+}
+
+func Test_DeadLetterService_ListBatches(t *testing.T) {
+	ctx := context.Background()
+
+	transaction, errs := entities.NewTransaction("desktop-web", "user123", 200, entities.CREDIT)
+	assert.Empty(t, errs)
+	batch, err := entities.NewDeadLetterBatch([]*entities.Transaction{transaction}, errors.New("connection refused"))
+	assert.NoError(t, err)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockDeadLetterRepo := mock_repositories.NewMockDeadLetterRepository(ctrl)
+
+	service, err := services.NewDeadLetterService(mockDeadLetterRepo, mock_repositories.NewMockTransactionRepository(ctrl))
+	assert.NoError(t, err)
+
+	mockDeadLetterRepo.EXPECT().List(gomock.Any()).Return([]*entities.DeadLetterBatch{batch}, nil)
+
+	res, err := service.ListBatches(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, res, 1)
+	assert.Equal(t, batch.ID.String(), res[0].ID)
+	// the transactions are only returned for a single batch
+	assert.Empty(t, res[0].Transactions)
+}
@@ -0,0 +1,26 @@
+package services
+
+import (
+	"user-transactions/application/dto"
+	"user-transactions/core/repositories"
+)
+
+// MonitorService reports the state of the write pipeline.
+type MonitorService struct {
+	QueueMonitor repositories.QueueMonitor
+}
+
+func NewMonitorService(qm repositories.QueueMonitor) *MonitorService {
+	return &MonitorService{
+		QueueMonitor: qm,
+	}
+}
+
+func (ms *MonitorService) QueueStats() *dto.QueueStatsRes {
+	stats := ms.QueueMonitor.QueueStats()
+	return &dto.QueueStatsRes{
+		Depth:    stats.Depth,
+		Capacity: stats.Capacity,
+		Pending:  stats.Pending,
+	}
+}
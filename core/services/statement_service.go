@@ -0,0 +1,126 @@
+package services
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"time"
+
+	"user-transactions/application/dto"
+	"user-transactions/core/entities"
+	"user-transactions/core/repositories"
+)
+
+// statementPeriod is the layout of the month of a statement.
+const statementPeriod = "2006-01"
+
+// StatementService generates the monthly account statements of the users from their transactions.
+type StatementService struct {
+	Timeout               int
+	DefaultCurrency       entities.Currency
+	TransactionRepository repositories.TransactionRepository
+}
+
+func NewStatementService(tr repositories.TransactionRepository) (*StatementService, error) {
+	timeout, err := strconv.Atoi(os.Getenv("TIMEOUT_SERVICES"))
+	if err != nil {
+		timeout = 5
+	}
+
+	defaultCurrency, err := loadDefaultCurrency()
+	if err != nil {
+		return nil, err
+	}
+
+	return &StatementService{
+		Timeout:               timeout,
+		DefaultCurrency:       defaultCurrency,
+		TransactionRepository: tr,
+	}, nil
+}
+
+// GetStatement returns the statement of the user for the month of the period filter (YYYY-MM, in UTC) in the
+// currency filter, the default currency when not sent. The opening balance is the ledger balance before the month
+// and the posted transactions of the month follow in the order they were created, each with the running balance.
+// The transactions still in the bulk buffer are in the statement once committed.
+func (ss *StatementService) GetStatement(c context.Context, userId string, filter map[string]string) (*dto.StatementRes, error) {
+	ctx, cancel := context.WithTimeout(c, time.Duration(ss.Timeout)*time.Second)
+	defer cancel()
+
+	from, err := time.Parse(statementPeriod, filter["period"])
+	if err != nil {
+		return nil, entities.NewParameterError("period", "period must be a month (YYYY-MM)")
+	}
+	if from.After(time.Now()) {
+		return nil, entities.NewParameterError("period", "period can't be in the future")
+	}
+	to := from.AddDate(0, 1, 0)
+
+	currency := ss.DefaultCurrency
+	if value := filter["currency"]; value != "" {
+		currency = entities.Currency(value)
+	}
+	if !currency.Valid() {
+		return nil, entities.NewParameterError("currency", "currency must be an ISO 4217 code")
+	}
+
+	// the balance filter is inclusive, the opening balance is the one of the last moment before the month
+	asOf := from.Add(-time.Nanosecond)
+	balances, err := ss.TransactionRepository.Balance(ctx, userId, &entities.BalanceFilter{Currency: currency, AsOf: &asOf})
+	if err != nil {
+		return nil, err
+	}
+
+	statement := entities.NewStatement(userId, currency, from, to, balances[currency].Ledger)
+	transactionFilter := &entities.TransactionFilter{
+		UserID:      userId,
+		Currency:    currency,
+		Status:      entities.STATUS_POSTED,
+		CreatedFrom: &from,
+		CreatedTo:   &to,
+		Sort:        entities.SORT_CREATED_AT,
+	}
+	err = ss.TransactionRepository.Stream(ctx, transactionFilter, func(transaction *entities.Transaction) error {
+		statement.Add(transaction)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return newStatementRes(statement), nil
+}
+
+func newStatementRes(statement *entities.Statement) *dto.StatementRes {
+	res := &dto.StatementRes{
+		UserID:   statement.UserID,
+		Period:   statement.From.Format(statementPeriod),
+		Currency: statement.Currency.String(),
+		Exponent: statement.Currency.Exponent(),
+		From:     statement.From,
+		To:       statement.To,
+		Opening:  statement.Opening,
+		Credits:  statement.Credits,
+		Debits:   statement.Debits,
+		Closing:  statement.Closing,
+		Lines:    make([]*dto.StatementLineRes, 0, len(statement.Lines)),
+	}
+	for _, line := range statement.Lines {
+		lineRes := &dto.StatementLineRes{
+			ID:        line.Transaction.ID.String(),
+			Origin:    line.Transaction.Origin,
+			Type:      line.Transaction.Type.String(),
+			Amount:    line.Transaction.Amount,
+			Balance:   line.Balance,
+			CreatedAt: line.Transaction.CreatedAt,
+		}
+		if line.Transaction.ReversalOf != nil {
+			lineRes.ReversalOf = line.Transaction.ReversalOf.String()
+		}
+		if line.Transaction.ExternalReference != nil {
+			lineRes.ExternalReference = *line.Transaction.ExternalReference
+		}
+		res.Lines = append(res.Lines, lineRes)
+	}
+	return res
+}
@@ -0,0 +1,110 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"user-transactions/core/entities"
+	mock_repositories "user-transactions/core/repositories/mock"
+	"user-transactions/core/services"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+func Test_StatementService_GetStatement(t *testing.T) {
+	ctx := context.Background()
+	from := time.Date(2023, 11, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2023, 12, 1, 0, 0, 0, 0, time.UTC)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRepo := mock_repositories.NewMockTransactionRepository(ctrl)
+
+	service, err := services.NewStatementService(mockRepo)
+	assert.Nil(t, err)
+
+	t.Run("opening balance and running balance of the month", func(t *testing.T) {
+		asOf := from.Add(-time.Nanosecond)
+		mockRepo.EXPECT().Balance(gomock.Any(), "user123", &entities.BalanceFilter{Currency: "BRL", AsOf: &asOf}).
+			Return(map[entities.Currency]entities.Balance{"BRL": {Ledger: 1000, Held: 300}}, nil)
+		mockRepo.EXPECT().Stream(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+			func(_ context.Context, filter *entities.TransactionFilter, fn func(*entities.Transaction) error) error {
+				assert.Equal(t, &entities.TransactionFilter{
+					UserID:      "user123",
+					Currency:    "BRL",
+					Status:      entities.STATUS_POSTED,
+					CreatedFrom: &from,
+					CreatedTo:   &to,
+					Sort:        entities.SORT_CREATED_AT,
+				}, filter)
+
+				credit, _ := entities.NewTransaction("desktop-web", "user123", 500, entities.CREDIT, entities.WithExternalReference("order-1"))
+				credit.CreatedAt = from.Add(time.Hour)
+				debit, _ := entities.NewTransaction("desktop-web", "user123", -2000, entities.DEBIT)
+				debit.CreatedAt = from.AddDate(0, 0, 10)
+				for _, transaction := range []*entities.Transaction{credit, debit} {
+					if err := fn(transaction); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+
+		res, err := service.GetStatement(ctx, "user123", map[string]string{"period": "2023-11"})
+		assert.NoError(t, err)
+		assert.Equal(t, "2023-11", res.Period)
+		assert.Equal(t, "BRL", res.Currency)
+		assert.Equal(t, 2, res.Exponent)
+		assert.Equal(t, from, res.From)
+		assert.Equal(t, to, res.To)
+		// the holds are not in the ledger balance
+		assert.Equal(t, int64(1000), res.Opening)
+		assert.Equal(t, int64(500), res.Credits)
+		assert.Equal(t, int64(-2000), res.Debits)
+		assert.Equal(t, int64(-500), res.Closing)
+		if assert.Len(t, res.Lines, 2) {
+			assert.Equal(t, int64(1500), res.Lines[0].Balance)
+			assert.Equal(t, "order-1", res.Lines[0].ExternalReference)
+			assert.Equal(t, int64(-500), res.Lines[1].Balance)
+			assert.Equal(t, "debit", res.Lines[1].Type)
+		}
+	})
+
+	t.Run("a month without transactions", func(t *testing.T) {
+		mockRepo.EXPECT().Balance(gomock.Any(), "user123", gomock.Any()).Return(map[entities.Currency]entities.Balance{}, nil)
+		mockRepo.EXPECT().Stream(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+
+		res, err := service.GetStatement(ctx, "user123", map[string]string{"period": "2023-11", "currency": "JPY"})
+		assert.NoError(t, err)
+		assert.Equal(t, "JPY", res.Currency)
+		assert.Equal(t, 0, res.Exponent)
+		assert.Equal(t, int64(0), res.Opening)
+		assert.Equal(t, int64(0), res.Closing)
+		assert.NotNil(t, res.Lines)
+		assert.Empty(t, res.Lines)
+	})
+
+	t.Run("invalid filters", func(t *testing.T) {
+		_, err := service.GetStatement(ctx, "user123", map[string]string{})
+		assert.EqualError(t, err, "period must be a month (YYYY-MM)")
+
+		_, err = service.GetStatement(ctx, "user123", map[string]string{"period": "2023-11-01"})
+		assert.EqualError(t, err, "period must be a month (YYYY-MM)")
+
+		_, err = service.GetStatement(ctx, "user123", map[string]string{"period": time.Now().AddDate(0, 2, 0).Format("2006-01")})
+		assert.EqualError(t, err, "period can't be in the future")
+
+		_, err = service.GetStatement(ctx, "user123", map[string]string{"period": "2023-11", "currency": "XYZ"})
+		assert.EqualError(t, err, "currency must be an ISO 4217 code")
+	})
+
+	t.Run("repository error", func(t *testing.T) {
+		mockRepo.EXPECT().Balance(gomock.Any(), "user123", gomock.Any()).Return(nil, errors.New("database error"))
+
+		_, err := service.GetStatement(ctx, "user123", map[string]string{"period": "2023-11"})
+		assert.EqualError(t, err, "database error")
+	})
+}
@@ -2,18 +2,68 @@ package services
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
 	"os"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
 
 	"user-transactions/application/dto"
 	"user-transactions/core/entities"
 	"user-transactions/core/repositories"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrIdempotencyKeyInProgress = entities.NewError(entities.KIND_CONFLICT, "idempotency_key_in_progress", "a request with this Idempotency-Key is still being processed")
+	ErrIdempotencyKeyReused     = entities.NewError(entities.KIND_UNPROCESSABLE, "idempotency_key_reused", "this Idempotency-Key was already used with a different request")
+	ErrInvalidTransactionID     = entities.NewError(entities.KIND_VALIDATION, "invalid_id", "the id of a transaction must be a UUID")
+	ErrBatchAborted             = entities.NewError(entities.KIND_UNPROCESSABLE, "batch_aborted", "not inserted, another transaction of the all-or-nothing batch failed")
+)
+
+// BatchTooLargeError is the error of a batch with more than maxSize transactions.
+func BatchTooLargeError(maxSize int) *entities.Error {
+	return entities.NewFieldError("invalid_batch_size", "/transactions", fmt.Sprintf("the batch must have at most %d transactions", maxSize))
+}
+
+const (
+	BATCH_ITEM_CREATED = "created"
+	BATCH_ITEM_FAILED  = "failed"
 )
 
+// defaultMaxBatchSize is how many transactions a batch accepts when BATCH_MAX_SIZE is not set.
+const defaultMaxBatchSize = 50000
+
+// batchChunkSize is how many transactions of a batch that is not all-or-nothing are inserted in each database transaction.
+const batchChunkSize = 1000
+
+// defaultHoldTTL is how long a hold lasts when the request has no expires_at and HOLD_TTL_MINUTES is not set.
+const defaultHoldTTL = 7 * 24 * time.Hour
+
+// defaultIdempotencyTTL is how long an idempotency key stays reserved by a submission that didn't finish, when
+// IDEMPOTENCY_TTL_SECONDS is not set. It's never shorter than the timeout of the requests.
+const defaultIdempotencyTTL = time.Minute
+
+// holdExpiryBatchSize is how many expired holds are voided in each database transaction.
+const holdExpiryBatchSize = 500
+
 type TransactionService struct {
 	Timeout               int
+	MaxBatchSize          int
+	DefaultCurrency       entities.Currency // of the requests without a currency
+	HoldTTL               time.Duration     // of the holds requested without expires_at
+	IdempotencyTTL        time.Duration     // of the reservations of the idempotency keys
 	TransactionRepository repositories.TransactionRepository
+	IdempotencyRepository repositories.IdempotencyRepository
+	OverdraftPolicy       *entities.OverdraftPolicy // the debits are not checked against the balance when nil
 }
 
 func NewTransactionService(tr repositories.TransactionRepository) (*TransactionService, error) {
@@ -22,37 +72,386 @@ func NewTransactionService(tr repositories.TransactionRepository) (*TransactionS
 		timeout = 5
 	}
 
+	maxBatchSize, err := strconv.Atoi(os.Getenv("BATCH_MAX_SIZE"))
+	if err != nil || maxBatchSize <= 0 {
+		maxBatchSize = defaultMaxBatchSize
+	}
+
+	defaultCurrency, err := loadDefaultCurrency()
+	if err != nil {
+		return nil, err
+	}
+
+	holdTTL := defaultHoldTTL
+	if minutes, err := strconv.Atoi(os.Getenv("HOLD_TTL_MINUTES")); err == nil && minutes > 0 {
+		holdTTL = time.Duration(minutes) * time.Minute
+	}
+
+	idempotencyTTL := defaultIdempotencyTTL
+	if seconds, err := strconv.Atoi(os.Getenv("IDEMPOTENCY_TTL_SECONDS")); err == nil && seconds > 0 {
+		idempotencyTTL = time.Duration(seconds) * time.Second
+	}
+	// a reservation must outlive the request that made it
+	idempotencyTTL = max(idempotencyTTL, time.Duration(timeout)*time.Second)
+
 	return &TransactionService{
 		Timeout:               timeout,
+		MaxBatchSize:          maxBatchSize,
+		DefaultCurrency:       defaultCurrency,
+		HoldTTL:               holdTTL,
+		IdempotencyTTL:        idempotencyTTL,
 		TransactionRepository: tr,
 	}, nil
 }
 
+func (ts *TransactionService) WithIdempotencyRepository(ir repositories.IdempotencyRepository) *TransactionService {
+	ts.IdempotencyRepository = ir
+	return ts
+}
+
+func (ts *TransactionService) WithOverdraftPolicy(policy *entities.OverdraftPolicy) *TransactionService {
+	ts.OverdraftPolicy = policy
+	return ts
+}
+
 func (ts *TransactionService) CreateTransaction(c context.Context, req *dto.CreateTransactionReq) (*dto.TransactionRes, []error) {
 	ctx, cancel := context.WithTimeout(c, time.Duration(ts.Timeout)*time.Second)
 	defer cancel()
 
-	transaction, errs := entities.NewTransaction(req.Origin, req.UserID, req.Amount, entities.OperationType(req.Type))
+	transaction, errs := ts.newTransaction(req)
 	if errs != nil {
 		return nil, errs
 	}
 
+	// with commit-sync the repository only returns once the transaction is committed
+	if req.Consistency != "" {
+		consistency := repositories.WriteConsistency(req.Consistency)
+		if !consistency.Valid() {
+			return nil, []error{entities.NewError(entities.KIND_VALIDATION, "invalid_consistency", fmt.Sprintf("consistency must be one of [%s %s]", repositories.CONSISTENCY_ASYNC, repositories.CONSISTENCY_COMMIT_SYNC))}
+		}
+		ctx = repositories.WithConsistency(ctx, consistency)
+	}
+
+	if req.IdempotencyKey != "" && ts.IdempotencyRepository != nil {
+		return ts.createIdempotentTransaction(ctx, req, transaction)
+	}
+
+	if err := ts.insert(ctx, transaction); err != nil {
+		return nil, []error{err}
+	}
+
+	return newTransactionRes(transaction), nil
+}
+
+// insert checks the debits limited by the overdraft policy against the balance while inserting them,
+// the other transactions go through the bulk buffer but the holds, which must be found to be captured,
+// and the ones with an external reference, whose uniqueness is checked while inserting them.
+func (ts *TransactionService) insert(ctx context.Context, transaction *entities.Transaction) error {
+	if _, ok := ts.OverdraftPolicy.Limit(transaction); ok {
+		return ts.TransactionRepository.InsertWithBalanceCheck(ctx, []*entities.Transaction{transaction}, ts.OverdraftPolicy)
+	}
+	if transaction.Status == entities.STATUS_PENDING || transaction.ExternalReference != nil {
+		return ts.TransactionRepository.InsertMany(ctx, []*entities.Transaction{transaction})
+	}
 	_, err := ts.TransactionRepository.Insert(ctx, transaction)
+	return err
+}
+
+// newTransaction creates the transaction of the request in its currency, or in the default one.
+func (ts *TransactionService) newTransaction(req *dto.CreateTransactionReq) (*entities.Transaction, []error) {
+	currency, err := requestCurrency(req.Currency, req.Exponent, ts.DefaultCurrency)
 	if err != nil {
 		return nil, []error{err}
 	}
 
-	return &dto.TransactionRes{
-		ID:        transaction.ID.String(),
-		Origin:    transaction.Origin,
-		UserID:    transaction.UserID,
-		Amount:    transaction.Amount,
-		Type:      transaction.Type.String(),
-		CreatedAt: transaction.CreatedAt,
-	}, nil
+	opts := []entities.TransactionOption{
+		entities.WithCurrency(currency),
+		entities.WithExternalReference(req.ExternalReference),
+		entities.WithMetadata(req.Metadata),
+	}
+	if req.Hold {
+		expiresAt := time.Now().Add(ts.HoldTTL)
+		if req.ExpiresAt != nil {
+			expiresAt = *req.ExpiresAt
+		}
+		opts = append(opts, entities.WithHold(expiresAt))
+	} else if req.ExpiresAt != nil {
+		return nil, []error{entities.NewFieldError("invalid_expires_at", "/expires_at", "expires_at is only allowed on holds")}
+	}
+
+	return entities.NewTransaction(req.Origin, req.UserID, req.Amount, entities.OperationType(req.Type), opts...)
+}
+
+// CreateTransactions validates and inserts a batch of transactions, reporting the result of each one.
+// An atomic batch is inserted in a single database transaction and only when all its transactions are valid,
+// otherwise the valid transactions are inserted even when others fail. With an overdraft policy the debits are
+// checked against the balances: all together in an atomic batch, one by one after the others in a batch that is not.
+func (ts *TransactionService) CreateTransactions(c context.Context, reqs []*dto.CreateTransactionReq, atomic bool) (*dto.TransactionBatchRes, []error) {
+	if len(reqs) == 0 {
+		return nil, []error{entities.NewFieldError("invalid_batch_size", "/transactions", "the batch must have at least one transaction")}
+	}
+	if len(reqs) > ts.MaxBatchSize {
+		return nil, []error{BatchTooLargeError(ts.MaxBatchSize)}
+	}
+
+	ctx, cancel := context.WithTimeout(c, time.Duration(ts.Timeout)*time.Second)
+	defer cancel()
+
+	res := &dto.TransactionBatchRes{Atomic: atomic}
+	transactions := make([]*entities.Transaction, 0, len(reqs))
+	items := make([]*dto.TransactionBatchItemRes, 0, len(reqs))
+	for i, req := range reqs {
+		item := &dto.TransactionBatchItemRes{Index: i}
+		res.Items = append(res.Items, item)
+
+		transaction, errs := ts.newTransaction(req)
+		if errs != nil {
+			failBatchItem(item, errs...)
+			continue
+		}
+		transactions = append(transactions, transaction)
+		items = append(items, item)
+	}
+
+	if atomic {
+		if len(transactions) < len(reqs) {
+			failBatchItems(items, ErrBatchAborted)
+		} else if err := ts.insertMany(ctx, transactions); err != nil {
+			failBatchItems(items, err)
+		} else {
+			createBatchItems(items, transactions)
+		}
+	} else {
+		// the transactions with an external reference are inserted one by one, so a duplicate reference only fails its own,
+		// and the limited debits are checked after the credits of the batch are inserted, each in its own database transaction
+		var referenced, limited []*entities.Transaction
+		var referencedItems, limitedItems []*dto.TransactionBatchItemRes
+		unlimited := make([]*entities.Transaction, 0, len(transactions))
+		unlimitedItems := make([]*dto.TransactionBatchItemRes, 0, len(items))
+		for i, transaction := range transactions {
+			if _, ok := ts.OverdraftPolicy.Limit(transaction); ok {
+				limited = append(limited, transaction)
+				limitedItems = append(limitedItems, items[i])
+			} else if transaction.ExternalReference != nil {
+				referenced = append(referenced, transaction)
+				referencedItems = append(referencedItems, items[i])
+			} else {
+				unlimited = append(unlimited, transaction)
+				unlimitedItems = append(unlimitedItems, items[i])
+			}
+		}
+
+		for start := 0; start < len(unlimited); start += batchChunkSize {
+			end := min(start+batchChunkSize, len(unlimited))
+			if err := ts.TransactionRepository.InsertMany(ctx, unlimited[start:end]); err != nil {
+				failBatchItems(unlimitedItems[start:end], err)
+				continue
+			}
+			createBatchItems(unlimitedItems[start:end], unlimited[start:end])
+		}
+		for i := range referenced {
+			if err := ts.TransactionRepository.InsertMany(ctx, referenced[i:i+1]); err != nil {
+				failBatchItem(referencedItems[i], err)
+				continue
+			}
+			createBatchItems(referencedItems[i:i+1], referenced[i:i+1])
+		}
+		for i := range limited {
+			if err := ts.TransactionRepository.InsertWithBalanceCheck(ctx, limited[i:i+1], ts.OverdraftPolicy); err != nil {
+				failBatchItem(limitedItems[i], err)
+				continue
+			}
+			createBatchItems(limitedItems[i:i+1], limited[i:i+1])
+		}
+	}
+
+	for _, item := range res.Items {
+		if item.Status == BATCH_ITEM_CREATED {
+			res.Created++
+		} else {
+			res.Failed++
+		}
+	}
+	return res, nil
+}
+
+// insertMany inserts the transactions in a single database transaction, checking the limited debits when there is any.
+func (ts *TransactionService) insertMany(ctx context.Context, transactions []*entities.Transaction) error {
+	for _, transaction := range transactions {
+		if _, ok := ts.OverdraftPolicy.Limit(transaction); ok {
+			return ts.TransactionRepository.InsertWithBalanceCheck(ctx, transactions, ts.OverdraftPolicy)
+		}
+	}
+	return ts.TransactionRepository.InsertMany(ctx, transactions)
+}
+
+func createBatchItems(items []*dto.TransactionBatchItemRes, transactions []*entities.Transaction) {
+	for i, item := range items {
+		item.Status = BATCH_ITEM_CREATED
+		item.Transaction = newTransactionRes(transactions[i])
+	}
+}
+
+func failBatchItems(items []*dto.TransactionBatchItemRes, err error) {
+	for _, item := range items {
+		failBatchItem(item, err)
+	}
+}
+
+// errBatchItemFailed replaces in the batch items the failures of the service, e.g. the database errors, which are logged instead.
+var errBatchItemFailed = errors.New("an unexpected error occurred")
+
+func failBatchItem(item *dto.TransactionBatchItemRes, errs ...error) {
+	item.Status = BATCH_ITEM_FAILED
+	for _, err := range errs {
+		var typed *entities.Error
+		if !errors.As(err, &typed) && !errors.Is(err, context.DeadlineExceeded) {
+			log.Printf("error inserting the transaction %d of a batch: %s", item.Index, err)
+			err = errBatchItemFailed
+		}
+		item.Errors = append(item.Errors, err.Error())
+	}
+}
+
+// createIdempotentTransaction reserves the idempotency key before inserting the transaction,
+// repeated submissions with the same key replay the response stored for the first one.
+func (ts *TransactionService) createIdempotentTransaction(ctx context.Context, req *dto.CreateTransactionReq, transaction *entities.Transaction) (*dto.TransactionRes, []error) {
+	key, errs := entities.NewIdempotencyKey(req.Origin, req.IdempotencyKey, fingerprint(req), transaction.ID, ts.IdempotencyTTL)
+	if errs != nil {
+		return nil, errs
+	}
+
+	stored, err := ts.reserveIdempotencyKey(ctx, key)
+	if err != nil {
+		return nil, []error{err}
+	}
+	if stored != nil {
+		res, err := replayIdempotentTransaction(stored, key)
+		if err != nil {
+			return nil, []error{err}
+		}
+		return res, nil
+	}
+
+	if err := ts.insert(ctx, transaction); err != nil {
+		switch {
+		case errors.Is(err, repositories.ErrCommitNotConfirmed):
+			// the transaction was accepted and may still be committed, a retry is answered with it instead of duplicating it
+			res := newTransactionRes(transaction)
+			res.CommitStatus = string(entities.COMMIT_PENDING)
+			ts.completeIdempotencyKey(key, res)
+		case rejected(err):
+			ts.releaseIdempotencyKey(key)
+		default:
+			// the transaction may have been inserted, so the key stays reserved until it expires
+			log.Printf("keeping the idempotency key %s of %s reserved: %s", key.Key, key.Origin, err)
+		}
+		return nil, []error{err}
+	}
+
+	res := newTransactionRes(transaction)
+	ts.completeIdempotencyKey(key, res)
+	return res, nil
+}
+
+// reserveIdempotencyKey reserves the key, or takes over the expired reservation of a submission that never finished,
+// e.g. the process died while inserting. When its transaction was inserted anyway the reservation is completed with
+// it instead, so the retry is answered with it rather than duplicating it. It returns the key stored by another
+// submission, nil once reserved.
+func (ts *TransactionService) reserveIdempotencyKey(ctx context.Context, key *entities.IdempotencyKey) (*entities.IdempotencyKey, error) {
+	reserved, err := ts.IdempotencyRepository.Reserve(ctx, key)
+	if err != nil || reserved {
+		return nil, err
+	}
+
+	stored, err := ts.IdempotencyRepository.Find(ctx, key.Origin, key.Key)
+	if err != nil || !stored.Expired(key.CreatedAt) {
+		return stored, err
+	}
+
+	// the transactions still in the bulk buffer are found too
+	transaction, err := ts.TransactionRepository.Find(ctx, stored.TransactionID.String())
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		if taken, err := ts.IdempotencyRepository.TakeOver(ctx, stored, key); err != nil || taken {
+			return nil, err
+		}
+		// taken over by another retry, which is still in progress
+		return stored, nil
+	case err != nil:
+		return nil, err
+	}
+
+	response, err := json.Marshal(newTransactionRes(transaction))
+	if err != nil {
+		return nil, err
+	}
+	stored.Response = string(response)
+	if err := ts.IdempotencyRepository.Complete(ctx, stored); err != nil {
+		return nil, err
+	}
+	return stored, nil
+}
+
+// rejected tells whether the transaction was certainly not inserted, e.g. it's invalid, it overdraws the balance
+// or the queue is full. The other failures, e.g. of the database, may have happened once it was.
+func rejected(err error) bool {
+	var e *entities.Error
+	return errors.As(err, &e) && e.Kind != entities.KIND_TIMEOUT && !errors.Is(err, repositories.ErrDatabaseUnavailable)
+}
+
+// completeIdempotencyKey stores the response of the key, so a retry is answered with it. The transaction is
+// accepted whether the request is still waiting or not, so the key is completed with a context of its own.
+func (ts *TransactionService) completeIdempotencyKey(key *entities.IdempotencyKey, res *dto.TransactionRes) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(ts.Timeout)*time.Second)
+	defer cancel()
+
+	response, err := json.Marshal(res)
+	if err == nil {
+		key.Response = string(response)
+		err = ts.IdempotencyRepository.Complete(ctx, key)
+	}
+	if err != nil {
+		// a retry is answered as in progress until the reservation expires
+		log.Printf("error completing the idempotency key %s of %s: %s", key.Key, key.Origin, err)
+	}
+}
+
+// releaseIdempotencyKey frees the key, so the client can retry the same request. The context of the request may be
+// the reason the insert failed, so the key is released with one of its own; when it can't be released the
+// reservation is taken over once expired.
+func (ts *TransactionService) releaseIdempotencyKey(key *entities.IdempotencyKey) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(ts.Timeout)*time.Second)
+	defer cancel()
+
+	if err := ts.IdempotencyRepository.Release(ctx, key); err != nil {
+		log.Printf("error releasing the idempotency key %s of %s: %s", key.Key, key.Origin, err)
+	}
+}
+
+// replayIdempotentTransaction answers the submission of the key with the response stored by the first one.
+func replayIdempotentTransaction(stored, key *entities.IdempotencyKey) (*dto.TransactionRes, error) {
+	if stored.Fingerprint != key.Fingerprint {
+		return nil, ErrIdempotencyKeyReused
+	}
+	if stored.Status != entities.IDEMPOTENCY_COMPLETED {
+		return nil, ErrIdempotencyKeyInProgress
+	}
+
+	res := &dto.TransactionRes{}
+	if err := json.Unmarshal([]byte(stored.Response), res); err != nil {
+		return nil, err
+	}
+	return res, nil
 }
 
+// GetTransaction returns the transaction with its reversals, the id must be a UUID so a typo doesn't reach the database.
 func (ts *TransactionService) GetTransaction(c context.Context, id string) (*dto.TransactionRes, error) {
+	id, err := parseTransactionID(id)
+	if err != nil {
+		return nil, err
+	}
+
 	ctx, cancel := context.WithTimeout(c, time.Duration(ts.Timeout)*time.Second)
 	defer cancel()
 
@@ -61,49 +460,573 @@ func (ts *TransactionService) GetTransaction(c context.Context, id string) (*dto
 		return nil, err
 	}
 
-	return &dto.TransactionRes{
-		ID:        transaction.ID.String(),
-		Origin:    transaction.Origin,
-		UserID:    transaction.UserID,
-		Amount:    transaction.Amount,
-		Type:      transaction.Type.String(),
-		CreatedAt: transaction.CreatedAt,
-	}, nil
+	res := newTransactionRes(transaction)
+	if transaction.ReversalOf != nil {
+		return res, nil
+	}
+
+	reversals, err := ts.TransactionRepository.ListReversals(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	res.ReversalStatus = string(transaction.ReversalStatus(reversals))
+	res.ReversedAmount = entities.ReversedAmount(reversals)
+	for _, reversal := range reversals {
+		res.Reversals = append(res.Reversals, reversal.ID.String())
+	}
+
+	return res, nil
 }
 
-func (ts *TransactionService) ListTransactions(c context.Context, pageSize, offset int, filter map[string]string) ([]*dto.TransactionRes, error) {
+func (ts *TransactionService) ReverseTransaction(c context.Context, id string, req *dto.CreateReversalReq) (*dto.TransactionRes, []error) {
+	id, err := parseTransactionID(id)
+	if err != nil {
+		return nil, []error{err}
+	}
+
 	ctx, cancel := context.WithTimeout(c, time.Duration(ts.Timeout)*time.Second)
 	defer cancel()
 
-	// only allow certain filters
-	allowedFilters := map[string]bool{
-		"origin":  true,
-		"user_id": true,
-		"type":    true,
+	reversal, errs := ts.TransactionRepository.Reverse(ctx, id, ts.OverdraftPolicy, func(original *entities.Transaction, reversals []*entities.Transaction) (*entities.Transaction, []error) {
+		return original.Reverse(req.Amount, reversals)
+	})
+	if errs != nil {
+		return nil, errs
 	}
-	validFilters := map[string]string{}
-	for key, value := range filter {
-		if allowedFilters[key] {
-			validFilters[key] = value
+
+	return newTransactionRes(reversal), nil
+}
+
+// parseTransactionID validates the id of a transaction, the other forms of UUID (e.g. with braces) are returned in the
+// canonical one they are stored in.
+func parseTransactionID(id string) (string, error) {
+	parsed, err := uuid.Parse(id)
+	if err != nil {
+		return "", ErrInvalidTransactionID
+	}
+	return parsed.String(), nil
+}
+
+// CaptureTransaction posts a pending hold, its amount moves from the held to the ledger balance.
+func (ts *TransactionService) CaptureTransaction(c context.Context, id string) (*dto.TransactionRes, error) {
+	id, err := parseTransactionID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(c, time.Duration(ts.Timeout)*time.Second)
+	defer cancel()
+
+	transaction, err := ts.TransactionRepository.UpdateStatus(ctx, id, func(transaction *entities.Transaction) error {
+		return transaction.Capture(time.Now())
+	})
+	if err != nil {
+		return nil, err
+	}
+	return newTransactionRes(transaction), nil
+}
+
+// VoidTransaction releases a pending hold, its amount is available again.
+func (ts *TransactionService) VoidTransaction(c context.Context, id string) (*dto.TransactionRes, error) {
+	id, err := parseTransactionID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(c, time.Duration(ts.Timeout)*time.Second)
+	defer cancel()
+
+	transaction, err := ts.TransactionRepository.UpdateStatus(ctx, id, func(transaction *entities.Transaction) error {
+		return transaction.Void()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return newTransactionRes(transaction), nil
+}
+
+// ExpireHolds voids the holds expired at now in batches, until there are no more, returning how many were voided.
+func (ts *TransactionService) ExpireHolds(c context.Context, now time.Time) (int64, error) {
+	var expired int64
+	for {
+		ctx, cancel := context.WithTimeout(c, time.Duration(ts.Timeout)*time.Second)
+		voided, err := ts.TransactionRepository.ExpireHolds(ctx, now, holdExpiryBatchSize)
+		cancel()
+		expired += voided
+		if err != nil {
+			return expired, err
+		}
+		if voided < holdExpiryBatchSize {
+			return expired, nil
+		}
+	}
+}
+
+// RunHoldExpiry voids the expired holds every interval until the context is done.
+func (ts *TransactionService) RunHoldExpiry(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			expired, err := ts.ExpireHolds(ctx, now)
+			if err != nil && ctx.Err() == nil {
+				log.Printf("error expiring the holds: %s", err)
+			}
+			if expired > 0 {
+				log.Printf("%d expired holds voided", expired)
+			}
+		}
+	}
+}
+
+func (ts *TransactionService) ListTransactions(c context.Context, pageSize, offset int, includeTotal bool, filter map[string]string) (*dto.TransactionPageRes, error) {
+	ctx, cancel := context.WithTimeout(c, time.Duration(ts.Timeout)*time.Second)
+	defer cancel()
+
+	transactionFilter, err := parseTransactionFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	// one more transaction is read to know if there is another page after this one
+	transactions, err := ts.TransactionRepository.List(ctx, pageSize+1, offset, transactionFilter)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &dto.TransactionPageRes{
+		HasNext: len(transactions) > pageSize,
+	}
+	if page.HasNext {
+		transactions = transactions[:pageSize]
+	}
+	for _, transaction := range transactions {
+		page.Transactions = append(page.Transactions, newTransactionRes(transaction))
+	}
+
+	if includeTotal {
+		if page.Total, err = ts.countTransactions(ctx, transactionFilter); err != nil {
+			return nil, err
+		}
+	}
+
+	return page, nil
+}
+
+// ListTransactionsByCursor lists the page next to the cursor, an empty cursor lists the first page.
+func (ts *TransactionService) ListTransactionsByCursor(c context.Context, pageSize int, cursor string, includeTotal bool, filter map[string]string) (*dto.TransactionPageRes, error) {
+	ctx, cancel := context.WithTimeout(c, time.Duration(ts.Timeout)*time.Second)
+	defer cancel()
+
+	transactionFilter, err := parseTransactionFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var current *entities.Cursor
+	if cursor != "" {
+		if current, err = entities.DecodeCursor(cursor); err != nil {
+			return nil, err
+		}
+		if current.Sort != transactionFilter.Sort {
+			return nil, entities.NewParameterError("cursor", "cursor was created for another sort")
 		}
 	}
 
-	transactions, err := ts.TransactionRepository.List(ctx, pageSize, offset, validFilters)
+	// one more transaction is read to know if there is another page after this one
+	transactions, err := ts.TransactionRepository.ListByCursor(ctx, pageSize+1, current, transactionFilter)
 	if err != nil {
 		return nil, err
 	}
 
-	var res []*dto.TransactionRes
+	backward := current != nil && current.Backward
+	hasMore := len(transactions) > pageSize
+	if hasMore {
+		if backward {
+			transactions = transactions[1:]
+		} else {
+			transactions = transactions[:pageSize]
+		}
+	}
+
+	page := &dto.TransactionPageRes{}
 	for _, transaction := range transactions {
-		res = append(res, &dto.TransactionRes{
-			ID:        transaction.ID.String(),
-			Origin:    transaction.Origin,
-			UserID:    transaction.UserID,
-			Amount:    transaction.Amount,
-			Type:      transaction.Type.String(),
-			CreatedAt: transaction.CreatedAt,
+		page.Transactions = append(page.Transactions, newTransactionRes(transaction))
+	}
+
+	if includeTotal {
+		if page.Total, err = ts.countTransactions(ctx, transactionFilter); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(transactions) == 0 {
+		return page, nil
+	}
+
+	first, last := transactions[0], transactions[len(transactions)-1]
+	if backward {
+		// going back, there is always the page that we came from after this one
+		page.NextCursor = entities.NewCursor(last, transactionFilter.Sort, false).Encode()
+		if hasMore {
+			page.PrevCursor = entities.NewCursor(first, transactionFilter.Sort, true).Encode()
+		}
+	} else {
+		if hasMore {
+			page.NextCursor = entities.NewCursor(last, transactionFilter.Sort, false).Encode()
+		}
+		if current != nil {
+			page.PrevCursor = entities.NewCursor(first, transactionFilter.Sort, true).Encode()
+		}
+	}
+	page.HasNext = page.NextCursor != ""
+	return page, nil
+}
+
+// ExportTransactions calls fn with each transaction passing the filter, the filter is validated before any transaction is read.
+// The export is not bounded by the services timeout, it ends when all the transactions are read or when c is done.
+func (ts *TransactionService) ExportTransactions(c context.Context, filter map[string]string, fn func(*dto.TransactionRes) error) error {
+	transactionFilter, err := parseTransactionFilter(filter)
+	if err != nil {
+		return err
+	}
+
+	return ts.TransactionRepository.Stream(c, transactionFilter, func(transaction *entities.Transaction) error {
+		return fn(newTransactionRes(transaction))
+	})
+}
+
+// SummarizeTransactions totals the transactions passing the List filters by currency, by the comma separated
+// columns of group_by and by the interval when sent.
+func (ts *TransactionService) SummarizeTransactions(c context.Context, filter map[string]string) ([]*dto.TransactionSummaryRes, error) {
+	ctx, cancel := context.WithTimeout(c, time.Duration(ts.Timeout)*time.Second)
+	defer cancel()
+
+	transactionFilter, err := parseTransactionFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var groupBy []entities.SummaryGroup
+	if value := filter["group_by"]; value != "" {
+		for _, column := range strings.Split(value, ",") {
+			group := entities.SummaryGroup(strings.TrimSpace(column))
+			if !group.Valid() {
+				return nil, entities.NewParameterError("group_by", "group_by must be a list of [origin type user_id]")
+			}
+			if !slices.Contains(groupBy, group) {
+				groupBy = append(groupBy, group)
+			}
+		}
+	}
+
+	interval := entities.SummaryInterval(filter["interval"])
+	if interval != "" && !interval.Valid() {
+		return nil, entities.NewParameterError("interval", "interval must be one of [day week month]")
+	}
+
+	summaries, err := ts.TransactionRepository.Summarize(ctx, transactionFilter, groupBy, interval)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]*dto.TransactionSummaryRes, 0, len(summaries))
+	for _, summary := range summaries {
+		res = append(res, &dto.TransactionSummaryRes{
+			Period:   summary.Period,
+			Origin:   summary.Origin,
+			UserID:   summary.UserID,
+			Type:     summary.Type.String(),
+			Currency: summary.Currency.String(),
+			Exponent: summary.Currency.Exponent(),
+			Count:    summary.Count,
+			Total:    summary.Total,
+			Credits:  summary.Credits,
+			Debits:   summary.Debits,
 		})
 	}
+	return res, nil
+}
 
+func (ts *TransactionService) countTransactions(ctx context.Context, filter *entities.TransactionFilter) (*int64, error) {
+	total, err := ts.TransactionRepository.Count(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	return &total, nil
+}
+
+// GetBalance sums the transactions of the user in a single currency, the currency filter is required
+// when the user has transactions in more than one currency.
+func (ts *TransactionService) GetBalance(c context.Context, userId string, filter map[string]string) (*dto.BalanceRes, error) {
+	balances, err := ts.GetBalances(c, userId, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	switch len(balances) {
+	case 0:
+		currency := entities.Currency(filter["currency"])
+		if currency == "" {
+			currency = ts.DefaultCurrency
+		}
+		asOf, _ := parseBalanceAsOf(filter)
+		return &dto.BalanceRes{
+			UserID:   userId,
+			Origin:   filter["origin"],
+			Currency: currency.String(),
+			Exponent: currency.Exponent(),
+			AsOf:     asOf,
+		}, nil
+	case 1:
+		return balances[0], nil
+	}
+
+	currencies := make([]string, 0, len(balances))
+	for _, balance := range balances {
+		currencies = append(currencies, balance.Currency)
+	}
+	return nil, fmt.Errorf("%w %v, filter the balance by currency", entities.ErrMixedCurrencies, currencies)
+}
+
+// GetBalances sums the transactions of the user by currency, ordered by currency.
+func (ts *TransactionService) GetBalances(c context.Context, userId string, filter map[string]string) ([]*dto.BalanceRes, error) {
+	ctx, cancel := context.WithTimeout(c, time.Duration(ts.Timeout)*time.Second)
+	defer cancel()
+
+	balanceFilter := &entities.BalanceFilter{
+		Origin:   filter["origin"],
+		Currency: entities.Currency(filter["currency"]),
+	}
+	if balanceFilter.Currency != "" && !balanceFilter.Currency.Valid() {
+		return nil, entities.NewParameterError("currency", "currency must be an ISO 4217 code")
+	}
+	asOf, err := parseBalanceAsOf(filter)
+	if err != nil {
+		return nil, err
+	}
+	if filter["as_of"] != "" {
+		balanceFilter.AsOf = &asOf
+	}
+
+	balances, err := ts.TransactionRepository.Balance(ctx, userId, balanceFilter)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]*dto.BalanceRes, 0, len(balances))
+	for currency, balance := range balances {
+		res = append(res, &dto.BalanceRes{
+			UserID:    userId,
+			Origin:    balanceFilter.Origin,
+			Currency:  currency.String(),
+			Exponent:  currency.Exponent(),
+			Balance:   balance.Ledger,
+			Held:      balance.Held,
+			Available: balance.Available(),
+			AsOf:      asOf,
+		})
+	}
+	slices.SortFunc(res, func(a, b *dto.BalanceRes) int {
+		return strings.Compare(a.Currency, b.Currency)
+	})
 	return res, nil
 }
+
+// parseBalanceAsOf reads the moment of the balance, now when not sent.
+func parseBalanceAsOf(filter map[string]string) (time.Time, error) {
+	value := filter["as_of"]
+	if value == "" {
+		return time.Now().UTC(), nil
+	}
+
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, entities.NewParameterError("as_of", "as_of must be a RFC 3339 timestamp")
+	}
+	return parsed.UTC(), nil
+}
+
+// parseTransactionFilter validates the filters used to list the transactions, the other keys are ignored.
+func parseTransactionFilter(filter map[string]string) (*entities.TransactionFilter, error) {
+	transactionFilter := &entities.TransactionFilter{
+		Origin: filter["origin"],
+		UserID: filter["user_id"],
+		Type:   entities.OperationType(filter["type"]),
+		Sort:   entities.SORT_CREATED_AT,
+	}
+
+	if transactionFilter.Type != "" && transactionFilter.Type != entities.DEBIT && transactionFilter.Type != entities.CREDIT {
+		return nil, entities.NewParameterError("type", "type must be one of [debit credit]")
+	}
+
+	if transactionFilter.Currency = entities.Currency(filter["currency"]); transactionFilter.Currency != "" && !transactionFilter.Currency.Valid() {
+		return nil, entities.NewParameterError("currency", "currency must be an ISO 4217 code")
+	}
+
+	if transactionFilter.Status = entities.TransactionStatus(filter["status"]); transactionFilter.Status != "" && !transactionFilter.Status.Valid() {
+		return nil, entities.NewParameterError("status", "status must be one of [pending posted voided]")
+	}
+
+	for key, value := range filter {
+		metadataKey, ok := strings.CutPrefix(key, "metadata.")
+		if !ok {
+			continue
+		}
+		if !entities.ValidMetadataKey(metadataKey) {
+			return nil, entities.NewParameterError(key, fmt.Sprintf("%s is not a valid metadata filter", key))
+		}
+		if transactionFilter.Metadata == nil {
+			transactionFilter.Metadata = make(map[string]string)
+		}
+		transactionFilter.Metadata[metadataKey] = value
+	}
+
+	if value := filter["sort"]; value != "" {
+		transactionFilter.Sort = entities.TransactionSort(value)
+		if !transactionFilter.Sort.Valid() {
+			return nil, entities.NewParameterError("sort", "sort must be one of [created_at -created_at amount -amount]")
+		}
+	}
+
+	var err error
+	if transactionFilter.CreatedFrom, err = parseTime(filter, "created_from"); err != nil {
+		return nil, err
+	}
+	if transactionFilter.CreatedTo, err = parseTime(filter, "created_to"); err != nil {
+		return nil, err
+	}
+	if transactionFilter.CreatedFrom != nil && transactionFilter.CreatedTo != nil && !transactionFilter.CreatedFrom.Before(*transactionFilter.CreatedTo) {
+		return nil, entities.NewParameterError("created_from", "created_from must be before created_to")
+	}
+
+	if transactionFilter.MinAmount, err = parseAmount(filter, "min_amount"); err != nil {
+		return nil, err
+	}
+	if transactionFilter.MaxAmount, err = parseAmount(filter, "max_amount"); err != nil {
+		return nil, err
+	}
+	if transactionFilter.MinAmount != nil && transactionFilter.MaxAmount != nil && *transactionFilter.MinAmount > *transactionFilter.MaxAmount {
+		return nil, entities.NewParameterError("min_amount", "min_amount must not be greater than max_amount")
+	}
+
+	return transactionFilter, nil
+}
+
+// parseTime reads a RFC 3339 timestamp or a date (midnight UTC) from the filter.
+func parseTime(filter map[string]string, key string) (*time.Time, error) {
+	value := filter[key]
+	if value == "" {
+		return nil, nil
+	}
+
+	for _, layout := range []string{time.RFC3339, time.DateOnly} {
+		if parsed, err := time.Parse(layout, value); err == nil {
+			parsed = parsed.UTC()
+			return &parsed, nil
+		}
+	}
+	return nil, entities.NewParameterError(key, fmt.Sprintf("%s must be a RFC 3339 timestamp or a date (YYYY-MM-DD)", key))
+}
+
+func parseAmount(filter map[string]string, key string) (*int64, error) {
+	value := filter[key]
+	if value == "" {
+		return nil, nil
+	}
+
+	amount, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return nil, entities.NewParameterError(key, fmt.Sprintf("%s must be an integer amount in cents", key))
+	}
+	return &amount, nil
+}
+
+// loadDefaultCurrency reads the currency of the requests without one from DEFAULT_CURRENCY.
+func loadDefaultCurrency() (entities.Currency, error) {
+	currency := entities.Currency(os.Getenv("DEFAULT_CURRENCY"))
+	if currency == "" {
+		return entities.DEFAULT_CURRENCY, nil
+	}
+	if !currency.Valid() {
+		return "", fmt.Errorf("DEFAULT_CURRENCY must be an ISO 4217 code")
+	}
+	return currency, nil
+}
+
+// requestCurrency returns the currency of a request, or the default one when not sent.
+// The exponent, when sent, must be the one of the currency, so an amount is never read in the wrong minor unit.
+func requestCurrency(value string, exponent *int, defaultCurrency entities.Currency) (entities.Currency, error) {
+	currency := defaultCurrency
+	if value != "" {
+		currency = entities.Currency(value)
+	}
+	if exponent != nil && currency.Valid() && *exponent != currency.Exponent() {
+		return "", entities.NewFieldError("exponent_mismatch", "/exponent", fmt.Sprintf("Exponent of %s amounts must be %d", currency, currency.Exponent()))
+	}
+	return currency, nil
+}
+
+func newTransactionRes(transaction *entities.Transaction) *dto.TransactionRes {
+	res := &dto.TransactionRes{
+		ID:           transaction.ID.String(),
+		Origin:       transaction.Origin,
+		UserID:       transaction.UserID,
+		Amount:       transaction.Amount,
+		Type:         transaction.Type.String(),
+		Currency:     transaction.Currency.String(),
+		Exponent:     transaction.Currency.Exponent(),
+		Status:       string(entities.STATUS_POSTED),
+		ExpiresAt:    transaction.ExpiresAt,
+		Metadata:     dto.Metadata(transaction.Metadata),
+		CommitStatus: string(transaction.CommitStatus()),
+		CreatedAt:    transaction.CreatedAt,
+	}
+	if transaction.Status != "" {
+		res.Status = string(transaction.Status)
+	}
+	if transaction.ReversalOf != nil {
+		res.ReversalOf = transaction.ReversalOf.String()
+	}
+	if transaction.TransferID != nil {
+		res.TransferID = transaction.TransferID.String()
+	}
+	if transaction.ExternalReference != nil {
+		res.ExternalReference = *transaction.ExternalReference
+	}
+	return res
+}
+
+// fingerprint identifies the content of a create request, the same key must always be sent with the same content.
+func fingerprint(req *dto.CreateTransactionReq) string {
+	// the fields added later are left out when not sent, so the keys stored before they existed keep their fingerprint
+	content, _ := json.Marshal(struct {
+		Origin            string
+		UserID            string
+		Amount            int64
+		Type              string
+		Currency          string            `json:",omitempty"`
+		Hold              bool              `json:",omitempty"`
+		ExpiresAt         *time.Time        `json:",omitempty"`
+		ExternalReference string            `json:",omitempty"`
+		Metadata          map[string]string `json:",omitempty"`
+	}{
+		Origin:            req.Origin,
+		UserID:            req.UserID,
+		Amount:            req.Amount,
+		Type:              req.Type,
+		Currency:          req.Currency,
+		Hold:              req.Hold,
+		ExpiresAt:         req.ExpiresAt,
+		ExternalReference: req.ExternalReference,
+		Metadata:          req.Metadata,
+	})
+
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
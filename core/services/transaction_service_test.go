@@ -3,18 +3,22 @@ package services_test
 import (
 	"context"
 	"errors"
+	"fmt"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
 	"user-transactions/application/dto"
 	"user-transactions/core/entities"
+	"user-transactions/core/repositories"
 	mock_repositories "user-transactions/core/repositories/mock"
 	"user-transactions/core/services"
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/mock/gomock"
+	"gorm.io/gorm"
 )
 
 func Test_NewTransactionService(t *testing.T) {
@@ -106,6 +110,7 @@ func Test_TransactionService_GetTransaction(t *testing.T) {
 
 	t.Run("get an existing transaction", func(t *testing.T) {
 		mockRepo.EXPECT().Find(gomock.Any(), idStr).Return(expected, nil)
+		mockRepo.EXPECT().ListReversals(gomock.Any(), idStr).Return(nil, nil)
 
 		res, err := service.GetTransaction(ctx, idStr)
 
@@ -116,6 +121,20 @@ func Test_TransactionService_GetTransaction(t *testing.T) {
 		assert.Equal(t, expected.Amount, res.Amount)
 		assert.Equal(t, expected.Type.String(), res.Type)
 		assert.Equal(t, expected.CreatedAt, res.CreatedAt)
+		assert.Equal(t, string(entities.NOT_REVERSED), res.ReversalStatus)
+	})
+
+	t.Run("get a partially reversed transaction", func(t *testing.T) {
+		reversal := &entities.Transaction{ID: uuid.New(), Amount: 50, Type: entities.CREDIT, ReversalOf: &id}
+		mockRepo.EXPECT().Find(gomock.Any(), idStr).Return(expected, nil)
+		mockRepo.EXPECT().ListReversals(gomock.Any(), idStr).Return([]*entities.Transaction{reversal}, nil)
+
+		res, err := service.GetTransaction(ctx, idStr)
+
+		assert.NoError(t, err)
+		assert.Equal(t, string(entities.PARTIALLY_REVERSED), res.ReversalStatus)
+		assert.Equal(t, int64(50), res.ReversedAmount)
+		assert.Equal(t, []string{reversal.ID.String()}, res.Reversals)
 	})
 
 	t.Run("get a non-existing transaction", func(t *testing.T) {
@@ -126,6 +145,72 @@ func Test_TransactionService_GetTransaction(t *testing.T) {
 		assert.Error(t, err)
 		assert.Nil(t, res)
 	})
+
+	t.Run("get a transaction by an id that is not a UUID", func(t *testing.T) {
+		res, err := service.GetTransaction(ctx, "invalid")
+
+		assert.ErrorIs(t, err, services.ErrInvalidTransactionID)
+		assert.Nil(t, res)
+	})
+
+	t.Run("get a transaction by an id in upper case", func(t *testing.T) {
+		mockRepo.EXPECT().Find(gomock.Any(), idStr).Return(expected, nil)
+		mockRepo.EXPECT().ListReversals(gomock.Any(), idStr).Return(nil, nil)
+
+		res, err := service.GetTransaction(ctx, strings.ToUpper(idStr))
+
+		assert.NoError(t, err)
+		assert.Equal(t, idStr, res.ID)
+	})
+}
+
+func Test_TransactionService_ReverseTransaction(t *testing.T) {
+	ctx := context.Background()
+	original, errs := entities.NewTransaction("desktop-web", "user123", 1000, entities.CREDIT)
+	assert.Empty(t, errs)
+	idStr := original.ID.String()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRepo := mock_repositories.NewMockTransactionRepository(ctrl)
+
+	service, err := services.NewTransactionService(mockRepo)
+	assert.Nil(t, err)
+
+	// simulates the repository calling the builder with the locked original and its reversals
+	reverse := func(reversals ...*entities.Transaction) func(context.Context, string, *entities.OverdraftPolicy, func(*entities.Transaction, []*entities.Transaction) (*entities.Transaction, []error)) (*entities.Transaction, []error) {
+		return func(ctx context.Context, id string, policy *entities.OverdraftPolicy, build func(*entities.Transaction, []*entities.Transaction) (*entities.Transaction, []error)) (*entities.Transaction, []error) {
+			return build(original, reversals)
+		}
+	}
+
+	t.Run("reverse a transaction", func(t *testing.T) {
+		mockRepo.EXPECT().Reverse(gomock.Any(), idStr, gomock.Any(), gomock.Any()).DoAndReturn(reverse())
+
+		res, errs := service.ReverseTransaction(ctx, idStr, &dto.CreateReversalReq{Amount: 250})
+
+		assert.Nil(t, errs)
+		assert.Equal(t, int64(-250), res.Amount)
+		assert.Equal(t, "debit", res.Type)
+		assert.Equal(t, idStr, res.ReversalOf)
+	})
+
+	t.Run("don't reverse a transaction already reversed", func(t *testing.T) {
+		previous, errs := original.Reverse(0, nil)
+		assert.Empty(t, errs)
+		mockRepo.EXPECT().Reverse(gomock.Any(), idStr, gomock.Any(), gomock.Any()).DoAndReturn(reverse(previous))
+
+		res, errs := service.ReverseTransaction(ctx, idStr, &dto.CreateReversalReq{})
+
+		assert.Nil(t, res)
+		assert.ErrorIs(t, errs[0], entities.ErrAlreadyReversed)
+	})
+	t.Run("don't reverse a transaction by an id that is not a UUID", func(t *testing.T) {
+		res, errs := service.ReverseTransaction(ctx, "invalid", &dto.CreateReversalReq{})
+
+		assert.Nil(t, res)
+		assert.Equal(t, []error{services.ErrInvalidTransactionID}, errs)
+	})
 }
 
 func Test_TransactionService_ListTransactions(t *testing.T) {
@@ -153,18 +238,21 @@ func Test_TransactionService_ListTransactions(t *testing.T) {
 			},
 		}
 
-		mockRepo.EXPECT().List(gomock.Any(), 10, 0, filter).Return(expected, nil)
+		mockRepo.EXPECT().List(gomock.Any(), 11, 0, &entities.TransactionFilter{
+			Origin: "desktop-web",
+			Sort:   entities.SORT_CREATED_AT,
+		}).Return(expected, nil)
 
-		res, err := service.ListTransactions(ctx, 10, 0, filter)
+		res, err := service.ListTransactions(ctx, 10, 0, false, filter)
 
 		assert.NoError(t, err)
-		assert.Equal(t, len(expected), len(res))
-		assert.Equal(t, expected[0].ID.String(), res[0].ID)
-		assert.Equal(t, expected[0].Origin, res[0].Origin)
-		assert.Equal(t, expected[0].UserID, res[0].UserID)
-		assert.Equal(t, expected[0].Amount, res[0].Amount)
-		assert.Equal(t, expected[0].Type.String(), res[0].Type)
-		assert.Equal(t, expected[0].CreatedAt, res[0].CreatedAt)
+		assert.Equal(t, len(expected), len(res.Transactions))
+		assert.Equal(t, expected[0].ID.String(), res.Transactions[0].ID)
+		assert.Equal(t, expected[0].Origin, res.Transactions[0].Origin)
+		assert.Equal(t, expected[0].UserID, res.Transactions[0].UserID)
+		assert.Equal(t, expected[0].Amount, res.Transactions[0].Amount)
+		assert.Equal(t, expected[0].Type.String(), res.Transactions[0].Type)
+		assert.Equal(t, expected[0].CreatedAt, res.Transactions[0].CreatedAt)
 	})
 
 	t.Run("list transactions", func(t *testing.T) {
@@ -187,17 +275,1095 @@ func Test_TransactionService_ListTransactions(t *testing.T) {
 			},
 		}
 
-		mockRepo.EXPECT().List(gomock.Any(), 10, 0, make(map[string]string)).Return(expected, nil)
+		mockRepo.EXPECT().List(gomock.Any(), 11, 0, &entities.TransactionFilter{Sort: entities.SORT_CREATED_AT}).Return(expected, nil)
+
+		res, err := service.ListTransactions(ctx, 10, 0, false, nil)
+
+		assert.NoError(t, err)
+		assert.Equal(t, len(expected), len(res.Transactions))
+		assert.Equal(t, expected[0].ID.String(), res.Transactions[0].ID)
+		assert.Equal(t, expected[0].Origin, res.Transactions[0].Origin)
+		assert.Equal(t, expected[0].UserID, res.Transactions[0].UserID)
+		assert.Equal(t, expected[0].Amount, res.Transactions[0].Amount)
+		assert.Equal(t, expected[0].Type.String(), res.Transactions[0].Type)
+		assert.Equal(t, expected[0].CreatedAt, res.Transactions[0].CreatedAt)
+	})
+}
+
+func Test_TransactionService_GetBalance(t *testing.T) {
+	ctx := context.Background()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRepo := mock_repositories.NewMockTransactionRepository(ctrl)
+
+	service, err := services.NewTransactionService(mockRepo)
+	assert.Nil(t, err)
+
+	t.Run("get the balance of a user", func(t *testing.T) {
+		mockRepo.EXPECT().Balance(gomock.Any(), "user123", &entities.BalanceFilter{}).Return(map[entities.Currency]entities.Balance{"BRL": {Ledger: 350}}, nil)
+
+		res, err := service.GetBalance(ctx, "user123", nil)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "user123", res.UserID)
+		assert.Equal(t, int64(350), res.Balance)
+		assert.Equal(t, "BRL", res.Currency)
+		assert.Equal(t, 2, res.Exponent)
+		assert.NotEmpty(t, res.AsOf)
+	})
+
+	t.Run("get the available balance of a user with holds", func(t *testing.T) {
+		mockRepo.EXPECT().Balance(gomock.Any(), "user123", &entities.BalanceFilter{}).Return(map[entities.Currency]entities.Balance{"BRL": {Ledger: 350, Held: 100}}, nil)
+
+		res, err := service.GetBalance(ctx, "user123", nil)
+
+		assert.NoError(t, err)
+		assert.Equal(t, int64(350), res.Balance)
+		assert.Equal(t, int64(100), res.Held)
+		assert.Equal(t, int64(250), res.Available)
+	})
+
+	t.Run("get the balance of a user by origin as of a timestamp", func(t *testing.T) {
+		asOf := time.Date(2023, 11, 20, 10, 0, 0, 0, time.UTC)
+		expectedFilter := &entities.BalanceFilter{Origin: "desktop-web", AsOf: &asOf}
+		mockRepo.EXPECT().Balance(gomock.Any(), "user123", expectedFilter).Return(map[entities.Currency]entities.Balance{"BRL": {Ledger: -150}}, nil)
+
+		res, err := service.GetBalance(ctx, "user123", map[string]string{
+			"origin": "desktop-web",
+			"as_of":  "2023-11-20T10:00:00Z",
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "desktop-web", res.Origin)
+		assert.Equal(t, int64(-150), res.Balance)
+		assert.Equal(t, asOf, res.AsOf)
+	})
+
+	t.Run("don't get the balance with an invalid as_of", func(t *testing.T) {
+		res, err := service.GetBalance(ctx, "user123", map[string]string{"as_of": "yesterday"})
+
+		assert.Error(t, err)
+		assert.Nil(t, res)
+	})
+
+	t.Run("get the balance of a user in a currency", func(t *testing.T) {
+		mockRepo.EXPECT().Balance(gomock.Any(), "user123", &entities.BalanceFilter{Currency: "JPY"}).Return(map[entities.Currency]entities.Balance{}, nil)
+
+		res, err := service.GetBalance(ctx, "user123", map[string]string{"currency": "JPY"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, int64(0), res.Balance)
+		assert.Equal(t, "JPY", res.Currency)
+		assert.Equal(t, 0, res.Exponent)
+	})
+
+	t.Run("don't get the balance mixing currencies", func(t *testing.T) {
+		mockRepo.EXPECT().Balance(gomock.Any(), "user123", &entities.BalanceFilter{}).Return(map[entities.Currency]entities.Balance{"BRL": {Ledger: 350}, "USD": {Ledger: 100}}, nil)
+
+		res, err := service.GetBalance(ctx, "user123", nil)
+
+		assert.ErrorIs(t, err, entities.ErrMixedCurrencies)
+		assert.Contains(t, err.Error(), "[BRL USD]")
+		assert.Nil(t, res)
+	})
+
+	t.Run("don't get the balance with an invalid currency", func(t *testing.T) {
+		res, err := service.GetBalance(ctx, "user123", map[string]string{"currency": "real"})
+
+		assert.EqualError(t, err, "currency must be an ISO 4217 code")
+		assert.Nil(t, res)
+	})
+}
+
+func Test_TransactionService_GetBalances(t *testing.T) {
+	ctx := context.Background()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRepo := mock_repositories.NewMockTransactionRepository(ctrl)
+
+	service, err := services.NewTransactionService(mockRepo)
+	assert.Nil(t, err)
+
+	t.Run("get a balance for each currency of the user", func(t *testing.T) {
+		mockRepo.EXPECT().Balance(gomock.Any(), "user123", &entities.BalanceFilter{}).Return(map[entities.Currency]entities.Balance{"USD": {Ledger: 100}, "BRL": {Ledger: 350}, "EUR": {Ledger: -20}}, nil)
+
+		res, err := service.GetBalances(ctx, "user123", nil)
+
+		assert.NoError(t, err)
+		assert.Len(t, res, 3)
+		assert.Equal(t, "BRL", res[0].Currency)
+		assert.Equal(t, int64(350), res[0].Balance)
+		assert.Equal(t, "EUR", res[1].Currency)
+		assert.Equal(t, "USD", res[2].Currency)
+		assert.Equal(t, int64(100), res[2].Balance)
+	})
+}
+
+func Test_TransactionService_CreateTransaction_Idempotency(t *testing.T) {
+	ctx := context.Background()
+	req := &dto.CreateTransactionReq{
+		Amount:         150,
+		Origin:         "mobile-android",
+		Type:           "credit",
+		UserID:         "user123",
+		IdempotencyKey: "key-123",
+	}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRepo := mock_repositories.NewMockTransactionRepository(ctrl)
+	mockIdempotencyRepo := mock_repositories.NewMockIdempotencyRepository(ctrl)
+
+	service, err := services.NewTransactionService(mockRepo)
+	assert.Nil(t, err)
+	service.WithIdempotencyRepository(mockIdempotencyRepo)
+
+	t.Run("create the transaction with a new key", func(t *testing.T) {
+		mockIdempotencyRepo.EXPECT().Reserve(gomock.Any(), gomock.Any()).Return(true, nil)
+		mockRepo.EXPECT().Insert(gomock.Any(), gomock.Any()).Return(nil, nil)
+		mockIdempotencyRepo.EXPECT().Complete(gomock.Any(), gomock.Any()).DoAndReturn(
+			func(ctx context.Context, key *entities.IdempotencyKey) error {
+				assert.Equal(t, "mobile-android", key.Origin)
+				assert.Equal(t, "key-123", key.Key)
+				assert.NotEmpty(t, key.Fingerprint)
+				assert.Contains(t, key.Response, key.TransactionID.String())
+				return nil
+			})
+
+		res, errs := service.CreateTransaction(ctx, req)
+
+		assert.Nil(t, errs)
+		assert.NotEmpty(t, res.ID)
+		assert.Equal(t, int64(150), res.Amount)
+	})
+
+	t.Run("replay the response of a completed key", func(t *testing.T) {
+		var stored *entities.IdempotencyKey
+		mockIdempotencyRepo.EXPECT().Reserve(gomock.Any(), gomock.Any()).DoAndReturn(
+			func(ctx context.Context, key *entities.IdempotencyKey) (bool, error) {
+				stored = &entities.IdempotencyKey{
+					Origin:      key.Origin,
+					Key:         key.Key,
+					Fingerprint: key.Fingerprint,
+					Status:      entities.IDEMPOTENCY_COMPLETED,
+					Response:    `{"id":"8c6a4a38-5a4c-4a4f-9c3f-2f7b0e7c1a11","origin":"mobile-android","user_id":"user123","amount":150,"type":"credit"}`,
+				}
+				return false, nil
+			})
+		mockIdempotencyRepo.EXPECT().Find(gomock.Any(), "mobile-android", "key-123").DoAndReturn(
+			func(ctx context.Context, origin, key string) (*entities.IdempotencyKey, error) {
+				return stored, nil
+			})
+
+		res, errs := service.CreateTransaction(ctx, req)
+
+		assert.Nil(t, errs)
+		assert.Equal(t, "8c6a4a38-5a4c-4a4f-9c3f-2f7b0e7c1a11", res.ID)
+		assert.Equal(t, int64(150), res.Amount)
+	})
+
+	t.Run("don't create with a key used by a different request", func(t *testing.T) {
+		mockIdempotencyRepo.EXPECT().Reserve(gomock.Any(), gomock.Any()).Return(false, nil)
+		mockIdempotencyRepo.EXPECT().Find(gomock.Any(), "mobile-android", "key-123").Return(&entities.IdempotencyKey{
+			Fingerprint: "another-request",
+			Status:      entities.IDEMPOTENCY_COMPLETED,
+		}, nil)
+
+		res, errs := service.CreateTransaction(ctx, req)
+
+		assert.Nil(t, res)
+		assert.ErrorIs(t, errs[0], services.ErrIdempotencyKeyReused)
+	})
+
+	t.Run("don't create while the first request is in progress", func(t *testing.T) {
+		var fingerprint string
+		mockIdempotencyRepo.EXPECT().Reserve(gomock.Any(), gomock.Any()).DoAndReturn(
+			func(ctx context.Context, key *entities.IdempotencyKey) (bool, error) {
+				fingerprint = key.Fingerprint
+				return false, nil
+			})
+		mockIdempotencyRepo.EXPECT().Find(gomock.Any(), "mobile-android", "key-123").DoAndReturn(
+			func(ctx context.Context, origin, key string) (*entities.IdempotencyKey, error) {
+				return &entities.IdempotencyKey{Fingerprint: fingerprint, Status: entities.IDEMPOTENCY_PROCESSING, ExpiresAt: time.Now().Add(time.Minute)}, nil
+			})
+
+		res, errs := service.CreateTransaction(ctx, req)
+
+		assert.Nil(t, res)
+		assert.ErrorIs(t, errs[0], services.ErrIdempotencyKeyInProgress)
+	})
+
+	t.Run("replay the transaction of an expired reservation inserted before being completed", func(t *testing.T) {
+		inserted, errs := entities.NewTransaction("mobile-android", "user123", 150, entities.CREDIT)
+		assert.Empty(t, errs)
+
+		var stored *entities.IdempotencyKey
+		mockIdempotencyRepo.EXPECT().Reserve(gomock.Any(), gomock.Any()).DoAndReturn(
+			func(ctx context.Context, key *entities.IdempotencyKey) (bool, error) {
+				// the process died once the transaction was accepted, before completing the key
+				stored = &entities.IdempotencyKey{
+					Origin:        key.Origin,
+					Key:           key.Key,
+					Fingerprint:   key.Fingerprint,
+					TransactionID: inserted.ID,
+					Status:        entities.IDEMPOTENCY_PROCESSING,
+					ExpiresAt:     key.CreatedAt.Add(-time.Second),
+				}
+				return false, nil
+			})
+		mockIdempotencyRepo.EXPECT().Find(gomock.Any(), "mobile-android", "key-123").DoAndReturn(
+			func(ctx context.Context, origin, key string) (*entities.IdempotencyKey, error) {
+				return stored, nil
+			})
+		mockRepo.EXPECT().Find(gomock.Any(), inserted.ID.String()).Return(inserted, nil)
+		mockIdempotencyRepo.EXPECT().Complete(gomock.Any(), gomock.Any()).DoAndReturn(
+			func(ctx context.Context, key *entities.IdempotencyKey) error {
+				assert.Equal(t, inserted.ID, key.TransactionID)
+				assert.Contains(t, key.Response, inserted.ID.String())
+				key.Status = entities.IDEMPOTENCY_COMPLETED
+				return nil
+			})
+
+		res, errs := service.CreateTransaction(ctx, req)
+
+		assert.Nil(t, errs)
+		assert.Equal(t, inserted.ID.String(), res.ID)
+	})
+
+	t.Run("take over an expired reservation whose transaction was not inserted", func(t *testing.T) {
+		abandoned := uuid.New()
+		mockIdempotencyRepo.EXPECT().Reserve(gomock.Any(), gomock.Any()).Return(false, nil)
+		mockIdempotencyRepo.EXPECT().Find(gomock.Any(), "mobile-android", "key-123").Return(&entities.IdempotencyKey{
+			Origin:        "mobile-android",
+			Key:           "key-123",
+			Fingerprint:   "another-request",
+			TransactionID: abandoned,
+			Status:        entities.IDEMPOTENCY_PROCESSING,
+		}, nil)
+		mockRepo.EXPECT().Find(gomock.Any(), abandoned.String()).Return(nil, gorm.ErrRecordNotFound)
+		mockIdempotencyRepo.EXPECT().TakeOver(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+			func(ctx context.Context, expired, key *entities.IdempotencyKey) (bool, error) {
+				assert.Equal(t, abandoned, expired.TransactionID)
+				assert.NotEqual(t, abandoned, key.TransactionID)
+				return true, nil
+			})
+		mockRepo.EXPECT().Insert(gomock.Any(), gomock.Any()).Return(nil, nil)
+		mockIdempotencyRepo.EXPECT().Complete(gomock.Any(), gomock.Any()).Return(nil)
+
+		res, errs := service.CreateTransaction(ctx, req)
+
+		assert.Nil(t, errs)
+		assert.NotEqual(t, abandoned.String(), res.ID)
+	})
+
+	t.Run("release the key when the transaction is rejected", func(t *testing.T) {
+		mockIdempotencyRepo.EXPECT().Reserve(gomock.Any(), gomock.Any()).DoAndReturn(
+			func(ctx context.Context, key *entities.IdempotencyKey) (bool, error) {
+				assert.Equal(t, key.CreatedAt.Add(service.IdempotencyTTL), key.ExpiresAt)
+				return true, nil
+			})
+		mockRepo.EXPECT().Insert(gomock.Any(), gomock.Any()).Return(nil, repositories.ErrQueueFull)
+		mockIdempotencyRepo.EXPECT().Release(gomock.Any(), gomock.Any()).DoAndReturn(
+			func(ctx context.Context, key *entities.IdempotencyKey) error {
+				// released with a context of its own, not the one of the request that may be done
+				assert.NoError(t, ctx.Err())
+				return nil
+			})
+
+		expired, cancel := context.WithCancel(ctx)
+		cancel()
+		res, errs := service.CreateTransaction(expired, req)
+
+		assert.Nil(t, res)
+		assert.ErrorIs(t, errs[0], repositories.ErrQueueFull)
+	})
+
+	t.Run("keep the key reserved when the transaction may be inserted", func(t *testing.T) {
+		mockIdempotencyRepo.EXPECT().Reserve(gomock.Any(), gomock.Any()).Return(true, nil)
+		mockRepo.EXPECT().Insert(gomock.Any(), gomock.Any()).Return(nil, errors.New("connection reset by peer"))
+
+		res, errs := service.CreateTransaction(ctx, req)
+
+		assert.Nil(t, res)
+		assert.Equal(t, "connection reset by peer", errs[0].Error())
+	})
+
+	t.Run("complete the key as pending when the commit is not confirmed", func(t *testing.T) {
+		mockIdempotencyRepo.EXPECT().Reserve(gomock.Any(), gomock.Any()).Return(true, nil)
+		mockRepo.EXPECT().Insert(gomock.Any(), gomock.Any()).Return(nil, fmt.Errorf("%w: %w", repositories.ErrCommitNotConfirmed, context.DeadlineExceeded))
+		mockIdempotencyRepo.EXPECT().Complete(gomock.Any(), gomock.Any()).DoAndReturn(
+			func(ctx context.Context, key *entities.IdempotencyKey) error {
+				// completed with a context of its own, the one of the request is done
+				assert.NoError(t, ctx.Err())
+				assert.Contains(t, key.Response, key.TransactionID.String())
+				assert.Contains(t, key.Response, `"commit_status":"pending"`)
+				return nil
+			})
+
+		expired, cancel := context.WithCancel(ctx)
+		cancel()
+		res, errs := service.CreateTransaction(expired, req)
+
+		assert.Nil(t, res)
+		assert.ErrorIs(t, errs[0], repositories.ErrCommitNotConfirmed)
+	})
+}
+
+func Test_TransactionService_ListTransactionsByCursor(t *testing.T) {
+	ctx := context.Background()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRepo := mock_repositories.NewMockTransactionRepository(ctrl)
+
+	service, err := services.NewTransactionService(mockRepo)
+	assert.Nil(t, err)
+
+	transactions := make([]*entities.Transaction, 5)
+	for i := range transactions {
+		transactions[i] = &entities.Transaction{
+			ID:        uuid.New(),
+			Origin:    "desktop-web",
+			UserID:    "user123",
+			Amount:    100,
+			Type:      entities.CREDIT,
+			CreatedAt: time.Date(2023, 11, 20, 10, i, 0, 0, time.UTC),
+		}
+	}
+
+	t.Run("list the first page", func(t *testing.T) {
+		mockRepo.EXPECT().ListByCursor(gomock.Any(), 3, nil, &entities.TransactionFilter{
+			Origin: "desktop-web",
+			Sort:   entities.SORT_CREATED_AT,
+		}).Return(transactions[:3], nil)
+
+		res, err := service.ListTransactionsByCursor(ctx, 2, "", false, map[string]string{"origin": "desktop-web", "cursor": ""})
+
+		assert.NoError(t, err)
+		assert.Len(t, res.Transactions, 2)
+		assert.Equal(t, transactions[0].ID.String(), res.Transactions[0].ID)
+		assert.Equal(t, entities.NewCursor(transactions[1], entities.SORT_CREATED_AT, false).Encode(), res.NextCursor)
+		assert.Empty(t, res.PrevCursor)
+		assert.True(t, res.HasNext)
+	})
+
+	t.Run("list the last page", func(t *testing.T) {
+		cursor := entities.NewCursor(transactions[2], entities.SORT_CREATED_AT, false)
+		mockRepo.EXPECT().ListByCursor(gomock.Any(), 3, cursor, &entities.TransactionFilter{Sort: entities.SORT_CREATED_AT}).Return(transactions[3:], nil)
+
+		res, err := service.ListTransactionsByCursor(ctx, 2, cursor.Encode(), false, nil)
+
+		assert.NoError(t, err)
+		assert.Len(t, res.Transactions, 2)
+		assert.Empty(t, res.NextCursor)
+		assert.False(t, res.HasNext)
+		assert.Equal(t, entities.NewCursor(transactions[3], entities.SORT_CREATED_AT, true).Encode(), res.PrevCursor)
+	})
+
+	t.Run("list the previous page", func(t *testing.T) {
+		cursor := entities.NewCursor(transactions[3], entities.SORT_CREATED_AT, true)
+		mockRepo.EXPECT().ListByCursor(gomock.Any(), 3, cursor, &entities.TransactionFilter{Sort: entities.SORT_CREATED_AT}).Return(transactions[:3], nil)
+
+		res, err := service.ListTransactionsByCursor(ctx, 2, cursor.Encode(), false, nil)
+
+		assert.NoError(t, err)
+		assert.Len(t, res.Transactions, 2)
+		assert.Equal(t, transactions[1].ID.String(), res.Transactions[0].ID)
+		assert.Equal(t, entities.NewCursor(transactions[2], entities.SORT_CREATED_AT, false).Encode(), res.NextCursor)
+		assert.Equal(t, entities.NewCursor(transactions[1], entities.SORT_CREATED_AT, true).Encode(), res.PrevCursor)
+	})
+
+	t.Run("don't list with an invalid cursor", func(t *testing.T) {
+		res, err := service.ListTransactionsByCursor(ctx, 2, "invalid", false, nil)
+
+		assert.Error(t, err)
+		assert.Nil(t, res)
+	})
+
+	t.Run("don't list with a cursor of another sort", func(t *testing.T) {
+		cursor := entities.NewCursor(transactions[2], entities.SORT_AMOUNT, false)
+		res, err := service.ListTransactionsByCursor(ctx, 2, cursor.Encode(), false, nil)
+
+		assert.Equal(t, "cursor was created for another sort", err.Error())
+		assert.Nil(t, res)
+	})
+}
+
+func Test_TransactionService_ListTransactions_Pages(t *testing.T) {
+	ctx := context.Background()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRepo := mock_repositories.NewMockTransactionRepository(ctrl)
+
+	service, err := services.NewTransactionService(mockRepo)
+	assert.Nil(t, err)
+
+	transactions := make([]*entities.Transaction, 3)
+	for i := range transactions {
+		transactions[i] = &entities.Transaction{ID: uuid.New(), Amount: 100, Type: entities.CREDIT}
+	}
+	filter := &entities.TransactionFilter{Sort: entities.SORT_CREATED_AT}
+
+	t.Run("list a page followed by another one", func(t *testing.T) {
+		mockRepo.EXPECT().List(gomock.Any(), 3, 0, filter).Return(transactions, nil)
+
+		res, err := service.ListTransactions(ctx, 2, 0, false, nil)
+
+		assert.NoError(t, err)
+		assert.Len(t, res.Transactions, 2)
+		assert.True(t, res.HasNext)
+		assert.Nil(t, res.Total)
+	})
+
+	t.Run("list the last page with the total", func(t *testing.T) {
+		mockRepo.EXPECT().List(gomock.Any(), 3, 2, filter).Return(transactions[2:], nil)
+		mockRepo.EXPECT().Count(gomock.Any(), filter).Return(int64(3), nil)
+
+		res, err := service.ListTransactions(ctx, 2, 2, true, nil)
+
+		assert.NoError(t, err)
+		assert.Len(t, res.Transactions, 1)
+		assert.False(t, res.HasNext)
+		assert.Equal(t, int64(3), *res.Total)
+	})
+
+	t.Run("list with cursor and the total", func(t *testing.T) {
+		mockRepo.EXPECT().ListByCursor(gomock.Any(), 3, nil, filter).Return(transactions, nil)
+		mockRepo.EXPECT().Count(gomock.Any(), filter).Return(int64(3), nil)
+
+		res, err := service.ListTransactionsByCursor(ctx, 2, "", true, nil)
+
+		assert.NoError(t, err)
+		assert.True(t, res.HasNext)
+		assert.Equal(t, int64(3), *res.Total)
+	})
+
+	t.Run("don't list when the count fails", func(t *testing.T) {
+		mockRepo.EXPECT().List(gomock.Any(), 3, 0, filter).Return(transactions, nil)
+		mockRepo.EXPECT().Count(gomock.Any(), filter).Return(int64(0), errors.New("connection refused"))
+
+		res, err := service.ListTransactions(ctx, 2, 0, true, nil)
+
+		assert.Error(t, err)
+		assert.Nil(t, res)
+	})
+}
+
+func Test_TransactionService_ListTransactions_Filters(t *testing.T) {
+	ctx := context.Background()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRepo := mock_repositories.NewMockTransactionRepository(ctrl)
+
+	service, err := services.NewTransactionService(mockRepo)
+	assert.Nil(t, err)
+
+	t.Run("list transactions with ranges and sort", func(t *testing.T) {
+		from := time.Date(2023, 11, 1, 0, 0, 0, 0, time.UTC)
+		to := time.Date(2023, 11, 20, 10, 30, 0, 0, time.UTC)
+		min, max := int64(-500), int64(1000)
+		mockRepo.EXPECT().List(gomock.Any(), 11, 0, &entities.TransactionFilter{
+			UserID:      "user123",
+			Type:        entities.DEBIT,
+			CreatedFrom: &from,
+			CreatedTo:   &to,
+			MinAmount:   &min,
+			MaxAmount:   &max,
+			Sort:        entities.SORT_AMOUNT_DESC,
+		}).Return(nil, nil)
 
-		res, err := service.ListTransactions(ctx, 10, 0, nil)
+		_, err := service.ListTransactions(ctx, 10, 0, false, map[string]string{
+			"user_id":      "user123",
+			"type":         "debit",
+			"created_from": "2023-11-01",
+			"created_to":   "2023-11-20T07:30:00-03:00",
+			"min_amount":   "-500",
+			"max_amount":   "1000",
+			"sort":         "-amount",
+			"unknown":      "ignored",
+		})
 
 		assert.NoError(t, err)
-		assert.Equal(t, len(expected), len(res))
-		assert.Equal(t, expected[0].ID.String(), res[0].ID)
-		assert.Equal(t, expected[0].Origin, res[0].Origin)
-		assert.Equal(t, expected[0].UserID, res[0].UserID)
-		assert.Equal(t, expected[0].Amount, res[0].Amount)
-		assert.Equal(t, expected[0].Type.String(), res[0].Type)
-		assert.Equal(t, expected[0].CreatedAt, res[0].CreatedAt)
 	})
+
+	t.Run("list transactions by status and metadata", func(t *testing.T) {
+		mockRepo.EXPECT().List(gomock.Any(), 11, 0, &entities.TransactionFilter{
+			Status:   entities.STATUS_PENDING,
+			Metadata: map[string]string{"order_id": "123", "channel": "web"},
+			Sort:     entities.SORT_CREATED_AT,
+		}).Return(nil, nil)
+
+		_, err := service.ListTransactions(ctx, 10, 0, false, map[string]string{
+			"status":            "pending",
+			"metadata.order_id": "123",
+			"metadata.channel":  "web",
+		})
+
+		assert.NoError(t, err)
+	})
+
+	invalid := []struct {
+		filter map[string]string
+		err    string
+	}{
+		{map[string]string{"type": "refund"}, "type must be one of [debit credit]"},
+		{map[string]string{"sort": "user_id"}, "sort must be one of [created_at -created_at amount -amount]"},
+		{map[string]string{"created_from": "yesterday"}, "created_from must be a RFC 3339 timestamp or a date (YYYY-MM-DD)"},
+		{map[string]string{"created_to": "20/11/2023"}, "created_to must be a RFC 3339 timestamp or a date (YYYY-MM-DD)"},
+		{map[string]string{"created_from": "2023-11-20", "created_to": "2023-11-01"}, "created_from must be before created_to"},
+		{map[string]string{"min_amount": "1.50"}, "min_amount must be an integer amount in cents"},
+		{map[string]string{"max_amount": "ten"}, "max_amount must be an integer amount in cents"},
+		{map[string]string{"min_amount": "100", "max_amount": "10"}, "min_amount must not be greater than max_amount"},
+		{map[string]string{"status": "settled"}, "status must be one of [pending posted voided]"},
+		{map[string]string{"metadata.order id": "123"}, "metadata.order id is not a valid metadata filter"},
+	}
+	for _, tc := range invalid {
+		t.Run("don't list with "+tc.err, func(t *testing.T) {
+			res, err := service.ListTransactions(ctx, 10, 0, false, tc.filter)
+
+			assert.Nil(t, res)
+			assert.Equal(t, tc.err, err.Error())
+		})
+	}
+}
+
+func Test_TransactionService_CreateTransaction_Consistency(t *testing.T) {
+	ctx := context.Background()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRepo := mock_repositories.NewMockTransactionRepository(ctrl)
+
+	service, err := services.NewTransactionService(mockRepo)
+	assert.NoError(t, err)
+
+	t.Run("asking the repository to wait for the commit", func(t *testing.T) {
+		req := &dto.CreateTransactionReq{Origin: "desktop-web", UserID: "user123", Amount: -150, Type: "debit", Consistency: "commit-sync"}
+
+		mockRepo.EXPECT().Insert(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, transaction *entities.Transaction) (*entities.Transaction, error) {
+			assert.Equal(t, repositories.CONSISTENCY_COMMIT_SYNC, repositories.Consistency(ctx))
+			return transaction, nil
+		})
+
+		res, errs := service.CreateTransaction(ctx, req)
+		assert.Empty(t, errs)
+		assert.Equal(t, int64(-150), res.Amount)
+	})
+
+	t.Run("returning the commit error", func(t *testing.T) {
+		req := &dto.CreateTransactionReq{Origin: "desktop-web", UserID: "user123", Amount: -150, Type: "debit", Consistency: "commit-sync"}
+
+		mockRepo.EXPECT().Insert(gomock.Any(), gomock.Any()).Return(nil, errors.New("connection refused"))
+
+		res, errs := service.CreateTransaction(ctx, req)
+		assert.Nil(t, res)
+		assert.Equal(t, []error{errors.New("connection refused")}, errs)
+	})
+
+	t.Run("rejecting an unknown consistency", func(t *testing.T) {
+		req := &dto.CreateTransactionReq{Origin: "desktop-web", UserID: "user123", Amount: -150, Type: "debit", Consistency: "eventual"}
+
+		res, errs := service.CreateTransaction(ctx, req)
+		assert.Nil(t, res)
+		if assert.Len(t, errs, 1) {
+			assert.EqualError(t, errs[0], "consistency must be one of [async commit-sync]")
+		}
+	})
+}
+
+func Test_TransactionService_CreateTransactions(t *testing.T) {
+	ctx := context.Background()
+	valid := &dto.CreateTransactionReq{Origin: "desktop-web", UserID: "user123", Amount: 150, Type: "credit"}
+	invalid := &dto.CreateTransactionReq{Origin: "desktop-web", UserID: "user123", Amount: 150, Type: "debit"}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRepo := mock_repositories.NewMockTransactionRepository(ctrl)
+
+	service, err := services.NewTransactionService(mockRepo)
+	assert.Nil(t, err)
+
+	t.Run("create the valid transactions of the batch", func(t *testing.T) {
+		mockRepo.EXPECT().InsertMany(gomock.Any(), gomock.Len(2)).Return(nil)
+
+		res, errs := service.CreateTransactions(ctx, []*dto.CreateTransactionReq{valid, invalid, valid}, false)
+
+		assert.Nil(t, errs)
+		assert.Equal(t, 2, res.Created)
+		assert.Equal(t, 1, res.Failed)
+		assert.Equal(t, services.BATCH_ITEM_CREATED, res.Items[0].Status)
+		assert.NotNil(t, res.Items[0].Transaction)
+		assert.Equal(t, services.BATCH_ITEM_FAILED, res.Items[1].Status)
+		assert.Equal(t, []string{"Amount must be negative for debit transactions"}, res.Items[1].Errors)
+		assert.Equal(t, 1, res.Items[1].Index)
+		assert.Equal(t, services.BATCH_ITEM_CREATED, res.Items[2].Status)
+	})
+
+	t.Run("fail the transactions of the chunk not inserted", func(t *testing.T) {
+		mockRepo.EXPECT().InsertMany(gomock.Any(), gomock.Len(1)).Return(errors.New("database is down"))
+
+		res, errs := service.CreateTransactions(ctx, []*dto.CreateTransactionReq{valid}, false)
+
+		assert.Nil(t, errs)
+		assert.Equal(t, 0, res.Created)
+		// the database errors are not sent to the clients
+		assert.Equal(t, []string{"an unexpected error occurred"}, res.Items[0].Errors)
+	})
+
+	t.Run("create all the transactions of an atomic batch", func(t *testing.T) {
+		mockRepo.EXPECT().InsertMany(gomock.Any(), gomock.Len(2)).Return(nil)
+
+		res, errs := service.CreateTransactions(ctx, []*dto.CreateTransactionReq{valid, valid}, true)
+
+		assert.Nil(t, errs)
+		assert.True(t, res.Atomic)
+		assert.Equal(t, 2, res.Created)
+		assert.Equal(t, 0, res.Failed)
+	})
+
+	t.Run("create none of the transactions of an atomic batch with an invalid one", func(t *testing.T) {
+		res, errs := service.CreateTransactions(ctx, []*dto.CreateTransactionReq{valid, invalid}, true)
+
+		assert.Nil(t, errs)
+		assert.Equal(t, 0, res.Created)
+		assert.Equal(t, 2, res.Failed)
+		assert.Equal(t, []string{services.ErrBatchAborted.Error()}, res.Items[0].Errors)
+		assert.Equal(t, []string{"Amount must be negative for debit transactions"}, res.Items[1].Errors)
+	})
+
+	t.Run("don't create an empty or too large batch", func(t *testing.T) {
+		_, errs := service.CreateTransactions(ctx, nil, false)
+		assert.NotNil(t, errs)
+
+		service.MaxBatchSize = 1
+		_, errs = service.CreateTransactions(ctx, []*dto.CreateTransactionReq{valid, valid}, false)
+		assert.Equal(t, "the batch must have at most 1 transactions", errs[0].Error())
+	})
+}
+
+func Test_TransactionService_ExportTransactions(t *testing.T) {
+	ctx := context.Background()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRepo := mock_repositories.NewMockTransactionRepository(ctrl)
+
+	service, err := services.NewTransactionService(mockRepo)
+	assert.Nil(t, err)
+
+	t.Run("export the transactions of the filter", func(t *testing.T) {
+		transactions := []*entities.Transaction{
+			{ID: uuid.New(), Origin: "desktop-web", UserID: "user123", Amount: 100, Type: entities.CREDIT},
+			{ID: uuid.New(), Origin: "desktop-web", UserID: "user123", Amount: -50, Type: entities.DEBIT},
+		}
+		mockRepo.EXPECT().Stream(gomock.Any(), &entities.TransactionFilter{
+			UserID: "user123",
+			Sort:   entities.SORT_AMOUNT,
+		}, gomock.Any()).DoAndReturn(func(_ context.Context, _ *entities.TransactionFilter, fn func(*entities.Transaction) error) error {
+			for _, transaction := range transactions {
+				if err := fn(transaction); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+
+		var exported []*dto.TransactionRes
+		err := service.ExportTransactions(ctx, map[string]string{"user_id": "user123", "sort": "amount"}, func(res *dto.TransactionRes) error {
+			exported = append(exported, res)
+			return nil
+		})
+
+		assert.NoError(t, err)
+		assert.Len(t, exported, 2)
+		assert.Equal(t, transactions[1].ID.String(), exported[1].ID)
+		assert.Equal(t, int64(-50), exported[1].Amount)
+	})
+
+	t.Run("don't export with an invalid filter", func(t *testing.T) {
+		err := service.ExportTransactions(ctx, map[string]string{"type": "refund"}, func(*dto.TransactionRes) error {
+			return nil
+		})
+
+		assert.EqualError(t, err, "type must be one of [debit credit]")
+	})
+}
+
+func Test_TransactionService_CreateTransaction_Currency(t *testing.T) {
+	ctx := context.Background()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRepo := mock_repositories.NewMockTransactionRepository(ctrl)
+
+	service, err := services.NewTransactionService(mockRepo)
+	assert.NoError(t, err)
+
+	t.Run("create the transaction in the given currency", func(t *testing.T) {
+		exponent := 0
+		req := &dto.CreateTransactionReq{Origin: "desktop-web", UserID: "user123", Amount: 1500, Type: "credit", Currency: "JPY", Exponent: &exponent}
+
+		mockRepo.EXPECT().Insert(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, transaction *entities.Transaction) (*entities.Transaction, error) {
+			assert.Equal(t, entities.Currency("JPY"), transaction.Currency)
+			return transaction, nil
+		})
+
+		res, errs := service.CreateTransaction(ctx, req)
+		assert.Empty(t, errs)
+		assert.Equal(t, "JPY", res.Currency)
+		assert.Equal(t, 0, res.Exponent)
+	})
+
+	t.Run("create the transaction in the default currency", func(t *testing.T) {
+		os.Setenv("DEFAULT_CURRENCY", "USD")
+		defer os.Unsetenv("DEFAULT_CURRENCY")
+		service, err := services.NewTransactionService(mockRepo)
+		assert.NoError(t, err)
+
+		mockRepo.EXPECT().Insert(gomock.Any(), gomock.Any()).Return(nil, nil)
+
+		res, errs := service.CreateTransaction(ctx, &dto.CreateTransactionReq{Origin: "desktop-web", UserID: "user123", Amount: 100, Type: "credit"})
+		assert.Empty(t, errs)
+		assert.Equal(t, "USD", res.Currency)
+	})
+
+	t.Run("don't create the transaction with the wrong exponent", func(t *testing.T) {
+		exponent := 2
+		req := &dto.CreateTransactionReq{Origin: "desktop-web", UserID: "user123", Amount: 1500, Type: "credit", Currency: "KWD", Exponent: &exponent}
+
+		res, errs := service.CreateTransaction(ctx, req)
+		assert.Nil(t, res)
+		assert.Equal(t, "Exponent of KWD amounts must be 3", errs[0].Error())
+	})
+
+	t.Run("don't create the transaction with an unknown currency", func(t *testing.T) {
+		req := &dto.CreateTransactionReq{Origin: "desktop-web", UserID: "user123", Amount: 1500, Type: "credit", Currency: "XYZ"}
+
+		res, errs := service.CreateTransaction(ctx, req)
+		assert.Nil(t, res)
+		assert.Equal(t, "Currency must be an ISO 4217 code", errs[0].Error())
+	})
+
+	t.Run("don't create the service with an unknown default currency", func(t *testing.T) {
+		os.Setenv("DEFAULT_CURRENCY", "XYZ")
+		defer os.Unsetenv("DEFAULT_CURRENCY")
+
+		service, err := services.NewTransactionService(mockRepo)
+		assert.Nil(t, service)
+		assert.Error(t, err)
+	})
+
+	t.Run("list the transactions of a currency", func(t *testing.T) {
+		mockRepo.EXPECT().List(gomock.Any(), 11, 0, &entities.TransactionFilter{Currency: "EUR", Sort: entities.SORT_CREATED_AT}).Return(nil, nil)
+
+		_, err := service.ListTransactions(ctx, 10, 0, false, map[string]string{"currency": "EUR"})
+		assert.NoError(t, err)
+
+		_, err = service.ListTransactions(ctx, 10, 0, false, map[string]string{"currency": "euro"})
+		assert.EqualError(t, err, "currency must be an ISO 4217 code")
+	})
+}
+
+func Test_TransactionService_CreateTransaction_Overdraft(t *testing.T) {
+	ctx := context.Background()
+	debit := &dto.CreateTransactionReq{Origin: "desktop-web", UserID: "user123", Amount: -150, Type: "debit"}
+	credit := &dto.CreateTransactionReq{Origin: "desktop-web", UserID: "user123", Amount: 150, Type: "credit"}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRepo := mock_repositories.NewMockTransactionRepository(ctrl)
+
+	policy := entities.NewOverdraftPolicy(nil, map[string]int64{"user123": 0}, nil)
+	service, err := services.NewTransactionService(mockRepo)
+	assert.NoError(t, err)
+	service.WithOverdraftPolicy(policy)
+
+	t.Run("check the debit against the balance", func(t *testing.T) {
+		mockRepo.EXPECT().InsertWithBalanceCheck(gomock.Any(), gomock.Len(1), policy).Return(nil)
+
+		res, errs := service.CreateTransaction(ctx, debit)
+		assert.Empty(t, errs)
+		assert.Equal(t, int64(-150), res.Amount)
+	})
+
+	t.Run("don't create the debit without funds", func(t *testing.T) {
+		mockRepo.EXPECT().InsertWithBalanceCheck(gomock.Any(), gomock.Len(1), policy).Return(entities.ErrInsufficientFunds)
+
+		res, errs := service.CreateTransaction(ctx, debit)
+		assert.Nil(t, res)
+		assert.ErrorIs(t, errs[0], entities.ErrInsufficientFunds)
+	})
+
+	t.Run("create the credit without checking the balance", func(t *testing.T) {
+		mockRepo.EXPECT().Insert(gomock.Any(), gomock.Any()).Return(nil, nil)
+
+		_, errs := service.CreateTransaction(ctx, credit)
+		assert.Empty(t, errs)
+	})
+
+	t.Run("check the debits of an atomic batch together", func(t *testing.T) {
+		mockRepo.EXPECT().InsertWithBalanceCheck(gomock.Any(), gomock.Len(2), policy).Return(entities.ErrInsufficientFunds)
+
+		res, errs := service.CreateTransactions(ctx, []*dto.CreateTransactionReq{credit, debit}, true)
+		assert.Nil(t, errs)
+		assert.Equal(t, 0, res.Created)
+		assert.Equal(t, 2, res.Failed)
+	})
+
+	t.Run("check the debits of a batch one by one after the credits", func(t *testing.T) {
+		gomock.InOrder(
+			mockRepo.EXPECT().InsertMany(gomock.Any(), gomock.Len(1)).Return(nil),
+			mockRepo.EXPECT().InsertWithBalanceCheck(gomock.Any(), gomock.Len(1), policy).Return(nil),
+			mockRepo.EXPECT().InsertWithBalanceCheck(gomock.Any(), gomock.Len(1), policy).Return(entities.ErrInsufficientFunds),
+		)
+
+		res, errs := service.CreateTransactions(ctx, []*dto.CreateTransactionReq{debit, credit, debit}, false)
+		assert.Nil(t, errs)
+		assert.Equal(t, 2, res.Created)
+		assert.Equal(t, services.BATCH_ITEM_CREATED, res.Items[0].Status)
+		assert.Equal(t, services.BATCH_ITEM_CREATED, res.Items[1].Status)
+		assert.Equal(t, services.BATCH_ITEM_FAILED, res.Items[2].Status)
+		assert.Equal(t, []string{entities.ErrInsufficientFunds.Error()}, res.Items[2].Errors)
+	})
+}
+
+func Test_TransactionService_Holds(t *testing.T) {
+	ctx := context.Background()
+	hold := &dto.CreateTransactionReq{Origin: "desktop-web", UserID: "user123", Amount: -150, Type: "debit", Hold: true}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRepo := mock_repositories.NewMockTransactionRepository(ctrl)
+
+	service, err := services.NewTransactionService(mockRepo)
+	assert.NoError(t, err)
+
+	// simulates the repository calling update with the locked transaction
+	updateStatus := func(transaction *entities.Transaction) func(context.Context, string, func(*entities.Transaction) error) (*entities.Transaction, error) {
+		return func(ctx context.Context, id string, update func(*entities.Transaction) error) (*entities.Transaction, error) {
+			if err := update(transaction); err != nil {
+				return nil, err
+			}
+			return transaction, nil
+		}
+	}
+	newHold := func(expiresAt time.Time) *entities.Transaction {
+		transaction, errs := entities.NewTransaction("desktop-web", "user123", -150, entities.DEBIT, entities.WithHold(expiresAt))
+		assert.Empty(t, errs)
+		return transaction
+	}
+
+	t.Run("create a hold that expires after the default ttl", func(t *testing.T) {
+		mockRepo.EXPECT().InsertMany(gomock.Any(), gomock.Len(1)).Return(nil)
+
+		res, errs := service.CreateTransaction(ctx, hold)
+
+		assert.Empty(t, errs)
+		assert.Equal(t, "pending", res.Status)
+		assert.WithinDuration(t, time.Now().Add(service.HoldTTL), *res.ExpiresAt, time.Minute)
+	})
+
+	t.Run("create a hold with its expiry", func(t *testing.T) {
+		expiresAt := time.Now().Add(time.Hour).UTC()
+		mockRepo.EXPECT().InsertMany(gomock.Any(), gomock.Len(1)).Return(nil)
+
+		res, errs := service.CreateTransaction(ctx, &dto.CreateTransactionReq{Origin: "desktop-web", UserID: "user123", Amount: -150, Type: "debit", Hold: true, ExpiresAt: &expiresAt})
+
+		assert.Empty(t, errs)
+		assert.Equal(t, expiresAt, *res.ExpiresAt)
+	})
+
+	t.Run("don't create a hold of a credit", func(t *testing.T) {
+		res, errs := service.CreateTransaction(ctx, &dto.CreateTransactionReq{Origin: "desktop-web", UserID: "user123", Amount: 150, Type: "credit", Hold: true})
+
+		assert.Nil(t, res)
+		assert.NotEmpty(t, errs)
+	})
+
+	t.Run("don't create a transaction expiring without a hold", func(t *testing.T) {
+		expiresAt := time.Now().Add(time.Hour)
+		res, errs := service.CreateTransaction(ctx, &dto.CreateTransactionReq{Origin: "desktop-web", UserID: "user123", Amount: -150, Type: "debit", ExpiresAt: &expiresAt})
+
+		assert.Nil(t, res)
+		assert.EqualError(t, errs[0], "expires_at is only allowed on holds")
+	})
+
+	t.Run("capture a hold", func(t *testing.T) {
+		transaction := newHold(time.Now().Add(time.Hour))
+		mockRepo.EXPECT().UpdateStatus(gomock.Any(), transaction.ID.String(), gomock.Any()).DoAndReturn(updateStatus(transaction))
+
+		res, err := service.CaptureTransaction(ctx, transaction.ID.String())
+
+		assert.NoError(t, err)
+		assert.Equal(t, "posted", res.Status)
+	})
+
+	t.Run("don't capture an expired hold", func(t *testing.T) {
+		transaction := newHold(time.Now().Add(time.Hour))
+		expired := time.Now().Add(-time.Minute)
+		transaction.ExpiresAt = &expired
+		mockRepo.EXPECT().UpdateStatus(gomock.Any(), transaction.ID.String(), gomock.Any()).DoAndReturn(updateStatus(transaction))
+
+		res, err := service.CaptureTransaction(ctx, transaction.ID.String())
+
+		assert.Nil(t, res)
+		assert.ErrorIs(t, err, entities.ErrHoldExpired)
+	})
+
+	t.Run("void a hold", func(t *testing.T) {
+		transaction := newHold(time.Now().Add(time.Hour))
+		mockRepo.EXPECT().UpdateStatus(gomock.Any(), transaction.ID.String(), gomock.Any()).DoAndReturn(updateStatus(transaction))
+
+		res, err := service.VoidTransaction(ctx, transaction.ID.String())
+
+		assert.NoError(t, err)
+		assert.Equal(t, "voided", res.Status)
+	})
+
+	t.Run("don't void a posted transaction", func(t *testing.T) {
+		transaction, errs := entities.NewTransaction("desktop-web", "user123", -150, entities.DEBIT)
+		assert.Empty(t, errs)
+		mockRepo.EXPECT().UpdateStatus(gomock.Any(), transaction.ID.String(), gomock.Any()).DoAndReturn(updateStatus(transaction))
+
+		res, err := service.VoidTransaction(ctx, transaction.ID.String())
+
+		assert.Nil(t, res)
+		assert.ErrorIs(t, err, entities.ErrNotPending)
+	})
+
+	t.Run("don't capture nor void a hold by an id that is not a UUID", func(t *testing.T) {
+		res, err := service.CaptureTransaction(ctx, "invalid")
+		assert.Nil(t, res)
+		assert.ErrorIs(t, err, services.ErrInvalidTransactionID)
+
+		res, err = service.VoidTransaction(ctx, "invalid")
+		assert.Nil(t, res)
+		assert.ErrorIs(t, err, services.ErrInvalidTransactionID)
+	})
+
+	t.Run("expire the holds in batches", func(t *testing.T) {
+		now := time.Now()
+		gomock.InOrder(
+			mockRepo.EXPECT().ExpireHolds(gomock.Any(), now, gomock.Any()).Return(int64(500), nil),
+			mockRepo.EXPECT().ExpireHolds(gomock.Any(), now, gomock.Any()).Return(int64(20), nil),
+		)
+
+		expired, err := service.ExpireHolds(ctx, now)
+
+		assert.NoError(t, err)
+		assert.Equal(t, int64(520), expired)
+	})
+}
+
+func Test_TransactionService_CreateTransaction_ExternalReference(t *testing.T) {
+	ctx := context.Background()
+	referenced := &dto.CreateTransactionReq{
+		Origin:            "desktop-web",
+		UserID:            "user123",
+		Amount:            150,
+		Type:              "credit",
+		ExternalReference: "order-123",
+		Metadata:          dto.Metadata{"description": "Monthly plan"},
+	}
+	plain := &dto.CreateTransactionReq{Origin: "desktop-web", UserID: "user123", Amount: 150, Type: "credit"}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRepo := mock_repositories.NewMockTransactionRepository(ctrl)
+
+	service, err := services.NewTransactionService(mockRepo)
+	assert.NoError(t, err)
+
+	t.Run("create a transaction with an external reference skipping the bulk buffer", func(t *testing.T) {
+		mockRepo.EXPECT().InsertMany(gomock.Any(), gomock.Len(1)).Return(nil)
+
+		res, errs := service.CreateTransaction(ctx, referenced)
+
+		assert.Empty(t, errs)
+		assert.Equal(t, "order-123", res.ExternalReference)
+		assert.Equal(t, dto.Metadata{"description": "Monthly plan"}, res.Metadata)
+	})
+
+	t.Run("don't create a transaction with a duplicate external reference", func(t *testing.T) {
+		mockRepo.EXPECT().InsertMany(gomock.Any(), gomock.Len(1)).Return(entities.ErrDuplicateExternalReference)
+
+		res, errs := service.CreateTransaction(ctx, referenced)
+
+		assert.Nil(t, res)
+		assert.ErrorIs(t, errs[0], entities.ErrDuplicateExternalReference)
+	})
+
+	t.Run("insert the transactions of a batch with an external reference one by one", func(t *testing.T) {
+		gomock.InOrder(
+			mockRepo.EXPECT().InsertMany(gomock.Any(), gomock.Len(1)).Return(nil),
+			mockRepo.EXPECT().InsertMany(gomock.Any(), gomock.Len(1)).Return(nil),
+			mockRepo.EXPECT().InsertMany(gomock.Any(), gomock.Len(1)).Return(entities.ErrDuplicateExternalReference),
+		)
+
+		res, errs := service.CreateTransactions(ctx, []*dto.CreateTransactionReq{referenced, plain, referenced}, false)
+
+		assert.Nil(t, errs)
+		assert.Equal(t, 2, res.Created)
+		assert.Equal(t, services.BATCH_ITEM_CREATED, res.Items[0].Status)
+		assert.Equal(t, services.BATCH_ITEM_CREATED, res.Items[1].Status)
+		assert.Equal(t, services.BATCH_ITEM_FAILED, res.Items[2].Status)
+	})
+}
+
+func Test_TransactionService_SummarizeTransactions(t *testing.T) {
+	ctx := context.Background()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRepo := mock_repositories.NewMockTransactionRepository(ctrl)
+
+	service, err := services.NewTransactionService(mockRepo)
+	assert.NoError(t, err)
+
+	t.Run("summarize the transactions by group and interval", func(t *testing.T) {
+		period := time.Date(2023, 11, 1, 0, 0, 0, 0, time.UTC)
+		mockRepo.EXPECT().Summarize(gomock.Any(),
+			&entities.TransactionFilter{UserID: "user123", Sort: entities.SORT_CREATED_AT},
+			[]entities.SummaryGroup{entities.GROUP_ORIGIN, entities.GROUP_TYPE},
+			entities.INTERVAL_MONTH,
+		).Return([]*entities.TransactionSummary{
+			{Period: &period, Origin: "desktop-web", Type: entities.CREDIT, Currency: "BRL", Count: 2, Total: 300, Credits: 300},
+		}, nil)
+
+		res, err := service.SummarizeTransactions(ctx, map[string]string{
+			"user_id":  "user123",
+			"group_by": "origin, type,origin",
+			"interval": "month",
+		})
+
+		assert.NoError(t, err)
+		assert.Len(t, res, 1)
+		assert.Equal(t, &period, res[0].Period)
+		assert.Equal(t, "desktop-web", res[0].Origin)
+		assert.Equal(t, "credit", res[0].Type)
+		assert.Equal(t, "BRL", res[0].Currency)
+		assert.Equal(t, 2, res[0].Exponent)
+		assert.Equal(t, int64(300), res[0].Total)
+	})
+
+	invalid := []struct {
+		filter map[string]string
+		err    string
+	}{
+		{map[string]string{"group_by": "amount"}, "group_by must be a list of [origin type user_id]"},
+		{map[string]string{"interval": "year"}, "interval must be one of [day week month]"},
+		{map[string]string{"type": "refund"}, "type must be one of [debit credit]"},
+	}
+	for _, tc := range invalid {
+		t.Run("don't summarize with "+tc.err, func(t *testing.T) {
+			res, err := service.SummarizeTransactions(ctx, tc.filter)
+
+			assert.Nil(t, res)
+			assert.EqualError(t, err, tc.err)
+		})
+	}
 }
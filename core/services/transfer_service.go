@@ -0,0 +1,107 @@
+package services
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"time"
+
+	"user-transactions/application/dto"
+	"user-transactions/core/entities"
+	"user-transactions/core/repositories"
+
+	"github.com/google/uuid"
+)
+
+var ErrInvalidTransferID = entities.NewError(entities.KIND_VALIDATION, "invalid_id", "the id of a transfer must be a UUID")
+
+// TransferService moves money between users, both legs of a transfer are committed together or not at all.
+type TransferService struct {
+	Timeout            int
+	DefaultCurrency    entities.Currency
+	TransferRepository repositories.TransferRepository
+	OverdraftPolicy    *entities.OverdraftPolicy // the debits are not checked against the balance when nil
+}
+
+func NewTransferService(tr repositories.TransferRepository) (*TransferService, error) {
+	timeout, err := strconv.Atoi(os.Getenv("TIMEOUT_SERVICES"))
+	if err != nil {
+		timeout = 5
+	}
+
+	defaultCurrency, err := loadDefaultCurrency()
+	if err != nil {
+		return nil, err
+	}
+
+	return &TransferService{
+		Timeout:            timeout,
+		DefaultCurrency:    defaultCurrency,
+		TransferRepository: tr,
+	}, nil
+}
+
+func (ts *TransferService) WithOverdraftPolicy(policy *entities.OverdraftPolicy) *TransferService {
+	ts.OverdraftPolicy = policy
+	return ts
+}
+
+func (ts *TransferService) CreateTransfer(c context.Context, req *dto.CreateTransferReq) (*dto.TransferRes, []error) {
+	ctx, cancel := context.WithTimeout(c, time.Duration(ts.Timeout)*time.Second)
+	defer cancel()
+
+	currency, err := requestCurrency(req.Currency, req.Exponent, ts.DefaultCurrency)
+	if err != nil {
+		return nil, []error{err}
+	}
+
+	transfer, errs := entities.NewTransfer(req.Origin, req.FromUserID, req.ToUserID, req.Amount, entities.WithCurrency(currency))
+	if errs != nil {
+		return nil, errs
+	}
+
+	if _, ok := ts.OverdraftPolicy.Limit(transfer.Debit); ok {
+		err = ts.TransferRepository.InsertWithBalanceCheck(ctx, transfer, ts.OverdraftPolicy)
+	} else {
+		err = ts.TransferRepository.Insert(ctx, transfer)
+	}
+	if err != nil {
+		return nil, []error{err}
+	}
+
+	return newTransferRes(transfer), nil
+}
+
+func (ts *TransferService) GetTransfer(c context.Context, id string) (*dto.TransferRes, error) {
+	parsed, err := uuid.Parse(id)
+	if err != nil {
+		return nil, ErrInvalidTransferID
+	}
+	// the other forms of UUID, e.g. with braces, are stored in the canonical one
+	id = parsed.String()
+
+	ctx, cancel := context.WithTimeout(c, time.Duration(ts.Timeout)*time.Second)
+	defer cancel()
+
+	transfer, err := ts.TransferRepository.Find(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return newTransferRes(transfer), nil
+}
+
+func newTransferRes(transfer *entities.Transfer) *dto.TransferRes {
+	return &dto.TransferRes{
+		ID:         transfer.ID.String(),
+		Origin:     transfer.Debit.Origin,
+		FromUserID: transfer.Debit.UserID,
+		ToUserID:   transfer.Credit.UserID,
+		Amount:     transfer.Amount(),
+		Currency:   transfer.Debit.Currency.String(),
+		Exponent:   transfer.Debit.Currency.Exponent(),
+		Debit:      newTransactionRes(transfer.Debit),
+		Credit:     newTransactionRes(transfer.Credit),
+		CreatedAt:  transfer.CreatedAt(),
+	}
+}
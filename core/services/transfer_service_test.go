@@ -0,0 +1,141 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"user-transactions/application/dto"
+	"user-transactions/core/entities"
+	mock_repositories "user-transactions/core/repositories/mock"
+	"user-transactions/core/services"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+func Test_TransferService_CreateTransfer(t *testing.T) {
+	ctx := context.Background()
+	req := &dto.CreateTransferReq{
+		Origin:     "desktop-web",
+		FromUserID: "user123",
+		ToUserID:   "user456",
+		Amount:     500,
+	}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRepo := mock_repositories.NewMockTransferRepository(ctrl)
+
+	service, err := services.NewTransferService(mockRepo)
+	assert.Nil(t, err)
+
+	t.Run("create the transfer", func(t *testing.T) {
+		mockRepo.EXPECT().Insert(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, transfer *entities.Transfer) error {
+			assert.Equal(t, int64(-500), transfer.Debit.Amount)
+			assert.Equal(t, int64(500), transfer.Credit.Amount)
+			return nil
+		})
+
+		res, errs := service.CreateTransfer(ctx, req)
+
+		assert.Nil(t, errs)
+		assert.NotEmpty(t, res.ID)
+		assert.Equal(t, "user123", res.FromUserID)
+		assert.Equal(t, "user456", res.ToUserID)
+		assert.Equal(t, int64(500), res.Amount)
+		assert.Equal(t, "BRL", res.Currency)
+		assert.Equal(t, res.ID, res.Debit.TransferID)
+		assert.Equal(t, res.ID, res.Credit.TransferID)
+	})
+
+	t.Run("don't create an invalid transfer", func(t *testing.T) {
+		res, errs := service.CreateTransfer(ctx, &dto.CreateTransferReq{Origin: "desktop-web", FromUserID: "user123", ToUserID: "user123", Amount: 500})
+
+		assert.Nil(t, res)
+		assert.Equal(t, []error{entities.ErrTransferToSameUser}, errs)
+	})
+
+	t.Run("return the error of the repository", func(t *testing.T) {
+		mockRepo.EXPECT().Insert(gomock.Any(), gomock.Any()).Return(errors.New("connection refused"))
+
+		res, errs := service.CreateTransfer(ctx, req)
+
+		assert.Nil(t, res)
+		assert.Equal(t, "connection refused", errs[0].Error())
+	})
+}
+
+func Test_TransferService_CreateTransfer_Overdraft(t *testing.T) {
+	ctx := context.Background()
+	req := &dto.CreateTransferReq{Origin: "desktop-web", FromUserID: "user123", ToUserID: "user456", Amount: 500}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRepo := mock_repositories.NewMockTransferRepository(ctrl)
+
+	policy := entities.NewOverdraftPolicy(nil, map[string]int64{"user123": 0}, nil)
+	service, err := services.NewTransferService(mockRepo)
+	assert.Nil(t, err)
+	service.WithOverdraftPolicy(policy)
+
+	t.Run("don't create the transfer without funds", func(t *testing.T) {
+		mockRepo.EXPECT().InsertWithBalanceCheck(gomock.Any(), gomock.Any(), policy).Return(entities.ErrInsufficientFunds)
+
+		res, errs := service.CreateTransfer(ctx, req)
+
+		assert.Nil(t, res)
+		assert.ErrorIs(t, errs[0], entities.ErrInsufficientFunds)
+	})
+
+	t.Run("create the transfer of a sender without limit", func(t *testing.T) {
+		mockRepo.EXPECT().Insert(gomock.Any(), gomock.Any()).Return(nil)
+
+		res, errs := service.CreateTransfer(ctx, &dto.CreateTransferReq{Origin: "desktop-web", FromUserID: "user456", ToUserID: "user123", Amount: 500})
+
+		assert.Nil(t, errs)
+		assert.Equal(t, "user456", res.FromUserID)
+	})
+}
+
+func Test_TransferService_GetTransfer(t *testing.T) {
+	ctx := context.Background()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRepo := mock_repositories.NewMockTransferRepository(ctrl)
+
+	service, err := services.NewTransferService(mockRepo)
+	assert.Nil(t, err)
+
+	t.Run("get the transfer", func(t *testing.T) {
+		transfer, errs := entities.NewTransfer("desktop-web", "user123", "user456", 500)
+		assert.Empty(t, errs)
+		mockRepo.EXPECT().Find(gomock.Any(), transfer.ID.String()).Return(transfer, nil)
+
+		res, err := service.GetTransfer(ctx, transfer.ID.String())
+
+		assert.NoError(t, err)
+		assert.Equal(t, transfer.ID.String(), res.ID)
+		assert.Equal(t, transfer.Debit.ID.String(), res.Debit.ID)
+		assert.Equal(t, transfer.Credit.ID.String(), res.Credit.ID)
+	})
+
+	t.Run("return the error of the repository", func(t *testing.T) {
+		id := uuid.NewString()
+		mockRepo.EXPECT().Find(gomock.Any(), id).Return(nil, errors.New("record not found"))
+
+		res, err := service.GetTransfer(ctx, id)
+
+		assert.Nil(t, res)
+		assert.Error(t, err)
+	})
+
+	t.Run("get a transfer by an id that is not a UUID", func(t *testing.T) {
+		res, err := service.GetTransfer(ctx, "non-existing-id")
+
+		assert.Nil(t, res)
+		assert.ErrorIs(t, err, services.ErrInvalidTransferID)
+	})
+}
@@ -1,10 +1,12 @@
 package database
 
 import (
+	"context"
 	"log"
 	"os"
 	"time"
 	"user-transactions/core/entities"
+	"user-transactions/infrastructure/repositories"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -17,6 +19,8 @@ type PostgresDB struct {
 	Dsn           string
 	Debug         bool
 	AutoMigrateDb bool
+	// DefaultCurrency is set on the transactions created before they had a currency
+	DefaultCurrency entities.Currency
 }
 
 func (psql *PostgresDB) Connect() (*gorm.DB, error) {
@@ -46,7 +50,21 @@ func (psql *PostgresDB) Connect() (*gorm.DB, error) {
 	}
 
 	if psql.AutoMigrateDb {
-		psql.Db.AutoMigrate(entities.Transaction{})
+		hasUserBalances := psql.Db.Migrator().HasTable(&entities.UserBalance{})
+		psql.Db.AutoMigrate(entities.Transaction{}, entities.IdempotencyKey{}, entities.DeadLetterBatch{}, entities.UserBalance{})
+		if err := MigrateDefaultCurrency(psql.Db, psql.DefaultCurrency); err != nil {
+			return nil, err
+		}
+		// the currencies are migrated first, the balances are summed by currency
+		if !hasUserBalances {
+			if err := MigrateUserBalances(psql.Db); err != nil {
+				return nil, err
+			}
+		}
+		// the metadata filters look for the key/values contained in the metadata
+		if err := psql.Db.Exec("CREATE INDEX IF NOT EXISTS idx_metadata ON transactions USING GIN (metadata)").Error; err != nil {
+			return nil, err
+		}
 	}
 
 	sqlDB, _ := psql.Db.DB()
@@ -62,3 +80,24 @@ func (psql *PostgresDB) Connect() (*gorm.DB, error) {
 
 	return psql.Db, nil
 }
+
+// MigrateDefaultCurrency sets the currency of the transactions without one, DEFAULT_CURRENCY when not given.
+func MigrateDefaultCurrency(db *gorm.DB, currency entities.Currency) error {
+	if currency == "" {
+		currency = entities.DEFAULT_CURRENCY
+	}
+
+	return db.Model(&entities.Transaction{}).
+		Where("currency IS NULL OR currency = ''").
+		Update("currency", currency).Error
+}
+
+// MigrateUserBalances sums the user balances of the transactions created before the balances were kept.
+func MigrateUserBalances(db *gorm.DB) error {
+	count, err := repositories.NewBalanceRepository(db).Rebuild(context.Background())
+	if err != nil {
+		return err
+	}
+	log.Printf("%d user balances summed from the transactions", count)
+	return nil
+}
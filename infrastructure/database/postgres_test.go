@@ -0,0 +1,87 @@
+//go:build integration
+// +build integration
+
+package database_test
+
+import (
+	"testing"
+	"user-transactions/core/entities"
+	"user-transactions/infrastructure/database"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func Test_MigrateDefaultCurrency(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	assert.NoError(t, db.AutoMigrate(&entities.Transaction{}))
+
+	// a transaction created before the currency existed and one with a currency
+	old, errs := entities.NewTransaction("desktop-web", "user123", 100, entities.CREDIT)
+	assert.Empty(t, errs)
+	assert.NoError(t, db.Create(old).Error)
+	assert.NoError(t, db.Model(old).Update("currency", nil).Error)
+
+	usd, errs := entities.NewTransaction("desktop-web", "user123", 100, entities.CREDIT, entities.WithCurrency("USD"))
+	assert.Empty(t, errs)
+	assert.NoError(t, db.Create(usd).Error)
+
+	assert.NoError(t, database.MigrateDefaultCurrency(db, "EUR"))
+
+	var migrated entities.Transaction
+	assert.NoError(t, db.First(&migrated, "id = ?", old.ID).Error)
+	assert.Equal(t, entities.Currency("EUR"), migrated.Currency)
+	var kept entities.Transaction
+	assert.NoError(t, db.First(&kept, "id = ?", usd.ID).Error)
+	assert.Equal(t, entities.Currency("USD"), kept.Currency)
+}
+
+func Test_MigrateUserBalances(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	assert.NoError(t, db.AutoMigrate(&entities.Transaction{}))
+
+	// transactions created before the balances were kept
+	for _, amount := range []int64{500, -200} {
+		opType := entities.CREDIT
+		if amount < 0 {
+			opType = entities.DEBIT
+		}
+		transaction, errs := entities.NewTransaction("desktop-web", "user123", amount, opType)
+		assert.Empty(t, errs)
+		assert.NoError(t, db.Create(transaction).Error)
+	}
+
+	assert.NoError(t, db.AutoMigrate(&entities.UserBalance{}))
+	assert.NoError(t, database.MigrateUserBalances(db))
+
+	var balance entities.UserBalance
+	assert.NoError(t, db.First(&balance, "user_id = ? AND currency = ?", "user123", entities.DEFAULT_CURRENCY).Error)
+	assert.Equal(t, int64(300), balance.Balance)
+}
+
+func Test_TransactionIndexes(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	assert.NoError(t, db.AutoMigrate(&entities.Transaction{}))
+
+	for _, index := range []string{"idx_created_at_id", "idx_user_id_created_at_id", "idx_origin_created_at_id"} {
+		assert.True(t, db.Migrator().HasIndex(&entities.Transaction{}, index), index)
+	}
+
+	// the pages sorted by creation are read from an index, without sorting the transactions
+	for _, query := range []string{
+		"SELECT * FROM transactions ORDER BY created_at, id LIMIT 10",
+		"SELECT * FROM transactions WHERE created_at < '2024-01-01' OR (created_at = '2024-01-01' AND id < 'a') ORDER BY created_at DESC, id DESC LIMIT 10",
+		"SELECT * FROM transactions WHERE user_id = 'user123' ORDER BY created_at DESC, id DESC LIMIT 10",
+		"SELECT * FROM transactions WHERE origin = 'desktop-web' AND created_at >= '2024-01-01' ORDER BY created_at, id LIMIT 10",
+	} {
+		var plan []struct{ Detail string }
+		assert.NoError(t, db.Raw("EXPLAIN QUERY PLAN "+query).Scan(&plan).Error)
+		for _, step := range plan {
+			assert.NotContains(t, step.Detail, "TEMP B-TREE", query)
+		}
+	}
+}
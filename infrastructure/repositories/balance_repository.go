@@ -0,0 +1,225 @@
+package repositories
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+	"user-transactions/core/entities"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// errConcurrentInsert rolls back an insert when another database transaction inserted some of the same transactions
+// in the meantime, the ones it inserted can't be told apart so they could be added twice to the balances.
+var errConcurrentInsert = errors.New("some of the transactions were inserted concurrently, retry the insert")
+
+// BalanceRepository rebuilds and checks the user balances, they are kept up to date by the repositories inserting the transactions.
+type BalanceRepository struct {
+	Db *gorm.DB
+}
+
+func NewBalanceRepository(db *gorm.DB) *BalanceRepository {
+	return &BalanceRepository{Db: db}
+}
+
+// Rebuild replaces the user balances by the sum of the transactions. On Postgres the balances are locked while rebuilt,
+// the inserts committed before are counted and the ones waiting for the lock are added after it.
+func (r *BalanceRepository) Rebuild(ctx context.Context) (int64, error) {
+	var count int64
+	err := r.Db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if tx.Dialector.Name() == "postgres" {
+			if err := tx.Exec("LOCK TABLE user_balances IN EXCLUSIVE MODE").Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Exec("DELETE FROM user_balances").Error; err != nil {
+			return err
+		}
+
+		result := tx.Exec(sumUserBalances, time.Now().UTC())
+		count = result.RowsAffected
+		return result.Error
+	})
+	return count, err
+}
+
+// sumUserBalances inserts the balance of every user and currency with transactions.
+const sumUserBalances = `INSERT INTO user_balances (user_id, currency, balance, held, updated_at)
+	SELECT user_id, currency, ` + sumLedger + `, ` + sumHeld + `, ? FROM transactions GROUP BY user_id, currency`
+
+// sumLedger sums the posted transactions and sumHeld the pending holds, the voided holds are in neither.
+const (
+	sumLedger = `COALESCE(SUM(CASE WHEN status IN ('pending', 'voided') THEN 0 ELSE amount END), 0)`
+	sumHeld   = `COALESCE(SUM(CASE WHEN status = 'pending' THEN -amount ELSE 0 END), 0)`
+)
+
+// Drift compares the user balances with the sum of the transactions in a single statement, so both are read at the same moment.
+func (r *BalanceRepository) Drift(ctx context.Context) ([]*entities.BalanceDrift, error) {
+	var drifts []*entities.BalanceDrift
+	err := r.Db.WithContext(ctx).Raw(`SELECT user_id, currency,
+		SUM(balance) AS balance, SUM(ledger) AS ledger, SUM(held) AS held, SUM(ledger_held) AS ledger_held
+	FROM (
+		SELECT user_id, currency, balance, 0 AS ledger, held, 0 AS ledger_held FROM user_balances
+		UNION ALL
+		SELECT user_id, currency, 0 AS balance, ` + sumLedger + ` AS ledger, 0 AS held, ` + sumHeld + ` AS ledger_held
+		FROM transactions GROUP BY user_id, currency
+	) AS accounts
+	GROUP BY user_id, currency HAVING SUM(balance) <> SUM(ledger) OR SUM(held) <> SUM(ledger_held)
+	ORDER BY user_id, currency`).
+		Scan(&drifts).Error
+	if err != nil {
+		return nil, err
+	}
+	return drifts, nil
+}
+
+// insertNew inserts the transactions not inserted yet and adds them to the user balances, in the database transaction tx.
+// The ones already inserted, e.g. replayed from the spool or retried by the client, are skipped and not added twice.
+func insertNew(tx *gorm.DB, transactions []*entities.Transaction) error {
+	inserted := make(map[uuid.UUID]bool)
+	for start := 0; start < len(transactions); start += insertManyBatchSize {
+		end := min(start+insertManyBatchSize, len(transactions))
+
+		var ids []uuid.UUID
+		if err := tx.Model(&entities.Transaction{}).Where("id IN ?", transactionIDs(transactions[start:end])).Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+		for _, id := range ids {
+			inserted[id] = true
+		}
+	}
+
+	fresh := make([]*entities.Transaction, 0, len(transactions))
+	for _, transaction := range transactions {
+		if !inserted[transaction.ID] {
+			fresh = append(fresh, transaction)
+			inserted[transaction.ID] = true
+		}
+	}
+	if len(fresh) == 0 {
+		return nil
+	}
+	if err := checkExternalReferences(tx, fresh); err != nil {
+		return err
+	}
+
+	result := tx.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(fresh, insertManyBatchSize)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected != int64(len(fresh)) {
+		// the conflict may be an external reference used by a transaction committed in the meantime
+		if err := checkExternalReferences(tx, fresh); err != nil {
+			return err
+		}
+		return errConcurrentInsert
+	}
+	return addToBalances(tx, fresh...)
+}
+
+// checkExternalReferences rejects the transactions whose external reference is used by another transaction
+// of the same origin, already inserted or in the same insert.
+func checkExternalReferences(tx *gorm.DB, transactions []*entities.Transaction) error {
+	type reference struct {
+		Origin            string
+		ExternalReference string
+	}
+
+	referenced := make([]*entities.Transaction, 0, len(transactions))
+	seen := make(map[reference]bool)
+	for _, transaction := range transactions {
+		if transaction.ExternalReference == nil {
+			continue
+		}
+		r := reference{Origin: transaction.Origin, ExternalReference: *transaction.ExternalReference}
+		if seen[r] {
+			return fmt.Errorf("%w: %s", entities.ErrDuplicateExternalReference, r.ExternalReference)
+		}
+		seen[r] = true
+		referenced = append(referenced, transaction)
+	}
+
+	for start := 0; start < len(referenced); start += insertManyBatchSize {
+		end := min(start+insertManyBatchSize, len(referenced))
+		references := make([]string, 0, end-start)
+		for _, transaction := range referenced[start:end] {
+			references = append(references, *transaction.ExternalReference)
+		}
+
+		var existing []reference
+		err := tx.Model(&entities.Transaction{}).
+			Select("origin, external_reference").
+			Where("external_reference IN ? AND id NOT IN ?", references, transactionIDs(referenced[start:end])).
+			Scan(&existing).Error
+		if err != nil {
+			return err
+		}
+		for _, r := range existing {
+			if seen[r] {
+				return fmt.Errorf("%w: %s", entities.ErrDuplicateExternalReference, r.ExternalReference)
+			}
+		}
+	}
+	return nil
+}
+
+// addToBalances adds the amounts of the transactions to the balances of their users, in the database transaction inserting them.
+func addToBalances(tx *gorm.DB, transactions ...*entities.Transaction) error {
+	deltas := make(map[account]entities.Balance)
+	for _, transaction := range transactions {
+		a := account{userId: transaction.UserID, currency: transaction.Currency}
+		deltas[a] = addBalance(deltas[a], transaction, 1)
+	}
+	return updateBalances(tx, deltas)
+}
+
+// changeBalance moves the amount of the transaction in the balance of its user from the status before to the current one.
+func changeBalance(tx *gorm.DB, transaction *entities.Transaction, before entities.TransactionStatus) error {
+	previous := *transaction
+	previous.Status = before
+
+	delta := addBalance(entities.Balance{}, transaction, 1)
+	delta = addBalance(delta, &previous, -1)
+	return updateBalances(tx, map[account]entities.Balance{
+		{userId: transaction.UserID, currency: transaction.Currency}: delta,
+	})
+}
+
+// addBalance adds the transaction to the balance sign times, -1 takes it out of the balance.
+func addBalance(balance entities.Balance, transaction *entities.Transaction, sign int64) entities.Balance {
+	if transaction.Posted() {
+		balance.Ledger += sign * transaction.Amount
+	}
+	balance.Held += sign * transaction.Held()
+	return balance
+}
+
+func updateBalances(tx *gorm.DB, deltas map[account]entities.Balance) error {
+	now := time.Now().UTC()
+	balances := make([]*entities.UserBalance, 0, len(deltas))
+	for a, delta := range deltas {
+		balances = append(balances, &entities.UserBalance{UserID: a.userId, Currency: a.currency, Balance: delta.Ledger, Held: delta.Held, UpdatedAt: now})
+	}
+	// every database transaction updates the balances in the same order, so two of them can't deadlock
+	slices.SortFunc(balances, func(a, b *entities.UserBalance) int {
+		if c := cmp.Compare(a.UserID, b.UserID); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Currency, b.Currency)
+	})
+
+	return tx.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "user_id"}, {Name: "currency"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"balance":    gorm.Expr("user_balances.balance + excluded.balance"),
+			"held":       gorm.Expr("user_balances.held + excluded.held"),
+			"updated_at": gorm.Expr("excluded.updated_at"),
+		}),
+	}).CreateInBatches(balances, insertManyBatchSize).Error
+}
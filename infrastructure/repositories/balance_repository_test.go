@@ -0,0 +1,103 @@
+//go:build integration
+// +build integration
+
+package repositories_test
+
+import (
+	"context"
+	"testing"
+	"time"
+	"user-transactions/core/entities"
+	"user-transactions/infrastructure/repositories"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_BalanceRepositoryImpl(t *testing.T) {
+	db := setupDB(t)
+
+	transactionRepo := repositories.NewTransactionRepository(db)
+	transferRepo := repositories.NewTransferRepository(db)
+	repo := repositories.NewBalanceRepository(db)
+	ctx := context.Background()
+
+	newTransaction := func(userId string, amount int64, operation entities.OperationType) *entities.Transaction {
+		transaction, errs := entities.NewTransaction("desktop-web", userId, amount, operation)
+		assert.Empty(t, errs)
+		return transaction
+	}
+	storedBalance := func(userId string) int64 {
+		var balance entities.UserBalance
+		assert.NoError(t, db.First(&balance, "user_id = ? AND currency = ?", userId, entities.DEFAULT_CURRENCY).Error)
+		return balance.Balance
+	}
+
+	t.Run("keeping the balances on every insert", func(t *testing.T) {
+		credit := newTransaction("user123", 1000, entities.CREDIT)
+		_, err := transactionRepo.Insert(ctx, credit)
+		assert.NoError(t, err)
+
+		// the transactions already inserted are not added twice
+		assert.NoError(t, transactionRepo.InsertMany(ctx, []*entities.Transaction{credit, newTransaction("user123", -200, entities.DEBIT)}))
+
+		transactionRepo.CommitWg.Add(1)
+		transactionRepo.CommitBulk(newTransaction("user123", 300, entities.CREDIT), newTransaction("user456", 50, entities.CREDIT))
+
+		_, errs := transactionRepo.Reverse(ctx, credit.ID.String(), nil, func(original *entities.Transaction, reversals []*entities.Transaction) (*entities.Transaction, []error) {
+			return original.Reverse(100, reversals)
+		})
+		assert.Empty(t, errs)
+
+		transfer, errs := entities.NewTransfer("desktop-web", "user123", "user456", 400)
+		assert.Empty(t, errs)
+		assert.NoError(t, transferRepo.Insert(ctx, transfer))
+
+		assert.Equal(t, int64(600), storedBalance("user123"))
+		assert.Equal(t, int64(450), storedBalance("user456"))
+
+		drifts, err := repo.Drift(ctx)
+		assert.NoError(t, err)
+		assert.Empty(t, drifts)
+	})
+
+	t.Run("reporting and rebuilding the balances that drifted", func(t *testing.T) {
+		// inserted without going through the repositories
+		assert.NoError(t, db.Create(newTransaction("user456", 25, entities.CREDIT)).Error)
+		assert.NoError(t, db.Create(newTransaction("user789", 10, entities.CREDIT)).Error)
+
+		drifts, err := repo.Drift(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, []*entities.BalanceDrift{
+			{UserID: "user456", Currency: entities.DEFAULT_CURRENCY, Balance: 450, Ledger: 475},
+			{UserID: "user789", Currency: entities.DEFAULT_CURRENCY, Balance: 0, Ledger: 10},
+		}, drifts)
+		assert.Equal(t, int64(-25), drifts[0].Difference())
+
+		count, err := repo.Rebuild(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(3), count)
+		assert.Equal(t, int64(475), storedBalance("user456"))
+
+		drifts, err = repo.Drift(ctx)
+		assert.NoError(t, err)
+		assert.Empty(t, drifts)
+	})
+	t.Run("reporting and rebuilding the amounts held", func(t *testing.T) {
+		hold, errs := entities.NewTransaction("desktop-web", "user789", -5, entities.DEBIT, entities.WithHold(time.Now().Add(time.Hour)))
+		assert.Empty(t, errs)
+		assert.NoError(t, db.Create(hold).Error)
+
+		drifts, err := repo.Drift(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, []*entities.BalanceDrift{
+			{UserID: "user789", Currency: entities.DEFAULT_CURRENCY, Balance: 10, Ledger: 10, Held: 0, LedgerHeld: 5},
+		}, drifts)
+
+		_, err = repo.Rebuild(ctx)
+		assert.NoError(t, err)
+
+		var balance entities.UserBalance
+		assert.NoError(t, db.First(&balance, "user_id = ?", "user789").Error)
+		assert.Equal(t, int64(5), balance.Held)
+	})
+}
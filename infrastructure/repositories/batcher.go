@@ -0,0 +1,141 @@
+package repositories
+
+import (
+	"context"
+	"log"
+	"time"
+	"user-transactions/core/entities"
+	coreRepositories "user-transactions/core/repositories"
+)
+
+// RunGroupTransactions groups the transactions sent to InsertChan in bulks, a bulk is committed when it reaches
+// BulkConfig.MaxSize, when its first transaction waited BulkConfig.MaxTime seconds or when InsertChan is closed.
+// It blocks without using the CPU while there is nothing to do, and returns after the last bulk is handed to a worker.
+func (r *TransactionRepository) RunGroupTransactions() {
+	defer close(r.batcherDone)
+
+	maxAge := time.Duration(r.BulkConfig.MaxTime * float64(time.Second))
+	maxWorkers := r.BulkConfig.MaxWorkers
+	if maxWorkers <= 0 {
+		maxWorkers = defaultBulkWorkers
+	}
+	// a worker slot is taken before committing, when all of them are busy the bulks wait in InsertChan
+	workers := make(chan struct{}, maxWorkers)
+
+	// the timer only runs while the bulk has transactions
+	timer := time.NewTimer(maxAge)
+	stopTimer(timer)
+
+	var bulk []*BulkItem
+	started := time.Now()
+	flush := func(reason string) {
+		if len(bulk) == 0 {
+			return
+		}
+		log.Printf("committing %d transactions by %s with elapsed time of %v", len(bulk), reason, time.Since(started))
+
+		r.CommitWg.Add(1)
+		workers <- struct{}{}
+		go func(bulk []*BulkItem) {
+			defer func() { <-workers }()
+			r.commitBulk(bulk)
+		}(bulk)
+
+		bulk = nil
+	}
+
+	for {
+		select {
+		case item, ok := <-r.InsertChan:
+			if !ok {
+				stopTimer(timer)
+				flush("shutdown")
+				return
+			}
+			// the transaction left the queue, it's bounded by the bulk size and the workers from now on
+			<-r.slots
+
+			if len(bulk) == 0 {
+				started = time.Now()
+				timer.Reset(maxAge)
+			}
+			bulk = append(bulk, item)
+
+			if len(bulk) >= r.BulkConfig.MaxSize {
+				stopTimer(timer)
+				flush("size")
+			}
+		case <-timer.C:
+			flush("age")
+		}
+	}
+}
+
+func (r *TransactionRepository) WithBulkWorkers(maxWorkers int) *TransactionRepository {
+	if r.BulkConfig != nil && maxWorkers > 0 {
+		r.BulkConfig.MaxWorkers = maxWorkers
+	}
+
+	return r
+}
+
+// WithBulkQueue bounds the transactions waiting for the bulk writer, when the queue is full
+// Insert waits up to the given time for room before failing with ErrQueueFull.
+// It must be called before RunGroupTransactions.
+func (r *TransactionRepository) WithBulkQueue(capacity int, wait time.Duration) *TransactionRepository {
+	if r.BulkConfig == nil {
+		return r
+	}
+
+	if capacity > 0 {
+		r.BulkConfig.QueueCapacity = capacity
+		r.InsertChan = make(chan *BulkItem, capacity)
+		r.slots = make(chan struct{}, capacity)
+	}
+	if wait >= 0 {
+		r.BulkConfig.QueueWait = wait
+	}
+
+	return r
+}
+
+// QueueStats reports the state of the bulk queue for monitoring.
+func (r *TransactionRepository) QueueStats() entities.QueueStats {
+	stats := entities.QueueStats{Pending: r.pending.len()}
+	if r.BulkConfig != nil {
+		stats.Depth = len(r.slots)
+		stats.Capacity = r.BulkConfig.QueueCapacity
+	}
+	return stats
+}
+
+// reserveSlot takes room in the queue for a transaction, waiting up to BulkConfig.QueueWait when it's full.
+func (r *TransactionRepository) reserveSlot(ctx context.Context) error {
+	select {
+	case r.slots <- struct{}{}:
+		return nil
+	default:
+	}
+
+	timer := time.NewTimer(r.BulkConfig.QueueWait)
+	defer timer.Stop()
+
+	select {
+	case r.slots <- struct{}{}:
+		return nil
+	case <-timer.C:
+		return coreRepositories.ErrQueueFull
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// stopTimer stops the timer and drains its channel, so a Reset doesn't see a stale expiration.
+func stopTimer(timer *time.Timer) {
+	if !timer.Stop() {
+		select {
+		case <-timer.C:
+		default:
+		}
+	}
+}
@@ -0,0 +1,139 @@
+//go:build integration && unix
+// +build integration,unix
+
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"syscall"
+	"testing"
+	"time"
+	"user-transactions/core/entities"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// legacyRunGroupTransactions is the busy loop replaced by RunGroupTransactions, kept to compare both of them.
+// It only differs from the original by returning when InsertChan is closed, so the benchmarks can stop it,
+// by releasing the queue slots and by committing the bulk items.
+func (r *TransactionRepository) legacyRunGroupTransactions() {
+	defer close(r.batcherDone)
+
+	var bulk []*BulkItem
+	timer := time.Now()
+	for {
+		select {
+		case item, ok := <-r.InsertChan:
+			if !ok {
+				if len(bulk) > 0 {
+					r.CommitWg.Add(1)
+					go r.commitBulk(bulk)
+				}
+				return
+			}
+			<-r.slots
+			bulk = append(bulk, item)
+		default:
+			if len(bulk) > 0 && (len(bulk) >= r.BulkConfig.MaxSize || time.Since(timer).Seconds() >= r.BulkConfig.MaxTime) {
+				r.CommitWg.Add(1)
+				go r.commitBulk(bulk)
+
+				bulk = nil
+				timer = time.Now()
+			}
+		}
+	}
+}
+
+var batchers = []struct {
+	name string
+	run  func(r *TransactionRepository)
+}{
+	{"legacy", (*TransactionRepository).legacyRunGroupTransactions},
+	{"event-driven", (*TransactionRepository).RunGroupTransactions},
+}
+
+func newBenchRepository(b *testing.B, maxBulkItems int, maxWaitingSeconds float64) *TransactionRepository {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		b.Fatal(err)
+	}
+	if err := db.AutoMigrate(&entities.Transaction{}, &entities.UserBalance{}); err != nil {
+		b.Fatal(err)
+	}
+	// every connection to file::memory: is a different database
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1)
+	b.Cleanup(func() { sqlDB.Close() })
+
+	return NewTransactionRepository(db).WithBulkConfig(maxBulkItems, maxWaitingSeconds)
+}
+
+// cpuTime returns the user and system CPU time used by the process so far.
+func cpuTime(b *testing.B) time.Duration {
+	var usage syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &usage); err != nil {
+		b.Fatal(err)
+	}
+	return time.Duration(usage.Utime.Nano() + usage.Stime.Nano())
+}
+
+// BenchmarkRunGroupTransactions_Idle measures the CPU used by the batcher per millisecond without transactions.
+func BenchmarkRunGroupTransactions_Idle(b *testing.B) {
+	for _, batcher := range batchers {
+		b.Run(batcher.name, func(b *testing.B) {
+			repo := newBenchRepository(b, 100, 1)
+			go batcher.run(repo)
+
+			b.ResetTimer()
+			before := cpuTime(b)
+			for i := 0; i < b.N; i++ {
+				time.Sleep(time.Millisecond)
+			}
+			used := cpuTime(b) - before
+			b.StopTimer()
+
+			b.ReportMetric(float64(used.Microseconds())/float64(b.N), "cpu-µs/idle-ms")
+			if err := repo.Shutdown(context.Background()); err != nil {
+				b.Fatal(err)
+			}
+		})
+	}
+}
+
+// BenchmarkRunGroupTransactions_Throughput measures the time to insert and commit the transactions.
+func BenchmarkRunGroupTransactions_Throughput(b *testing.B) {
+	for _, batcher := range batchers {
+		b.Run(batcher.name, func(b *testing.B) {
+			repo := newBenchRepository(b, 100, 0.01)
+			go batcher.run(repo)
+
+			transactions := make([]*entities.Transaction, b.N)
+			for i := range transactions {
+				transaction, errs := entities.NewTransaction("desktop-web", fmt.Sprintf("user%d", i%100), 200, entities.CREDIT)
+				if errs != nil {
+					b.Fatal(errs)
+				}
+				transactions[i] = transaction
+			}
+
+			b.ResetTimer()
+			before := cpuTime(b)
+			for _, transaction := range transactions {
+				if _, err := repo.Insert(context.Background(), transaction); err != nil {
+					b.Fatal(err)
+				}
+			}
+			if err := repo.Shutdown(context.Background()); err != nil {
+				b.Fatal(err)
+			}
+			used := cpuTime(b) - before
+			b.StopTimer()
+
+			b.ReportMetric(float64(used.Nanoseconds())/float64(b.N), "cpu-ns/op")
+		})
+	}
+}
@@ -0,0 +1,219 @@
+//go:build integration
+// +build integration
+
+package repositories_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+	"user-transactions/core/entities"
+	coreRepositories "user-transactions/core/repositories"
+	"user-transactions/infrastructure/repositories"
+	"user-transactions/infrastructure/spool"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func Test_TransactionRepositoryImpl_RunGroupTransactions(t *testing.T) {
+	committed := func(repo *repositories.TransactionRepository, transaction *entities.Transaction) func() bool {
+		return func() bool {
+			var count int64
+			repo.Db.Model(&entities.Transaction{}).Where("id = ?", transaction.ID.String()).Count(&count)
+			return count == 1
+		}
+	}
+
+	t.Run("committing a bulk when it is full", func(t *testing.T) {
+		repo := repositories.NewTransactionRepository(setupDB(t)).WithBulkConfig(2, 3600)
+		go repo.RunGroupTransactions()
+
+		var transactions []*entities.Transaction
+		for _, amount := range []int64{200, 300} {
+			transaction, errs := entities.NewTransaction("desktop-web", "user123", amount, entities.CREDIT)
+			assert.Empty(t, errs)
+			_, err := repo.Insert(context.Background(), transaction)
+			assert.NoError(t, err)
+			transactions = append(transactions, transaction)
+		}
+
+		assert.Eventually(t, committed(repo, transactions[1]), time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("committing a bulk when it is old enough", func(t *testing.T) {
+		repo := repositories.NewTransactionRepository(setupDB(t)).WithBulkConfig(100, 0.05)
+		go repo.RunGroupTransactions()
+
+		transaction, errs := entities.NewTransaction("desktop-web", "user123", 200, entities.CREDIT)
+		assert.Empty(t, errs)
+		_, err := repo.Insert(context.Background(), transaction)
+		assert.NoError(t, err)
+
+		assert.Eventually(t, committed(repo, transaction), time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("committing the last bulk on shutdown", func(t *testing.T) {
+		repo := repositories.NewTransactionRepository(setupDB(t)).WithBulkConfig(100, 3600)
+		go repo.RunGroupTransactions()
+
+		transaction, errs := entities.NewTransaction("desktop-web", "user123", 200, entities.CREDIT)
+		assert.Empty(t, errs)
+		_, err := repo.Insert(context.Background(), transaction)
+		assert.NoError(t, err)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		assert.NoError(t, repo.Shutdown(ctx))
+		assert.True(t, committed(repo, transaction)())
+	})
+}
+
+func Test_TransactionRepositoryImpl_Insert_QueueFull(t *testing.T) {
+	dir := t.TempDir()
+	s, _, err := spool.Open(spool.Config{Dir: dir})
+	assert.NoError(t, err)
+	defer s.Close()
+
+	// the bulk writer is not running, so the queue is never drained
+	repo := repositories.NewTransactionRepository(setupDB(t)).
+		WithBulkConfig(100, 3600).
+		WithBulkQueue(1, 10*time.Millisecond).
+		WithSpool(s)
+
+	accepted, errs := entities.NewTransaction("desktop-web", "user123", 200, entities.CREDIT)
+	assert.Empty(t, errs)
+	_, err = repo.Insert(context.Background(), accepted)
+	assert.NoError(t, err)
+	assert.Equal(t, entities.QueueStats{Depth: 1, Capacity: 1, Pending: 1}, repo.QueueStats())
+
+	t.Run("rejecting a transaction when the queue is full", func(t *testing.T) {
+		rejected, errs := entities.NewTransaction("desktop-web", "user123", 300, entities.CREDIT)
+		assert.Empty(t, errs)
+
+		result, err := repo.Insert(context.Background(), rejected)
+		assert.Nil(t, result)
+		assert.ErrorIs(t, err, coreRepositories.ErrQueueFull)
+		assert.Equal(t, entities.QueueStats{Depth: 1, Capacity: 1, Pending: 1}, repo.QueueStats())
+	})
+
+	t.Run("giving up when the context is done", func(t *testing.T) {
+		rejected, errs := entities.NewTransaction("desktop-web", "user123", 300, entities.CREDIT)
+		assert.Empty(t, errs)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		repo.WithBulkQueue(0, time.Hour)
+
+		result, err := repo.Insert(ctx, rejected)
+		assert.Nil(t, result)
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+
+	// the rejected transactions were not spooled
+	assert.NoError(t, s.Close())
+	_, entries, err := spool.Open(spool.Config{Dir: dir})
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func Test_TransactionRepositoryImpl_Insert_CommitSync(t *testing.T) {
+	commitSync := func(ctx context.Context) context.Context {
+		return coreRepositories.WithConsistency(ctx, coreRepositories.CONSISTENCY_COMMIT_SYNC)
+	}
+
+	t.Run("returning once the transaction is committed", func(t *testing.T) {
+		db := setupDB(t)
+		repo := repositories.NewTransactionRepository(db).WithBulkConfig(100, 0.05)
+		go repo.RunGroupTransactions()
+
+		transaction, errs := entities.NewTransaction("desktop-web", "user123", 200, entities.CREDIT)
+		assert.Empty(t, errs)
+		_, err := repo.Insert(commitSync(context.Background()), transaction)
+		assert.NoError(t, err)
+
+		var count int64
+		assert.NoError(t, db.Model(&entities.Transaction{}).Where("id = ?", transaction.ID.String()).Count(&count).Error)
+		assert.Equal(t, int64(1), count)
+	})
+
+	t.Run("retrying the commit while the caller waits", func(t *testing.T) {
+		// the transactions table is missing until the first commit failed
+		db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+		assert.NoError(t, err)
+		t.Cleanup(func() {
+			sqlDB, _ := db.DB()
+			sqlDB.Close()
+		})
+
+		repo := repositories.NewTransactionRepository(db).WithBulkConfig(100, 0.05)
+		go repo.RunGroupTransactions()
+		time.AfterFunc(200*time.Millisecond, func() {
+			assert.NoError(t, db.AutoMigrate(&entities.Transaction{}, &entities.UserBalance{}))
+		})
+
+		transaction, errs := entities.NewTransaction("desktop-web", "user123", 200, entities.CREDIT)
+		assert.Empty(t, errs)
+		ctx, cancel := context.WithTimeout(commitSync(context.Background()), 10*time.Second)
+		defer cancel()
+		_, err = repo.Insert(ctx, transaction)
+		assert.NoError(t, err)
+
+		var count int64
+		assert.NoError(t, db.Model(&entities.Transaction{}).Where("id = ?", transaction.ID.String()).Count(&count).Error)
+		assert.Equal(t, int64(1), count)
+	})
+
+	t.Run("failing the commit once dead-lettered", func(t *testing.T) {
+		// the transactions table is missing, so every commit fails
+		db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+		assert.NoError(t, err)
+		t.Cleanup(func() {
+			sqlDB, _ := db.DB()
+			sqlDB.Close()
+		})
+
+		dir := t.TempDir()
+		s, _, err := spool.Open(spool.Config{Dir: dir})
+		assert.NoError(t, err)
+		defer s.Close()
+
+		sink := repositories.NewDeadLetterFile(filepath.Join(dir, "dead-letters.ndjson"))
+		repo := repositories.NewTransactionRepository(db).WithBulkConfig(100, 0.05).WithSpool(s).WithDeadLetter(sink, 10*time.Millisecond)
+		go repo.RunGroupTransactions()
+
+		transaction, errs := entities.NewTransaction("desktop-web", "user123", 200, entities.CREDIT)
+		assert.Empty(t, errs)
+		result, err := repo.Insert(commitSync(context.Background()), transaction)
+		assert.Nil(t, result)
+		assert.ErrorIs(t, err, coreRepositories.ErrCommitNotConfirmed)
+		assert.ErrorContains(t, err, "no such table")
+		assert.Equal(t, 0, repo.QueueStats().Pending)
+
+		// the transaction is in the dead-letter sink, so it is not replayed on the next start
+		batches, err := sink.List(context.Background())
+		assert.NoError(t, err)
+		assert.Len(t, batches, 1)
+		assert.NoError(t, s.Close())
+		_, entries, err := spool.Open(spool.Config{Dir: dir})
+		assert.NoError(t, err)
+		assert.Empty(t, entries)
+	})
+
+	t.Run("giving up waiting when the context is done", func(t *testing.T) {
+		repo := repositories.NewTransactionRepository(setupDB(t)).WithBulkConfig(100, 3600)
+		go repo.RunGroupTransactions()
+
+		transaction, errs := entities.NewTransaction("desktop-web", "user123", 200, entities.CREDIT)
+		assert.Empty(t, errs)
+		ctx, cancel := context.WithTimeout(commitSync(context.Background()), 10*time.Millisecond)
+		defer cancel()
+
+		result, err := repo.Insert(ctx, transaction)
+		assert.Nil(t, result)
+		assert.ErrorIs(t, err, coreRepositories.ErrCommitNotConfirmed)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+}
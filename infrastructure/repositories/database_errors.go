@@ -0,0 +1,24 @@
+package repositories
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"net"
+	"strings"
+
+	coreRepositories "user-transactions/core/repositories"
+)
+
+// databaseError tells the errors of a database that can't be reached, which can be retried later, from the failing
+// queries. The pool answers with an unexported error once closed, it is matched by its message.
+func databaseError(err error) error {
+	var netErr net.Error
+	if errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, sql.ErrConnDone) ||
+		errors.As(err, &netErr) ||
+		err != nil && strings.Contains(err.Error(), "sql: database is closed") {
+		return coreRepositories.ErrDatabaseUnavailable.WithCause(err)
+	}
+	return err
+}
@@ -0,0 +1,182 @@
+package repositories
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"user-transactions/core/entities"
+
+	"gorm.io/gorm"
+)
+
+// DeadLetterFile keeps the dead-lettered batches in a local NDJSON file, one batch per line.
+// It doesn't depend on the database, so the batches are kept even when the database is down.
+// The file is shared by the server and the deadletter command, they take an advisory lock on Path + ".lock" (a file of
+// its own since Delete replaces the batches file) before touching it.
+type DeadLetterFile struct {
+	Path string
+	mu   sync.Mutex
+}
+
+func NewDeadLetterFile(path string) *DeadLetterFile {
+	return &DeadLetterFile{
+		Path: path,
+	}
+}
+
+func (f *DeadLetterFile) Insert(ctx context.Context, batch *entities.DeadLetterBatch) error {
+	line, err := json.Marshal(batch)
+	if err != nil {
+		return err
+	}
+
+	unlock, err := f.lock(syscall.LOCK_EX)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	file, err := os.OpenFile(f.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return file.Sync()
+}
+
+func (f *DeadLetterFile) List(ctx context.Context) ([]*entities.DeadLetterBatch, error) {
+	unlock, err := f.lock(syscall.LOCK_SH)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	return f.read()
+}
+
+func (f *DeadLetterFile) Find(ctx context.Context, id string) (*entities.DeadLetterBatch, error) {
+	unlock, err := f.lock(syscall.LOCK_SH)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	batches, err := f.read()
+	if err != nil {
+		return nil, err
+	}
+	for _, batch := range batches {
+		if batch.ID.String() == id {
+			return batch, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+// Delete rewrites the file without the batch, the new file replaces the old one atomically.
+func (f *DeadLetterFile) Delete(ctx context.Context, id string) error {
+	unlock, err := f.lock(syscall.LOCK_EX)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	batches, err := f.read()
+	if err != nil {
+		return err
+	}
+
+	found := false
+	tmp, err := os.CreateTemp(filepath.Dir(f.Path), filepath.Base(f.Path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	writer := bufio.NewWriter(tmp)
+	encoder := json.NewEncoder(writer)
+	for _, batch := range batches {
+		if batch.ID.String() == id {
+			found = true
+			continue
+		}
+		if err := encoder.Encode(batch); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if !found {
+		tmp.Close()
+		return gorm.ErrRecordNotFound
+	}
+
+	if err := writer.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), f.Path)
+}
+
+// lock takes the in-process mutex and the flock of the lock file, how is syscall.LOCK_SH to read or syscall.LOCK_EX to
+// write. It blocks until the other processes release the file.
+func (f *DeadLetterFile) lock(how int) (func(), error) {
+	f.mu.Lock()
+
+	if err := os.MkdirAll(filepath.Dir(f.Path), 0o750); err != nil {
+		f.mu.Unlock()
+		return nil, err
+	}
+	file, err := os.OpenFile(f.Path+".lock", os.O_CREATE|os.O_RDWR, 0o640)
+	if err != nil {
+		f.mu.Unlock()
+		return nil, err
+	}
+	if err := syscall.Flock(int(file.Fd()), how); err != nil {
+		file.Close()
+		f.mu.Unlock()
+		return nil, err
+	}
+	return func() {
+		// closing the file releases the flock
+		file.Close()
+		f.mu.Unlock()
+	}, nil
+}
+
+func (f *DeadLetterFile) read() ([]*entities.DeadLetterBatch, error) {
+	file, err := os.Open(f.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var batches []*entities.DeadLetterBatch
+	decoder := json.NewDecoder(file)
+	for decoder.More() {
+		var batch entities.DeadLetterBatch
+		if err := decoder.Decode(&batch); err != nil {
+			return nil, err
+		}
+		batches = append(batches, &batch)
+	}
+	return batches, nil
+}
@@ -0,0 +1,50 @@
+package repositories
+
+import (
+	"context"
+	"user-transactions/core/entities"
+
+	"gorm.io/gorm"
+)
+
+// DeadLetterRepository keeps the dead-lettered batches in a table of the database.
+type DeadLetterRepository struct {
+	Db *gorm.DB
+}
+
+func NewDeadLetterRepository(db *gorm.DB) *DeadLetterRepository {
+	return &DeadLetterRepository{
+		Db: db,
+	}
+}
+
+func (r *DeadLetterRepository) Insert(ctx context.Context, batch *entities.DeadLetterBatch) error {
+	return r.Db.WithContext(ctx).Create(batch).Error
+}
+
+func (r *DeadLetterRepository) List(ctx context.Context) ([]*entities.DeadLetterBatch, error) {
+	var batches []*entities.DeadLetterBatch
+	if err := r.Db.WithContext(ctx).Order("created_at, id").Find(&batches).Error; err != nil {
+		return nil, err
+	}
+	return batches, nil
+}
+
+func (r *DeadLetterRepository) Find(ctx context.Context, id string) (*entities.DeadLetterBatch, error) {
+	var batch entities.DeadLetterBatch
+	if err := r.Db.WithContext(ctx).Where("id = ?", id).First(&batch).Error; err != nil {
+		return nil, err
+	}
+	return &batch, nil
+}
+
+func (r *DeadLetterRepository) Delete(ctx context.Context, id string) error {
+	result := r.Db.WithContext(ctx).Where("id = ?", id).Delete(&entities.DeadLetterBatch{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
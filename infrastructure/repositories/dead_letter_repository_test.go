@@ -0,0 +1,122 @@
+//go:build integration
+// +build integration
+
+package repositories_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"user-transactions/core/entities"
+	"user-transactions/infrastructure/repositories"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+type deadLetterStore interface {
+	Insert(ctx context.Context, batch *entities.DeadLetterBatch) error
+	List(ctx context.Context) ([]*entities.DeadLetterBatch, error)
+	Find(ctx context.Context, id string) (*entities.DeadLetterBatch, error)
+	Delete(ctx context.Context, id string) error
+}
+
+func Test_DeadLetterRepositories(t *testing.T) {
+	stores := map[string]func(t *testing.T) deadLetterStore{
+		"table": func(t *testing.T) deadLetterStore {
+			db := setupDB(t)
+			assert.NoError(t, db.AutoMigrate(&entities.DeadLetterBatch{}))
+			return repositories.NewDeadLetterRepository(db)
+		},
+		"file": func(t *testing.T) deadLetterStore {
+			return repositories.NewDeadLetterFile(filepath.Join(t.TempDir(), "dead-letters.ndjson"))
+		},
+	}
+
+	for name, newStore := range stores {
+		t.Run("keeping the batches in the "+name, func(t *testing.T) {
+			store := newStore(t)
+			ctx := context.Background()
+
+			batches, err := store.List(ctx)
+			assert.NoError(t, err)
+			assert.Empty(t, batches)
+
+			transaction, errs := entities.NewTransaction("desktop-web", "user123", 200, entities.CREDIT)
+			assert.Empty(t, errs)
+			first, err := entities.NewDeadLetterBatch([]*entities.Transaction{transaction}, errors.New("connection refused"))
+			assert.NoError(t, err)
+			second, err := entities.NewDeadLetterBatch([]*entities.Transaction{transaction}, errors.New("disk full"))
+			assert.NoError(t, err)
+			assert.NoError(t, store.Insert(ctx, first))
+			assert.NoError(t, store.Insert(ctx, second))
+
+			batches, err = store.List(ctx)
+			assert.NoError(t, err)
+			assert.Len(t, batches, 2)
+
+			found, err := store.Find(ctx, first.ID.String())
+			assert.NoError(t, err)
+			assert.Equal(t, "connection refused", found.LastError)
+			transactions, err := found.Transactions()
+			assert.NoError(t, err)
+			assert.Equal(t, transaction.ID, transactions[0].ID)
+
+			assert.NoError(t, store.Delete(ctx, first.ID.String()))
+			_, err = store.Find(ctx, first.ID.String())
+			assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+			assert.ErrorIs(t, store.Delete(ctx, first.ID.String()), gorm.ErrRecordNotFound)
+
+			batches, err = store.List(ctx)
+			assert.NoError(t, err)
+			assert.Len(t, batches, 1)
+			assert.Equal(t, second.ID, batches[0].ID)
+		})
+	}
+}
+
+func Test_DeadLetterFile(t *testing.T) {
+	t.Run("keeping the batches appended while another process discards one", func(t *testing.T) {
+		// the server and the deadletter command have a DeadLetterFile each
+		path := filepath.Join(t.TempDir(), "dead-letters.ndjson")
+		server, command := repositories.NewDeadLetterFile(path), repositories.NewDeadLetterFile(path)
+		ctx := context.Background()
+
+		transaction, errs := entities.NewTransaction("desktop-web", "user123", 200, entities.CREDIT)
+		assert.Empty(t, errs)
+		newBatch := func() *entities.DeadLetterBatch {
+			batch, err := entities.NewDeadLetterBatch([]*entities.Transaction{transaction}, errors.New("connection refused"))
+			assert.NoError(t, err)
+			return batch
+		}
+
+		const count = 50
+		discarded := make([]*entities.DeadLetterBatch, count)
+		for i := range discarded {
+			discarded[i] = newBatch()
+			assert.NoError(t, server.Insert(ctx, discarded[i]))
+		}
+
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < count; i++ {
+				assert.NoError(t, server.Insert(ctx, newBatch()))
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for _, batch := range discarded {
+				assert.NoError(t, command.Delete(ctx, batch.ID.String()))
+			}
+		}()
+		wg.Wait()
+
+		batches, err := command.List(ctx)
+		assert.NoError(t, err)
+		assert.Len(t, batches, count)
+	})
+}
@@ -0,0 +1,83 @@
+package repositories
+
+import (
+	"context"
+	"user-transactions/core/entities"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type IdempotencyRepository struct {
+	Db *gorm.DB
+}
+
+func NewIdempotencyRepository(db *gorm.DB) *IdempotencyRepository {
+	return &IdempotencyRepository{
+		Db: db,
+	}
+}
+
+func (r *IdempotencyRepository) Reserve(ctx context.Context, key *entities.IdempotencyKey) (bool, error) {
+	// the primary key (origin, key) makes concurrent submissions race on the database, only one of them is stored
+	result := r.Db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(key)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *IdempotencyRepository) TakeOver(ctx context.Context, expired, key *entities.IdempotencyKey) (bool, error) {
+	// the update is conditional, so of the submissions taking it over only one succeeds. The reservations made
+	// before they had an expiry have none and are taken over too.
+	result := r.Db.WithContext(ctx).
+		Model(&entities.IdempotencyKey{}).
+		Where(map[string]interface{}{
+			"origin":         expired.Origin,
+			"key":            expired.Key,
+			"transaction_id": expired.TransactionID,
+			"status":         entities.IDEMPOTENCY_PROCESSING,
+		}).
+		Where("expires_at IS NULL OR expires_at < ?", key.CreatedAt).
+		Updates(map[string]interface{}{
+			"fingerprint":    key.Fingerprint,
+			"transaction_id": key.TransactionID,
+			"response":       "",
+			"created_at":     key.CreatedAt,
+			"expires_at":     key.ExpiresAt,
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *IdempotencyRepository) Find(ctx context.Context, origin, key string) (*entities.IdempotencyKey, error) {
+	var idempotencyKey entities.IdempotencyKey
+	err := r.Db.WithContext(ctx).
+		Where(map[string]interface{}{"origin": origin, "key": key}).
+		First(&idempotencyKey).Error
+	if err != nil {
+		return nil, err
+	}
+	return &idempotencyKey, nil
+}
+
+func (r *IdempotencyRepository) Complete(ctx context.Context, key *entities.IdempotencyKey) error {
+	key.Status = entities.IDEMPOTENCY_COMPLETED
+	return r.Db.WithContext(ctx).
+		Model(&entities.IdempotencyKey{}).
+		Where(map[string]interface{}{"origin": key.Origin, "key": key.Key, "transaction_id": key.TransactionID}).
+		Updates(map[string]interface{}{"status": key.Status, "response": key.Response}).Error
+}
+
+func (r *IdempotencyRepository) Release(ctx context.Context, key *entities.IdempotencyKey) error {
+	return r.Db.WithContext(ctx).
+		Where(map[string]interface{}{
+			"origin":         key.Origin,
+			"key":            key.Key,
+			"transaction_id": key.TransactionID,
+			"status":         entities.IDEMPOTENCY_PROCESSING,
+		}).
+		Delete(&entities.IdempotencyKey{}).Error
+}
@@ -0,0 +1,133 @@
+//go:build integration
+// +build integration
+
+package repositories_test
+
+import (
+	"context"
+	"testing"
+	"time"
+	"user-transactions/core/entities"
+	"user-transactions/infrastructure/repositories"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_IdempotencyRepositoryImpl(t *testing.T) {
+	db := setupDB(t)
+	assert.NoError(t, db.AutoMigrate(&entities.IdempotencyKey{}))
+
+	repo := repositories.NewIdempotencyRepository(db)
+	ctx := context.Background()
+
+	t.Run("reserving a new key", func(t *testing.T) {
+		key, errs := entities.NewIdempotencyKey("desktop-web", "key-1", "fingerprint", uuid.New(), time.Minute)
+		assert.Empty(t, errs)
+
+		reserved, err := repo.Reserve(ctx, key)
+		assert.NoError(t, err)
+		assert.True(t, reserved)
+
+		found, err := repo.Find(ctx, "desktop-web", "key-1")
+		assert.NoError(t, err)
+		assert.Equal(t, key.TransactionID, found.TransactionID)
+		assert.Equal(t, entities.IDEMPOTENCY_PROCESSING, found.Status)
+	})
+
+	t.Run("reserving a key that already exists", func(t *testing.T) {
+		key, errs := entities.NewIdempotencyKey("desktop-web", "key-1", "another-fingerprint", uuid.New(), time.Minute)
+		assert.Empty(t, errs)
+
+		reserved, err := repo.Reserve(ctx, key)
+		assert.NoError(t, err)
+		assert.False(t, reserved)
+	})
+
+	t.Run("reserving the same key for another origin", func(t *testing.T) {
+		key, errs := entities.NewIdempotencyKey("mobile-android", "key-1", "fingerprint", uuid.New(), time.Minute)
+		assert.Empty(t, errs)
+
+		reserved, err := repo.Reserve(ctx, key)
+		assert.NoError(t, err)
+		assert.True(t, reserved)
+	})
+
+	t.Run("completing a key", func(t *testing.T) {
+		key, err := repo.Find(ctx, "desktop-web", "key-1")
+		assert.NoError(t, err)
+
+		key.Response = `{"id":"1"}`
+		assert.NoError(t, repo.Complete(ctx, key))
+
+		found, err := repo.Find(ctx, "desktop-web", "key-1")
+		assert.NoError(t, err)
+		assert.Equal(t, entities.IDEMPOTENCY_COMPLETED, found.Status)
+		assert.Equal(t, `{"id":"1"}`, found.Response)
+	})
+
+	t.Run("releasing a key in progress", func(t *testing.T) {
+		key, err := repo.Find(ctx, "mobile-android", "key-1")
+		assert.NoError(t, err)
+
+		assert.NoError(t, repo.Release(ctx, key))
+
+		_, err = repo.Find(ctx, "mobile-android", "key-1")
+		assert.Error(t, err)
+	})
+
+	t.Run("taking over an expired reservation", func(t *testing.T) {
+		abandoned, errs := entities.NewIdempotencyKey("desktop-web", "key-2", "fingerprint", uuid.New(), -time.Second)
+		assert.Empty(t, errs)
+		reserved, err := repo.Reserve(ctx, abandoned)
+		assert.NoError(t, err)
+		assert.True(t, reserved)
+
+		retry, errs := entities.NewIdempotencyKey("desktop-web", "key-2", "fingerprint", uuid.New(), time.Minute)
+		assert.Empty(t, errs)
+		reserved, err = repo.Reserve(ctx, retry)
+		assert.NoError(t, err)
+		assert.False(t, reserved)
+
+		stored, err := repo.Find(ctx, "desktop-web", "key-2")
+		assert.NoError(t, err)
+		assert.True(t, stored.Expired(retry.CreatedAt))
+		taken, err := repo.TakeOver(ctx, stored, retry)
+		assert.NoError(t, err)
+		assert.True(t, taken)
+
+		// the reservation taken over is not released by the submission that abandoned it
+		assert.NoError(t, repo.Release(ctx, abandoned))
+		found, err := repo.Find(ctx, "desktop-web", "key-2")
+		assert.NoError(t, err)
+		assert.Equal(t, retry.TransactionID, found.TransactionID)
+		assert.Equal(t, entities.IDEMPOTENCY_PROCESSING, found.Status)
+		assert.False(t, found.Expired(retry.CreatedAt))
+
+		// nor taken over twice from the same expired reservation
+		another, errs := entities.NewIdempotencyKey("desktop-web", "key-2", "fingerprint", uuid.New(), time.Minute)
+		assert.Empty(t, errs)
+		taken, err = repo.TakeOver(ctx, stored, another)
+		assert.NoError(t, err)
+		assert.False(t, taken)
+	})
+
+	t.Run("keeping an expired key once completed", func(t *testing.T) {
+		key, errs := entities.NewIdempotencyKey("desktop-web", "key-3", "fingerprint", uuid.New(), -time.Second)
+		assert.Empty(t, errs)
+		reserved, err := repo.Reserve(ctx, key)
+		assert.NoError(t, err)
+		assert.True(t, reserved)
+		key.Response = `{"id":"3"}`
+		assert.NoError(t, repo.Complete(ctx, key))
+
+		retry, errs := entities.NewIdempotencyKey("desktop-web", "key-3", "fingerprint", uuid.New(), time.Minute)
+		assert.Empty(t, errs)
+		stored, err := repo.Find(ctx, "desktop-web", "key-3")
+		assert.NoError(t, err)
+		assert.False(t, stored.Expired(retry.CreatedAt))
+		taken, err := repo.TakeOver(ctx, key, retry)
+		assert.NoError(t, err)
+		assert.False(t, taken)
+	})
+}
@@ -0,0 +1,80 @@
+package repositories
+
+import (
+	"cmp"
+	"slices"
+	"time"
+	"user-transactions/core/entities"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// account is a balance of a user, the amounts of different currencies are never added up.
+type account struct {
+	userId   string
+	currency entities.Currency
+}
+
+// checkOverdraft locks the balances of the transactions and checks the debits against the policy, in the database
+// transaction that inserts them. The debits and the holds are checked against the available balance, the transactions
+// are applied in order, so a debit can use the credits before it.
+// pendingDebits sums the debits of an account that are still in the bulk buffer.
+func checkOverdraft(tx *gorm.DB, transactions []*entities.Transaction, policy *entities.OverdraftPolicy, pendingDebits func(account) int64) error {
+	limited := false
+	accounts := make([]account, 0, len(transactions))
+	for _, transaction := range transactions {
+		_, ok := policy.Limit(transaction)
+		limited = limited || ok
+		accounts = append(accounts, account{userId: transaction.UserID, currency: transaction.Currency})
+	}
+	if !limited {
+		return nil
+	}
+
+	// every database transaction locks the balances in the same order, so two of them can't deadlock;
+	// all the balances updated by the insert are locked, not only the debited ones, to keep that order
+	slices.SortFunc(accounts, func(a, b account) int {
+		if c := cmp.Compare(a.userId, b.userId); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.currency, b.currency)
+	})
+	accounts = slices.Compact(accounts)
+
+	balances := make(map[account]int64, len(accounts))
+	for _, a := range accounts {
+		balance, err := lockBalance(tx, a)
+		if err != nil {
+			return err
+		}
+		balances[a] = balance + pendingDebits(a)
+	}
+
+	for _, transaction := range transactions {
+		a := account{userId: transaction.UserID, currency: transaction.Currency}
+		balances[a] += transaction.Amount
+		if err := policy.Check(transaction, balances[a]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lockBalance reads the available balance of the account locking it until the end of the database transaction,
+// the balance is created first so there is always a row to lock. SQLite already serializes the writes.
+func lockBalance(tx *gorm.DB, a account) (int64, error) {
+	created := &entities.UserBalance{UserID: a.userId, Currency: a.currency, UpdatedAt: time.Now().UTC()}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(created).Error; err != nil {
+		return 0, err
+	}
+
+	var balance entities.UserBalance
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("user_id = ? AND currency = ?", a.userId, a.currency).
+		First(&balance).Error
+	if err != nil {
+		return 0, err
+	}
+	return entities.Balance{Ledger: balance.Balance, Held: balance.Held}.Available(), nil
+}
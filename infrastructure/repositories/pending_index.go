@@ -0,0 +1,86 @@
+package repositories
+
+import (
+	"sync"
+	"user-transactions/core/entities"
+	"user-transactions/infrastructure/spool"
+
+	"github.com/google/uuid"
+)
+
+type pendingItem struct {
+	transaction *entities.Transaction
+	position    spool.Position // the zero position means the transaction was not spooled
+}
+
+// pendingIndex keeps the transactions accepted by the bulk mode that were not committed yet,
+// so reads can take them into account while they are still waiting in the buffer.
+type pendingIndex struct {
+	mu    sync.RWMutex
+	items map[uuid.UUID]pendingItem
+}
+
+func newPendingIndex() *pendingIndex {
+	return &pendingIndex{
+		items: make(map[uuid.UUID]pendingItem),
+	}
+}
+
+func (p *pendingIndex) add(transaction *entities.Transaction, position spool.Position) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.items[transaction.ID] = pendingItem{transaction: transaction, position: position}
+}
+
+// remove drops the transactions from the index and returns their spool positions.
+func (p *pendingIndex) remove(transactions ...*entities.Transaction) []spool.Position {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	positions := make([]spool.Position, 0, len(transactions))
+	for _, transaction := range transactions {
+		if item, ok := p.items[transaction.ID]; ok {
+			positions = append(positions, item.position)
+			delete(p.items, transaction.ID)
+		}
+	}
+	return positions
+}
+
+func (p *pendingIndex) len() int {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return len(p.items)
+}
+
+// get returns a copy of the pending transaction, marked as pending.
+func (p *pendingIndex) get(id uuid.UUID) (*entities.Transaction, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	item, ok := p.items[id]
+	if !ok {
+		return nil, false
+	}
+	transaction := *item.transaction
+	transaction.Pending = true
+	return &transaction, true
+}
+
+// snapshot returns copies of the pending transactions accepted by the match function, marked as pending.
+func (p *pendingIndex) snapshot(match func(*entities.Transaction) bool) []*entities.Transaction {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	var transactions []*entities.Transaction
+	for _, item := range p.items {
+		if match(item.transaction) {
+			transaction := *item.transaction
+			transaction.Pending = true
+			transactions = append(transactions, &transaction)
+		}
+	}
+	return transactions
+}
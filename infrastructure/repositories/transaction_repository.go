@@ -1,41 +1,128 @@
 package repositories
 
 import (
+	"cmp"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"slices"
 	"sync"
 	"time"
 	"user-transactions/core/entities"
+	coreRepositories "user-transactions/core/repositories"
+	"user-transactions/infrastructure/spool"
 
 	backoff "github.com/cenkalti/backoff/v4"
+	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// errReversalRejected rolls back the reversal database transaction when the reversal is not valid.
+var errReversalRejected = errors.New("reversal rejected")
+
+// defaultRetryBudget is how long CommitBulk retries a bulk before sending it to the dead-letter sink.
+const defaultRetryBudget = 60 * time.Minute
+
+// defaultBulkWorkers is how many bulks are committed at the same time by default.
+const defaultBulkWorkers = 4
+
+// insertManyBatchSize is how many rows InsertMany sends in a statement, to stay under the limit of parameters of a statement.
+const insertManyBatchSize = 1000
+
+const (
+	defaultQueueCapacity = 1000                   // transactions waiting for the bulk writer
+	defaultQueueWait     = 100 * time.Millisecond // how long Insert waits for room in a full queue
 )
 
 type BulkConfig struct {
-	MaxSize int
-	MaxTime float64
+	MaxSize       int
+	MaxTime       float64
+	MaxWorkers    int // bulks being committed at the same time
+	QueueCapacity int
+	QueueWait     time.Duration
+}
+
+// DeadLetterSink receives the bulks that could not be committed within the retry budget.
+type DeadLetterSink interface {
+	Insert(ctx context.Context, batch *entities.DeadLetterBatch) error
 }
 
 type TransactionRepository struct {
-	Db         *gorm.DB
-	InsertChan chan *entities.Transaction
-	BulkConfig *BulkConfig
-	CommitWg   sync.WaitGroup
+	Db          *gorm.DB
+	InsertChan  chan *BulkItem
+	BulkConfig  *BulkConfig
+	CommitWg    sync.WaitGroup
+	Spool       *spool.Spool   // optional, records the bulk transactions until they are committed
+	DeadLetter  DeadLetterSink // optional, without it the failed bulks are retried forever
+	RetryBudget time.Duration
+	pending     *pendingIndex
+	slots       chan struct{} // one per transaction in InsertChan, reserved before spooling the transaction
+	batcherDone chan struct{} // closed when RunGroupTransactions has flushed the last bulk
 }
 
 func NewTransactionRepository(db *gorm.DB) *TransactionRepository {
 	return &TransactionRepository{
-		Db:         db,
-		InsertChan: make(chan *entities.Transaction),
+		Db:          db,
+		InsertChan:  make(chan *BulkItem),
+		RetryBudget: defaultRetryBudget,
+		pending:     newPendingIndex(),
 	}
 }
 
 func (r *TransactionRepository) Insert(ctx context.Context, transaction *entities.Transaction) (*entities.Transaction, error) {
 	if r.BulkConfig != nil {
-		r.InsertChan <- transaction
+		// the room in the queue is reserved first, so a rejected transaction is never spooled
+		if err := r.reserveSlot(ctx); err != nil {
+			return nil, err
+		}
+
+		// spooled before being acknowledged, so it's replayed on startup if the process dies before the commit
+		var position spool.Position
+		if r.Spool != nil {
+			data, err := json.Marshal(transaction)
+			if err == nil {
+				position, err = r.Spool.Append(data)
+			}
+			if err != nil {
+				<-r.slots
+				return nil, fmt.Errorf("error spooling the transaction: %w", err)
+			}
+		}
+
+		item := &BulkItem{Transaction: transaction}
+		if coreRepositories.Consistency(ctx) == coreRepositories.CONSISTENCY_COMMIT_SYNC {
+			item.done = make(chan error, 1)
+		} else {
+			// marked before being sent, the caller is acknowledged before the commit
+			transaction.Pending = true
+		}
+
+		// tracked before being sent, so it is visible to the reads as soon as the caller is acknowledged
+		r.pending.add(transaction, position)
+		// it doesn't block, the reserved slot guarantees there is room in the channel
+		r.InsertChan <- item
+
+		if item.done != nil {
+			select {
+			case err := <-item.done:
+				if err != nil {
+					return nil, err
+				}
+			case <-ctx.Done():
+				return nil, fmt.Errorf("%w: %w", coreRepositories.ErrCommitNotConfirmed, ctx.Err())
+			}
+		}
 	} else {
-		if err := r.Db.Create(transaction).Error; err != nil {
+		err := r.Db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(transaction).Error; err != nil {
+				return err
+			}
+			return addToBalances(tx, transaction)
+		})
+		if err != nil {
 			return nil, err
 		}
 	}
@@ -43,18 +130,133 @@ func (r *TransactionRepository) Insert(ctx context.Context, transaction *entitie
 	return transaction, nil
 }
 
+func (r *TransactionRepository) InsertMany(ctx context.Context, transactions []*entities.Transaction) error {
+	if len(transactions) == 0 {
+		return nil
+	}
+
+	return r.Db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return insertNew(tx, transactions)
+	})
+}
+
+// InsertWithBalanceCheck inserts the transactions in a single database transaction, once the debits limited by
+// the policy are checked against the balances. The debits still in the bulk buffer are taken into account,
+// the credits are not since their commit may still fail.
+func (r *TransactionRepository) InsertWithBalanceCheck(ctx context.Context, transactions []*entities.Transaction, policy *entities.OverdraftPolicy) error {
+	if len(transactions) == 0 {
+		return nil
+	}
+
+	return r.Db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := checkOverdraft(tx, transactions, policy, r.pendingDebits); err != nil {
+			return err
+		}
+		return insertNew(tx, transactions)
+	})
+}
+
+func (r *TransactionRepository) pendingDebits(a account) int64 {
+	var debits int64
+	for _, transaction := range r.pending.snapshot(func(t *entities.Transaction) bool {
+		return t.UserID == a.userId && t.Currency == a.currency && t.Amount < 0
+	}) {
+		debits += transaction.Amount
+	}
+	return debits
+}
+
 func (r *TransactionRepository) Find(ctx context.Context, id string) (*entities.Transaction, error) {
+	// the transactions still in the bulk buffer are found before being committed
+	if parsed, err := uuid.Parse(id); err == nil {
+		if transaction, ok := r.pending.get(parsed); ok {
+			return transaction, nil
+		}
+	}
+
 	var transaction entities.Transaction
-	if err := r.Db.Where("id = ?", id).First(&transaction).Error; err != nil {
-		return nil, err
+	if err := r.Db.WithContext(ctx).Where("id = ?", id).First(&transaction).Error; err != nil {
+		return nil, databaseError(err)
 	}
 	return &transaction, nil
 }
 
-func (r *TransactionRepository) List(ctx context.Context, pageSize, offset int, filter map[string]string) ([]*entities.Transaction, error) {
-	query := r.Db.Limit(pageSize).Offset(offset)
-	for key, value := range filter {
-		query = query.Where(fmt.Sprintf("%v = ?", key), value)
+// List returns the page of transactions as if the ones still in the bulk buffer were already committed.
+func (r *TransactionRepository) List(ctx context.Context, pageSize, offset int, filter *entities.TransactionFilter) ([]*entities.Transaction, error) {
+	pending := r.pendingTransactions(filter)
+	if len(pending) == 0 {
+		return r.listCommitted(ctx, pageSize, offset, filter, nil)
+	}
+
+	// the merge moves a committed transaction by at most len(pending) positions,
+	// so the committed transactions are read from that many positions before the page
+	dbOffset := max(0, offset-len(pending))
+	dbLimit := pageSize + offset - dbOffset
+	committed, err := r.listCommitted(ctx, dbLimit, dbOffset, filter, pending)
+	if err != nil {
+		return nil, err
+	}
+
+	type positioned struct {
+		index       int64
+		transaction *entities.Transaction
+	}
+	var page []positioned
+	inPage := func(index int64) bool {
+		return index >= int64(offset) && index < int64(offset+pageSize)
+	}
+
+	// the position of a committed transaction is moved by the pending ones sorted before it
+	p := 0
+	for k, transaction := range committed {
+		for p < len(pending) && filter.Sort.Compare(pending[p], transaction) < 0 {
+			p++
+		}
+		if index := int64(dbOffset + k + p); inPage(index) {
+			page = append(page, positioned{index, transaction})
+		}
+	}
+
+	// the position of a pending transaction is given by the committed ones sorted before it
+	c := 0
+	for i, transaction := range pending {
+		for c < len(committed) && filter.Sort.Compare(committed[c], transaction) < 0 {
+			c++
+		}
+
+		var before int64
+		switch {
+		case c == len(committed) && len(committed) == dbLimit:
+			// after the committed transactions read, so after the page
+			continue
+		case c == 0 && dbOffset > 0:
+			// before the committed transactions read, so at most dbOffset committed ones and the pending ones before
+			// it precede it, which is before offset since dbOffset is offset-len(pending)
+			continue
+		default:
+			before = int64(dbOffset + c)
+		}
+		if index := before + int64(i); inPage(index) {
+			page = append(page, positioned{index, transaction})
+		}
+	}
+
+	slices.SortFunc(page, func(a, b positioned) int { return cmp.Compare(a.index, b.index) })
+	transactions := make([]*entities.Transaction, 0, len(page))
+	for _, item := range page {
+		transactions = append(transactions, item.transaction)
+	}
+	return transactions, nil
+}
+
+// listCommitted reads a page of the committed transactions, without the excluded ones.
+func (r *TransactionRepository) listCommitted(ctx context.Context, pageSize, offset int, filter *entities.TransactionFilter, exclude []*entities.Transaction) ([]*entities.Transaction, error) {
+	query := filterTransactions(r.Db.WithContext(ctx), filter).
+		Limit(pageSize).
+		Offset(offset).
+		Order(orderTransactions(filter.Sort, true))
+	if len(exclude) > 0 {
+		query = query.Where("id NOT IN ?", transactionIDs(exclude))
 	}
 
 	var transactions []*entities.Transaction
@@ -64,60 +266,528 @@ func (r *TransactionRepository) List(ctx context.Context, pageSize, offset int,
 	return transactions, nil
 }
 
-func (r *TransactionRepository) RunGroupTransactions() {
-	var bulk []*entities.Transaction
-	timer := time.Now()
-	for {
-		select {
-		case transaction := <-r.InsertChan:
-			bulk = append(bulk, transaction)
-		default:
-			if len(bulk) > 0 && (len(bulk) >= r.BulkConfig.MaxSize || time.Since(timer).Seconds() >= r.BulkConfig.MaxTime) {
-				fmt.Printf("committing %d transactions with elapsed time of %v\n", len(bulk), time.Since(timer))
+// ListByCursor returns the page next to the cursor, the transactions still in the bulk buffer are merged
+// with the committed ones.
+func (r *TransactionRepository) ListByCursor(ctx context.Context, pageSize int, cursor *entities.Cursor, filter *entities.TransactionFilter) ([]*entities.Transaction, error) {
+	var pending []*entities.Transaction
+	for _, transaction := range r.pendingTransactions(filter) {
+		if cursor == nil || cursor.Precedes(transaction) {
+			pending = append(pending, transaction)
+		}
+	}
 
-				r.CommitWg.Add(1)
-				go r.CommitBulk(bulk...)
+	query := filterTransactions(r.Db.WithContext(ctx), filter).Limit(pageSize)
+	if len(pending) > 0 {
+		query = query.Where("id NOT IN ?", transactionIDs(pending))
+	}
+
+	// going backward, the page is read in the reverse order to get the transactions closest to the cursor
+	backward := cursor != nil && cursor.Backward
+	query = query.Order(orderTransactions(filter.Sort, !backward))
+	if cursor != nil {
+		query = keysetCondition(query, filter.Sort, cursor, !backward)
+	}
+
+	var transactions []*entities.Transaction
+	if err := query.Find(&transactions).Error; err != nil {
+		return nil, err
+	}
 
-				bulk = nil
-				timer = time.Now()
+	if backward {
+		slices.Reverse(transactions)
+	}
+	if len(pending) == 0 {
+		return transactions, nil
+	}
+
+	// the page is made of the transactions closest to the cursor, committed or not
+	transactions = append(transactions, pending...)
+	slices.SortFunc(transactions, filter.Sort.Compare)
+	if len(transactions) > pageSize {
+		if backward {
+			transactions = transactions[len(transactions)-pageSize:]
+		} else {
+			transactions = transactions[:pageSize]
+		}
+	}
+	return transactions, nil
+}
+
+// Count counts the transactions, including the ones still in the bulk buffer.
+func (r *TransactionRepository) Count(ctx context.Context, filter *entities.TransactionFilter) (int64, error) {
+	pending := r.pendingTransactions(filter)
+
+	query := filterTransactions(r.Db.WithContext(ctx).Model(&entities.Transaction{}), filter)
+	if len(pending) > 0 {
+		query = query.Where("id NOT IN ?", transactionIDs(pending))
+	}
+
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count + int64(len(pending)), nil
+}
+
+// Stream reads the committed transactions row by row with a database cursor, the ones still in the bulk buffer are not exported.
+func (r *TransactionRepository) Stream(ctx context.Context, filter *entities.TransactionFilter, fn func(*entities.Transaction) error) error {
+	query := filterTransactions(r.Db.WithContext(ctx).Model(&entities.Transaction{}), filter)
+	rows, err := query.Order(orderTransactions(filter.Sort, true)).Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var transaction entities.Transaction
+		if err := r.Db.ScanRows(rows, &transaction); err != nil {
+			return err
+		}
+		if err := fn(&transaction); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	// the driver may end the rows without an error when the context is canceled
+	return ctx.Err()
+}
+
+// pendingTransactions returns the transactions of the bulk buffer passing the filter, in the order of its sort.
+// They are excluded from the queries, so a transaction committed in the meantime is not returned twice.
+func (r *TransactionRepository) pendingTransactions(filter *entities.TransactionFilter) []*entities.Transaction {
+	pending := r.pending.snapshot(filter.Match)
+	slices.SortFunc(pending, filter.Sort.Compare)
+	return pending
+}
+
+// keysetCondition selects the transactions sorted after the position, or before it when not after.
+func keysetCondition(query *gorm.DB, sort entities.TransactionSort, position *entities.Cursor, after bool) *gorm.DB {
+	column, op := sort.Column(), ">"
+	if sort.Desc() == after {
+		op = "<"
+	}
+	return query.Where(
+		fmt.Sprintf("(%[1]s %[2]s ? OR (%[1]s = ? AND id %[2]s ?))", column, op),
+		position.Value(), position.Value(), position.ID.String(),
+	)
+}
+
+// filterTransactions applies the filter conditions. Each column has an index, the created_from/created_to range
+// is served by the (created_at, id) ones, and the metadata has a GIN index on Postgres but none on SQLite.
+func filterTransactions(query *gorm.DB, filter *entities.TransactionFilter) *gorm.DB {
+	if filter.Origin != "" {
+		query = query.Where("origin = ?", filter.Origin)
+	}
+	if filter.UserID != "" {
+		query = query.Where("user_id = ?", filter.UserID)
+	}
+	if filter.Type != "" {
+		query = query.Where("type = ?", filter.Type)
+	}
+	if filter.Currency != "" {
+		query = query.Where("currency = ?", filter.Currency)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if len(filter.Metadata) > 0 {
+		query = filterMetadata(query, filter.Metadata)
+	}
+	if filter.CreatedFrom != nil {
+		query = query.Where("created_at >= ?", filter.CreatedFrom.UTC())
+	}
+	if filter.CreatedTo != nil {
+		query = query.Where("created_at < ?", filter.CreatedTo.UTC())
+	}
+	if filter.MinAmount != nil {
+		query = query.Where("amount >= ?", *filter.MinAmount)
+	}
+	if filter.MaxAmount != nil {
+		query = query.Where("amount <= ?", *filter.MaxAmount)
+	}
+	return query
+}
+
+// filterMetadata keeps the transactions with all the metadata of the filter, on Postgres the containment
+// is covered by the GIN index of the metadata. The keys were validated, so they are safe in the JSON paths.
+func filterMetadata(query *gorm.DB, metadata map[string]string) *gorm.DB {
+	if query.Dialector.Name() == "postgres" {
+		return query.Where("metadata @> ?", entities.Metadata(metadata))
+	}
+
+	keys := make([]string, 0, len(metadata))
+	for key := range metadata {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		query = query.Where("json_extract(metadata, ?) = ?", fmt.Sprintf(`$."%s"`, key), metadata[key])
+	}
+	return query
+}
+
+// orderTransactions returns the ORDER BY of the sort, or of its opposite when not forward.
+func orderTransactions(sort entities.TransactionSort, forward bool) string {
+	direction := "ASC"
+	if sort.Desc() == forward {
+		direction = "DESC"
+	}
+	return fmt.Sprintf("%[1]s %[2]s, id %[2]s", sort.Column(), direction)
+}
+
+func (r *TransactionRepository) Reverse(ctx context.Context, id string, policy *entities.OverdraftPolicy, build func(original *entities.Transaction, reversals []*entities.Transaction) (*entities.Transaction, []error)) (*entities.Transaction, []error) {
+	var reversal *entities.Transaction
+	var errs []error
+
+	// the reversal skips the bulk buffer, the original row stays locked until it is committed
+	// so concurrent reversals can't exceed the original amount
+	err := r.Db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var original entities.Transaction
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id).First(&original).Error; err != nil {
+			return err
+		}
+
+		var reversals []*entities.Transaction
+		if err := tx.Where("reversal_of = ?", id).Find(&reversals).Error; err != nil {
+			return err
+		}
+
+		reversal, errs = build(&original, reversals)
+		if errs != nil {
+			return errReversalRejected
+		}
+
+		// the reversal of a credit is a debit, limited as the other ones
+		if err := checkOverdraft(tx, []*entities.Transaction{reversal}, policy, r.pendingDebits); err != nil {
+			return err
+		}
+		if err := tx.Create(reversal).Error; err != nil {
+			return err
+		}
+		return addToBalances(tx, reversal)
+	})
+	if errs != nil {
+		return nil, errs
+	}
+	if err != nil {
+		return nil, []error{err}
+	}
+
+	return reversal, nil
+}
+
+func (r *TransactionRepository) UpdateStatus(ctx context.Context, id string, update func(transaction *entities.Transaction) error) (*entities.Transaction, error) {
+	var transaction entities.Transaction
+	err := r.Db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id).First(&transaction).Error; err != nil {
+			return err
+		}
+
+		before := transaction.Status
+		if err := update(&transaction); err != nil {
+			return err
+		}
+		return saveStatus(tx, &transaction, before)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &transaction, nil
+}
+
+// ExpireHolds voids the expired holds in a single database transaction, on Postgres the holds locked by a capture
+// or by another instance are skipped and left for the next run.
+func (r *TransactionRepository) ExpireHolds(ctx context.Context, now time.Time, limit int) (int64, error) {
+	var holds []*entities.Transaction
+	err := r.Db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND expires_at <= ?", entities.STATUS_PENDING, now.UTC()).
+			Order("expires_at").
+			Limit(limit).
+			Find(&holds).Error
+		if err != nil {
+			return err
+		}
+
+		for _, hold := range holds {
+			if err := hold.Void(); err != nil {
+				return err
+			}
+			if err := saveStatus(tx, hold, entities.STATUS_PENDING); err != nil {
+				return err
 			}
 		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(holds)), nil
+}
+
+// saveStatus updates the status of the transaction and the balance of its user, in the database transaction locking it.
+func saveStatus(tx *gorm.DB, transaction *entities.Transaction, before entities.TransactionStatus) error {
+	if transaction.Status == before {
+		return nil
+	}
+	if err := tx.Model(transaction).Update("status", transaction.Status).Error; err != nil {
+		return err
+	}
+	return changeBalance(tx, transaction, before)
+}
+
+func (r *TransactionRepository) ListReversals(ctx context.Context, id string) ([]*entities.Transaction, error) {
+	var reversals []*entities.Transaction
+	if err := r.Db.WithContext(ctx).Where("reversal_of = ?", id).Order("created_at").Find(&reversals).Error; err != nil {
+		return nil, databaseError(err)
+	}
+	return reversals, nil
+}
+
+// Balance sums the transactions of the user by currency, including the ones still waiting in the bulk buffer,
+// the amounts of different currencies are never added up. The user balances are read when the transactions
+// don't have to be filtered by origin or time.
+func (r *TransactionRepository) Balance(ctx context.Context, userId string, filter *entities.BalanceFilter) (map[entities.Currency]entities.Balance, error) {
+	// the pending snapshot is taken before querying the database and its transactions are left out of the sums,
+	// so a transaction committed in the meantime is counted exactly once
+	pending := r.pending.snapshot(func(t *entities.Transaction) bool {
+		return filter.Match(userId, t)
+	})
+
+	var rows []struct {
+		Currency entities.Currency
+		Balance  int64
+		Held     int64
 	}
+	var err error
+	if filter.Origin == "" && filter.AsOf == nil {
+		err = storedBalances(r.Db.WithContext(ctx), userId, filter, pending).Scan(&rows).Error
+	} else {
+		err = summedBalances(r.Db.WithContext(ctx), userId, filter, pending).Scan(&rows).Error
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	balances := make(map[entities.Currency]entities.Balance, len(rows))
+	for _, row := range rows {
+		balances[row.Currency] = entities.Balance{Ledger: row.Balance, Held: row.Held}
+	}
+	// the holds skip the bulk buffer, the pending transactions are all posted
+	for _, transaction := range pending {
+		balance := balances[transaction.Currency]
+		balance.Ledger += transaction.Amount
+		balances[transaction.Currency] = balance
+	}
+	return balances, nil
+}
+
+// storedBalances reads the user balances without the pending transactions committed in the meantime,
+// in a single statement so the balances and the transactions are read at the same moment.
+func storedBalances(query *gorm.DB, userId string, filter *entities.BalanceFilter, pending []*entities.Transaction) *gorm.DB {
+	query = query.Model(&entities.UserBalance{}).Where("user_id = ?", userId)
+	if filter.Currency != "" {
+		query = query.Where("currency = ?", filter.Currency)
+	}
+	if len(pending) == 0 {
+		return query.Select("currency, balance, held")
+	}
+	return query.Select(`currency, held, balance - (
+		SELECT COALESCE(SUM(amount), 0) FROM transactions
+		WHERE transactions.user_id = user_balances.user_id AND transactions.currency = user_balances.currency AND transactions.id IN ?
+	) AS balance`, transactionIDs(pending))
+}
+
+// summedBalances sums the committed transactions passing the filter, without the pending ones.
+func summedBalances(query *gorm.DB, userId string, filter *entities.BalanceFilter, pending []*entities.Transaction) *gorm.DB {
+	query = query.Model(&entities.Transaction{}).Where("user_id = ?", userId)
+	if filter.Origin != "" {
+		query = query.Where("origin = ?", filter.Origin)
+	}
+	if filter.Currency != "" {
+		query = query.Where("currency = ?", filter.Currency)
+	}
+	if filter.AsOf != nil {
+		query = query.Where("created_at <= ?", filter.AsOf.UTC())
+	}
+	if len(pending) > 0 {
+		query = query.Where("id NOT IN ?", transactionIDs(pending))
+	}
+	return query.Select("currency, " + sumLedger + " AS balance, " + sumHeld + " AS held").Group("currency")
+}
+
+// BulkItem is a transaction waiting in InsertChan, done receives the result of the commit
+// when the caller waits for it.
+type BulkItem struct {
+	Transaction *entities.Transaction
+	done        chan error
 }
 
 func (r *TransactionRepository) CommitBulk(transactions ...*entities.Transaction) {
+	items := make([]*BulkItem, 0, len(transactions))
+	for _, transaction := range transactions {
+		items = append(items, &BulkItem{Transaction: transaction})
+	}
+	r.commitBulk(items)
+}
+
+func (r *TransactionRepository) commitBulk(items []*BulkItem) {
 	defer r.CommitWg.Done()
 	retryBo := backoff.NewExponentialBackOff()
-	retryBo.MaxElapsedTime = 60 * time.Minute
+	retryBo.MaxElapsedTime = r.RetryBudget
 
+	transactions := bulkTransactions(items)
 	retryOp := func() error {
-		err := r.Db.Create(transactions).Error
+		if len(transactions) == 0 {
+			return nil
+		}
+
+		// the transactions replayed from the spool may have been committed before the crash
+		err := r.Db.Transaction(func(tx *gorm.DB) error {
+			return insertNew(tx, transactions)
+		})
 		if err != nil {
-			fmt.Printf("error when committing %v transactions: %v, retrying in %v\n", len(transactions), err, retryBo.NextBackOff())
+			log.Printf("error when committing %v transactions: %v, retrying in %v", len(transactions), err, retryBo.NextBackOff())
+			return err
+		}
+		return nil
+	}
+
+	// the callers waiting for the commit keep waiting through the retries, until their own context is done
+	var result error
+	for {
+		err := backoff.Retry(retryOp, retryBo)
+		if err == nil {
+			break
+		}
+
+		log.Printf("error when committing %v transactions, aborting...", len(transactions))
+		if err := r.deadLetter(transactions, err); err != nil {
+			// the bulk is kept in memory and in the spool, the retries start over
+			log.Printf("error when dead-lettering %v transactions: %v, retrying the commit", len(transactions), err)
+			retryBo.Reset()
+			continue
+		}
+		// the dead-lettered transactions can still be replayed, so their outcome is unknown to the callers
+		result = fmt.Errorf("%w: %w", coreRepositories.ErrCommitNotConfirmed, err)
+		break
+	}
+
+	// the spool is only truncated once the transactions are in the database or in the dead-letter sink
+	r.release(transactions...)
+	for _, item := range items {
+		if item.done != nil {
+			item.done <- result
 		}
-		return err
 	}
+}
 
-	if err := backoff.Retry(retryOp, retryBo); err != nil {
-		fmt.Printf("error when committing %v transactions, aborting...", len(transactions))
-		// here we have some options, send to a dead letter queue, another table or database, file, or retry again
-		r.CommitBulk(transactions...)
+// release drops the transactions from the pending index and the spool.
+func (r *TransactionRepository) release(transactions ...*entities.Transaction) {
+	positions := r.pending.remove(transactions...)
+	if r.Spool != nil {
+		r.Spool.Ack(positions...)
+	}
+}
+
+func bulkTransactions(items []*BulkItem) []*entities.Transaction {
+	transactions := make([]*entities.Transaction, 0, len(items))
+	for _, item := range items {
+		transactions = append(transactions, item.Transaction)
+	}
+	return transactions
+}
+
+// deadLetter sends the bulk to the dead-letter sink with the error of the last commit attempt.
+func (r *TransactionRepository) deadLetter(transactions []*entities.Transaction, lastError error) error {
+	if r.DeadLetter == nil {
+		return errors.New("no dead-letter sink configured")
+	}
+
+	batch, err := entities.NewDeadLetterBatch(transactions, lastError)
+	if err != nil {
+		return err
 	}
+	if err := r.DeadLetter.Insert(context.Background(), batch); err != nil {
+		return err
+	}
+
+	log.Printf("%d transactions sent to the dead-letter sink as batch %s", len(transactions), batch.ID)
+	return nil
 }
 
+// WithBulkConfig enables the bulk mode, RunGroupTransactions must be running to commit the transactions.
 func (r *TransactionRepository) WithBulkConfig(maxBulkItems int, maxWaitingSeconds float64) *TransactionRepository {
 	r.BulkConfig = &BulkConfig{
-		MaxSize: maxBulkItems,
-		MaxTime: maxWaitingSeconds,
+		MaxSize:       maxBulkItems,
+		MaxTime:       maxWaitingSeconds,
+		MaxWorkers:    defaultBulkWorkers,
+		QueueCapacity: defaultQueueCapacity,
+		QueueWait:     defaultQueueWait,
+	}
+	r.InsertChan = make(chan *BulkItem, defaultQueueCapacity)
+	r.slots = make(chan struct{}, defaultQueueCapacity)
+	r.batcherDone = make(chan struct{})
+
+	return r
+}
+
+func (r *TransactionRepository) WithDeadLetter(sink DeadLetterSink, retryBudget time.Duration) *TransactionRepository {
+	r.DeadLetter = sink
+	if retryBudget > 0 {
+		r.RetryBudget = retryBudget
 	}
 
 	return r
 }
 
+func (r *TransactionRepository) WithSpool(s *spool.Spool) *TransactionRepository {
+	r.Spool = s
+
+	return r
+}
+
+// ReplaySpool commits the transactions left in the spool by a previous run, it must be called before serving requests.
+func (r *TransactionRepository) ReplaySpool(entries []spool.Entry) error {
+	batchSize := 100
+	if r.BulkConfig != nil {
+		batchSize = r.BulkConfig.MaxSize
+	}
+
+	var bulk []*entities.Transaction
+	for i, entry := range entries {
+		var transaction entities.Transaction
+		if err := json.Unmarshal(entry.Data, &transaction); err != nil {
+			return fmt.Errorf("error decoding the spooled transaction: %w", err)
+		}
+		r.pending.add(&transaction, entry.Position)
+		bulk = append(bulk, &transaction)
+
+		if len(bulk) >= batchSize || i == len(entries)-1 {
+			log.Printf("replaying %d spooled transactions", len(bulk))
+			r.CommitWg.Add(1)
+			r.CommitBulk(bulk...)
+			bulk = nil
+		}
+	}
+
+	return nil
+}
+
 func (r *TransactionRepository) Shutdown(ctx context.Context) error {
 	// since we close the HTTP server, InsertChan will not receive any more transactions
-	// so we can close it safely without any data loss
-	defer close(r.InsertChan)
+	// so we can close it safely, RunGroupTransactions flushes what is left and returns
+	close(r.InsertChan)
+
+	if r.batcherDone != nil {
+		select {
+		case <-r.batcherDone:
+		case <-ctx.Done():
+			return fmt.Errorf("timed out waiting for the last bulk to be flushed: %s", ctx.Err())
+		}
+	}
 
 	// Waiting all CommitBulk operations finish.
 	done := make(chan struct{})
@@ -135,3 +805,11 @@ func (r *TransactionRepository) Shutdown(ctx context.Context) error {
 		return fmt.Errorf("timed out waiting for transactions to be committed: %s", ctx.Err())
 	}
 }
+
+func transactionIDs(transactions []*entities.Transaction) []string {
+	ids := make([]string, 0, len(transactions))
+	for _, transaction := range transactions {
+		ids = append(ids, transaction.ID.String())
+	}
+	return ids
+}
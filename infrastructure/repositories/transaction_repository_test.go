@@ -5,10 +5,17 @@ package repositories_test
 
 import (
 	"context"
+	"errors"
+	"path/filepath"
+	"sort"
 	"testing"
+	"time"
 	"user-transactions/core/entities"
+	coreRepositories "user-transactions/core/repositories"
 	"user-transactions/infrastructure/repositories"
+	"user-transactions/infrastructure/spool"
 
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
@@ -18,7 +25,7 @@ import (
 func setupDB(t *testing.T) *gorm.DB {
 	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
 	assert.NoError(t, err)
-	assert.NoError(t, db.AutoMigrate(&entities.Transaction{}))
+	assert.NoError(t, db.AutoMigrate(&entities.Transaction{}, &entities.UserBalance{}))
 
 	t.Cleanup(func() {
 		sqlDB, _ := db.DB()
@@ -35,7 +42,7 @@ func Test_TransactionRepositoryImpl_Insert(t *testing.T) {
 
 	transaction, errs := entities.NewTransaction("desktop-web", "user123", 200, entities.CREDIT)
 	assert.Empty(t, errs)
-	ctx, cancel := context.WithTimeout(context.Background(), 5)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
 	result, err := repo.Insert(ctx, transaction)
@@ -55,7 +62,7 @@ func Test_TransactionRepositoryImpl_Find(t *testing.T) {
 		err := db.Create(transaction).Error
 		assert.NoError(t, err)
 
-		ctx, cancel := context.WithTimeout(context.Background(), 5)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
 
 		found, err := repo.Find(ctx, transaction.ID.String())
@@ -69,13 +76,45 @@ func Test_TransactionRepositoryImpl_Find(t *testing.T) {
 	})
 
 	t.Run("finding a transaction that does not exist", func(t *testing.T) {
-		ctx, cancel := context.WithTimeout(context.Background(), 5)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
 
 		found, err := repo.Find(ctx, "non-existing-id")
 		assert.Error(t, err)
 		assert.Equal(t, "record not found", err.Error())
 		assert.Nil(t, found)
+
+		found, err = repo.Find(ctx, uuid.NewString())
+		assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+		assert.Nil(t, found)
+	})
+
+	t.Run("finding a transaction in a table that does not exist", func(t *testing.T) {
+		db := setupDB(t)
+		assert.NoError(t, db.Migrator().DropTable(&entities.Transaction{}))
+		repo := repositories.NewTransactionRepository(db)
+
+		found, err := repo.Find(context.Background(), uuid.NewString())
+		assert.Error(t, err)
+		assert.NotErrorIs(t, err, gorm.ErrRecordNotFound)
+		assert.NotErrorIs(t, err, coreRepositories.ErrDatabaseUnavailable)
+		assert.Nil(t, found)
+	})
+
+	t.Run("finding a transaction when the database is unavailable", func(t *testing.T) {
+		db := setupDB(t)
+		sqlDB, err := db.DB()
+		assert.NoError(t, err)
+		assert.NoError(t, sqlDB.Close())
+		repo := repositories.NewTransactionRepository(db)
+
+		found, err := repo.Find(context.Background(), uuid.NewString())
+		assert.ErrorIs(t, err, coreRepositories.ErrDatabaseUnavailable)
+		assert.ErrorContains(t, errors.Unwrap(err), "database is closed")
+		assert.Nil(t, found)
+
+		_, err = repo.ListReversals(context.Background(), uuid.NewString())
+		assert.ErrorIs(t, err, coreRepositories.ErrDatabaseUnavailable)
 	})
 }
 
@@ -95,10 +134,10 @@ func Test_TransactionRepositoryImpl_List(t *testing.T) {
 		err = db.Create(transaction2).Error
 		assert.NoError(t, err)
 
-		ctx, cancel := context.WithTimeout(context.Background(), 5)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
 
-		found, err := repo.List(ctx, 10, 0, map[string]string{})
+		found, err := repo.List(ctx, 10, 0, &entities.TransactionFilter{})
 		assert.NoError(t, err)
 		assert.Len(t, found, 2)
 		assert.Equal(t, transaction1.ID, found[0].ID)
@@ -125,10 +164,10 @@ func Test_TransactionRepositoryImpl_List(t *testing.T) {
 		err = db.Create(transaction3).Error
 		assert.NoError(t, err)
 
-		ctx, cancel := context.WithTimeout(context.Background(), 5)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
 
-		found, err := repo.List(ctx, 10, 0, map[string]string{"origin": "desktop-web"})
+		found, err := repo.List(ctx, 10, 0, &entities.TransactionFilter{Origin: "desktop-web"})
 		assert.NoError(t, err)
 		assert.Len(t, found, 2)
 		assert.Equal(t, transaction1.ID, found[0].ID)
@@ -150,10 +189,10 @@ func Test_TransactionRepositoryImpl_List(t *testing.T) {
 		err = db.Create(transaction2).Error
 		assert.NoError(t, err)
 
-		ctx, cancel := context.WithTimeout(context.Background(), 5)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
 
-		found, err := repo.List(ctx, 10, 0, map[string]string{"origin": "mobile-android"})
+		found, err := repo.List(ctx, 10, 0, &entities.TransactionFilter{Origin: "mobile-android"})
 		assert.NoError(t, err)
 		assert.Len(t, found, 0)
 	})
@@ -173,17 +212,1002 @@ func Test_TransactionRepositoryImpl_List(t *testing.T) {
 		err = db.Create(transaction2).Error
 		assert.NoError(t, err)
 
-		ctx, cancel := context.WithTimeout(context.Background(), 5)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
 
-		found, err := repo.List(ctx, 1, 0, map[string]string{})
+		found, err := repo.List(ctx, 1, 0, &entities.TransactionFilter{})
 		assert.NoError(t, err)
 		assert.Len(t, found, 1)
 		assert.Equal(t, transaction1.ID, found[0].ID)
 
-		found, err = repo.List(ctx, 1, 1, map[string]string{})
+		found, err = repo.List(ctx, 1, 1, &entities.TransactionFilter{})
 		assert.NoError(t, err)
 		assert.Len(t, found, 1)
 		assert.Equal(t, transaction2.ID, found[0].ID)
 	})
 }
+
+func Test_TransactionRepositoryImpl_Balance(t *testing.T) {
+	t.Run("summing the transactions of a user", func(t *testing.T) {
+		db := setupDB(t)
+
+		repo := repositories.NewTransactionRepository(db)
+
+		for _, amount := range []int64{200, -50, 300} {
+			opType := entities.CREDIT
+			if amount < 0 {
+				opType = entities.DEBIT
+			}
+			transaction, errs := entities.NewTransaction("desktop-web", "user123", amount, opType)
+			assert.Empty(t, errs)
+			assert.NoError(t, repo.InsertMany(context.Background(), []*entities.Transaction{transaction}))
+		}
+		other, errs := entities.NewTransaction("desktop-web", "user456", 1000, entities.CREDIT)
+		assert.Empty(t, errs)
+		assert.NoError(t, repo.InsertMany(context.Background(), []*entities.Transaction{other}))
+
+		balance, err := repo.Balance(context.Background(), "user123", &entities.BalanceFilter{})
+		assert.NoError(t, err)
+		assert.Equal(t, map[entities.Currency]entities.Balance{entities.DEFAULT_CURRENCY: {Ledger: 450}}, balance)
+	})
+
+	t.Run("summing the transactions of a user by origin and as of a timestamp", func(t *testing.T) {
+		db := setupDB(t)
+
+		repo := repositories.NewTransactionRepository(db)
+
+		old, errs := entities.NewTransaction("desktop-web", "user123", 200, entities.CREDIT)
+		assert.Empty(t, errs)
+		old.CreatedAt = old.CreatedAt.Add(-time.Hour)
+		assert.NoError(t, db.Create(old).Error)
+
+		recent, errs := entities.NewTransaction("desktop-web", "user123", 300, entities.CREDIT)
+		assert.Empty(t, errs)
+		assert.NoError(t, db.Create(recent).Error)
+
+		mobile, errs := entities.NewTransaction("mobile-android", "user123", 500, entities.CREDIT)
+		assert.Empty(t, errs)
+		assert.NoError(t, db.Create(mobile).Error)
+
+		balance, err := repo.Balance(context.Background(), "user123", &entities.BalanceFilter{Origin: "desktop-web"})
+		assert.NoError(t, err)
+		assert.Equal(t, map[entities.Currency]entities.Balance{entities.DEFAULT_CURRENCY: {Ledger: 500}}, balance)
+
+		asOf := time.Now().UTC().Add(-time.Minute)
+		balance, err = repo.Balance(context.Background(), "user123", &entities.BalanceFilter{AsOf: &asOf})
+		assert.NoError(t, err)
+		assert.Equal(t, map[entities.Currency]entities.Balance{entities.DEFAULT_CURRENCY: {Ledger: 200}}, balance)
+	})
+
+	t.Run("summing the transactions that are still in the bulk buffer", func(t *testing.T) {
+		db := setupDB(t)
+
+		repo := repositories.NewTransactionRepository(db).WithBulkConfig(100, 3600)
+		// holds the transactions as the bulk buffer does, without committing them
+		go func() {
+			for range repo.InsertChan {
+			}
+		}()
+
+		committed, errs := entities.NewTransaction("desktop-web", "user123", 200, entities.CREDIT)
+		assert.Empty(t, errs)
+		assert.NoError(t, repo.InsertMany(context.Background(), []*entities.Transaction{committed}))
+
+		buffered, errs := entities.NewTransaction("desktop-web", "user123", -50, entities.DEBIT)
+		assert.Empty(t, errs)
+		_, err := repo.Insert(context.Background(), buffered)
+		assert.NoError(t, err)
+
+		balance, err := repo.Balance(context.Background(), "user123", &entities.BalanceFilter{})
+		assert.NoError(t, err)
+		assert.Equal(t, map[entities.Currency]entities.Balance{entities.DEFAULT_CURRENCY: {Ledger: 150}}, balance)
+
+		// once committed, the transaction is not counted twice
+		repo.CommitWg.Add(1)
+		repo.CommitBulk(buffered)
+
+		balance, err = repo.Balance(context.Background(), "user123", &entities.BalanceFilter{})
+		assert.NoError(t, err)
+		assert.Equal(t, map[entities.Currency]entities.Balance{entities.DEFAULT_CURRENCY: {Ledger: 150}}, balance)
+	})
+
+	t.Run("summing the transactions of a user by currency", func(t *testing.T) {
+		db := setupDB(t)
+
+		repo := repositories.NewTransactionRepository(db)
+
+		for _, currency := range []entities.Currency{"BRL", "USD", "USD"} {
+			transaction, errs := entities.NewTransaction("desktop-web", "user123", 100, entities.CREDIT, entities.WithCurrency(currency))
+			assert.Empty(t, errs)
+			assert.NoError(t, repo.InsertMany(context.Background(), []*entities.Transaction{transaction}))
+		}
+
+		balance, err := repo.Balance(context.Background(), "user123", &entities.BalanceFilter{})
+		assert.NoError(t, err)
+		assert.Equal(t, map[entities.Currency]entities.Balance{"BRL": {Ledger: 100}, "USD": {Ledger: 200}}, balance)
+
+		balance, err = repo.Balance(context.Background(), "user123", &entities.BalanceFilter{Currency: "USD"})
+		assert.NoError(t, err)
+		assert.Equal(t, map[entities.Currency]entities.Balance{"USD": {Ledger: 200}}, balance)
+	})
+}
+
+func Test_TransactionRepositoryImpl_Reverse(t *testing.T) {
+	db := setupDB(t)
+
+	repo := repositories.NewTransactionRepository(db)
+
+	original, errs := entities.NewTransaction("desktop-web", "user123", 1000, entities.CREDIT)
+	assert.Empty(t, errs)
+	assert.NoError(t, db.Create(original).Error)
+
+	build := func(amount int64) func(*entities.Transaction, []*entities.Transaction) (*entities.Transaction, []error) {
+		return func(original *entities.Transaction, reversals []*entities.Transaction) (*entities.Transaction, []error) {
+			return original.Reverse(amount, reversals)
+		}
+	}
+
+	t.Run("reversing part of a transaction", func(t *testing.T) {
+		reversal, errs := repo.Reverse(context.Background(), original.ID.String(), nil, build(400))
+		assert.Empty(t, errs)
+		assert.Equal(t, int64(-400), reversal.Amount)
+
+		found, err := repo.Find(context.Background(), reversal.ID.String())
+		assert.NoError(t, err)
+		assert.Equal(t, original.ID, *found.ReversalOf)
+	})
+
+	t.Run("reversing more than what is left", func(t *testing.T) {
+		reversal, errs := repo.Reverse(context.Background(), original.ID.String(), nil, build(700))
+		assert.Nil(t, reversal)
+		assert.ErrorIs(t, errs[0], entities.ErrReversalExceedsOriginal)
+	})
+
+	t.Run("reversing what is left", func(t *testing.T) {
+		reversal, errs := repo.Reverse(context.Background(), original.ID.String(), nil, build(0))
+		assert.Empty(t, errs)
+		assert.Equal(t, int64(-600), reversal.Amount)
+
+		reversals, err := repo.ListReversals(context.Background(), original.ID.String())
+		assert.NoError(t, err)
+		assert.Len(t, reversals, 2)
+	})
+
+	t.Run("reversing a transaction that does not exist", func(t *testing.T) {
+		reversal, errs := repo.Reverse(context.Background(), "non-existing-id", nil, build(0))
+		assert.Nil(t, reversal)
+		assert.Equal(t, "record not found", errs[0].Error())
+	})
+}
+
+func Test_TransactionRepositoryImpl_Reverse_Overdraft(t *testing.T) {
+	policy := entities.NewOverdraftPolicy(nil, map[string]int64{"user123": 0}, nil)
+
+	db := setupDB(t)
+	repo := repositories.NewTransactionRepository(db).WithBulkConfig(100, 3600)
+	// holds the transactions as the bulk buffer does, without committing them
+	go func() {
+		for range repo.InsertChan {
+		}
+	}()
+
+	credit, errs := entities.NewTransaction("desktop-web", "user123", 1000, entities.CREDIT)
+	assert.Empty(t, errs)
+	debit, errs := entities.NewTransaction("desktop-web", "user123", -700, entities.DEBIT)
+	assert.Empty(t, errs)
+	assert.NoError(t, repo.InsertMany(context.Background(), []*entities.Transaction{credit, debit}))
+
+	build := func(amount int64) func(*entities.Transaction, []*entities.Transaction) (*entities.Transaction, []error) {
+		return func(original *entities.Transaction, reversals []*entities.Transaction) (*entities.Transaction, []error) {
+			return original.Reverse(amount, reversals)
+		}
+	}
+
+	t.Run("reversing a credit beyond the overdraft limit", func(t *testing.T) {
+		reversal, errs := repo.Reverse(context.Background(), credit.ID.String(), policy, build(301))
+		assert.Nil(t, reversal)
+		assert.ErrorIs(t, errs[0], entities.ErrInsufficientFunds)
+
+		reversals, err := repo.ListReversals(context.Background(), credit.ID.String())
+		assert.NoError(t, err)
+		assert.Empty(t, reversals)
+	})
+
+	t.Run("counting the debits that are still in the bulk buffer", func(t *testing.T) {
+		pending, errs := entities.NewTransaction("desktop-web", "user123", -200, entities.DEBIT)
+		assert.Empty(t, errs)
+		_, err := repo.Insert(context.Background(), pending)
+		assert.NoError(t, err)
+
+		_, errs = repo.Reverse(context.Background(), credit.ID.String(), policy, build(101))
+		assert.ErrorIs(t, errs[0], entities.ErrInsufficientFunds)
+		reversal, errs := repo.Reverse(context.Background(), credit.ID.String(), policy, build(100))
+		assert.Empty(t, errs)
+		assert.Equal(t, int64(-100), reversal.Amount)
+	})
+
+	t.Run("reversing a debit whatever the balance", func(t *testing.T) {
+		reversal, errs := repo.Reverse(context.Background(), debit.ID.String(), policy, build(0))
+		assert.Empty(t, errs)
+		assert.Equal(t, int64(700), reversal.Amount)
+	})
+}
+
+func Test_TransactionRepositoryImpl_ListByCursor(t *testing.T) {
+	db := setupDB(t)
+
+	repo := repositories.NewTransactionRepository(db)
+
+	createdAt := time.Date(2023, 11, 20, 10, 0, 0, 0, time.UTC)
+	transactions := make([]*entities.Transaction, 5)
+	for i := range transactions {
+		origin := "desktop-web"
+		if i == 4 {
+			origin = "mobile-android"
+		}
+		transaction, errs := entities.NewTransaction(origin, "user123", 100, entities.CREDIT)
+		assert.Empty(t, errs)
+		transaction.CreatedAt = createdAt.Add(time.Duration(i) * time.Second)
+		assert.NoError(t, db.Create(transaction).Error)
+		transactions[i] = transaction
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	t.Run("listing the first transactions", func(t *testing.T) {
+		found, err := repo.ListByCursor(ctx, 2, nil, &entities.TransactionFilter{})
+		assert.NoError(t, err)
+		assert.Len(t, found, 2)
+		assert.Equal(t, transactions[0].ID, found[0].ID)
+		assert.Equal(t, transactions[1].ID, found[1].ID)
+	})
+
+	t.Run("listing the transactions after a cursor", func(t *testing.T) {
+		found, err := repo.ListByCursor(ctx, 2, entities.NewCursor(transactions[1], entities.SORT_CREATED_AT, false), &entities.TransactionFilter{})
+		assert.NoError(t, err)
+		assert.Len(t, found, 2)
+		assert.Equal(t, transactions[2].ID, found[0].ID)
+		assert.Equal(t, transactions[3].ID, found[1].ID)
+	})
+
+	t.Run("listing the transactions before a cursor", func(t *testing.T) {
+		found, err := repo.ListByCursor(ctx, 2, entities.NewCursor(transactions[3], entities.SORT_CREATED_AT, true), &entities.TransactionFilter{})
+		assert.NoError(t, err)
+		assert.Len(t, found, 2)
+		assert.Equal(t, transactions[1].ID, found[0].ID)
+		assert.Equal(t, transactions[2].ID, found[1].ID)
+	})
+
+	t.Run("listing the transactions after a cursor with filters", func(t *testing.T) {
+		found, err := repo.ListByCursor(ctx, 10, entities.NewCursor(transactions[2], entities.SORT_CREATED_AT, false), &entities.TransactionFilter{Origin: "desktop-web"})
+		assert.NoError(t, err)
+		assert.Len(t, found, 1)
+		assert.Equal(t, transactions[3].ID, found[0].ID)
+	})
+
+	t.Run("listing the transactions with the same creation time", func(t *testing.T) {
+		db := setupDB(t)
+		repo := repositories.NewTransactionRepository(db)
+
+		var ids []string
+		for i := 0; i < 3; i++ {
+			transaction, errs := entities.NewTransaction("desktop-web", "user123", 100, entities.CREDIT)
+			assert.Empty(t, errs)
+			transaction.CreatedAt = createdAt
+			assert.NoError(t, db.Create(transaction).Error)
+			ids = append(ids, transaction.ID.String())
+		}
+		sort.Strings(ids)
+
+		first, err := repo.ListByCursor(ctx, 2, nil, &entities.TransactionFilter{})
+		assert.NoError(t, err)
+		second, err := repo.ListByCursor(ctx, 2, entities.NewCursor(first[1], entities.SORT_CREATED_AT, false), &entities.TransactionFilter{})
+		assert.NoError(t, err)
+
+		assert.Equal(t, ids, []string{first[0].ID.String(), first[1].ID.String(), second[0].ID.String()})
+	})
+}
+
+func Test_TransactionRepositoryImpl_Count(t *testing.T) {
+	db := setupDB(t)
+
+	repo := repositories.NewTransactionRepository(db)
+
+	for _, origin := range []string{"desktop-web", "desktop-web", "mobile-android"} {
+		transaction, errs := entities.NewTransaction(origin, "user123", 100, entities.CREDIT)
+		assert.Empty(t, errs)
+		assert.NoError(t, db.Create(transaction).Error)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	count, err := repo.Count(ctx, &entities.TransactionFilter{})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), count)
+
+	count, err = repo.Count(ctx, &entities.TransactionFilter{Origin: "desktop-web", Sort: entities.SORT_AMOUNT})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+}
+
+func Test_TransactionRepositoryImpl_List_Filters(t *testing.T) {
+	db := setupDB(t)
+
+	repo := repositories.NewTransactionRepository(db)
+
+	createdAt := time.Date(2023, 11, 20, 10, 0, 0, 0, time.UTC)
+	amounts := []int64{300, -100, 500, -200, 100}
+	transactions := make([]*entities.Transaction, len(amounts))
+	for i, amount := range amounts {
+		opType := entities.CREDIT
+		if amount < 0 {
+			opType = entities.DEBIT
+		}
+		transaction, errs := entities.NewTransaction("desktop-web", "user123", amount, opType)
+		assert.Empty(t, errs)
+		transaction.CreatedAt = createdAt.Add(time.Duration(i) * time.Hour)
+		assert.NoError(t, db.Create(transaction).Error)
+		transactions[i] = transaction
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	ids := func(found []*entities.Transaction) (ids []uuid.UUID) {
+		for _, transaction := range found {
+			ids = append(ids, transaction.ID)
+		}
+		return
+	}
+
+	t.Run("listing transactions in a date range", func(t *testing.T) {
+		from, to := createdAt.Add(time.Hour), createdAt.Add(3*time.Hour)
+		found, err := repo.List(ctx, 10, 0, &entities.TransactionFilter{CreatedFrom: &from, CreatedTo: &to})
+		assert.NoError(t, err)
+		assert.Equal(t, []uuid.UUID{transactions[1].ID, transactions[2].ID}, ids(found))
+	})
+
+	t.Run("listing transactions in an amount range", func(t *testing.T) {
+		min, max := int64(-100), int64(300)
+		found, err := repo.List(ctx, 10, 0, &entities.TransactionFilter{MinAmount: &min, MaxAmount: &max})
+		assert.NoError(t, err)
+		assert.Equal(t, []uuid.UUID{transactions[0].ID, transactions[1].ID, transactions[4].ID}, ids(found))
+	})
+
+	t.Run("listing transactions sorted by amount", func(t *testing.T) {
+		found, err := repo.List(ctx, 10, 0, &entities.TransactionFilter{Sort: entities.SORT_AMOUNT})
+		assert.NoError(t, err)
+		assert.Equal(t, []uuid.UUID{transactions[3].ID, transactions[1].ID, transactions[4].ID, transactions[0].ID, transactions[2].ID}, ids(found))
+	})
+
+	t.Run("listing transactions sorted by the newest", func(t *testing.T) {
+		found, err := repo.List(ctx, 2, 0, &entities.TransactionFilter{Sort: entities.SORT_CREATED_AT_DESC})
+		assert.NoError(t, err)
+		assert.Equal(t, []uuid.UUID{transactions[4].ID, transactions[3].ID}, ids(found))
+	})
+
+	t.Run("listing transactions sorted by the highest amount with cursors", func(t *testing.T) {
+		filter := &entities.TransactionFilter{Sort: entities.SORT_AMOUNT_DESC}
+
+		found, err := repo.ListByCursor(ctx, 2, entities.NewCursor(transactions[0], filter.Sort, false), filter)
+		assert.NoError(t, err)
+		assert.Equal(t, []uuid.UUID{transactions[4].ID, transactions[1].ID}, ids(found))
+
+		found, err = repo.ListByCursor(ctx, 2, entities.NewCursor(transactions[4], filter.Sort, true), filter)
+		assert.NoError(t, err)
+		assert.Equal(t, []uuid.UUID{transactions[2].ID, transactions[0].ID}, ids(found))
+	})
+}
+
+func Test_TransactionRepositoryImpl_ReplaySpool(t *testing.T) {
+	db := setupDB(t)
+	dir := t.TempDir()
+
+	s, _, err := spool.Open(spool.Config{Dir: dir})
+	assert.NoError(t, err)
+
+	repo := repositories.NewTransactionRepository(db).WithBulkConfig(100, 3600).WithSpool(s)
+	// holds the transactions as the bulk buffer does, without committing them, as if the process crashed
+	go func() {
+		for range repo.InsertChan {
+		}
+	}()
+
+	var spooled []*entities.Transaction
+	for _, amount := range []int64{200, 300} {
+		transaction, errs := entities.NewTransaction("desktop-web", "user123", amount, entities.CREDIT)
+		assert.Empty(t, errs)
+		_, err := repo.Insert(context.Background(), transaction)
+		assert.NoError(t, err)
+		spooled = append(spooled, transaction)
+	}
+	// committed right before the crash, the replay must not duplicate it
+	assert.NoError(t, db.Create(spooled[0]).Error)
+	assert.NoError(t, s.Close())
+
+	s, entries, err := spool.Open(spool.Config{Dir: dir})
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+
+	replayRepo := repositories.NewTransactionRepository(db).WithSpool(s)
+	assert.NoError(t, replayRepo.ReplaySpool(entries))
+
+	var count int64
+	assert.NoError(t, db.Model(&entities.Transaction{}).Count(&count).Error)
+	assert.Equal(t, int64(2), count)
+
+	found, err := replayRepo.Find(context.Background(), spooled[1].ID.String())
+	assert.NoError(t, err)
+	assert.Equal(t, spooled[1].Amount, found.Amount)
+
+	// the replayed transactions were acknowledged, nothing is left for the next start
+	assert.NoError(t, s.Close())
+	_, entries, err = spool.Open(spool.Config{Dir: dir})
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func Test_TransactionRepositoryImpl_InsertMany(t *testing.T) {
+	db := setupDB(t)
+
+	repo := repositories.NewTransactionRepository(db)
+
+	first, errs := entities.NewTransaction("desktop-web", "user123", 200, entities.CREDIT)
+	assert.Empty(t, errs)
+	second, errs := entities.NewTransaction("desktop-web", "user123", -50, entities.DEBIT)
+	assert.Empty(t, errs)
+	assert.NoError(t, db.Create(first).Error)
+
+	// the transactions already inserted are skipped
+	assert.NoError(t, repo.InsertMany(context.Background(), []*entities.Transaction{first, second}))
+
+	var count int64
+	assert.NoError(t, db.Model(&entities.Transaction{}).Count(&count).Error)
+	assert.Equal(t, int64(2), count)
+}
+
+func Test_TransactionRepositoryImpl_InsertWithBalanceCheck(t *testing.T) {
+	policy := entities.NewOverdraftPolicy(nil, map[string]int64{"user123": 100}, nil)
+
+	newTransaction := func(amount int64, operation entities.OperationType) *entities.Transaction {
+		transaction, errs := entities.NewTransaction("desktop-web", "user123", amount, operation)
+		assert.Empty(t, errs)
+		return transaction
+	}
+	count := func(db *gorm.DB) int64 {
+		var count int64
+		assert.NoError(t, db.Model(&entities.Transaction{}).Count(&count).Error)
+		return count
+	}
+
+	t.Run("inserting the debits within the overdraft limit", func(t *testing.T) {
+		db := setupDB(t)
+		repo := repositories.NewTransactionRepository(db)
+		assert.NoError(t, repo.InsertMany(context.Background(), []*entities.Transaction{newTransaction(200, entities.CREDIT)}))
+
+		assert.NoError(t, repo.InsertWithBalanceCheck(context.Background(), []*entities.Transaction{newTransaction(-300, entities.DEBIT)}, policy))
+		assert.Equal(t, int64(2), count(db))
+	})
+
+	t.Run("inserting none of the transactions when a debit is beyond the limit", func(t *testing.T) {
+		db := setupDB(t)
+		repo := repositories.NewTransactionRepository(db)
+		assert.NoError(t, repo.InsertMany(context.Background(), []*entities.Transaction{newTransaction(200, entities.CREDIT)}))
+
+		err := repo.InsertWithBalanceCheck(context.Background(), []*entities.Transaction{
+			newTransaction(-250, entities.DEBIT),
+			newTransaction(-51, entities.DEBIT),
+		}, policy)
+		assert.ErrorIs(t, err, entities.ErrInsufficientFunds)
+		assert.Equal(t, int64(1), count(db))
+	})
+
+	t.Run("using the credits before the debit", func(t *testing.T) {
+		db := setupDB(t)
+		repo := repositories.NewTransactionRepository(db)
+
+		assert.NoError(t, repo.InsertWithBalanceCheck(context.Background(), []*entities.Transaction{
+			newTransaction(500, entities.CREDIT),
+			newTransaction(-600, entities.DEBIT),
+		}, policy))
+		assert.Equal(t, int64(2), count(db))
+
+		// the credit after the debit doesn't count
+		err := repo.InsertWithBalanceCheck(context.Background(), []*entities.Transaction{
+			newTransaction(-1, entities.DEBIT),
+			newTransaction(500, entities.CREDIT),
+		}, policy)
+		assert.ErrorIs(t, err, entities.ErrInsufficientFunds)
+	})
+
+	t.Run("counting the debits that are still in the bulk buffer", func(t *testing.T) {
+		db := setupDB(t)
+		repo := repositories.NewTransactionRepository(db).WithBulkConfig(100, 3600)
+		// holds the transactions as the bulk buffer does, without committing them
+		go func() {
+			for range repo.InsertChan {
+			}
+		}()
+
+		_, err := repo.Insert(context.Background(), newTransaction(-80, entities.DEBIT))
+		assert.NoError(t, err)
+
+		err = repo.InsertWithBalanceCheck(context.Background(), []*entities.Transaction{newTransaction(-30, entities.DEBIT)}, policy)
+		assert.ErrorIs(t, err, entities.ErrInsufficientFunds)
+		assert.NoError(t, repo.InsertWithBalanceCheck(context.Background(), []*entities.Transaction{newTransaction(-20, entities.DEBIT)}, policy))
+	})
+
+	t.Run("checking each currency on its own", func(t *testing.T) {
+		db := setupDB(t)
+		repo := repositories.NewTransactionRepository(db)
+		credit, errs := entities.NewTransaction("desktop-web", "user123", 1000, entities.CREDIT, entities.WithCurrency("USD"))
+		assert.Empty(t, errs)
+		assert.NoError(t, repo.InsertMany(context.Background(), []*entities.Transaction{credit}))
+
+		err := repo.InsertWithBalanceCheck(context.Background(), []*entities.Transaction{newTransaction(-101, entities.DEBIT)}, policy)
+		assert.ErrorIs(t, err, entities.ErrInsufficientFunds)
+	})
+}
+
+func Test_TransactionRepositoryImpl_CommitBulk_DeadLetter(t *testing.T) {
+	// the transactions table is missing, so every commit fails
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	t.Cleanup(func() {
+		sqlDB, _ := db.DB()
+		sqlDB.Close()
+	})
+
+	dir := t.TempDir()
+	s, _, err := spool.Open(spool.Config{Dir: dir})
+	assert.NoError(t, err)
+	defer s.Close()
+
+	sink := repositories.NewDeadLetterFile(filepath.Join(dir, "dead-letters.ndjson"))
+	repo := repositories.NewTransactionRepository(db).
+		WithBulkConfig(100, 3600).
+		WithSpool(s).
+		WithDeadLetter(sink, 10*time.Millisecond)
+	go func() {
+		for range repo.InsertChan {
+		}
+	}()
+
+	transaction, errs := entities.NewTransaction("desktop-web", "user123", 200, entities.CREDIT)
+	assert.Empty(t, errs)
+	_, err = repo.Insert(context.Background(), transaction)
+	assert.NoError(t, err)
+
+	repo.CommitWg.Add(1)
+	repo.CommitBulk(transaction)
+
+	batches, err := sink.List(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, batches, 1)
+	assert.Equal(t, 1, batches[0].Size)
+	assert.Contains(t, batches[0].LastError, "no such table")
+
+	transactions, err := batches[0].Transactions()
+	assert.NoError(t, err)
+	assert.Equal(t, transaction.ID, transactions[0].ID)
+
+	// the dead-lettered transactions are not replayed from the spool anymore
+	assert.NoError(t, s.Close())
+	_, entries, err := spool.Open(spool.Config{Dir: dir})
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func Test_TransactionRepositoryImpl_ReadYourWrites(t *testing.T) {
+	db := setupDB(t)
+
+	repo := repositories.NewTransactionRepository(db).WithBulkConfig(100, 3600)
+	// holds the transactions as the bulk buffer does, without committing them
+	go func() {
+		for range repo.InsertChan {
+		}
+	}()
+
+	// committed and pending transactions are interleaved, the merged order is by minute
+	start := time.Now().UTC().Add(-time.Hour)
+	byMinute := map[int]*entities.Transaction{}
+	for _, minute := range []int{1, 2, 3, 5, 6, 7, 8} {
+		transaction, errs := entities.NewTransaction("desktop-web", "user123", int64(minute*100), entities.CREDIT)
+		assert.Empty(t, errs)
+		transaction.CreatedAt = start.Add(time.Duration(minute) * time.Minute)
+		byMinute[minute] = transaction
+	}
+	for _, minute := range []int{1, 3, 5, 7} {
+		assert.NoError(t, db.Create(byMinute[minute]).Error)
+	}
+	for _, minute := range []int{2, 6, 8} {
+		_, err := repo.Insert(context.Background(), byMinute[minute])
+		assert.NoError(t, err)
+	}
+	// committed right now, but still in the pending index
+	assert.NoError(t, db.Create(byMinute[6]).Error)
+
+	ids := func(transactions []*entities.Transaction) []string {
+		var result []string
+		for _, transaction := range transactions {
+			result = append(result, transaction.ID.String())
+		}
+		return result
+	}
+	expected := func(minutes ...int) []string {
+		var result []string
+		for _, minute := range minutes {
+			result = append(result, byMinute[minute].ID.String())
+		}
+		return result
+	}
+
+	t.Run("finding a pending transaction", func(t *testing.T) {
+		found, err := repo.Find(context.Background(), byMinute[2].ID.String())
+		assert.NoError(t, err)
+		assert.Equal(t, byMinute[2].Amount, found.Amount)
+		assert.Equal(t, entities.COMMIT_PENDING, found.CommitStatus())
+
+		found, err = repo.Find(context.Background(), byMinute[1].ID.String())
+		assert.NoError(t, err)
+		assert.Equal(t, entities.COMMITTED, found.CommitStatus())
+	})
+
+	t.Run("listing pages with the pending transactions in place", func(t *testing.T) {
+		filter := &entities.TransactionFilter{Sort: entities.SORT_CREATED_AT}
+		pages := [][]int{{1, 2}, {3, 5}, {6, 7}, {8}, nil}
+		for i, page := range pages {
+			transactions, err := repo.List(context.Background(), 2, i*2, filter)
+			assert.NoError(t, err)
+			assert.Equal(t, expected(page...), ids(transactions), "page %d", i)
+		}
+
+		filter = &entities.TransactionFilter{Sort: entities.SORT_AMOUNT_DESC}
+		transactions, err := repo.List(context.Background(), 3, 2, filter)
+		assert.NoError(t, err)
+		assert.Equal(t, expected(6, 5, 3), ids(transactions))
+	})
+
+	t.Run("listing a page after the pending transactions with a single query", func(t *testing.T) {
+		queries := 0
+		assert.NoError(t, db.Callback().Query().Before("gorm:query").Register("count_queries", func(*gorm.DB) { queries++ }))
+		defer db.Callback().Query().Remove("count_queries")
+
+		// the pending transaction of minute 2 is before the committed ones read for the page
+		transactions, err := repo.List(context.Background(), 2, 4, &entities.TransactionFilter{Sort: entities.SORT_CREATED_AT})
+		assert.NoError(t, err)
+		assert.Equal(t, expected(6, 7), ids(transactions))
+		assert.Equal(t, 1, queries)
+	})
+
+	t.Run("listing pages by cursor with the pending transactions in place", func(t *testing.T) {
+		filter := &entities.TransactionFilter{Sort: entities.SORT_CREATED_AT}
+		transactions, err := repo.ListByCursor(context.Background(), 3, nil, filter)
+		assert.NoError(t, err)
+		assert.Equal(t, expected(1, 2, 3), ids(transactions))
+
+		cursor := entities.NewCursor(transactions[2], filter.Sort, false)
+		transactions, err = repo.ListByCursor(context.Background(), 3, cursor, filter)
+		assert.NoError(t, err)
+		assert.Equal(t, expected(5, 6, 7), ids(transactions))
+
+		cursor = entities.NewCursor(transactions[0], filter.Sort, true)
+		transactions, err = repo.ListByCursor(context.Background(), 2, cursor, filter)
+		assert.NoError(t, err)
+		assert.Equal(t, expected(2, 3), ids(transactions))
+	})
+
+	t.Run("counting the pending transactions once", func(t *testing.T) {
+		count, err := repo.Count(context.Background(), &entities.TransactionFilter{})
+		assert.NoError(t, err)
+		assert.Equal(t, int64(7), count)
+
+		minAmount := int64(500)
+		count, err = repo.Count(context.Background(), &entities.TransactionFilter{MinAmount: &minAmount})
+		assert.NoError(t, err)
+		assert.Equal(t, int64(4), count)
+	})
+}
+
+func Test_TransactionRepositoryImpl_Stream(t *testing.T) {
+	db := setupDB(t)
+
+	repo := repositories.NewTransactionRepository(db)
+
+	for _, amount := range []int64{300, 100, 200} {
+		transaction, errs := entities.NewTransaction("desktop-web", "user123", amount, entities.CREDIT)
+		assert.Empty(t, errs)
+		assert.NoError(t, db.Create(transaction).Error)
+	}
+	other, errs := entities.NewTransaction("mobile-android", "user123", 50, entities.CREDIT)
+	assert.Empty(t, errs)
+	assert.NoError(t, db.Create(other).Error)
+
+	t.Run("streaming the transactions of the filter in order", func(t *testing.T) {
+		var amounts []int64
+		err := repo.Stream(context.Background(), &entities.TransactionFilter{Origin: "desktop-web", Sort: entities.SORT_AMOUNT_DESC}, func(transaction *entities.Transaction) error {
+			amounts = append(amounts, transaction.Amount)
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, []int64{300, 200, 100}, amounts)
+	})
+
+	t.Run("stopping at the first error", func(t *testing.T) {
+		errStop := errors.New("client disconnected")
+		read := 0
+		err := repo.Stream(context.Background(), &entities.TransactionFilter{Sort: entities.SORT_CREATED_AT}, func(transaction *entities.Transaction) error {
+			read++
+			return errStop
+		})
+		assert.ErrorIs(t, err, errStop)
+		assert.Equal(t, 1, read)
+	})
+
+	t.Run("stopping when the context is canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		read := 0
+		err := repo.Stream(ctx, &entities.TransactionFilter{Sort: entities.SORT_CREATED_AT}, func(transaction *entities.Transaction) error {
+			read++
+			cancel()
+			return nil
+		})
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Less(t, read, 4)
+	})
+}
+
+func Test_TransactionRepositoryImpl_Holds(t *testing.T) {
+	db := setupDB(t)
+
+	repo := repositories.NewTransactionRepository(db)
+	balanceRepo := repositories.NewBalanceRepository(db)
+	ctx := context.Background()
+	now := time.Now()
+
+	newHold := func(amount int64, expiresAt time.Time) *entities.Transaction {
+		transaction, errs := entities.NewTransaction("desktop-web", "user123", amount, entities.DEBIT, entities.WithHold(expiresAt))
+		assert.Empty(t, errs)
+		return transaction
+	}
+	balance := func() entities.Balance {
+		balances, err := repo.Balance(ctx, "user123", &entities.BalanceFilter{})
+		assert.NoError(t, err)
+		return balances[entities.DEFAULT_CURRENCY]
+	}
+
+	credit, errs := entities.NewTransaction("desktop-web", "user123", 1000, entities.CREDIT)
+	assert.Empty(t, errs)
+	captured := newHold(-300, now.Add(time.Hour))
+	voided := newHold(-200, now.Add(time.Hour))
+	expired := newHold(-100, now.Add(time.Minute))
+	assert.NoError(t, repo.InsertMany(ctx, []*entities.Transaction{credit, captured, voided, expired}))
+
+	t.Run("holding the amount out of the available balance", func(t *testing.T) {
+		assert.Equal(t, entities.Balance{Ledger: 1000, Held: 600}, balance())
+	})
+
+	t.Run("capturing a hold into the ledger balance", func(t *testing.T) {
+		transaction, err := repo.UpdateStatus(ctx, captured.ID.String(), func(transaction *entities.Transaction) error {
+			return transaction.Capture(now)
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, entities.STATUS_POSTED, transaction.Status)
+		assert.Equal(t, entities.Balance{Ledger: 700, Held: 300}, balance())
+	})
+
+	t.Run("voiding a hold", func(t *testing.T) {
+		_, err := repo.UpdateStatus(ctx, voided.ID.String(), func(transaction *entities.Transaction) error {
+			return transaction.Void()
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, entities.Balance{Ledger: 700, Held: 100}, balance())
+	})
+
+	t.Run("not changing the status when the update fails", func(t *testing.T) {
+		_, err := repo.UpdateStatus(ctx, voided.ID.String(), func(transaction *entities.Transaction) error {
+			return transaction.Capture(now)
+		})
+		assert.ErrorIs(t, err, entities.ErrNotPending)
+
+		found, err := repo.Find(ctx, voided.ID.String())
+		assert.NoError(t, err)
+		assert.Equal(t, entities.STATUS_VOIDED, found.Status)
+	})
+
+	t.Run("voiding the expired holds", func(t *testing.T) {
+		count, err := repo.ExpireHolds(ctx, now, 10)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(0), count)
+
+		count, err = repo.ExpireHolds(ctx, now.Add(2*time.Minute), 10)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), count)
+
+		found, err := repo.Find(ctx, expired.ID.String())
+		assert.NoError(t, err)
+		assert.Equal(t, entities.STATUS_VOIDED, found.Status)
+		assert.Equal(t, entities.Balance{Ledger: 700}, balance())
+	})
+
+	t.Run("summing the same balance from the transactions", func(t *testing.T) {
+		asOf := time.Now().Add(time.Hour)
+		balances, err := repo.Balance(ctx, "user123", &entities.BalanceFilter{AsOf: &asOf})
+		assert.NoError(t, err)
+		assert.Equal(t, entities.Balance{Ledger: 700}, balances[entities.DEFAULT_CURRENCY])
+
+		drifts, err := balanceRepo.Drift(ctx)
+		assert.NoError(t, err)
+		assert.Empty(t, drifts)
+	})
+
+	t.Run("listing the transactions by status", func(t *testing.T) {
+		transactions, err := repo.List(ctx, 10, 0, &entities.TransactionFilter{Status: entities.STATUS_VOIDED, Sort: entities.SORT_AMOUNT})
+		assert.NoError(t, err)
+		assert.Len(t, transactions, 2)
+		assert.Equal(t, voided.ID, transactions[0].ID)
+		assert.Equal(t, expired.ID, transactions[1].ID)
+	})
+}
+
+func Test_TransactionRepositoryImpl_ExternalReference(t *testing.T) {
+	db := setupDB(t)
+
+	repo := repositories.NewTransactionRepository(db)
+	ctx := context.Background()
+
+	newTransaction := func(origin, reference string, metadata map[string]string) *entities.Transaction {
+		transaction, errs := entities.NewTransaction(origin, "user123", 100, entities.CREDIT, entities.WithExternalReference(reference), entities.WithMetadata(metadata))
+		assert.Empty(t, errs)
+		return transaction
+	}
+
+	first := newTransaction("desktop-web", "order-123", map[string]string{"order_id": "123", "channel": "web"})
+	assert.NoError(t, repo.InsertMany(ctx, []*entities.Transaction{first}))
+
+	t.Run("storing the metadata and the external reference", func(t *testing.T) {
+		found, err := repo.Find(ctx, first.ID.String())
+		assert.NoError(t, err)
+		assert.Equal(t, "order-123", *found.ExternalReference)
+		assert.Equal(t, entities.Metadata{"order_id": "123", "channel": "web"}, found.Metadata)
+	})
+
+	t.Run("inserting the same transaction again", func(t *testing.T) {
+		assert.NoError(t, repo.InsertMany(ctx, []*entities.Transaction{first}))
+	})
+
+	t.Run("inserting a duplicate external reference of the origin", func(t *testing.T) {
+		err := repo.InsertMany(ctx, []*entities.Transaction{newTransaction("desktop-web", "order-456", nil), newTransaction("desktop-web", "order-123", nil)})
+		assert.ErrorIs(t, err, entities.ErrDuplicateExternalReference)
+
+		count, err := repo.Count(ctx, &entities.TransactionFilter{})
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), count)
+	})
+
+	t.Run("inserting a duplicate external reference in the same insert", func(t *testing.T) {
+		err := repo.InsertMany(ctx, []*entities.Transaction{newTransaction("desktop-web", "order-789", nil), newTransaction("desktop-web", "order-789", nil)})
+		assert.ErrorIs(t, err, entities.ErrDuplicateExternalReference)
+	})
+
+	t.Run("inserting the same external reference for another origin", func(t *testing.T) {
+		assert.NoError(t, repo.InsertMany(ctx, []*entities.Transaction{newTransaction("mobile-android", "order-123", map[string]string{"order_id": "456"})}))
+	})
+
+	t.Run("listing transactions by metadata", func(t *testing.T) {
+		transactions, err := repo.List(ctx, 10, 0, &entities.TransactionFilter{Metadata: map[string]string{"order_id": "123", "channel": "web"}, Sort: entities.SORT_CREATED_AT})
+		assert.NoError(t, err)
+		assert.Len(t, transactions, 1)
+		assert.Equal(t, first.ID, transactions[0].ID)
+
+		transactions, err = repo.List(ctx, 10, 0, &entities.TransactionFilter{Metadata: map[string]string{"channel": "mobile"}, Sort: entities.SORT_CREATED_AT})
+		assert.NoError(t, err)
+		assert.Empty(t, transactions)
+	})
+}
+
+func Test_TransactionRepositoryImpl_Summarize(t *testing.T) {
+	db := setupDB(t)
+
+	repo := repositories.NewTransactionRepository(db)
+	ctx := context.Background()
+
+	newTransaction := func(origin string, amount int64, currency entities.Currency, createdAt time.Time) *entities.Transaction {
+		opType := entities.CREDIT
+		if amount < 0 {
+			opType = entities.DEBIT
+		}
+		transaction, errs := entities.NewTransaction(origin, "user123", amount, opType, entities.WithCurrency(currency))
+		assert.Empty(t, errs)
+		transaction.CreatedAt = createdAt
+		return transaction
+	}
+
+	// Sunday 19 and Monday 20 of November, Friday 1 of December
+	sunday := time.Date(2023, 11, 19, 23, 0, 0, 0, time.UTC)
+	monday := time.Date(2023, 11, 20, 8, 0, 0, 0, time.UTC)
+	december := time.Date(2023, 12, 1, 12, 0, 0, 0, time.UTC)
+	assert.NoError(t, repo.InsertMany(ctx, []*entities.Transaction{
+		newTransaction("desktop-web", 500, "BRL", sunday),
+		newTransaction("desktop-web", -200, "BRL", monday),
+		newTransaction("mobile-android", 100, "BRL", monday),
+		newTransaction("desktop-web", 300, "USD", monday),
+		newTransaction("desktop-web", 50, "BRL", december),
+	}))
+
+	// a transaction still in the bulk buffer is added to its group
+	repo.WithBulkConfig(100, 60)
+	_, err := repo.Insert(ctx, newTransaction("mobile-android", 25, "BRL", monday))
+	assert.NoError(t, err)
+
+	t.Run("summarizing by currency", func(t *testing.T) {
+		summaries, err := repo.Summarize(ctx, &entities.TransactionFilter{Sort: entities.SORT_CREATED_AT}, nil, "")
+		assert.NoError(t, err)
+		assert.Equal(t, []*entities.TransactionSummary{
+			{Currency: "BRL", Count: 5, Total: 475, Credits: 675, Debits: -200},
+			{Currency: "USD", Count: 1, Total: 300, Credits: 300},
+		}, summaries)
+	})
+
+	t.Run("summarizing by week and origin", func(t *testing.T) {
+		filter := &entities.TransactionFilter{Currency: "BRL", Sort: entities.SORT_CREATED_AT}
+		summaries, err := repo.Summarize(ctx, filter, []entities.SummaryGroup{entities.GROUP_ORIGIN}, entities.INTERVAL_WEEK)
+		assert.NoError(t, err)
+
+		weekOf13 := time.Date(2023, 11, 13, 0, 0, 0, 0, time.UTC)
+		weekOf20 := time.Date(2023, 11, 20, 0, 0, 0, 0, time.UTC)
+		weekOf27 := time.Date(2023, 11, 27, 0, 0, 0, 0, time.UTC)
+		assert.Equal(t, []*entities.TransactionSummary{
+			{Period: &weekOf13, Origin: "desktop-web", Currency: "BRL", Count: 1, Total: 500, Credits: 500},
+			{Period: &weekOf20, Origin: "desktop-web", Currency: "BRL", Count: 1, Total: -200, Debits: -200},
+			{Period: &weekOf20, Origin: "mobile-android", Currency: "BRL", Count: 2, Total: 125, Credits: 125},
+			{Period: &weekOf27, Origin: "desktop-web", Currency: "BRL", Count: 1, Total: 50, Credits: 50},
+		}, summaries)
+	})
+
+	t.Run("summarizing by day and month", func(t *testing.T) {
+		filter := &entities.TransactionFilter{Origin: "desktop-web", Currency: "BRL", Sort: entities.SORT_CREATED_AT}
+		days, err := repo.Summarize(ctx, filter, nil, entities.INTERVAL_DAY)
+		assert.NoError(t, err)
+		assert.Len(t, days, 3)
+		assert.Equal(t, time.Date(2023, 11, 19, 0, 0, 0, 0, time.UTC), *days[0].Period)
+
+		months, err := repo.Summarize(ctx, filter, []entities.SummaryGroup{entities.GROUP_TYPE}, entities.INTERVAL_MONTH)
+		assert.NoError(t, err)
+		november := time.Date(2023, 11, 1, 0, 0, 0, 0, time.UTC)
+		assert.Equal(t, []*entities.TransactionSummary{
+			{Period: &november, Type: entities.CREDIT, Currency: "BRL", Count: 1, Total: 500, Credits: 500},
+			{Period: &november, Type: entities.DEBIT, Currency: "BRL", Count: 1, Total: -200, Debits: -200},
+			{Period: days[2].Period, Type: entities.CREDIT, Currency: "BRL", Count: 1, Total: 50, Credits: 50},
+		}, months)
+	})
+	t.Run("summarizing the posted transactions unless filtered by status", func(t *testing.T) {
+		newHold := func(amount int64) *entities.Transaction {
+			hold, errs := entities.NewTransaction("desktop-web", "user123", amount, entities.DEBIT,
+				entities.WithCurrency("EUR"), entities.WithHold(time.Now().Add(time.Hour)))
+			assert.Empty(t, errs)
+			return hold
+		}
+		posted := newTransaction("desktop-web", 1000, "EUR", monday)
+		pending, voided := newHold(-300), newHold(-400)
+		assert.NoError(t, voided.Void())
+		assert.NoError(t, repo.InsertMany(ctx, []*entities.Transaction{posted, pending, voided}))
+
+		summaries, err := repo.Summarize(ctx, &entities.TransactionFilter{Currency: "EUR", Sort: entities.SORT_CREATED_AT}, nil, "")
+		assert.NoError(t, err)
+		assert.Equal(t, []*entities.TransactionSummary{
+			{Currency: "EUR", Count: 1, Total: 1000, Credits: 1000},
+		}, summaries)
+
+		for status, hold := range map[entities.TransactionStatus]*entities.Transaction{entities.STATUS_PENDING: pending, entities.STATUS_VOIDED: voided} {
+			filter := &entities.TransactionFilter{Currency: "EUR", Status: status, Sort: entities.SORT_CREATED_AT}
+			summaries, err := repo.Summarize(ctx, filter, nil, "")
+			assert.NoError(t, err)
+			assert.Equal(t, []*entities.TransactionSummary{
+				{Currency: "EUR", Count: 1, Total: hold.Amount, Debits: hold.Amount},
+			}, summaries)
+		}
+	})
+}
@@ -0,0 +1,174 @@
+package repositories
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"user-transactions/core/entities"
+)
+
+// summaryRow is a group of transactions totaled by the database, the period is read as a RFC 3339 string
+// since SQLite has no date type.
+type summaryRow struct {
+	Period   *string
+	Origin   string
+	UserID   string
+	Type     entities.OperationType
+	Currency entities.Currency
+	Count    int64
+	Total    int64
+	Credits  int64
+	Debits   int64
+}
+
+// Summarize totals the committed transactions in SQL and adds the ones still in the bulk buffer to their groups,
+// so the totals match what List returns. The amounts of different currencies are never added up.
+// Only the posted transactions are totaled unless the filter has another status, the pending and the voided holds
+// aren't in the balances.
+func (r *TransactionRepository) Summarize(ctx context.Context, filter *entities.TransactionFilter, groupBy []entities.SummaryGroup, interval entities.SummaryInterval) ([]*entities.TransactionSummary, error) {
+	if filter.Status == "" {
+		posted := *filter
+		posted.Status = entities.STATUS_POSTED
+		filter = &posted
+	}
+	pending := r.pendingTransactions(filter)
+
+	query := filterTransactions(r.Db.WithContext(ctx).Model(&entities.Transaction{}), filter)
+	if len(pending) > 0 {
+		query = query.Where("id NOT IN ?", transactionIDs(pending))
+	}
+
+	// the groups were validated, only their column names are in the statement
+	columns := []string{"currency"}
+	for _, group := range groupBy {
+		columns = append(columns, string(group))
+	}
+	groups := strings.Join(columns, ", ")
+	selected := groups
+	if interval != "" {
+		// both dialects group by the alias of the period
+		selected += ", " + periodColumn(r.Db.Dialector.Name(), interval)
+		groups += ", period"
+	}
+
+	var rows []summaryRow
+	err := query.
+		Select(selected + `, COUNT(*) AS count, COALESCE(SUM(amount), 0) AS total,
+			COALESCE(SUM(CASE WHEN amount > 0 THEN amount ELSE 0 END), 0) AS credits,
+			COALESCE(SUM(CASE WHEN amount < 0 THEN amount ELSE 0 END), 0) AS debits`).
+		Group(groups).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	summaries := make(map[summaryGroup]*entities.TransactionSummary, len(rows))
+	for _, row := range rows {
+		summary := &entities.TransactionSummary{
+			Origin:   row.Origin,
+			UserID:   row.UserID,
+			Type:     row.Type,
+			Currency: row.Currency,
+			Count:    row.Count,
+			Total:    row.Total,
+			Credits:  row.Credits,
+			Debits:   row.Debits,
+		}
+		if row.Period != nil {
+			period, err := time.Parse(time.RFC3339, *row.Period)
+			if err != nil {
+				return nil, fmt.Errorf("error reading the period of a summary: %w", err)
+			}
+			summary.Period = &period
+		}
+		summaries[summaryKey(summary)] = summary
+	}
+
+	for _, transaction := range pending {
+		group := &entities.TransactionSummary{Currency: transaction.Currency}
+		if interval != "" {
+			period := interval.Start(transaction.CreatedAt)
+			group.Period = &period
+		}
+		for _, column := range groupBy {
+			switch column {
+			case entities.GROUP_ORIGIN:
+				group.Origin = transaction.Origin
+			case entities.GROUP_USER_ID:
+				group.UserID = transaction.UserID
+			case entities.GROUP_TYPE:
+				group.Type = transaction.Type
+			}
+		}
+
+		key := summaryKey(group)
+		if summary, ok := summaries[key]; ok {
+			group = summary
+		} else {
+			summaries[key] = group
+		}
+		group.Add(transaction)
+	}
+
+	res := make([]*entities.TransactionSummary, 0, len(summaries))
+	for _, summary := range summaries {
+		res = append(res, summary)
+	}
+	slices.SortFunc(res, compareSummaries)
+	return res, nil
+}
+
+// periodColumn buckets created_at by the start of the interval in UTC, as a RFC 3339 string.
+func periodColumn(dialect string, interval entities.SummaryInterval) string {
+	if dialect == "postgres" {
+		return fmt.Sprintf(`to_char(date_trunc('%s', created_at AT TIME ZONE 'UTC'), 'YYYY-MM-DD"T"HH24:MI:SS"Z"') AS period`, interval)
+	}
+
+	switch interval {
+	case entities.INTERVAL_WEEK:
+		// the next Sunday, or the same day on Sundays, minus 6 days is the Monday starting the week
+		return `strftime('%Y-%m-%dT00:00:00Z', created_at, 'weekday 0', '-6 days') AS period`
+	case entities.INTERVAL_MONTH:
+		return `strftime('%Y-%m-01T00:00:00Z', created_at) AS period`
+	}
+	return `strftime('%Y-%m-%dT00:00:00Z', created_at) AS period`
+}
+
+// summaryGroup identifies the group of a summary, the period is the start of the interval in Unix seconds.
+type summaryGroup struct {
+	period   int64
+	origin   string
+	userId   string
+	opType   entities.OperationType
+	currency entities.Currency
+}
+
+func summaryKey(summary *entities.TransactionSummary) summaryGroup {
+	key := summaryGroup{origin: summary.Origin, userId: summary.UserID, opType: summary.Type, currency: summary.Currency}
+	if summary.Period != nil {
+		key.period = summary.Period.Unix()
+	}
+	return key
+}
+
+// compareSummaries orders the summaries by period, then by the groups and the currency.
+func compareSummaries(a, b *entities.TransactionSummary) int {
+	keyA, keyB := summaryKey(a), summaryKey(b)
+	if c := cmp.Compare(keyA.period, keyB.period); c != 0 {
+		return c
+	}
+	for _, c := range []int{
+		strings.Compare(keyA.origin, keyB.origin),
+		strings.Compare(keyA.userId, keyB.userId),
+		strings.Compare(string(keyA.opType), string(keyB.opType)),
+		strings.Compare(string(keyA.currency), string(keyB.currency)),
+	} {
+		if c != 0 {
+			return c
+		}
+	}
+	return 0
+}
@@ -0,0 +1,79 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"user-transactions/core/entities"
+
+	"gorm.io/gorm"
+)
+
+var errPartialTransfer = errors.New("a transaction of the transfer was already inserted without the other one")
+
+// TransferRepository keeps the transfers as their pair of transactions in the transactions table.
+type TransferRepository struct {
+	Db           *gorm.DB
+	Transactions *TransactionRepository // its bulk buffer has the debits not committed yet, when any
+}
+
+func NewTransferRepository(db *gorm.DB) *TransferRepository {
+	return &TransferRepository{
+		Db: db,
+	}
+}
+
+// WithTransactions counts the debits still in the bulk buffer of the transaction repository in the balance checks.
+func (r *TransferRepository) WithTransactions(transactions *TransactionRepository) *TransferRepository {
+	r.Transactions = transactions
+
+	return r
+}
+
+func (r *TransferRepository) Insert(ctx context.Context, transfer *entities.Transfer) error {
+	legs := []*entities.Transaction{transfer.Debit, transfer.Credit}
+	return r.Db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return insertLegs(tx, legs)
+	})
+}
+
+// InsertWithBalanceCheck inserts both legs of the transfer once the debit is checked against the balance of the sender,
+// with the debits of the sender still in the bulk buffer.
+func (r *TransferRepository) InsertWithBalanceCheck(ctx context.Context, transfer *entities.Transfer, policy *entities.OverdraftPolicy) error {
+	legs := []*entities.Transaction{transfer.Debit, transfer.Credit}
+	return r.Db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := checkOverdraft(tx, legs, policy, r.pendingDebits); err != nil {
+			return err
+		}
+		return insertLegs(tx, legs)
+	})
+}
+
+func (r *TransferRepository) pendingDebits(a account) int64 {
+	if r.Transactions == nil {
+		return 0
+	}
+	return r.Transactions.pendingDebits(a)
+}
+
+// insertLegs inserts the legs as insertNew does, a retried transfer is skipped but never only one of its legs.
+func insertLegs(tx *gorm.DB, legs []*entities.Transaction) error {
+	var inserted int64
+	if err := tx.Model(&entities.Transaction{}).Where("id IN ?", transactionIDs(legs)).Count(&inserted).Error; err != nil {
+		return err
+	}
+	if inserted > 0 && inserted < int64(len(legs)) {
+		return errPartialTransfer
+	}
+	return insertNew(tx, legs)
+}
+
+func (r *TransferRepository) Find(ctx context.Context, id string) (*entities.Transfer, error) {
+	var legs []*entities.Transaction
+	if err := r.Db.WithContext(ctx).Where("transfer_id = ?", id).Find(&legs).Error; err != nil {
+		return nil, databaseError(err)
+	}
+	if len(legs) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return entities.NewTransferFromLegs(legs)
+}
@@ -0,0 +1,130 @@
+//go:build integration
+// +build integration
+
+package repositories_test
+
+import (
+	"context"
+	"testing"
+	"user-transactions/core/entities"
+	"user-transactions/infrastructure/repositories"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func Test_TransferRepositoryImpl(t *testing.T) {
+	db := setupDB(t)
+
+	repo := repositories.NewTransferRepository(db)
+	ctx := context.Background()
+
+	t.Run("inserting and finding a transfer", func(t *testing.T) {
+		transfer, errs := entities.NewTransfer("desktop-web", "user123", "user456", 500)
+		assert.Empty(t, errs)
+
+		assert.NoError(t, repo.Insert(ctx, transfer))
+
+		found, err := repo.Find(ctx, transfer.ID.String())
+		assert.NoError(t, err)
+		assert.Equal(t, transfer.ID, found.ID)
+		assert.Equal(t, transfer.Debit.ID, found.Debit.ID)
+		assert.Equal(t, int64(-500), found.Debit.Amount)
+		assert.Equal(t, transfer.Credit.ID, found.Credit.ID)
+		assert.Equal(t, int64(500), found.Credit.Amount)
+	})
+
+	t.Run("inserting a retried transfer once", func(t *testing.T) {
+		transfer, errs := entities.NewTransfer("desktop-web", "user321", "user654", 500)
+		assert.Empty(t, errs)
+
+		assert.NoError(t, repo.Insert(ctx, transfer))
+		assert.NoError(t, repo.Insert(ctx, transfer))
+
+		var count int64
+		assert.NoError(t, db.Model(&entities.Transaction{}).Where("transfer_id = ?", transfer.ID).Count(&count).Error)
+		assert.Equal(t, int64(2), count)
+		var balance entities.UserBalance
+		assert.NoError(t, db.Where("user_id = ?", "user654").First(&balance).Error)
+		assert.Equal(t, int64(500), balance.Balance)
+	})
+
+	t.Run("inserting none of the legs when one fails", func(t *testing.T) {
+		transfer, errs := entities.NewTransfer("desktop-web", "user123", "user456", 500)
+		assert.Empty(t, errs)
+
+		// the credit leg conflicts with a transaction already stored
+		existing, errs := entities.NewTransaction("desktop-web", "user456", 100, entities.CREDIT)
+		assert.Empty(t, errs)
+		assert.NoError(t, db.Create(existing).Error)
+		transfer.Credit.ID = existing.ID
+
+		assert.Error(t, repo.Insert(ctx, transfer))
+
+		var count int64
+		assert.NoError(t, db.Model(&entities.Transaction{}).Where("transfer_id = ?", transfer.ID).Count(&count).Error)
+		assert.Equal(t, int64(0), count)
+		assert.NoError(t, db.Model(&entities.Transaction{}).Where("id = ?", transfer.Debit.ID).Count(&count).Error)
+		assert.Equal(t, int64(0), count)
+	})
+
+	t.Run("inserting none of the legs when the sender has no funds", func(t *testing.T) {
+		policy := entities.NewOverdraftPolicy(nil, map[string]int64{"user789": 0}, nil)
+		transfer, errs := entities.NewTransfer("desktop-web", "user789", "user456", 500)
+		assert.Empty(t, errs)
+
+		assert.ErrorIs(t, repo.InsertWithBalanceCheck(ctx, transfer, policy), entities.ErrInsufficientFunds)
+
+		var count int64
+		assert.NoError(t, db.Model(&entities.Transaction{}).Where("transfer_id = ?", transfer.ID).Count(&count).Error)
+		assert.Equal(t, int64(0), count)
+
+		credit, errs := entities.NewTransaction("desktop-web", "user789", 500, entities.CREDIT)
+		assert.Empty(t, errs)
+		assert.NoError(t, repositories.NewTransactionRepository(db).InsertMany(ctx, []*entities.Transaction{credit}))
+
+		assert.NoError(t, repo.InsertWithBalanceCheck(ctx, transfer, policy))
+		found, err := repo.Find(ctx, transfer.ID.String())
+		assert.NoError(t, err)
+		assert.Equal(t, int64(-500), found.Debit.Amount)
+	})
+
+	t.Run("counting the debits of the sender that are still in the bulk buffer", func(t *testing.T) {
+		transactionRepo := repositories.NewTransactionRepository(db).WithBulkConfig(100, 3600)
+		// holds the transactions as the bulk buffer does, without committing them
+		go func() {
+			for range transactionRepo.InsertChan {
+			}
+		}()
+		repo := repositories.NewTransferRepository(db).WithTransactions(transactionRepo)
+		policy := entities.NewOverdraftPolicy(nil, map[string]int64{"user999": 0}, nil)
+
+		credit, errs := entities.NewTransaction("desktop-web", "user999", 500, entities.CREDIT)
+		assert.Empty(t, errs)
+		assert.NoError(t, transactionRepo.InsertMany(ctx, []*entities.Transaction{credit}))
+		debit, errs := entities.NewTransaction("desktop-web", "user999", -300, entities.DEBIT)
+		assert.Empty(t, errs)
+		_, err := transactionRepo.Insert(ctx, debit)
+		assert.NoError(t, err)
+
+		transfer, errs := entities.NewTransfer("desktop-web", "user999", "user456", 201)
+		assert.Empty(t, errs)
+		assert.ErrorIs(t, repo.InsertWithBalanceCheck(ctx, transfer, policy), entities.ErrInsufficientFunds)
+
+		transfer, errs = entities.NewTransfer("desktop-web", "user999", "user456", 200)
+		assert.Empty(t, errs)
+		assert.NoError(t, repo.InsertWithBalanceCheck(ctx, transfer, policy))
+
+		// the debit in the bulk buffer is not committed yet
+		var balance entities.UserBalance
+		assert.NoError(t, db.Where("user_id = ? AND currency = ?", "user999", credit.Currency).First(&balance).Error)
+		assert.Equal(t, int64(300), balance.Balance)
+	})
+
+	t.Run("finding a transfer that doesn't exist", func(t *testing.T) {
+		found, err := repo.Find(ctx, uuid.New().String())
+		assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+		assert.Nil(t, found)
+	})
+}
@@ -0,0 +1,367 @@
+package spool
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type FsyncPolicy string
+
+const (
+	FSYNC_ALWAYS   FsyncPolicy = "always"   // every record is synced before being acknowledged
+	FSYNC_INTERVAL FsyncPolicy = "interval" // the active segment is synced periodically
+	FSYNC_NEVER    FsyncPolicy = "never"    // the operating system decides when to write
+)
+
+const (
+	segmentExt   = ".seg"
+	corruptExt   = ".corrupt"
+	headerLength = 8 // payload length + checksum
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+type Config struct {
+	Dir           string
+	SegmentSize   int64 // bytes written to a segment before rotating to a new one
+	Fsync         FsyncPolicy
+	FsyncInterval time.Duration
+}
+
+// Position identifies where a record was written, it's used to acknowledge the record once it's processed.
+type Position struct {
+	segment uint64
+}
+
+// Entry is a record not acknowledged before the spool was closed.
+type Entry struct {
+	Position Position
+	Data     []byte
+}
+
+type segment struct {
+	seq         uint64
+	path        string
+	file        *os.File // only the active segment is open
+	size        int64
+	outstanding int  // records not acknowledged yet
+	sealed      bool // no more records are written to it
+	corrupt     bool // kept for inspection instead of being removed
+}
+
+// Spool is an append-only log split in segment files, each record is written with its checksum
+// and a segment is removed once it's sealed and all its records are acknowledged.
+type Spool struct {
+	config   Config
+	mu       sync.Mutex
+	active   *segment
+	segments map[uint64]*segment
+	nextSeq  uint64
+	closed   bool
+	stop     chan struct{}
+	stopped  chan struct{}
+}
+
+// Open loads the segments left in the directory and starts a new active segment,
+// the records that were not acknowledged are returned to be replayed.
+func Open(config Config) (*Spool, []Entry, error) {
+	if config.SegmentSize <= 0 {
+		config.SegmentSize = 64 << 20
+	}
+	if config.Fsync == "" {
+		config.Fsync = FSYNC_ALWAYS
+	}
+	if config.Fsync == FSYNC_INTERVAL && config.FsyncInterval <= 0 {
+		config.FsyncInterval = 100 * time.Millisecond
+	}
+
+	if err := os.MkdirAll(config.Dir, 0o750); err != nil {
+		return nil, nil, err
+	}
+
+	s := &Spool{
+		config:   config,
+		segments: make(map[uint64]*segment),
+		nextSeq:  1,
+		stop:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+
+	entries, err := s.load()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := s.rotate(); err != nil {
+		return nil, nil, err
+	}
+
+	if config.Fsync == FSYNC_INTERVAL {
+		go s.syncPeriodically()
+	} else {
+		close(s.stopped)
+	}
+
+	return s, entries, nil
+}
+
+// Append writes the record to the active segment, with the always policy it's on disk when Append returns.
+func (s *Spool) Append(data []byte) (Position, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return Position{}, errors.New("spool is closed")
+	}
+
+	record := make([]byte, headerLength+len(data))
+	binary.BigEndian.PutUint32(record[0:4], uint32(len(data)))
+	binary.BigEndian.PutUint32(record[4:8], crc32.Checksum(data, crcTable))
+	copy(record[headerLength:], data)
+
+	if s.active.size > 0 && s.active.size+int64(len(record)) > s.config.SegmentSize {
+		if err := s.rotate(); err != nil {
+			return Position{}, err
+		}
+	}
+
+	if _, err := s.active.file.Write(record); err != nil {
+		return Position{}, err
+	}
+	s.active.size += int64(len(record))
+
+	if s.config.Fsync == FSYNC_ALWAYS {
+		if err := s.active.file.Sync(); err != nil {
+			return Position{}, err
+		}
+	}
+
+	s.active.outstanding++
+	return Position{segment: s.active.seq}, nil
+}
+
+// Ack marks the records as processed, the sealed segments without outstanding records are removed.
+func (s *Spool) Ack(positions ...Position) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, position := range positions {
+		seg, ok := s.segments[position.segment]
+		if !ok {
+			continue
+		}
+
+		seg.outstanding--
+		if seg.sealed && seg.outstanding <= 0 {
+			s.remove(seg)
+		}
+	}
+}
+
+// Close syncs and closes the active segment, it's removed when all its records were acknowledged.
+func (s *Spool) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	close(s.stop)
+	s.mu.Unlock()
+
+	<-s.stopped
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.seal(s.active)
+}
+
+func (s *Spool) syncPeriodically() {
+	defer close(s.stopped)
+
+	ticker := time.NewTicker(s.config.FsyncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.mu.Lock()
+			if err := s.active.file.Sync(); err != nil {
+				log.Printf("error syncing the spool segment %s: %s", s.active.path, err)
+			}
+			s.mu.Unlock()
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// rotate seals the active segment and opens the next one.
+func (s *Spool) rotate() error {
+	if s.active != nil {
+		if err := s.seal(s.active); err != nil {
+			return err
+		}
+	}
+
+	seg := &segment{
+		seq:  s.nextSeq,
+		path: filepath.Join(s.config.Dir, fmt.Sprintf("%020d%s", s.nextSeq, segmentExt)),
+	}
+	file, err := os.OpenFile(seg.path, os.O_CREATE|os.O_EXCL|os.O_WRONLY|os.O_APPEND, 0o640)
+	if err != nil {
+		return err
+	}
+	seg.file = file
+
+	// the directory is synced so the new segment survives a crash
+	if err := syncDir(s.config.Dir); err != nil {
+		return err
+	}
+
+	s.nextSeq++
+	s.active = seg
+	s.segments[seg.seq] = seg
+	return nil
+}
+
+func (s *Spool) seal(seg *segment) error {
+	seg.sealed = true
+	if seg.file != nil {
+		if err := seg.file.Sync(); err != nil {
+			return err
+		}
+		if err := seg.file.Close(); err != nil {
+			return err
+		}
+		seg.file = nil
+	}
+
+	if seg.outstanding <= 0 {
+		s.remove(seg)
+	}
+	return nil
+}
+
+func (s *Spool) remove(seg *segment) {
+	delete(s.segments, seg.seq)
+
+	if seg.corrupt {
+		if err := os.Rename(seg.path, strings.TrimSuffix(seg.path, segmentExt)+corruptExt); err != nil {
+			log.Printf("error keeping the corrupted spool segment %s: %s", seg.path, err)
+		}
+		return
+	}
+
+	if err := os.Remove(seg.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Printf("error removing the spool segment %s: %s", seg.path, err)
+	}
+}
+
+// load reads the records of the segments in the directory, in the order they were written.
+func (s *Spool) load() ([]Entry, error) {
+	paths, err := filepath.Glob(filepath.Join(s.config.Dir, "*"+segmentExt))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	var entries []Entry
+	for _, path := range paths {
+		seq, err := strconv.ParseUint(strings.TrimSuffix(filepath.Base(path), segmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+
+		seg := &segment{seq: seq, path: path, sealed: true}
+		data, err := readSegment(path)
+		if err != nil {
+			// the records before the damaged one are still replayed
+			log.Printf("spool segment %s is damaged after %d records: %s", path, len(data), err)
+			seg.corrupt = true
+		}
+
+		for _, record := range data {
+			entries = append(entries, Entry{Position: Position{segment: seq}, Data: record})
+		}
+		seg.outstanding = len(data)
+		s.segments[seq] = seg
+		if seg.outstanding == 0 {
+			s.remove(seg)
+		}
+
+		if seq >= s.nextSeq {
+			s.nextSeq = seq + 1
+		}
+	}
+
+	return entries, nil
+}
+
+// readSegment returns the valid records of the segment, a record partially written at the end
+// of the segment (a crash during the write) is ignored, any other damage is returned as an error.
+func readSegment(path string) ([][]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	reader := bufio.NewReader(file)
+	header := make([]byte, headerLength)
+	remaining := info.Size()
+
+	var records [][]byte
+	for remaining > 0 {
+		if remaining < headerLength {
+			return records, nil
+		}
+		if _, err := io.ReadFull(reader, header); err != nil {
+			return records, err
+		}
+		remaining -= headerLength
+
+		length := int64(binary.BigEndian.Uint32(header[0:4]))
+		if length > remaining {
+			return records, nil
+		}
+
+		data := make([]byte, length)
+		if _, err := io.ReadFull(reader, data); err != nil {
+			return records, err
+		}
+		remaining -= length
+
+		if crc32.Checksum(data, crcTable) != binary.BigEndian.Uint32(header[4:8]) {
+			return records, fmt.Errorf("checksum mismatch in record %d", len(records)+1)
+		}
+		records = append(records, data)
+	}
+	return records, nil
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
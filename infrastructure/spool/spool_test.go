@@ -0,0 +1,128 @@
+package spool_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"user-transactions/infrastructure/spool"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func segments(t *testing.T, dir, pattern string) []string {
+	paths, err := filepath.Glob(filepath.Join(dir, pattern))
+	assert.NoError(t, err)
+	return paths
+}
+
+func Test_Spool(t *testing.T) {
+	t.Run("replaying the records not acknowledged", func(t *testing.T) {
+		dir := t.TempDir()
+
+		s, entries, err := spool.Open(spool.Config{Dir: dir})
+		assert.NoError(t, err)
+		assert.Empty(t, entries)
+
+		first, err := s.Append([]byte("first"))
+		assert.NoError(t, err)
+		_, err = s.Append([]byte("second"))
+		assert.NoError(t, err)
+		s.Ack(first)
+		assert.NoError(t, s.Close())
+
+		s, entries, err = spool.Open(spool.Config{Dir: dir})
+		assert.NoError(t, err)
+		// the acknowledgements are not persisted, the whole segment is replayed
+		assert.Len(t, entries, 2)
+		assert.Equal(t, []byte("first"), entries[0].Data)
+		assert.Equal(t, []byte("second"), entries[1].Data)
+
+		for _, entry := range entries {
+			s.Ack(entry.Position)
+		}
+		assert.NoError(t, s.Close())
+		assert.Empty(t, segments(t, dir, "*.seg"))
+
+		_, entries, err = spool.Open(spool.Config{Dir: dir})
+		assert.NoError(t, err)
+		assert.Empty(t, entries)
+	})
+
+	t.Run("removing the sealed segments once acknowledged", func(t *testing.T) {
+		dir := t.TempDir()
+
+		s, _, err := spool.Open(spool.Config{Dir: dir, SegmentSize: 16, Fsync: spool.FSYNC_NEVER})
+		assert.NoError(t, err)
+		defer s.Close()
+
+		// every record fills a segment
+		first, err := s.Append([]byte("first record"))
+		assert.NoError(t, err)
+		second, err := s.Append([]byte("second record"))
+		assert.NoError(t, err)
+		assert.Len(t, segments(t, dir, "*.seg"), 2)
+
+		s.Ack(first)
+		assert.Len(t, segments(t, dir, "*.seg"), 1)
+
+		// the active segment is kept until it's sealed
+		s.Ack(second)
+		assert.Len(t, segments(t, dir, "*.seg"), 1)
+	})
+
+	t.Run("ignoring a record partially written", func(t *testing.T) {
+		dir := t.TempDir()
+
+		s, _, err := spool.Open(spool.Config{Dir: dir})
+		assert.NoError(t, err)
+		_, err = s.Append([]byte("complete"))
+		assert.NoError(t, err)
+		assert.NoError(t, s.Close())
+
+		paths := segments(t, dir, "*.seg")
+		assert.Len(t, paths, 1)
+		file, err := os.OpenFile(paths[0], os.O_APPEND|os.O_WRONLY, 0)
+		assert.NoError(t, err)
+		// a header announcing 100 bytes followed by only a few of them
+		_, err = file.Write([]byte{0, 0, 0, 100, 1, 2, 3, 4, 'p', 'a', 'r'})
+		assert.NoError(t, err)
+		assert.NoError(t, file.Close())
+
+		s, entries, err := spool.Open(spool.Config{Dir: dir})
+		assert.NoError(t, err)
+		defer s.Close()
+		assert.Len(t, entries, 1)
+		assert.Equal(t, []byte("complete"), entries[0].Data)
+		assert.Empty(t, segments(t, dir, "*.corrupt"))
+	})
+
+	t.Run("keeping a segment with a checksum mismatch", func(t *testing.T) {
+		dir := t.TempDir()
+
+		s, _, err := spool.Open(spool.Config{Dir: dir})
+		assert.NoError(t, err)
+		_, err = s.Append([]byte("valid"))
+		assert.NoError(t, err)
+		_, err = s.Append([]byte("damaged"))
+		assert.NoError(t, err)
+		assert.NoError(t, s.Close())
+
+		paths := segments(t, dir, "*.seg")
+		assert.Len(t, paths, 1)
+		data, err := os.ReadFile(paths[0])
+		assert.NoError(t, err)
+		data[len(data)-1] ^= 0xff
+		assert.NoError(t, os.WriteFile(paths[0], data, 0o640))
+
+		s, entries, err := spool.Open(spool.Config{Dir: dir})
+		assert.NoError(t, err)
+		defer s.Close()
+		// the records before the damaged one are still replayed
+		assert.Len(t, entries, 1)
+		assert.Equal(t, []byte("valid"), entries[0].Data)
+
+		s.Ack(entries[0].Position)
+		assert.Empty(t, segments(t, dir, "00000000000000000001.seg"))
+		assert.Len(t, segments(t, dir, "*.corrupt"), 1)
+	})
+}